@@ -0,0 +1,331 @@
+// Package anomaly flags unusual stretches in the recorded history: cost
+// spikes per namespace, node churn and spot-to-on-demand fallback per
+// NodePool, and growing stranded capacity. Every hour of the window is
+// scored against the same hour of day over the preceding lookback with a
+// robust z-score, so daily cycles and the odd outlier in the baseline
+// don't raise alarms of their own.
+package anomaly
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/clusters"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+)
+
+type Kind string
+
+const (
+	KindCostSpike        Kind = "cost-spike"
+	KindNodeChurn        Kind = "node-churn"
+	KindSpotFallback     Kind = "spot-fallback"
+	KindStrandedCapacity Kind = "stranded-capacity"
+)
+
+// Kinds lists every kind of anomaly.
+var Kinds = []Kind{KindCostSpike, KindNodeChurn, KindSpotFallback, KindStrandedCapacity}
+
+const (
+	DefaultWindow   = 24 * time.Hour
+	DefaultLookback = 14 * 24 * time.Hour
+	DefaultMinScore = 3.5
+
+	// madScale makes the median absolute deviation comparable to a
+	// standard deviation.
+	madScale = 1.4826
+	// minBaseline is the fewest recorded hours a baseline needs.
+	minBaseline = 24
+	// minSameHour is the fewest values at the same hour of day for the
+	// seasonal baseline; with fewer, every baseline hour is used.
+	minSameHour = 5
+	// relativeFloor bounds the spread of a flat baseline from below, as a
+	// share of its median; half the metric's minDelta bounds it too, so a
+	// baseline of all zeros doesn't score without limit.
+	relativeFloor = 0.05
+
+	maxContributors = 5
+)
+
+// metric is how a kind is measured and how large a deviation must be to
+// matter, next to its score.
+type metric struct {
+	unit string
+	// minDelta is the least increase over the baseline worth reporting.
+	minDelta float64
+	// minRatio is the least multiple of the baseline worth reporting.
+	minRatio float64
+}
+
+var metrics = map[Kind]metric{
+	KindCostSpike:        {unit: "$/h", minDelta: 0.25, minRatio: 1.5},
+	KindNodeChurn:        {unit: "nodes/h", minDelta: 3, minRatio: 2},
+	KindSpotFallback:     {unit: "%", minDelta: 20},
+	KindStrandedCapacity: {unit: "$/h", minDelta: 0.25, minRatio: 1.5},
+}
+
+// Anomaly is a run of consecutive anomalous hours of one metric.
+type Anomaly struct {
+	Kind    Kind   `json:"kind"`
+	Cluster string `json:"cluster"`
+	// Subject is the namespace or NodePool; empty for the whole cluster.
+	Subject string    `json:"subject,omitempty"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	// Ongoing is set when the run lasts into the latest recorded hour.
+	Ongoing bool `json:"ongoing"`
+	// Peak is the hour with the highest score, which Value, Baseline and
+	// Score describe.
+	Peak     time.Time `json:"peak"`
+	Value    float64   `json:"value"`
+	Baseline float64   `json:"baseline"`
+	Score    float64   `json:"score"`
+	Unit     string    `json:"unit"`
+	Message  string    `json:"message"`
+	// Contributors are the workloads, or for stranded capacity the nodes,
+	// that account for most of the subject's cost now.
+	Contributors []Contributor `json:"contributors,omitempty"`
+}
+
+type Contributor struct {
+	Kind       string  `json:"kind"`
+	Namespace  string  `json:"namespace,omitempty"`
+	Name       string  `json:"name"`
+	Pods       int     `json:"pods,omitempty"`
+	HourlyCost float64 `json:"hourlyCost"`
+}
+
+type Options struct {
+	// Cluster is a recorded cluster, or history.AllClusters for each of
+	// them in turn.
+	Cluster string
+	// Window is how far back from now hours are scored.
+	Window time.Duration
+	// Lookback is the baseline before the window.
+	Lookback time.Duration
+	// MinScore is the robust z-score an hour needs to be anomalous.
+	MinScore float64
+}
+
+// Detector scores the recorded history and reads the live clusters for
+// the contributors of what it finds.
+type Detector struct {
+	store    *history.Store
+	clusters *clusters.Registry
+	catalog  *pricing.Catalog
+}
+
+func NewDetector(store *history.Store, registry *clusters.Registry, catalog *pricing.Catalog) *Detector {
+	return &Detector{store: store, clusters: registry, catalog: catalog}
+}
+
+// Detect returns the anomalies of the window, highest score first.
+func (d *Detector) Detect(ctx context.Context, opts Options) ([]Anomaly, error) {
+	if opts.Window <= 0 {
+		opts.Window = DefaultWindow
+	}
+	if opts.Lookback <= 0 {
+		opts.Lookback = DefaultLookback
+	}
+	if opts.MinScore <= 0 {
+		opts.MinScore = DefaultMinScore
+	}
+
+	names := []string{opts.Cluster}
+	if opts.Cluster == history.AllClusters {
+		var err error
+		if names, err = d.store.Clusters(); err != nil {
+			return nil, err
+		}
+	}
+
+	anomalies := []Anomaly{}
+	now := time.Now().UTC()
+	for _, cluster := range names {
+		found, err := d.detect(cluster, now, opts)
+		if err != nil {
+			return nil, fmt.Errorf("cluster %s: %w", cluster, err)
+		}
+		if len(found) > 0 {
+			d.attachContributors(ctx, cluster, found)
+		}
+		anomalies = append(anomalies, found...)
+	}
+	sort.SliceStable(anomalies, func(i, j int) bool { return anomalies[i].Score > anomalies[j].Score })
+	return anomalies, nil
+}
+
+// observations are the recorded hours of one metric of one subject.
+type observations struct {
+	times  []time.Time
+	values []float64
+}
+
+func (o *observations) add(t time.Time, v float64) {
+	o.times = append(o.times, t)
+	o.values = append(o.values, v)
+}
+
+type subject struct {
+	kind Kind
+	name string
+}
+
+func (d *Detector) detect(cluster string, now time.Time, opts Options) ([]Anomaly, error) {
+	to := now.Truncate(time.Hour).Add(time.Hour)
+	windowStart := to.Add(-opts.Window.Truncate(time.Hour))
+	series, err := d.store.Query(history.Query{
+		Cluster: cluster,
+		From:    windowStart.Add(-opts.Lookback),
+		To:      to,
+		Step:    time.Hour,
+		GroupBy: history.DimensionNamespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// A namespace or NodePool missing from a recorded hour had nothing
+	// there; an hour missing altogether is a collection gap and skipped.
+	namespaces, nodePools := map[string]bool{}, map[string]bool{}
+	for _, point := range series.Points {
+		for name := range point.Groups {
+			namespaces[name] = true
+		}
+		for name := range point.NodePools {
+			nodePools[name] = true
+		}
+	}
+	all := map[subject]*observations{}
+	observe := func(kind Kind, name string, t time.Time, v float64) {
+		key := subject{kind, name}
+		if all[key] == nil {
+			all[key] = &observations{}
+		}
+		all[key].add(t, v)
+	}
+	for _, point := range series.Points {
+		for name := range namespaces {
+			if name == history.IdleNamespace {
+				observe(KindStrandedCapacity, "", point.Time, point.Groups[name])
+			} else {
+				observe(KindCostSpike, name, point.Time, point.Groups[name])
+			}
+		}
+		for name := range nodePools {
+			stats := point.NodePools[name]
+			observe(KindNodeChurn, name, point.Time, stats.Launches+stats.Terminations)
+			if stats.Nodes > 0 {
+				observe(KindSpotFallback, name, point.Time, (stats.Nodes-stats.SpotNodes)/stats.Nodes*100)
+			}
+		}
+	}
+
+	var anomalies []Anomaly
+	for key, obs := range all {
+		for _, a := range score(obs, windowStart, metrics[key.kind], opts.MinScore) {
+			a.Kind, a.Cluster, a.Subject = key.kind, cluster, key.name
+			a.Unit = metrics[key.kind].unit
+			a.Message = describe(&a)
+			anomalies = append(anomalies, a)
+		}
+	}
+	return anomalies, nil
+}
+
+// score scores every hour of obs from windowStart on against its baseline
+// and returns the runs of consecutive anomalous hours.
+func score(obs *observations, windowStart time.Time, m metric, minScore float64) []Anomaly {
+	var baseline []int
+	for i, t := range obs.times {
+		if t.Before(windowStart) {
+			baseline = append(baseline, i)
+		}
+	}
+	if len(baseline) < minBaseline {
+		return nil
+	}
+
+	var anomalies []Anomaly
+	var run *Anomaly
+	last := len(obs.times) - 1
+	for i := len(baseline); i <= last; i++ {
+		t, v := obs.times[i], obs.values[i]
+		median, spread := baselineOf(obs, baseline, t.Hour())
+		spread = max(spread, m.minDelta/2)
+		z := (v - median) / spread
+		anomalous := z >= minScore && v-median >= m.minDelta && v >= m.minRatio*median
+		// Runs break at an hour that isn't anomalous or isn't recorded.
+		if run != nil && (!anomalous || t.Sub(run.End) > 0) {
+			anomalies = append(anomalies, *run)
+			run = nil
+		}
+		if !anomalous {
+			continue
+		}
+		if run == nil {
+			run = &Anomaly{Start: t, Score: math.Inf(-1)}
+		}
+		run.End = t.Add(time.Hour)
+		run.Ongoing = i == last
+		if z > run.Score {
+			run.Peak, run.Value, run.Baseline, run.Score = t, v, median, z
+		}
+	}
+	if run != nil {
+		anomalies = append(anomalies, *run)
+	}
+	return anomalies
+}
+
+// baselineOf returns the median and spread of the baseline values at hour
+// of day, or of all of them when too few were recorded at that hour.
+func baselineOf(obs *observations, baseline []int, hour int) (median, spread float64) {
+	var values, sameHour []float64
+	for _, i := range baseline {
+		values = append(values, obs.values[i])
+		if obs.times[i].Hour() == hour {
+			sameHour = append(sameHour, obs.values[i])
+		}
+	}
+	if len(sameHour) >= minSameHour {
+		values = sameHour
+	}
+
+	median = medianOf(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - median)
+	}
+	spread = madScale * medianOf(deviations)
+	return median, max(spread, relativeFloor*math.Abs(median))
+}
+
+func medianOf(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+func describe(a *Anomaly) string {
+	at := a.Peak.Format("2006-01-02 15:04 MST")
+	switch a.Kind {
+	case KindCostSpike:
+		return fmt.Sprintf("Namespace %s in cluster %s cost $%.2f/h at %s, against a usual $%.2f/h.", a.Subject, a.Cluster, a.Value, at, a.Baseline)
+	case KindNodeChurn:
+		return fmt.Sprintf("NodePool %s in cluster %s launched and terminated %.0f nodes an hour at %s, against a usual %.0f.", a.Subject, a.Cluster, a.Value, at, a.Baseline)
+	case KindSpotFallback:
+		return fmt.Sprintf("NodePool %s in cluster %s ran %.0f%% of its nodes on-demand at %s, against a usual %.0f%%; spot capacity may be short.", a.Subject, a.Cluster, a.Value, at, a.Baseline)
+	case KindStrandedCapacity:
+		return fmt.Sprintf("Cluster %s had $%.2f/h of capacity no pod requests at %s, against a usual $%.2f/h.", a.Cluster, a.Value, at, a.Baseline)
+	}
+	return ""
+}
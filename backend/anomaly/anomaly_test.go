@@ -0,0 +1,126 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+// hours records five baseline days of the given values, each day at every
+// hour, followed by the window from midnight of the sixth day. NaN leaves
+// a window hour unrecorded.
+func hours(baseline []float64, window ...float64) (*observations, time.Time) {
+	obs := &observations{}
+	for day, v := range baseline {
+		for h := 0; h < 24; h++ {
+			obs.add(start.Add(time.Duration(day*24+h)*time.Hour), v)
+		}
+	}
+	windowStart := start.Add(time.Duration(len(baseline)*24) * time.Hour)
+	for h, v := range window {
+		if !math.IsNaN(v) {
+			obs.add(windowStart.Add(time.Duration(h)*time.Hour), v)
+		}
+	}
+	return obs, windowStart
+}
+
+// The baseline at every hour is 1.0, 1.1, 0.9, 1.0, 1.2: a median of 1.0
+// and a median absolute deviation of 0.1, so a spread of 0.14826. At the
+// default threshold an hour needs 1.0 + 3.5 × 0.14826 = 1.5189.
+func TestScore(t *testing.T) {
+	usual := []float64{1.0, 1.1, 0.9, 1.0, 1.2}
+	cost := metrics[KindCostSpike]
+	gap := math.NaN()
+
+	for _, tc := range []struct {
+		name     string
+		baseline []float64
+		window   []float64
+		minScore float64
+		// want lists runs as "start-end peak value/baseline score", hours
+		// into the window.
+		want []string
+	}{
+		{
+			// 1.5 scores 3.37; 1.6 scores 4.05 and 1.55 scores 3.71.
+			name:     "threshold",
+			baseline: usual,
+			window:   []float64{1.5, 1.6, 1.55, 1.0, 2.0},
+			minScore: DefaultMinScore,
+			want:     []string{"1-3 1 1.60/1.00 4.047", "4-5 4 2.00/1.00 6.745 ongoing"},
+		},
+		{
+			name:     "lower threshold",
+			baseline: usual,
+			window:   []float64{1.5, 1.6, 1.55, 1.0, 2.0},
+			minScore: 3,
+			want:     []string{"0-3 1 1.60/1.00 4.047", "4-5 4 2.00/1.00 6.745 ongoing"},
+		},
+		{
+			name:     "gap breaks a run",
+			baseline: usual,
+			window:   []float64{1.6, gap, 1.6, 1.0},
+			minScore: DefaultMinScore,
+			want:     []string{"0-1 0 1.60/1.00 4.047", "2-3 2 1.60/1.00 4.047"},
+		},
+		{
+			// Half the metric's minimum increase, 0.125, bounds the spread
+			// of an all-zero baseline: 0.3 scores 2.4 and 0.5 scores 4.
+			name:     "zero baseline",
+			baseline: []float64{0, 0, 0, 0, 0},
+			window:   []float64{0.3, 0.5},
+			minScore: DefaultMinScore,
+			want:     []string{"1-2 1 0.50/0.00 4.000 ongoing"},
+		},
+		{
+			// A flat $10/h has a spread of 5% of it, 0.5. 12 scores 4 but
+			// is short of 1.5 times the baseline; 15 is both.
+			name:     "flat baseline",
+			baseline: []float64{10, 10, 10, 10, 10},
+			window:   []float64{10.3, 12, 15},
+			minScore: DefaultMinScore,
+			want:     []string{"2-3 2 15.00/10.00 10.000 ongoing"},
+		},
+		{
+			name:     "baseline too short",
+			baseline: nil,
+			window:   []float64{5},
+			minScore: DefaultMinScore,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			obs, windowStart := hours(tc.baseline, tc.window...)
+			var got []string
+			for _, a := range score(obs, windowStart, cost, tc.minScore) {
+				run := fmt.Sprintf("%d-%d %d %.2f/%.2f %.3f",
+					int(a.Start.Sub(windowStart).Hours()), int(a.End.Sub(windowStart).Hours()), int(a.Peak.Sub(windowStart).Hours()),
+					a.Value, a.Baseline, a.Score)
+				if a.Ongoing {
+					run += " ongoing"
+				}
+				got = append(got, run)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("runs = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// With fewer than five values at the hour, every baseline hour counts.
+func TestBaselineOf(t *testing.T) {
+	obs := &observations{}
+	for h, v := range []float64{1, 2, 3, 4, 100} {
+		obs.add(start.Add(time.Duration(h)*time.Hour), v)
+	}
+	median, spread := baselineOf(obs, []int{0, 1, 2, 3, 4}, 0)
+	// Deviations from 3 are 2, 1, 0, 1, 97.
+	if median != 3 || math.Abs(spread-madScale) > 1e-9 {
+		t.Errorf("median, spread = %v, %v, want 3, %v", median, spread, madScale)
+	}
+}
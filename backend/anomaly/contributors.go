@@ -0,0 +1,133 @@
+package anomaly
+
+import (
+	"context"
+	"sort"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+)
+
+// nodeCosts is a node's price split between its pods, as history records it.
+type nodeCosts struct {
+	node   k8s.NodeDetails
+	pool   string
+	pods   []k8s.PodDetails
+	shares []float64
+	idle   float64
+}
+
+// attachContributors ranks what makes up each anomaly's subject in the
+// cluster now. It leaves the anomalies alone when the cluster can't be
+// read; the history alone still tells what happened.
+func (d *Detector) attachContributors(ctx context.Context, cluster string, anomalies []Anomaly) {
+	source, err := d.clusters.Get(cluster)
+	if err != nil {
+		return
+	}
+	nodeInfo, err := source.GetNodes(ctx)
+	if err != nil {
+		return
+	}
+	podInfo, err := source.GetPods(ctx)
+	if err != nil {
+		return
+	}
+
+	podsByNode := map[string][]k8s.PodDetails{}
+	for _, pod := range podInfo.Pods {
+		if pod.NodeName != "" && pod.Status != "Succeeded" && pod.Status != "Failed" {
+			podsByNode[pod.NodeName] = append(podsByNode[pod.NodeName], pod)
+		}
+	}
+	nodes := make([]nodeCosts, 0, len(nodeInfo.Nodes))
+	for _, node := range nodeInfo.Nodes {
+		price := history.NodePrice(&node, d.catalog)
+		pool := node.NodePool
+		if pool == "" {
+			pool = history.Unmanaged
+		}
+		pods := podsByNode[node.Name]
+		shares, idle := history.PodCosts(node, pods, price)
+		nodes = append(nodes, nodeCosts{node: node, pool: pool, pods: pods, shares: shares, idle: idle})
+	}
+
+	for i := range anomalies {
+		a := &anomalies[i]
+		switch a.Kind {
+		case KindCostSpike:
+			a.Contributors = workloads(nodes, func(n *nodeCosts) bool { return true }, func(pod *k8s.PodDetails) bool {
+				return pod.Namespace == a.Subject
+			})
+		case KindNodeChurn:
+			// Nodes launched during the anomaly show what drove it; without
+			// any left, the pool's current workloads stand in.
+			launched := func(n *nodeCosts) bool { return n.pool == a.Subject && !n.node.CreatedAt.Before(a.Start) }
+			a.Contributors = workloads(nodes, launched, nil)
+			if len(a.Contributors) == 0 {
+				a.Contributors = workloads(nodes, func(n *nodeCosts) bool { return n.pool == a.Subject }, nil)
+			}
+		case KindSpotFallback:
+			a.Contributors = workloads(nodes, func(n *nodeCosts) bool { return n.pool == a.Subject && !n.node.IsSpot }, nil)
+		case KindStrandedCapacity:
+			a.Contributors = idleNodes(nodes)
+		}
+	}
+}
+
+// workloads ranks the workloads of the matching pods on the matching nodes
+// by their hourly cost. A nil pod filter matches every pod.
+func workloads(nodes []nodeCosts, nodeFilter func(*nodeCosts) bool, podFilter func(*k8s.PodDetails) bool) []Contributor {
+	byWorkload := map[[3]string]*Contributor{}
+	for i := range nodes {
+		n := &nodes[i]
+		if !nodeFilter(n) {
+			continue
+		}
+		for j := range n.pods {
+			pod := &n.pods[j]
+			if podFilter != nil && !podFilter(pod) {
+				continue
+			}
+			kind, name := pod.OwnerKind, pod.OwnerName
+			if kind == "" {
+				kind, name = "Pod", pod.Name
+			}
+			key := [3]string{pod.Namespace, kind, name}
+			if byWorkload[key] == nil {
+				byWorkload[key] = &Contributor{Kind: kind, Namespace: pod.Namespace, Name: name}
+			}
+			byWorkload[key].Pods++
+			byWorkload[key].HourlyCost += n.shares[j]
+		}
+	}
+
+	contributors := make([]Contributor, 0, len(byWorkload))
+	for _, c := range byWorkload {
+		contributors = append(contributors, *c)
+	}
+	return top(contributors)
+}
+
+func idleNodes(nodes []nodeCosts) []Contributor {
+	contributors := make([]Contributor, 0, len(nodes))
+	for _, n := range nodes {
+		if n.idle > 0 {
+			contributors = append(contributors, Contributor{Kind: "Node", Name: n.node.Name, Pods: len(n.pods), HourlyCost: n.idle})
+		}
+	}
+	return top(contributors)
+}
+
+func top(contributors []Contributor) []Contributor {
+	sort.Slice(contributors, func(i, j int) bool {
+		if contributors[i].HourlyCost != contributors[j].HourlyCost {
+			return contributors[i].HourlyCost > contributors[j].HourlyCost
+		}
+		return contributors[i].Namespace+"/"+contributors[i].Name < contributors[j].Namespace+"/"+contributors[j].Name
+	})
+	if len(contributors) > maxContributors {
+		contributors = contributors[:maxContributors]
+	}
+	return contributors
+}
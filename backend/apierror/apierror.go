@@ -0,0 +1,132 @@
+// Package apierror is the error model of the API. Every failed request is
+// answered with the same envelope:
+//
+//	{"error": {"code": "cluster_forbidden", "message": "...", "details": {...}, "retryable": false}}
+//
+// The HTTP status says what kind of failure it is, the code says which
+// one, so automation can tell a service account without RBAC
+// permissions (403 cluster_forbidden) from an API server that is down
+// (503 cluster_unreachable) without parsing messages.
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Codes of the errors the API answers with. Errors of a cluster's API
+// server have the cluster_ prefix.
+const (
+	CodeInvalidArgument     = "invalid_argument"
+	CodeUnauthenticated     = "unauthenticated"
+	CodeForbidden           = "forbidden"
+	CodeNotFound            = "not_found"
+	CodeFeatureDisabled     = "feature_disabled"
+	CodeNotEnabled          = "not_enabled"
+	CodeTimeout             = "timeout"
+	CodeCanceled            = "canceled"
+	CodeInternal            = "internal"
+	CodeClusterForbidden    = "cluster_forbidden"
+	CodeClusterUnauthorized = "cluster_unauthorized"
+	CodeClusterNotFound     = "cluster_not_found"
+	CodeClusterRateLimited  = "cluster_rate_limited"
+	CodeClusterUnavailable  = "cluster_unavailable"
+	CodeClusterUnreachable  = "cluster_unreachable"
+	CodeClusterError        = "cluster_error"
+)
+
+// Error is an error the API answers with.
+type Error struct {
+	// Status is the HTTP status of the response.
+	Status  int            `json:"-"`
+	Code    string         `json:"code"`
+	Message string         `json:"message"`
+	Details map[string]any `json:"details,omitempty"`
+	// Retryable says whether the same request may succeed later.
+	Retryable bool `json:"retryable"`
+	// RetryAfter is the seconds to wait before retrying, if known.
+	RetryAfter int `json:"-"`
+}
+
+// Response is the body of a failed request.
+type Response struct {
+	Error *Error `json:"error"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// With adds a detail to the error.
+func (e *Error) With(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = map[string]any{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// New returns an error with the given status and code.
+func New(status int, code, format string, args ...any) *Error {
+	return &Error{Status: status, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// Invalid is a request the caller has to change, such as a bad parameter.
+func Invalid(err error) *Error {
+	return New(http.StatusBadRequest, CodeInvalidArgument, "%s", err)
+}
+
+func Invalidf(format string, args ...any) *Error {
+	return New(http.StatusBadRequest, CodeInvalidArgument, format, args...)
+}
+
+// NotFound is a cluster, snapshot or record the API doesn't know.
+func NotFound(err error) *Error {
+	return New(http.StatusNotFound, CodeNotFound, "%s", err)
+}
+
+func NotFoundf(format string, args ...any) *Error {
+	return New(http.StatusNotFound, CodeNotFound, format, args...)
+}
+
+// Forbidden is a request the caller's role or namespaces don't allow.
+func Forbidden(err error) *Error {
+	return New(http.StatusForbidden, CodeForbidden, "%s", err)
+}
+
+func Forbiddenf(format string, args ...any) *Error {
+	return New(http.StatusForbidden, CodeForbidden, format, args...)
+}
+
+// NotEnabled is a part of the API the server isn't configured for, such
+// as the cost history. Asking again doesn't help until the config changes.
+func NotEnabled(format string, args ...any) *Error {
+	return New(http.StatusServiceUnavailable, CodeNotEnabled, format, args...)
+}
+
+// Internal is a failure of the server itself.
+func Internal(err error) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, "%s", err)
+}
+
+// Abort answers the request with e and stops the handlers after the
+// current one.
+func Abort(c *gin.Context, e *Error) {
+	if e.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(e.RetryAfter))
+	}
+	if e.Status >= http.StatusInternalServerError {
+		// Keep the cause in the request log.
+		c.Error(e)
+	}
+	c.AbortWithStatusJSON(e.Status, Response{Error: e})
+}
+
+// Respond answers the request with err, classified with From; errors of
+// unknown cause are internal.
+func Respond(c *gin.Context, err error) {
+	Abort(c, From(err, http.StatusInternalServerError))
+}
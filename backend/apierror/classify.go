@@ -0,0 +1,123 @@
+package apierror
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// defaultCodes are the codes of errors of unknown cause by the status
+// they are given.
+var defaultCodes = map[int]string{
+	http.StatusBadRequest:          CodeInvalidArgument,
+	http.StatusUnauthorized:        CodeUnauthenticated,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusInternalServerError: CodeInternal,
+}
+
+// From classifies err. An *Error stays as it is, errors a cluster's API
+// server answered with and failures to reach it get the status and code
+// of what went wrong, and anything else gets status, such as 400 for
+// input that turned out to be invalid.
+func From(err error, status int) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if e := fromKubernetes(err); e != nil {
+		return e
+	}
+	switch {
+	case errors.Is(err, context.Canceled):
+		e = New(http.StatusServiceUnavailable, CodeCanceled, "%s", err)
+		e.Retryable = true
+		return e
+	case errors.Is(err, context.DeadlineExceeded):
+		e = New(http.StatusServiceUnavailable, CodeTimeout, "%s", err)
+		e.Retryable = true
+		return e
+	}
+	if e := fromNetwork(err); e != nil {
+		return e
+	}
+	code, ok := defaultCodes[status]
+	if !ok {
+		status, code = http.StatusInternalServerError, CodeInternal
+	}
+	return New(status, code, "%s", err)
+}
+
+// fromKubernetes classifies the Status a Kubernetes API server answered
+// with, or returns nil for other errors.
+func fromKubernetes(err error) *Error {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return nil
+	}
+	var e *Error
+	switch {
+	case apierrors.IsForbidden(err):
+		e = New(http.StatusForbidden, CodeClusterForbidden, "%s", err)
+	case apierrors.IsUnauthorized(err):
+		e = New(http.StatusForbidden, CodeClusterUnauthorized, "%s", err)
+	case apierrors.IsNotFound(err):
+		e = New(http.StatusNotFound, CodeClusterNotFound, "%s", err)
+	case apierrors.IsTooManyRequests(err):
+		e = New(http.StatusTooManyRequests, CodeClusterRateLimited, "%s", err)
+		e.Retryable = true
+	case apierrors.IsServiceUnavailable(err), apierrors.IsTimeout(err),
+		apierrors.IsServerTimeout(err), apierrors.IsInternalError(err):
+		e = New(http.StatusServiceUnavailable, CodeClusterUnavailable, "%s", err)
+		e.Retryable = true
+	default:
+		e = New(http.StatusBadGateway, CodeClusterError, "%s", err)
+		e.Retryable = apierrors.IsConflict(err)
+	}
+	if seconds, ok := apierrors.SuggestsClientDelay(err); ok && e.Retryable {
+		e.RetryAfter = seconds
+	}
+
+	s := status.Status()
+	e.With("kubernetesStatus", s.Code)
+	if s.Reason != "" {
+		e.With("reason", string(s.Reason))
+	}
+	if s.Details != nil {
+		if s.Details.Kind != "" {
+			e.With("kind", s.Details.Kind)
+		}
+		if s.Details.Name != "" {
+			e.With("name", s.Details.Name)
+		}
+	}
+	return e
+}
+
+// fromNetwork classifies a failure to reach an API server, or returns nil
+// for other errors. A certificate the server can't be trusted with is a
+// misconfiguration and isn't worth retrying.
+func fromNetwork(err error) *Error {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &verifyErr) || errors.As(err, &authorityErr) || errors.As(err, &hostnameErr) {
+		return New(http.StatusServiceUnavailable, CodeClusterUnreachable, "%s", err)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) {
+		return nil
+	}
+	code := CodeClusterUnreachable
+	if netErr.Timeout() {
+		code = CodeTimeout
+	}
+	e := New(http.StatusServiceUnavailable, code, "%s", err)
+	e.Retryable = true
+	return e
+}
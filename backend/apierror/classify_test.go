@@ -0,0 +1,63 @@
+package apierror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestFrom(t *testing.T) {
+	pods := schema.GroupResource{Resource: "pods"}
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+
+	for _, tc := range []struct {
+		name       string
+		err        error
+		status     int
+		wantStatus int
+		wantCode   string
+		retryable  bool
+		retryAfter int
+	}{
+		{"forbidden", apierrors.NewForbidden(pods, "", errors.New("no list")), 0, http.StatusForbidden, CodeClusterForbidden, false, 0},
+		{"not found", apierrors.NewNotFound(pods, "web"), 0, http.StatusNotFound, CodeClusterNotFound, false, 0},
+		{"too many requests", apierrors.NewTooManyRequests("slow down", 5), 0, http.StatusTooManyRequests, CodeClusterRateLimited, true, 5},
+		{"service unavailable", apierrors.NewServiceUnavailable("down"), 0, http.StatusServiceUnavailable, CodeClusterUnavailable, true, 0},
+		// The cluster rejecting our credentials is not the caller's fault,
+		// so it isn't a 401 either.
+		{"unauthorized", apierrors.NewUnauthorized("expired token"), 0, http.StatusForbidden, CodeClusterUnauthorized, false, 0},
+		{"conflict", apierrors.NewConflict(pods, "web", errors.New("changed")), 0, http.StatusBadGateway, CodeClusterError, true, 0},
+		{"wrapped", fmt.Errorf("list pods: %w", apierrors.NewNotFound(pods, "web")), 0, http.StatusNotFound, CodeClusterNotFound, false, 0},
+		{"connection refused", refused, 0, http.StatusServiceUnavailable, CodeClusterUnreachable, true, 0},
+		{"dial timeout", &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}, 0, http.StatusServiceUnavailable, CodeTimeout, true, 0},
+		{"deadline", fmt.Errorf("list pods: %w", context.DeadlineExceeded), 0, http.StatusServiceUnavailable, CodeTimeout, true, 0},
+		{"canceled", context.Canceled, 0, http.StatusServiceUnavailable, CodeCanceled, true, 0},
+		{"api error", NotFoundf("no cluster"), http.StatusBadRequest, http.StatusNotFound, CodeNotFound, false, 0},
+		{"invalid input", errors.New("bad json"), http.StatusBadRequest, http.StatusBadRequest, CodeInvalidArgument, false, 0},
+		{"unknown", errors.New("boom"), http.StatusTeapot, http.StatusInternalServerError, CodeInternal, false, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			e := From(tc.err, tc.status)
+			if e.Status != tc.wantStatus || e.Code != tc.wantCode || e.Retryable != tc.retryable || e.RetryAfter != tc.retryAfter {
+				t.Errorf("From = %d %s retryable=%v after=%d, want %d %s retryable=%v after=%d",
+					e.Status, e.Code, e.Retryable, e.RetryAfter, tc.wantStatus, tc.wantCode, tc.retryable, tc.retryAfter)
+			}
+		})
+	}
+}
+
+// The Status the API server answered with is kept for the caller.
+func TestFromKubernetesDetails(t *testing.T) {
+	e := From(apierrors.NewNotFound(schema.GroupResource{Resource: "pods"}, "web"), 0)
+	if e.Details["kubernetesStatus"] != int32(http.StatusNotFound) || e.Details["reason"] != "NotFound" || e.Details["kind"] != "pods" || e.Details["name"] != "web" {
+		t.Errorf("details = %v", e.Details)
+	}
+}
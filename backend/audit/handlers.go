@@ -0,0 +1,138 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/gin-gonic/gin"
+)
+
+const FormatJSONL = "jsonl"
+
+// HandleList returns the records matching ?from=, ?to=, ?actor=, ?action=,
+// ?cluster= and ?result=, oldest first, ?limit= at a time; ?after=<id>
+// continues from the last one. ?format=jsonl downloads them as JSON lines.
+func (r *Recorder) HandleList(c *gin.Context) {
+	if r == nil {
+		apierror.Abort(c, apierror.NotEnabled("the audit log is not enabled"))
+		return
+	}
+
+	filter, err := parseFilter(c, time.Now().UTC())
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+
+	switch c.Query("format") {
+	case FormatJSONL:
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "karpops-audit.jsonl"))
+		c.Status(http.StatusOK)
+		encoder := json.NewEncoder(c.Writer)
+		if err := r.store.Query(filter, func(record *Record) error { return encoder.Encode(record) }); err != nil {
+			// The status is already sent; the export just ends early.
+			c.Error(err)
+		}
+	case "", "json":
+		records := []*Record{}
+		err := r.store.Query(filter, func(record *Record) error {
+			records = append(records, record)
+			return nil
+		})
+		if err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, records)
+	default:
+		apierror.Abort(c, apierror.Invalidf("unsupported format %q", c.Query("format")))
+	}
+}
+
+// HandleGetManifest returns a recorded manifest by its SHA-256.
+func (r *Recorder) HandleGetManifest(c *gin.Context) {
+	if r == nil {
+		apierror.Abort(c, apierror.NotEnabled("the audit log is not enabled"))
+		return
+	}
+	content, ok, err := r.store.Manifest(c.Param("sha256"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	if !ok {
+		apierror.Abort(c, apierror.NotFoundf("no manifest %s recorded", c.Param("sha256")))
+		return
+	}
+	c.Data(http.StatusOK, "application/yaml", content)
+}
+
+// HandleVerify checks that no record was modified or removed from the
+// middle of the trail.
+func (r *Recorder) HandleVerify(c *gin.Context) {
+	if r == nil {
+		apierror.Abort(c, apierror.NotEnabled("the audit log is not enabled"))
+		return
+	}
+	result, err := r.store.Verify()
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func parseFilter(c *gin.Context, now time.Time) (Filter, error) {
+	filter := Filter{
+		Actor:   c.Query("actor"),
+		Action:  c.Query("action"),
+		Cluster: c.Query("cluster"),
+		Result:  c.Query("result"),
+	}
+	var err error
+	if v := c.Query("from"); v != "" {
+		if filter.From, err = parseTime(v, now); err != nil {
+			return filter, err
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if filter.To, err = parseTime(v, now); err != nil {
+			return filter, err
+		}
+	}
+	if v := c.Query("after"); v != "" {
+		if filter.AfterID, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return filter, fmt.Errorf("invalid after %q: must be a record ID", v)
+		}
+	}
+	if c.Query("format") == FormatJSONL {
+		filter.Limit = MaxLimit
+	}
+	if v := c.Query("limit"); v != "" {
+		if filter.Limit, err = strconv.Atoi(v); err != nil || filter.Limit <= 0 || filter.Limit > MaxLimit {
+			return filter, fmt.Errorf("invalid limit %q: must be between 1 and %d", v, MaxLimit)
+		}
+	}
+	return filter, nil
+}
+
+// parseTime accepts RFC 3339 or a duration before now such as "7d".
+func parseTime(v string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	d, err := history.ParseDuration(v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339 or a duration such as 7d", v)
+	}
+	if d > 0 {
+		d = -d
+	}
+	return now.Add(d), nil
+}
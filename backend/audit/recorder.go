@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/auth"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/logging"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// maxRequestBody is the largest JSON body kept in a record; larger
+	// ones are only described.
+	maxRequestBody = 1 << 20
+	// maxResponseBody is how much of a response is kept to find its
+	// error message.
+	maxResponseBody = 64 << 10
+
+	manifestsKey = "audit.manifests"
+)
+
+// Recorder audits the routes it is put in front of. A nil Recorder audits
+// nothing, so routes can be wired the same way whether auditing is on or
+// not.
+type Recorder struct {
+	store *Store
+	// defaultCluster is the cluster of requests without ?cluster=.
+	defaultCluster string
+}
+
+func NewRecorder(store *Store, defaultCluster string) *Recorder {
+	return &Recorder{store: store, defaultCluster: defaultCluster}
+}
+
+// Attach adds a manifest that the current call received or generated to
+// its audit record. It does nothing on routes that aren't audited.
+func Attach(c *gin.Context, name string, content []byte) {
+	if v, ok := c.Get(manifestsKey); ok {
+		manifests := v.(*[]NamedContent)
+		*manifests = append(*manifests, NamedContent{Name: name, Content: content})
+	}
+}
+
+// Middleware records every call of the route as action once the handler
+// has answered it.
+func (r *Recorder) Middleware(action string) gin.HandlerFunc {
+	if r == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		record := &Record{
+			Time:      time.Now().UTC(),
+			Actor:     auth.FromContext(c.Request.Context()),
+			Action:    action,
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Query:     c.Request.URL.RawQuery,
+			Cluster:   c.Query("cluster"),
+			Snapshot:  c.Query("snapshot"),
+			RequestID: logging.RequestID(c.Request.Context()),
+		}
+		if record.Actor == nil {
+			record.Actor = auth.Anonymous
+		}
+		if record.Cluster == "" && record.Snapshot == "" {
+			record.Cluster = r.defaultCluster
+		}
+		r.readRequest(c, record)
+
+		writer := &capturingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		manifests := &[]NamedContent{}
+		c.Set(manifestsKey, manifests)
+
+		c.Next()
+
+		record.Status = writer.Status()
+		record.Result = ResultSuccess
+		if record.Status >= http.StatusBadRequest {
+			record.Result = ResultFailure
+			var body apierror.Response
+			if json.Unmarshal(writer.body.Bytes(), &body) == nil && body.Error != nil {
+				record.Error = body.Error.Message
+			}
+		}
+		if err := r.store.Append(record, *manifests); err != nil {
+			slog.ErrorContext(c.Request.Context(), "audit: failed to record", "action", action, "user", record.Actor.Name, "error", err)
+		}
+	}
+}
+
+// readRequest keeps a JSON body in record, and leaves the body for the
+// handler to read as if nothing had.
+func (r *Recorder) readRequest(c *gin.Context, record *Record) {
+	if c.Request.Body == nil || c.Request.Body == http.NoBody {
+		return
+	}
+	head, err := io.ReadAll(io.LimitReader(c.Request.Body, maxRequestBody+1))
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+	if err != nil || len(head) == 0 {
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if len(head) <= maxRequestBody && json.Valid(head) {
+		record.Request = json.RawMessage(head)
+		return
+	}
+	record.RequestType = contentType
+	if record.RequestType == "" {
+		record.RequestType = "application/octet-stream"
+	}
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// capturingWriter keeps the start of the response next to writing it.
+type capturingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *capturingWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *capturingWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *capturingWriter) capture(data []byte) {
+	if room := maxResponseBody - w.body.Len(); room > 0 {
+		w.body.Write(data[:min(len(data), room)])
+	}
+}
@@ -0,0 +1,281 @@
+// Package audit keeps an append-only trail of the calls that produce or
+// check Karpenter configuration: who made them, what they sent, what came
+// out and against which cluster. Records are chained by hash, so editing
+// or deleting one breaks the chain, and manifests are stored once by
+// their SHA-256.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/auth"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/health"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+
+	DefaultLimit = 100
+	MaxLimit     = 10000
+)
+
+var (
+	bucketRecords   = []byte("records")
+	bucketManifests = []byte("manifests")
+)
+
+// Record is one audited call.
+type Record struct {
+	ID     uint64         `json:"id"`
+	Time   time.Time      `json:"time"`
+	Actor  *auth.Identity `json:"actor"`
+	Action string         `json:"action"`
+	Method string         `json:"method"`
+	Path   string         `json:"path"`
+	// Query is the raw query string, which selects the cluster or snapshot
+	// and the output format.
+	Query    string `json:"query,omitempty"`
+	Cluster  string `json:"cluster,omitempty"`
+	Snapshot string `json:"snapshot,omitempty"`
+	// Request is the JSON request body; other bodies are only described
+	// by RequestType, with their manifests attached.
+	Request     json.RawMessage `json:"request,omitempty"`
+	RequestType string          `json:"requestType,omitempty"`
+	Status      int             `json:"status"`
+	Result      string          `json:"result"`
+	Error       string          `json:"error,omitempty"`
+	Manifests   []Manifest      `json:"manifests,omitempty"`
+	// RequestID ties the record to the server's logs of the request.
+	RequestID string `json:"requestID,omitempty"`
+	// PreviousHash is the Hash of the record before this one, and Hash the
+	// SHA-256 of this record with Hash left empty.
+	PreviousHash string `json:"previousHash"`
+	Hash         string `json:"hash"`
+}
+
+// Manifest is a manifest a call received or generated. Its content is
+// kept in the store under SHA256.
+type Manifest struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// Store is an append-only bbolt file of records, keyed by ID.
+type Store struct {
+	db *bolt.DB
+}
+
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketRecords, bucketManifests} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize audit database: %w", err)
+	}
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Check reads the database and makes sure its file is still there.
+func (s *Store) Check(context.Context) error {
+	return health.Bolt(s.db, "audit")
+}
+
+// Append stores the manifests, by content, and record after the last one,
+// filling in its ID, Manifests and hashes.
+func (s *Store) Append(record *Record, manifests []NamedContent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		contents := tx.Bucket(bucketManifests)
+		for _, m := range manifests {
+			sum := sha256.Sum256(m.Content)
+			key := hex.EncodeToString(sum[:])
+			if contents.Get([]byte(key)) == nil {
+				if err := contents.Put([]byte(key), m.Content); err != nil {
+					return err
+				}
+			}
+			record.Manifests = append(record.Manifests, Manifest{Name: m.Name, SHA256: key, Size: len(m.Content)})
+		}
+
+		records := tx.Bucket(bucketRecords)
+		record.PreviousHash = ""
+		if _, last := records.Cursor().Last(); last != nil {
+			var previous Record
+			if err := json.Unmarshal(last, &previous); err != nil {
+				return fmt.Errorf("failed to read the last audit record: %w", err)
+			}
+			record.PreviousHash = previous.Hash
+		}
+		id, err := records.NextSequence()
+		if err != nil {
+			return err
+		}
+		record.ID = id
+		if record.Hash, err = record.hash(); err != nil {
+			return err
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		return records.Put(idKey(id), data)
+	})
+}
+
+// NamedContent is a manifest to attach to a record.
+type NamedContent struct {
+	Name    string
+	Content []byte
+}
+
+func (r *Record) hash() (string, error) {
+	unhashed := *r
+	unhashed.Hash = ""
+	data, err := json.Marshal(&unhashed)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Filter selects records. Zero fields match everything.
+type Filter struct {
+	From    time.Time
+	To      time.Time
+	Actor   string
+	Action  string
+	Cluster string
+	Result  string
+	// AfterID pages through the trail: only records with a higher ID
+	// match.
+	AfterID uint64
+	// Limit caps the number of records; zero means DefaultLimit.
+	Limit int
+}
+
+func (f *Filter) matches(r *Record) bool {
+	switch {
+	case !f.From.IsZero() && r.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && !r.Time.Before(f.To):
+		return false
+	case f.Actor != "" && (r.Actor == nil || r.Actor.Name != f.Actor):
+		return false
+	case f.Action != "" && r.Action != f.Action:
+		return false
+	case f.Cluster != "" && r.Cluster != f.Cluster:
+		return false
+	case f.Result != "" && r.Result != f.Result:
+		return false
+	}
+	return true
+}
+
+// Query calls fn with each matching record, oldest first, until Limit
+// records matched or fn fails.
+func (s *Store) Query(filter Filter, fn func(*Record) error) error {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	return s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketRecords).Cursor()
+		matched := 0
+		for k, v := c.Seek(idKey(filter.AfterID + 1)); k != nil && matched < limit; k, v = c.Next() {
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to read audit record %d: %w", binary.BigEndian.Uint64(k), err)
+			}
+			if !filter.matches(&record) {
+				continue
+			}
+			matched++
+			if err := fn(&record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Manifest returns the content stored under sha256, or false when there
+// is none.
+func (s *Store) Manifest(sha256 string) ([]byte, bool, error) {
+	var content []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(bucketManifests).Get([]byte(sha256)); v != nil {
+			content = append([]byte(nil), v...)
+		}
+		return nil
+	})
+	return content, content != nil, err
+}
+
+// Verification is the outcome of checking the hash chain.
+type Verification struct {
+	Records int  `json:"records"`
+	Valid   bool `json:"valid"`
+	// BrokenAt is the first record whose hash or link doesn't match.
+	BrokenAt uint64 `json:"brokenAt,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// Verify recomputes every record's hash and checks that each one links to
+// the record before it.
+func (s *Store) Verify() (*Verification, error) {
+	result := &Verification{Valid: true}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		previous := ""
+		return tx.Bucket(bucketRecords).ForEach(func(k, v []byte) error {
+			result.Records++
+			if !result.Valid {
+				return nil
+			}
+			id := binary.BigEndian.Uint64(k)
+			var record Record
+			if err := json.Unmarshal(v, &record); err != nil {
+				result.Valid, result.BrokenAt, result.Reason = false, id, "record is not valid JSON"
+				return nil
+			}
+			hash, err := record.hash()
+			switch {
+			case err != nil || hash != record.Hash:
+				result.Valid, result.BrokenAt, result.Reason = false, id, "record was modified"
+			case record.PreviousHash != previous:
+				result.Valid, result.BrokenAt, result.Reason = false, id, "record before it was modified or removed"
+			}
+			previous = record.Hash
+			return nil
+		})
+	})
+	return result, err
+}
+
+func idKey(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
@@ -0,0 +1,118 @@
+// Package auth authenticates API callers by the bearer token they send:
+// static API tokens, OIDC ID tokens validated against the issuer's JWKS,
+// and, for callers inside the cluster, Kubernetes service account tokens
+// checked with a TokenReview. The caller's identity travels in the request
+// context.
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	MethodToken       = "token"
+	MethodOIDC        = "oidc"
+	MethodTokenReview = "tokenreview"
+	MethodAnonymous   = "anonymous"
+)
+
+// Identity is an authenticated caller.
+type Identity struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+	// Method is how the caller was authenticated.
+	Method string `json:"method"`
+}
+
+// Anonymous stands in for every caller when authentication is disabled.
+var Anonymous = &Identity{Name: "system:anonymous", Groups: []string{"system:unauthenticated"}, Method: MethodAnonymous}
+
+// Authenticator checks a bearer token. It returns nil and no error for a
+// token it doesn't recognise, so that the next one can try it, and an
+// error when it couldn't tell.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Identity, error)
+}
+
+// Chain tries each authenticator in turn and accepts the first identity.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	var firstErr error
+	for _, a := range c {
+		identity, err := a.Authenticate(ctx, token)
+		if identity != nil {
+			return identity, nil
+		}
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a copy of ctx that carries identity.
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, identity)
+}
+
+// FromContext returns the identity in ctx, or nil when there is none.
+func FromContext(ctx context.Context) *Identity {
+	identity, _ := ctx.Value(contextKey{}).(*Identity)
+	return identity
+}
+
+// Middleware rejects requests without a valid bearer token and puts the
+// caller's identity in the request context of the rest. A nil
+// authenticator lets every request through as Anonymous.
+func Middleware(authenticator Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identity := Anonymous
+		if authenticator != nil {
+			token, ok := bearerToken(c.Request)
+			if !ok {
+				unauthorized(c, "missing bearer token")
+				return
+			}
+			var err error
+			identity, err = authenticator.Authenticate(c.Request.Context(), token)
+			if err != nil {
+				slog.WarnContext(c.Request.Context(), "auth: failed to authenticate", "method", c.Request.Method, "path", c.Request.URL.Path, "error", err)
+			}
+			if identity == nil {
+				unauthorized(c, "invalid bearer token")
+				return
+			}
+		}
+		c.Request = c.Request.WithContext(WithIdentity(c.Request.Context(), identity))
+		c.Next()
+	}
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func unauthorized(c *gin.Context, reason string) {
+	c.Header("WWW-Authenticate", `Bearer realm="karpops-wiz"`)
+	apierror.Abort(c, apierror.New(http.StatusUnauthorized, apierror.CodeUnauthenticated, "%s", reason))
+}
+
+// HandleWhoAmI returns the caller's identity, so the UI can show who is
+// signed in.
+func HandleWhoAmI(c *gin.Context) {
+	c.JSON(http.StatusOK, FromContext(c.Request.Context()))
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the authentication file. Every method it sets up is tried in
+// turn: static tokens, then OIDC, then TokenReview. String values may
+// reference environment variables as ${NAME}, which keeps tokens in
+// Secrets.
+type Config struct {
+	Tokens      []StaticToken      `json:"tokens,omitempty"`
+	OIDC        *OIDCConfig        `json:"oidc,omitempty"`
+	TokenReview *TokenReviewConfig `json:"tokenReview,omitempty"`
+}
+
+// StaticToken is an API token for scripts and CI. Give either the token
+// or its hex SHA-256, which keeps the token itself out of the file.
+type StaticToken struct {
+	Name        string   `json:"name"`
+	Token       string   `json:"token,omitempty"`
+	TokenSHA256 string   `json:"tokenSHA256,omitempty"`
+	Groups      []string `json:"groups,omitempty"`
+}
+
+// OIDCConfig accepts ID tokens of one issuer, for people signed in to the
+// UI through the company identity provider.
+type OIDCConfig struct {
+	Issuer string `json:"issuer"`
+	// ClientID is the audience the tokens must be issued for.
+	ClientID string `json:"clientID"`
+	// JWKSURL overrides the key set named by the issuer's discovery
+	// document.
+	JWKSURL string `json:"jwksURL,omitempty"`
+	// UsernameClaim names the caller; the default is sub.
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// GroupsClaim lists the caller's groups; the default is groups.
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// UsernamePrefix and GroupsPrefix keep OIDC names apart from
+	// Kubernetes ones, as the API server's flags of the same name do.
+	UsernamePrefix string `json:"usernamePrefix,omitempty"`
+	GroupsPrefix   string `json:"groupsPrefix,omitempty"`
+}
+
+// TokenReviewConfig accepts the tokens the Kubernetes API server accepts,
+// for service accounts and other callers inside the cluster.
+type TokenReviewConfig struct {
+	Enabled bool `json:"enabled"`
+	// Audiences the token must be valid for; empty means the API
+	// server's own.
+	Audiences []string `json:"audiences,omitempty"`
+}
+
+// Load reads and validates an authentication file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth config: %w", err)
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(data))), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse auth config %s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid auth config %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	names := map[string]bool{}
+	for i, t := range c.Tokens {
+		if t.Name == "" {
+			return fmt.Errorf("token %d: name is required", i+1)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("duplicate token %q", t.Name)
+		}
+		names[t.Name] = true
+		if (t.Token == "") == (t.TokenSHA256 == "") {
+			return fmt.Errorf("token %s: set one of token and tokenSHA256", t.Name)
+		}
+		if t.TokenSHA256 != "" && len(t.TokenSHA256) != 64 {
+			return fmt.Errorf("token %s: tokenSHA256 must be 64 hex digits", t.Name)
+		}
+	}
+
+	if o := c.OIDC; o != nil {
+		if o.Issuer == "" || o.ClientID == "" {
+			return fmt.Errorf("oidc: issuer and clientID are required")
+		}
+		if u, err := url.Parse(o.Issuer); err != nil || u.Scheme != "https" {
+			return fmt.Errorf("oidc: issuer must be an https URL")
+		}
+		o.Issuer = strings.TrimSuffix(o.Issuer, "/")
+		if o.UsernameClaim == "" {
+			o.UsernameClaim = "sub"
+		}
+		if o.GroupsClaim == "" {
+			o.GroupsClaim = "groups"
+		}
+	}
+
+	if len(c.Tokens) == 0 && c.OIDC == nil && (c.TokenReview == nil || !c.TokenReview.Enabled) {
+		return fmt.Errorf("no authentication method is configured")
+	}
+	return nil
+}
+
+// New builds the authenticators of config. reviewer answers TokenReviews
+// and may be nil when the config doesn't enable them.
+func New(config *Config, reviewer TokenReviewer) (Authenticator, error) {
+	var chain Chain
+	if len(config.Tokens) > 0 {
+		tokens, err := newStaticTokens(config.Tokens)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token: %w", err)
+		}
+		chain = append(chain, tokens)
+	}
+	if config.OIDC != nil {
+		chain = append(chain, newOIDC(config.OIDC))
+	}
+	if config.TokenReview != nil && config.TokenReview.Enabled {
+		if reviewer == nil {
+			return nil, fmt.Errorf("tokenReview needs a live cluster to review tokens")
+		}
+		chain = append(chain, newTokenReview(reviewer, config.TokenReview.Audiences))
+	}
+	return chain, nil
+}
@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// clockSkew is how far the clocks of the issuer and this server may
+	// drift apart.
+	clockSkew = time.Minute
+	// jwksRefresh is how often the key set is fetched again, and
+	// jwksMinRefresh how soon an unknown key may trigger another fetch.
+	jwksRefresh    = time.Hour
+	jwksMinRefresh = time.Minute
+)
+
+// oidc accepts ID tokens signed by the configured issuer. Tokens of other
+// issuers are left to the next authenticator.
+type oidc struct {
+	config *OIDCConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	jwksURL string
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+func newOIDC(config *OIDCConfig) *oidc {
+	return &oidc{config: config, client: &http.Client{Timeout: 10 * time.Second}, jwksURL: config.JWKSURL}
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func (o *oidc) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil
+	}
+	var header jwtHeader
+	var claims map[string]any
+	if decodeSegment(parts[0], &header) != nil || decodeSegment(parts[1], &claims) != nil {
+		return nil, nil
+	}
+	if iss, _ := claims["iss"].(string); strings.TrimSuffix(iss, "/") != o.config.Issuer {
+		return nil, nil
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("oidc: malformed signature")
+	}
+	key, err := o.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+	if err := o.checkClaims(claims, time.Now()); err != nil {
+		return nil, fmt.Errorf("oidc: %w", err)
+	}
+
+	name, _ := claims[o.config.UsernameClaim].(string)
+	if name == "" {
+		return nil, fmt.Errorf("oidc: token has no %s claim", o.config.UsernameClaim)
+	}
+	identity := &Identity{Name: o.config.UsernamePrefix + name, Method: MethodOIDC}
+	switch groups := claims[o.config.GroupsClaim].(type) {
+	case string:
+		identity.Groups = []string{o.config.GroupsPrefix + groups}
+	case []any:
+		for _, g := range groups {
+			if g, ok := g.(string); ok {
+				identity.Groups = append(identity.Groups, o.config.GroupsPrefix+g)
+			}
+		}
+	}
+	return identity, nil
+}
+
+func (o *oidc) checkClaims(claims map[string]any, now time.Time) error {
+	audience := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		audience = aud == o.config.ClientID
+	case []any:
+		for _, a := range aud {
+			audience = audience || a == o.config.ClientID
+		}
+	}
+	if !audience {
+		return fmt.Errorf("token is not for client %s", o.config.ClientID)
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return fmt.Errorf("token has no expiry")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(clockSkew)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(clockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return fmt.Errorf("token is not valid yet")
+	}
+	// As with the Kubernetes API server, an unverified email doesn't name
+	// anyone, and neither does a claim that isn't the boolean true, such
+	// as the string "true".
+	if o.config.UsernameClaim == "email" {
+		if verified, ok := claims["email_verified"]; ok && verified != true {
+			return fmt.Errorf("email is not verified")
+		}
+	}
+	return nil
+}
+
+// key returns the issuer's key kid, fetching the key set when it is stale
+// or doesn't have it.
+func (o *oidc) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	key, ok := o.keys[kid]
+	stale := time.Since(o.fetched) > jwksRefresh
+	if ok && !stale {
+		return key, nil
+	}
+	if !stale && time.Since(o.fetched) < jwksMinRefresh {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	if err := o.fetchKeys(ctx); err != nil {
+		// A stale key set still beats none while the issuer is down.
+		if ok {
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, ok = o.keys[kid]; !ok {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+func (o *oidc) fetchKeys(ctx context.Context) error {
+	if o.jwksURL == "" {
+		var discovery struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := o.getJSON(ctx, o.config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return fmt.Errorf("oidc: discovery failed: %w", err)
+		}
+		if strings.TrimSuffix(discovery.Issuer, "/") != o.config.Issuer {
+			return fmt.Errorf("oidc: discovery names issuer %q, not %q", discovery.Issuer, o.config.Issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return fmt.Errorf("oidc: discovery has no jwks_uri")
+		}
+		o.jwksURL = discovery.JWKSURI
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := o.getJSON(ctx, o.jwksURL, &set); err != nil {
+		return fmt.Errorf("oidc: failed to fetch keys: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped rather than failing the
+		// whole set.
+		if key, err := k.publicKey(); err == nil {
+			keys[k.Kid] = key
+		}
+	}
+	o.keys, o.fetched = keys, time.Now()
+	return nil
+}
+
+func (o *oidc) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// jwk is one key of a JSON Web Key Set.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+var errBadSignature = errors.New("invalid signature")
+
+// ecdsaCurves are the curves the ECDSA algorithms sign with (RFC 7518).
+var ecdsaCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		// Notably "none" and the HMAC algorithms, which a public key set
+		// can't vouch for.
+		return fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[0] {
+		case 'R':
+			if rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+				return errBadSignature
+			}
+			return nil
+		case 'P':
+			if rsa.VerifyPSS(key, hash, digest, signature, nil) != nil {
+				return errBadSignature
+			}
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if ecdsaCurves[alg] != key.Curve || len(signature) != 2*size {
+			break
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(key, digest, r, s) {
+			return errBadSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("key does not match signing algorithm %q", alg)
+}
+
+func decodeSegment(segment string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
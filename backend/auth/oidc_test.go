@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testIssuer is an OIDC issuer serving discovery and a key set of an RSA
+// key and P-256 and P-384 keys.
+type testIssuer struct {
+	server *httptest.Server
+	rsa    *rsa.PrivateKey
+	p256   *ecdsa.PrivateKey
+	p384   *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	issuer := &testIssuer{}
+	var err error
+	if issuer.rsa, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		t.Fatal(err)
+	}
+	if issuer.p256, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if issuer.p384, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	ecKey := func(kid, crv string, key *ecdsa.PrivateKey) map[string]string {
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return map[string]string{"kty": "EC", "kid": kid, "use": "sig", "crv": crv,
+			"x": b64(key.X.FillBytes(make([]byte, size))), "y": b64(key.Y.FillBytes(make([]byte, size)))}
+	}
+	keys := []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": b64(issuer.rsa.N.Bytes()), "e": b64(big.NewInt(int64(issuer.rsa.E)).Bytes())},
+		ecKey("p256", "P-256", issuer.p256),
+		ecKey("p384", "P-384", issuer.p384),
+		// Encryption keys and unsupported key types are skipped.
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(issuer.rsa.N.Bytes()), "e": "AQAB"},
+		{"kty": "OKP", "kid": "ed25519", "crv": "Ed25519", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.server.URL, "jwks_uri": issuer.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	issuer.server = httptest.NewTLSServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) authenticator(usernameClaim string) *oidc {
+	o := newOIDC(&OIDCConfig{
+		Issuer:         i.server.URL,
+		ClientID:       "karpops",
+		UsernameClaim:  usernameClaim,
+		GroupsClaim:    "groups",
+		UsernamePrefix: "oidc:",
+		GroupsPrefix:   "oidc:",
+	})
+	o.client = i.server.Client()
+	return o
+}
+
+// claims are those of a valid token for alice, with changes applied.
+func (i *testIssuer) claims(changes map[string]any) map[string]any {
+	now := time.Now()
+	claims := map[string]any{
+		"iss":    i.server.URL,
+		"aud":    "karpops",
+		"sub":    "alice",
+		"exp":    now.Add(time.Hour).Unix(),
+		"iat":    now.Unix(),
+		"groups": []string{"dev", "ops"},
+	}
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+// token signs claims with alg. The key is picked by alg; kid is only the
+// header's.
+func (i *testIssuer) token(t *testing.T, alg, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(i.sign(t, alg, signed))
+}
+
+func (i *testIssuer) sign(t *testing.T, alg, signed string) []byte {
+	t.Helper()
+	var signature []byte
+	var err error
+	switch alg {
+	case "none":
+		return nil
+	case "HS256":
+		// The public key as an HMAC secret, as in algorithm confusion
+		// attacks.
+		mac := hmac.New(sha256.New, i.rsa.N.Bytes())
+		mac.Write([]byte(signed))
+		return mac.Sum(nil)
+	case "RS256":
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPKCS1v15(rand.Reader, i.rsa, crypto.SHA256, digest[:])
+	case "PS256":
+		digest := sha256.Sum256([]byte(signed))
+		signature, err = rsa.SignPSS(rand.Reader, i.rsa, crypto.SHA256, digest[:], nil)
+	case "ES256":
+		signature, err = signECDSA(i.p256, crypto.SHA256, signed)
+	case "ES384":
+		signature, err = signECDSA(i.p384, crypto.SHA384, signed)
+	default:
+		t.Fatalf("no test signer for %s", alg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signature
+}
+
+// signECDSA returns the JWS form of an ECDSA signature: r and s as
+// big-endian integers of the curve's size, concatenated.
+func signECDSA(key *ecdsa.PrivateKey, hash crypto.Hash, signed string) ([]byte, error) {
+	h := hash.New()
+	h.Write([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, h.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...), nil
+}
+
+func TestOIDC(t *testing.T) {
+	issuer := newTestIssuer(t)
+	now := time.Now()
+
+	// reSigned replaces the signature of a token.
+	reSigned := func(token string, signature func(signed string) []byte) string {
+		signed := token[:strings.LastIndex(token, ".")]
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature(signed))
+	}
+	tampered := func(token string) string {
+		parts := strings.Split(token, ".")
+		payload, _ := json.Marshal(issuer.claims(map[string]any{"sub": "mallory"}))
+		return parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+	}
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		// want is the identity's name; empty wants an error.
+		want string
+	}{
+		{"RS256", issuer.token(t, "RS256", "rsa", issuer.claims(nil)), "oidc:alice"},
+		{"PS256", issuer.token(t, "PS256", "rsa", issuer.claims(nil)), "oidc:alice"},
+		{"ES256", issuer.token(t, "ES256", "p256", issuer.claims(nil)), "oidc:alice"},
+		{"ES384", issuer.token(t, "ES384", "p384", issuer.claims(nil)), "oidc:alice"},
+
+		{"alg none", issuer.token(t, "none", "rsa", issuer.claims(nil)), ""},
+		{"HS256 with the public key", issuer.token(t, "HS256", "rsa", issuer.claims(nil)), ""},
+		{"unknown kid", issuer.token(t, "RS256", "rotated", issuer.claims(nil)), ""},
+		{"encryption key", issuer.token(t, "RS256", "enc", issuer.claims(nil)), ""},
+		{"kid of another key type", issuer.token(t, "RS256", "p256", issuer.claims(nil)), ""},
+		{"tampered claims", tampered(issuer.token(t, "RS256", "rsa", issuer.claims(nil))), ""},
+		{"signed by another key", reSigned(issuer.token(t, "ES256", "p384", issuer.claims(nil)), func(signed string) []byte {
+			signature, _ := signECDSA(issuer.p256, crypto.SHA256, signed)
+			return signature
+		}), ""},
+
+		// ECDSA signatures are r||s of the curve's size, not ASN.1.
+		{"ES256 signature in ASN.1", reSigned(issuer.token(t, "ES256", "p256", issuer.claims(nil)), func(signed string) []byte {
+			digest := sha256.Sum256([]byte(signed))
+			signature, _ := ecdsa.SignASN1(rand.Reader, issuer.p256, digest[:])
+			return signature
+		}), ""},
+		{"ES256 signature cut short", reSigned(issuer.token(t, "ES256", "p256", issuer.claims(nil)), func(signed string) []byte {
+			signature, _ := signECDSA(issuer.p256, crypto.SHA256, signed)
+			return signature[:63]
+		}), ""},
+		{"ES384 with a P-256 key", reSigned(issuer.token(t, "ES384", "p256", issuer.claims(nil)), func(signed string) []byte {
+			signature, _ := signECDSA(issuer.p256, crypto.SHA384, signed)
+			return signature
+		}), ""},
+
+		{"expired within the skew", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"exp": now.Add(-30 * time.Second).Unix()})), "oidc:alice"},
+		{"expired", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"exp": now.Add(-2 * time.Minute).Unix()})), ""},
+		{"no expiry", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"exp": nil})), ""},
+		{"not before, within the skew", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"nbf": now.Add(30 * time.Second).Unix()})), "oidc:alice"},
+		{"not before", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"nbf": now.Add(2 * time.Minute).Unix()})), ""},
+
+		{"audience in an array", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"aud": []string{"other", "karpops"}})), "oidc:alice"},
+		{"array without the audience", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"aud": []string{"other"}})), ""},
+		{"other audience", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"aud": "other"})), ""},
+		{"no audience", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"aud": nil})), ""},
+		{"no subject", issuer.token(t, "RS256", "rsa", issuer.claims(map[string]any{"sub": nil})), ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			identity, err := issuer.authenticator("sub").Authenticate(context.Background(), tc.token)
+			switch {
+			case tc.want == "" && err == nil:
+				t.Errorf("accepted as %+v", identity)
+			case tc.want != "" && err != nil:
+				t.Errorf("rejected: %v", err)
+			case tc.want != "" && (identity == nil || identity.Name != tc.want):
+				t.Errorf("identity = %+v, want %s", identity, tc.want)
+			}
+		})
+	}
+}
+
+func TestOIDCIdentity(t *testing.T) {
+	issuer := newTestIssuer(t)
+	identity, err := issuer.authenticator("sub").Authenticate(context.Background(), issuer.token(t, "RS256", "rsa", issuer.claims(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Name != "oidc:alice" || strings.Join(identity.Groups, ",") != "oidc:dev,oidc:ops" || identity.Method != MethodOIDC {
+		t.Errorf("identity = %+v", identity)
+	}
+
+	// Tokens of other issuers are left to the next authenticator.
+	other := issuer.claims(map[string]any{"iss": "https://other.example.com"})
+	if identity, err := issuer.authenticator("sub").Authenticate(context.Background(), issuer.token(t, "RS256", "rsa", other)); identity != nil || err != nil {
+		t.Errorf("token of another issuer: %+v, %v", identity, err)
+	}
+}
+
+func TestOIDCEmailVerified(t *testing.T) {
+	issuer := newTestIssuer(t)
+	for _, tc := range []struct {
+		verified any
+		ok       bool
+	}{
+		{true, true},
+		{nil, true},
+		{false, false},
+		// Some issuers send the claim as a string.
+		{"false", false},
+		{"true", false},
+	} {
+		claims := issuer.claims(map[string]any{"email": "alice@example.com", "email_verified": tc.verified})
+		identity, err := issuer.authenticator("email").Authenticate(context.Background(), issuer.token(t, "RS256", "rsa", claims))
+		if tc.ok && (err != nil || identity.Name != "oidc:alice@example.com") {
+			t.Errorf("email_verified %#v: %+v, %v", tc.verified, identity, err)
+		}
+		if !tc.ok && err == nil {
+			t.Errorf("email_verified %#v: accepted as %+v", tc.verified, identity)
+		}
+	}
+}
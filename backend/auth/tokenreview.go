@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenReviewTTL is how long a TokenReview answer is reused, so that a
+// busy caller doesn't cost an API server round trip per request.
+const tokenReviewTTL = time.Minute
+
+// TokenReviewer asks the Kubernetes API server who a token belongs to.
+// k8s.K8sClient implements it.
+type TokenReviewer interface {
+	ReviewToken(ctx context.Context, token string, audiences []string) (authenticated bool, username string, groups []string, err error)
+}
+
+type tokenReview struct {
+	reviewer  TokenReviewer
+	audiences []string
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]reviewed
+}
+
+type reviewed struct {
+	identity *Identity
+	expires  time.Time
+}
+
+func newTokenReview(reviewer TokenReviewer, audiences []string) *tokenReview {
+	return &tokenReview{reviewer: reviewer, audiences: audiences, cache: map[[sha256.Size]byte]reviewed{}}
+}
+
+func (t *tokenReview) Authenticate(ctx context.Context, token string) (*Identity, error) {
+	// Only JWTs are worth a round trip; anything else is for another
+	// authenticator or nobody.
+	if strings.Count(token, ".") != 2 {
+		return nil, nil
+	}
+
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+	t.mu.Lock()
+	cached, ok := t.cache[key]
+	t.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.identity, nil
+	}
+
+	authenticated, username, groups, err := t.reviewer.ReviewToken(ctx, token, t.audiences)
+	if err != nil {
+		return nil, err
+	}
+	var identity *Identity
+	if authenticated {
+		identity = &Identity{Name: username, Groups: groups, Method: MethodTokenReview}
+	}
+
+	t.mu.Lock()
+	for k, v := range t.cache {
+		if now.After(v.expires) {
+			delete(t.cache, k)
+		}
+	}
+	t.cache[key] = reviewed{identity: identity, expires: now.Add(tokenReviewTTL)}
+	t.mu.Unlock()
+	return identity, nil
+}
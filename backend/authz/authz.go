@@ -0,0 +1,290 @@
+// Package authz decides what an authenticated caller may do. Roles gate
+// routes: viewers read cost, recommendations and simulations, operators
+// may also change what the server holds, which today is the uploaded and
+// captured snapshots, and admins may also read the audit log and see every
+// namespace. Namespace grants, from the policy file and from Kubernetes
+// SubjectAccessReviews, limit which pods and namespace costs a caller
+// sees.
+package authz
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"
+	RoleOperator Role = "operator"
+	RoleAdmin    Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleViewer: 1, RoleOperator: 2, RoleAdmin: 3}
+
+func (r Role) valid() bool {
+	return roleRanks[r] > 0
+}
+
+// Includes reports whether r may do everything other may.
+func (r Role) Includes(other Role) bool {
+	return r.valid() && roleRanks[r] >= roleRanks[other]
+}
+
+// allClusters is the ?cluster= value of the whole fleet, as in
+// history.AllClusters.
+const allClusters = "*"
+
+// reviewTTL is how long a SubjectAccessReview answer is reused.
+const reviewTTL = time.Minute
+
+// AccessReviewer asks a cluster's API server whether a user may verb
+// resource in namespace, or in every namespace when it is empty.
+// k8s.K8sClient implements it.
+type AccessReviewer interface {
+	ReviewAccess(ctx context.Context, user string, groups []string, verb, resource, namespace string) (bool, error)
+}
+
+// ClusterResolver names the cluster a request's ?cluster= selects, the
+// default one for an empty value, and returns its AccessReviewer, or nil
+// when the cluster can't review access.
+type ClusterResolver func(cluster string) (name string, reviewer AccessReviewer)
+
+// Authorizer applies a Policy. A nil Authorizer allows everything, so
+// routes can be wired the same way whether authorization is on or not.
+type Authorizer struct {
+	policy  *Policy
+	resolve ClusterResolver
+
+	mu      sync.Mutex
+	reviews map[reviewKey]review
+}
+
+type reviewKey struct {
+	cluster, user, groups, namespace string
+}
+
+type review struct {
+	allowed bool
+	expires time.Time
+}
+
+func New(policy *Policy, resolve ClusterResolver) *Authorizer {
+	return &Authorizer{policy: policy, resolve: resolve, reviews: map[reviewKey]review{}}
+}
+
+// Role returns the highest role the policy gives identity, or an empty
+// role when it gives none.
+func (a *Authorizer) Role(identity *auth.Identity) Role {
+	role := a.policy.DefaultRole
+	for _, b := range a.policy.Roles {
+		if roleRanks[b.Role] > roleRanks[role] && b.names(identity.Name, identity.Groups) {
+			role = b.Role
+		}
+	}
+	return role
+}
+
+// Access is what the caller of a request may do.
+type Access struct {
+	Role  Role
+	Scope *Scope
+}
+
+type contextKey struct{}
+
+// FromContext returns the access of the request ctx belongs to, or nil
+// when authorization is off.
+func FromContext(ctx context.Context) *Access {
+	access, _ := ctx.Value(contextKey{}).(*Access)
+	return access
+}
+
+// ScopeFromContext returns the namespaces the caller of ctx may see. A
+// nil scope allows every namespace.
+func ScopeFromContext(ctx context.Context) *Scope {
+	if access := FromContext(ctx); access != nil {
+		return access.Scope
+	}
+	return nil
+}
+
+// Middleware turns away callers without a role and puts the access of the
+// rest in the request context. It runs after auth.Middleware.
+func (a *Authorizer) Middleware() gin.HandlerFunc {
+	if a == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		identity := auth.FromContext(c.Request.Context())
+		if identity == nil {
+			identity = auth.Anonymous
+		}
+		role := a.Role(identity)
+		if role == "" {
+			forbidden(c, "%s has no role in karpops-wiz", identity.Name)
+			return
+		}
+		// Uploaded snapshots belong to no cluster: only grants for every
+		// cluster apply to them.
+		var cluster string
+		var reviewer AccessReviewer
+		if c.Query("snapshot") == "" {
+			cluster, reviewer = a.resolve(c.Query("cluster"))
+		}
+		access := &Access{Role: role, Scope: a.scope(c.Request.Context(), identity, role, cluster, reviewer)}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), contextKey{}, access))
+		c.Next()
+	}
+}
+
+// Require turns away callers whose role doesn't include role.
+func (a *Authorizer) Require(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if access := FromContext(c.Request.Context()); access != nil && !access.Role.Includes(role) {
+			forbidden(c, "this needs the %s role; %s is not enough", role, access.Role)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAllNamespaces turns away callers who can't see every namespace,
+// from routes whose answers are about the whole cluster.
+func (a *Authorizer) RequireAllNamespaces() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !ScopeFromContext(c.Request.Context()).All() {
+			forbidden(c, "this needs access to every namespace of the cluster")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireFleet turns away callers who can't see every namespace of every
+// cluster, from routes whose answers add up the fleet. As for ?cluster=*,
+// only grants for every cluster count.
+func (a *Authorizer) RequireFleet() gin.HandlerFunc {
+	if a == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		identity := auth.FromContext(ctx)
+		if identity == nil {
+			identity = auth.Anonymous
+		}
+		var role Role
+		if access := FromContext(ctx); access != nil {
+			role = access.Role
+		}
+		cluster, reviewer := a.resolve(allClusters)
+		if !a.scope(ctx, identity, role, cluster, reviewer).All() {
+			forbidden(c, "this needs access to every namespace of every cluster")
+			return
+		}
+		c.Next()
+	}
+}
+
+func forbidden(c *gin.Context, format string, args ...any) {
+	apierror.Abort(c, apierror.Forbiddenf(format, args...))
+}
+
+// scope works out the namespaces identity may see in cluster: all of them
+// for admins and when the policy doesn't limit namespaces, otherwise those
+// granted in the policy and those Kubernetes RBAC lets identity list pods
+// in.
+func (a *Authorizer) scope(ctx context.Context, identity *auth.Identity, role Role, cluster string, reviewer AccessReviewer) *Scope {
+	if !a.policy.scoped() || role.Includes(RoleAdmin) {
+		return nil
+	}
+
+	scope := &Scope{}
+	for _, g := range a.policy.Namespaces {
+		if !g.names(identity.Name, identity.Groups) || (len(g.Clusters) > 0 && !slices.Contains(g.Clusters, cluster)) {
+			continue
+		}
+		for _, pattern := range g.Namespaces {
+			if pattern == "*" {
+				return nil
+			}
+			scope.patterns = append(scope.patterns, pattern)
+		}
+	}
+
+	if a.policy.reviews() && reviewer != nil {
+		review := func(ctx context.Context, namespace string) bool {
+			return a.review(ctx, reviewer, cluster, identity, namespace)
+		}
+		if review(ctx, "") {
+			return nil
+		}
+		scope.review = review
+	}
+	return scope
+}
+
+func (a *Authorizer) review(ctx context.Context, reviewer AccessReviewer, cluster string, identity *auth.Identity, namespace string) bool {
+	key := reviewKey{cluster: cluster, user: identity.Name, groups: strings.Join(identity.Groups, "\n"), namespace: namespace}
+	now := time.Now()
+	a.mu.Lock()
+	cached, ok := a.reviews[key]
+	a.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.allowed
+	}
+
+	config := a.policy.SubjectAccessReview
+	allowed, err := reviewer.ReviewAccess(ctx, identity.Name, identity.Groups, config.Verb, config.Resource, namespace)
+	if err != nil {
+		// Failing closed hides the namespace; the error isn't cached, so
+		// the next request asks again.
+		slog.WarnContext(ctx, "authz: failed to review access", "user", identity.Name, "namespace", namespace, "cluster", cluster, "error", err)
+		return false
+	}
+
+	a.mu.Lock()
+	for k, v := range a.reviews {
+		if now.After(v.expires) {
+			delete(a.reviews, k)
+		}
+	}
+	a.reviews[key] = review{allowed: allowed, expires: now.Add(reviewTTL)}
+	a.mu.Unlock()
+	return allowed
+}
+
+// Scope is the namespaces a caller may see. A nil Scope allows every
+// namespace.
+type Scope struct {
+	patterns []string
+	review   func(ctx context.Context, namespace string) bool
+}
+
+// All reports whether the scope allows every namespace.
+func (s *Scope) All() bool {
+	return s == nil
+}
+
+// Allows reports whether the scope includes namespace.
+func (s *Scope) Allows(ctx context.Context, namespace string) bool {
+	if s == nil {
+		return true
+	}
+	for _, pattern := range s.patterns {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return s.review != nil && s.review(ctx, namespace)
+}
@@ -0,0 +1,101 @@
+package authz
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// allowAll is a cluster whose RBAC lets everyone list pods everywhere.
+type allowAll struct{}
+
+func (allowAll) ReviewAccess(context.Context, string, []string, string, string, string) (bool, error) {
+	return true, nil
+}
+
+func TestGuards(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	subjects := func(group string) Subjects { return Subjects{Groups: []string{group}} }
+	policy := &Policy{
+		Roles: []RoleBinding{
+			{Role: RoleAdmin, Subjects: subjects("admins")},
+			{Role: RoleOperator, Subjects: subjects("operators")},
+		},
+		DefaultRole: RoleViewer,
+		Namespaces: []NamespaceGrant{
+			{Subjects: subjects("finance"), Namespaces: []string{"*"}},
+			{Subjects: subjects("platform-a"), Namespaces: []string{"*"}, Clusters: []string{"a"}},
+			{Subjects: subjects("shop"), Namespaces: []string{"shop"}},
+		},
+		SubjectAccessReview: &SubjectAccessReviewConfig{Enabled: true},
+	}
+	a := New(policy, func(cluster string) (string, AccessReviewer) {
+		switch cluster {
+		case allClusters:
+			return cluster, nil
+		case "":
+			cluster = "a"
+		}
+		if cluster == "rbac" {
+			return cluster, allowAll{}
+		}
+		return cluster, nil
+	})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		identity := &auth.Identity{Name: "someone", Groups: []string{c.GetHeader("X-Group")}}
+		c.Request = c.Request.WithContext(auth.WithIdentity(c.Request.Context(), identity))
+	}, a.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/operator", a.Require(RoleOperator), ok)
+	router.GET("/cluster", a.RequireAllNamespaces(), ok)
+	router.GET("/fleet", a.RequireFleet(), ok)
+
+	for _, tc := range []struct {
+		group, path string
+		want        int
+	}{
+		{"admins", "/operator", http.StatusOK},
+		{"operators", "/operator", http.StatusOK},
+		{"finance", "/operator", http.StatusForbidden},
+
+		{"admins", "/fleet", http.StatusOK},
+		{"finance", "/fleet", http.StatusOK},
+		// Every namespace of cluster a is not the fleet.
+		{"platform-a", "/cluster", http.StatusOK},
+		{"platform-a", "/fleet", http.StatusForbidden},
+		{"platform-a", "/fleet?cluster=a", http.StatusForbidden},
+		// Nor is what RBAC allows in one cluster.
+		{"shop", "/cluster?cluster=rbac", http.StatusOK},
+		{"shop", "/fleet?cluster=rbac", http.StatusForbidden},
+		{"shop", "/cluster", http.StatusForbidden},
+		{"operators", "/fleet", http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+		req.Header.Set("X-Group", tc.group)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != tc.want {
+			t.Errorf("%s GET %s = %d, want %d", tc.group, tc.path, w.Code, tc.want)
+		}
+	}
+}
+
+// Without a policy, every guard lets everyone through.
+func TestNilAuthorizer(t *testing.T) {
+	var a *Authorizer
+	router := gin.New()
+	router.GET("/", a.Middleware(), a.Require(RoleAdmin), a.RequireAllNamespaces(), a.RequireFleet(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET = %d, want 200", w.Code)
+	}
+}
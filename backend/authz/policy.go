@@ -0,0 +1,134 @@
+package authz
+
+import (
+	"fmt"
+	"os"
+	"path"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Policy is the authorization file. String values may reference
+// environment variables as ${NAME}.
+type Policy struct {
+	// Roles bind users and groups to roles; a caller gets the highest
+	// role of every binding naming them.
+	Roles []RoleBinding `json:"roles"`
+	// DefaultRole is the role of authenticated callers no binding names;
+	// empty turns them away.
+	DefaultRole Role `json:"defaultRole,omitempty"`
+	// Namespaces grant callers the pods and cost of namespaces. Without
+	// any grants and without SubjectAccessReview every caller sees every
+	// namespace.
+	Namespaces []NamespaceGrant `json:"namespaces,omitempty"`
+	// SubjectAccessReview grants callers the namespaces Kubernetes RBAC
+	// lets them list pods in.
+	SubjectAccessReview *SubjectAccessReviewConfig `json:"subjectAccessReview,omitempty"`
+}
+
+// Subjects names users and groups, as the authenticator reports them.
+type Subjects struct {
+	Users  []string `json:"users,omitempty"`
+	Groups []string `json:"groups,omitempty"`
+}
+
+type RoleBinding struct {
+	Role Role `json:"role"`
+	Subjects
+}
+
+// NamespaceGrant lets its subjects see the namespaces matching any of
+// Namespaces, glob patterns such as team-a-*, in the listed clusters or in
+// every cluster when Clusters is empty.
+type NamespaceGrant struct {
+	Subjects
+	Namespaces []string `json:"namespaces"`
+	Clusters   []string `json:"clusters,omitempty"`
+}
+
+type SubjectAccessReviewConfig struct {
+	Enabled bool `json:"enabled"`
+	// Verb and Resource are what a caller must be allowed in a namespace
+	// to see it; the default is to list pods.
+	Verb     string `json:"verb,omitempty"`
+	Resource string `json:"resource,omitempty"`
+}
+
+// Load reads and validates an authorization file.
+func Load(file string) (*Policy, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read authorization policy: %w", err)
+	}
+	var policy Policy
+	if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(data))), &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse authorization policy %s: %w", file, err)
+	}
+	if err := policy.validate(); err != nil {
+		return nil, fmt.Errorf("invalid authorization policy %s: %w", file, err)
+	}
+	return &policy, nil
+}
+
+func (p *Policy) validate() error {
+	if p.DefaultRole != "" && !p.DefaultRole.valid() {
+		return fmt.Errorf("defaultRole: unknown role %q", p.DefaultRole)
+	}
+	for i, b := range p.Roles {
+		if !b.Role.valid() {
+			return fmt.Errorf("role binding %d: unknown role %q (want %s, %s or %s)", i+1, b.Role, RoleViewer, RoleOperator, RoleAdmin)
+		}
+		if b.empty() {
+			return fmt.Errorf("role binding %d: users or groups are required", i+1)
+		}
+	}
+	for i, g := range p.Namespaces {
+		if g.empty() || len(g.Namespaces) == 0 {
+			return fmt.Errorf("namespace grant %d: namespaces and users or groups are required", i+1)
+		}
+		for _, pattern := range g.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("namespace grant %d: invalid pattern %q", i+1, pattern)
+			}
+		}
+	}
+	if r := p.SubjectAccessReview; r != nil {
+		if r.Verb == "" {
+			r.Verb = "list"
+		}
+		if r.Resource == "" {
+			r.Resource = "pods"
+		}
+	}
+	return nil
+}
+
+func (s *Subjects) empty() bool {
+	return len(s.Users) == 0 && len(s.Groups) == 0
+}
+
+// names reports whether the subjects include user or one of groups.
+func (s *Subjects) names(user string, groups []string) bool {
+	for _, u := range s.Users {
+		if u == user {
+			return true
+		}
+	}
+	for _, g := range s.Groups {
+		for _, group := range groups {
+			if g == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// scoped reports whether the policy limits callers to some namespaces.
+func (p *Policy) scoped() bool {
+	return len(p.Namespaces) > 0 || p.reviews()
+}
+
+func (p *Policy) reviews() bool {
+	return p.SubjectAccessReview != nil && p.SubjectAccessReview.Enabled
+}
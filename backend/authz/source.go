@@ -0,0 +1,73 @@
+package authz
+
+import (
+	"context"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+)
+
+// Source limits what source serves to the namespaces of the scope: pods,
+// their metrics and disruption budgets. Nodes, NodePools and node classes
+// belong to the whole cluster and are served as they are.
+func (s *Scope) Source(source k8s.ClusterSource) k8s.ClusterSource {
+	if s.All() {
+		return source
+	}
+	return &scopedSource{ClusterSource: source, scope: s}
+}
+
+type scopedSource struct {
+	k8s.ClusterSource
+	scope *Scope
+}
+
+func (s *scopedSource) GetPods(ctx context.Context) (*k8s.PodInfo, error) {
+	podInfo, err := s.ClusterSource.GetPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scoped := &k8s.PodInfo{Pods: []k8s.PodDetails{}}
+	for _, pod := range podInfo.Pods {
+		if s.scope.Allows(ctx, pod.Namespace) {
+			scoped.Pods = append(scoped.Pods, pod)
+			scoped.TotalCPU += pod.CPURequest
+			scoped.TotalMemory += pod.MemoryRequest
+		}
+	}
+	scoped.TotalPods = len(scoped.Pods)
+	return scoped, nil
+}
+
+func (s *scopedSource) GetPDBs(ctx context.Context) ([]k8s.PDBDetails, error) {
+	pdbs, err := s.ClusterSource.GetPDBs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	scoped := []k8s.PDBDetails{}
+	for _, pdb := range pdbs {
+		if s.scope.Allows(ctx, pdb.Namespace) {
+			scoped = append(scoped, pdb)
+		}
+	}
+	return scoped, nil
+}
+
+func (s *scopedSource) GetPodMetrics(ctx context.Context, namespace string) (map[string]k8s.PodUsage, error) {
+	if namespace != "" && !s.scope.Allows(ctx, namespace) {
+		return map[string]k8s.PodUsage{}, nil
+	}
+	usage, err := s.ClusterSource.GetPodMetrics(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	// Metrics are keyed by namespace/name. The map may be the source's own,
+	// such as a snapshot's or a cached copy, so it is left as it is.
+	scoped := map[string]k8s.PodUsage{}
+	for key, value := range usage {
+		if ns, _, _ := strings.Cut(key, "/"); s.scope.Allows(ctx, ns) {
+			scoped[key] = value
+		}
+	}
+	return scoped, nil
+}
@@ -0,0 +1,29 @@
+package authz
+
+import (
+	"context"
+	"testing"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/snapshot"
+)
+
+func TestScopedPodMetricsLeavesSourceAlone(t *testing.T) {
+	snap := &snapshot.Snapshot{Usage: map[string]k8s.PodUsage{
+		"shop/web-a":       {},
+		"batch/worker-a":   {},
+		"monitoring/agent": {},
+	}}
+	scope := &Scope{patterns: []string{"shop"}}
+
+	usage, err := scope.Source(snap).GetPodMetrics(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := usage["shop/web-a"]; len(usage) != 1 || !ok {
+		t.Errorf("scoped usage = %v, want only shop/web-a", usage)
+	}
+	if len(snap.Usage) != 3 {
+		t.Errorf("source usage has %d pods after a scoped read, want 3", len(snap.Usage))
+	}
+}
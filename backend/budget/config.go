@@ -0,0 +1,301 @@
+// Package budget watches recorded and forecast spend against budgets and
+// alerts through webhooks, Slack-compatible webhooks or email when a
+// threshold is crossed, and again when it no longer is.
+package budget
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/anomaly"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	PeriodDaily   = "daily"
+	PeriodWeekly  = "weekly"
+	PeriodMonthly = "monthly"
+
+	// SpendActual compares what was spent so far in the period, and
+	// SpendForecast what will have been spent by its end.
+	SpendActual   = "actual"
+	SpendForecast = "forecast"
+
+	NotifierWebhook = "webhook"
+	NotifierSlack   = "slack"
+	NotifierSMTP    = "smtp"
+
+	DefaultInterval       = 15 * time.Minute
+	DefaultRepeatInterval = 24 * time.Hour
+)
+
+// defaultThresholds apply to budgets that set none: a warning at 80% spent,
+// and alerts once the budget is spent or forecast to be.
+var defaultThresholds = []Threshold{
+	{Spend: SpendActual, Percent: 80},
+	{Spend: SpendActual, Percent: 100},
+	{Spend: SpendForecast, Percent: 100},
+}
+
+// Config is the budgets file. String values may reference environment
+// variables as ${NAME}, which keeps webhook URLs and passwords in Secrets.
+type Config struct {
+	// Interval is how often budgets are evaluated.
+	Interval string `json:"interval,omitempty"`
+	// RepeatInterval is how long a firing alert stays quiet before it is
+	// sent again.
+	RepeatInterval string `json:"repeatInterval,omitempty"`
+	// MonthlySavingsTarget alerts when the recommendations for a cluster
+	// would save at least this much a month; zero disables it.
+	MonthlySavingsTarget float64          `json:"monthlySavingsTarget,omitempty"`
+	Notifiers            []NotifierConfig `json:"notifiers,omitempty"`
+	Budgets              []Budget         `json:"budgets"`
+	// Anomalies alerts on ongoing anomalies in the recorded history.
+	Anomalies *AnomalyAlerts `json:"anomalies,omitempty"`
+
+	interval       time.Duration
+	repeatInterval time.Duration
+}
+
+// Budget caps the spend of a scope per calendar period, in UTC. The scope
+// is a cluster, or the whole fleet when Cluster is empty, narrowed to at
+// most one of a namespace, a NodePool or the pods with a label.
+type Budget struct {
+	Name      string `json:"name"`
+	Cluster   string `json:"cluster,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	NodePool  string `json:"nodePool,omitempty"`
+	// Label is a pod label as key=value.
+	Label      string      `json:"label,omitempty"`
+	Period     string      `json:"period,omitempty"`
+	Amount     float64     `json:"amount"`
+	Thresholds []Threshold `json:"thresholds,omitempty"`
+	// Notifiers names the notifiers to alert; empty means all of them.
+	Notifiers []string `json:"notifiers,omitempty"`
+}
+
+// Threshold fires when the actual or forecast spend of the period reaches
+// Percent of the budget.
+type Threshold struct {
+	Spend   string  `json:"spend"`
+	Percent float64 `json:"percent"`
+}
+
+// AnomalyAlerts selects the anomalies to alert on.
+type AnomalyAlerts struct {
+	// MinScore overrides the robust z-score an hour needs to count.
+	MinScore float64 `json:"minScore,omitempty"`
+	// Kinds limits alerts to these kinds; empty means all of them.
+	Kinds []anomaly.Kind `json:"kinds,omitempty"`
+	// Notifiers names the notifiers to alert; empty means all of them.
+	Notifiers []string `json:"notifiers,omitempty"`
+}
+
+func (a *AnomalyAlerts) watches(kind anomaly.Kind) bool {
+	if len(a.Kinds) == 0 {
+		return true
+	}
+	for _, k := range a.Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+type NotifierConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// URL is where webhook and slack notifiers post to.
+	URL     string            `json:"url,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	SMTP    *SMTPConfig       `json:"smtp,omitempty"`
+}
+
+type SMTPConfig struct {
+	// Host is host:port of the mail server.
+	Host     string   `json:"host"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// Load reads and validates a budgets file.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read budgets: %w", err)
+	}
+	var config Config
+	if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(data))), &config); err != nil {
+		return nil, fmt.Errorf("failed to parse budgets %s: %w", path, err)
+	}
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid budgets %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+func (c *Config) validate() error {
+	var err error
+	c.interval, c.repeatInterval = DefaultInterval, DefaultRepeatInterval
+	if c.Interval != "" {
+		if c.interval, err = history.ParseDuration(c.Interval); err != nil {
+			return fmt.Errorf("interval: %w", err)
+		}
+	}
+	if c.RepeatInterval != "" {
+		if c.repeatInterval, err = history.ParseDuration(c.RepeatInterval); err != nil {
+			return fmt.Errorf("repeatInterval: %w", err)
+		}
+	}
+
+	notifiers := map[string]bool{}
+	for i, n := range c.Notifiers {
+		if n.Name == "" {
+			return fmt.Errorf("notifier %d: name is required", i+1)
+		}
+		if notifiers[n.Name] {
+			return fmt.Errorf("duplicate notifier %q", n.Name)
+		}
+		notifiers[n.Name] = true
+		switch n.Type {
+		case NotifierWebhook, NotifierSlack:
+			if n.URL == "" {
+				return fmt.Errorf("notifier %s: url is required", n.Name)
+			}
+		case NotifierSMTP:
+			if n.SMTP == nil || n.SMTP.Host == "" || n.SMTP.From == "" || len(n.SMTP.To) == 0 {
+				return fmt.Errorf("notifier %s: smtp needs host, from and to", n.Name)
+			}
+		default:
+			return fmt.Errorf("notifier %s: unknown type %q (want %s, %s or %s)", n.Name, n.Type, NotifierWebhook, NotifierSlack, NotifierSMTP)
+		}
+	}
+
+	budgets := map[string]bool{}
+	for i := range c.Budgets {
+		b := &c.Budgets[i]
+		if b.Name == "" {
+			return fmt.Errorf("budget %d: name is required", i+1)
+		}
+		if budgets[b.Name] {
+			return fmt.Errorf("duplicate budget %q", b.Name)
+		}
+		budgets[b.Name] = true
+		if b.Amount <= 0 {
+			return fmt.Errorf("budget %s: amount must be positive", b.Name)
+		}
+		if b.Period == "" {
+			b.Period = PeriodMonthly
+		}
+		if b.Period != PeriodDaily && b.Period != PeriodWeekly && b.Period != PeriodMonthly {
+			return fmt.Errorf("budget %s: unknown period %q", b.Name, b.Period)
+		}
+		scopes := 0
+		for _, v := range []string{b.Namespace, b.NodePool, b.Label} {
+			if v != "" {
+				scopes++
+			}
+		}
+		if scopes > 1 {
+			return fmt.Errorf("budget %s: set at most one of namespace, nodePool and label", b.Name)
+		}
+		if b.Label != "" {
+			if key, value, ok := strings.Cut(b.Label, "="); !ok || key == "" || value == "" {
+				return fmt.Errorf("budget %s: label must be key=value", b.Name)
+			}
+		}
+		if len(b.Thresholds) == 0 {
+			b.Thresholds = defaultThresholds
+		}
+		for _, t := range b.Thresholds {
+			if t.Spend != SpendActual && t.Spend != SpendForecast {
+				return fmt.Errorf("budget %s: threshold spend must be %s or %s", b.Name, SpendActual, SpendForecast)
+			}
+			if t.Percent <= 0 {
+				return fmt.Errorf("budget %s: threshold percent must be positive", b.Name)
+			}
+		}
+		for _, name := range b.Notifiers {
+			if !notifiers[name] {
+				return fmt.Errorf("budget %s: unknown notifier %q", b.Name, name)
+			}
+		}
+	}
+
+	if a := c.Anomalies; a != nil {
+		for _, kind := range a.Kinds {
+			known := false
+			for _, k := range anomaly.Kinds {
+				known = known || k == kind
+			}
+			if !known {
+				return fmt.Errorf("anomalies: unknown kind %q", kind)
+			}
+		}
+		for _, name := range a.Notifiers {
+			if !notifiers[name] {
+				return fmt.Errorf("anomalies: unknown notifier %q", name)
+			}
+		}
+	}
+	return nil
+}
+
+// LabelKeys are the pod label keys budgets are scoped by, which the
+// history collector has to record.
+func (c *Config) LabelKeys() []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, b := range c.Budgets {
+		if key, _, ok := strings.Cut(b.Label, "="); ok && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// dimension is the history dimension and value the budget's scope selects,
+// or empty for the whole cluster.
+func (b *Budget) dimension() (dimension, value string) {
+	switch {
+	case b.Namespace != "":
+		return history.DimensionNamespace, b.Namespace
+	case b.NodePool != "":
+		return history.DimensionNodePool, b.NodePool
+	case b.Label != "":
+		key, value, _ := strings.Cut(b.Label, "=")
+		return history.LabelDimension(key), value
+	}
+	return "", ""
+}
+
+func (b *Budget) cluster() string {
+	if b.Cluster == "" {
+		return history.AllClusters
+	}
+	return b.Cluster
+}
+
+// period returns the start and end of the calendar period containing now.
+func period(name string, now time.Time) (start, end time.Time) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	switch name {
+	case PeriodDaily:
+		return day, day.AddDate(0, 0, 1)
+	case PeriodWeekly:
+		// Weeks start on Monday.
+		start = day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return start, start.AddDate(0, 0, 7)
+	default:
+		start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
@@ -0,0 +1,506 @@
+package budget
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/anomaly"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/forecast"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+)
+
+const (
+	// Budget alerts are of the kind of their threshold, SpendActual or
+	// SpendForecast; these are the others.
+	KindSavings = "savings"
+	KindAnomaly = "anomaly"
+
+	// forecastLookback is the least history the spend forecast is fitted
+	// to; four weeks lets it pick up the weekly cycle.
+	forecastLookback = 28 * history.Day
+	weeklySeason     = 7
+
+	// stateKey is where alerts are kept in the history database, so a
+	// restart neither repeats nor forgets them.
+	stateKey = "budget-alerts"
+)
+
+type State string
+
+const (
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Alert is a crossed budget threshold, a cluster past the savings target
+// or an ongoing anomaly. It fires once, repeats every RepeatInterval while
+// it holds, and resolves to the notifiers that heard it fire.
+type Alert struct {
+	Key       string     `json:"key"`
+	Budget    string     `json:"budget,omitempty"`
+	Cluster   string     `json:"cluster,omitempty"`
+	Kind      string     `json:"kind"`
+	State     State      `json:"state"`
+	Title     string     `json:"title"`
+	Message   string     `json:"message"`
+	Value     float64    `json:"value"`
+	Threshold float64    `json:"threshold"`
+	StartsAt  time.Time  `json:"startsAt"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+	Notifiers []string   `json:"notifiers"`
+	// Notified is when each notifier last heard the alert fire.
+	Notified map[string]time.Time `json:"notified,omitempty"`
+	// Anomaly details alerts of KindAnomaly.
+	Anomaly *anomaly.Anomaly `json:"anomaly,omitempty"`
+}
+
+// Status is how a budget stands in its current period.
+type Status struct {
+	Budget          Budget    `json:"budget"`
+	PeriodStart     time.Time `json:"periodStart"`
+	PeriodEnd       time.Time `json:"periodEnd"`
+	Actual          float64   `json:"actual"`
+	Forecast        float64   `json:"forecast"`
+	ActualPercent   float64   `json:"actualPercent"`
+	ForecastPercent float64   `json:"forecastPercent"`
+	EvaluatedAt     time.Time `json:"evaluatedAt"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// SavingsFunc returns the monthly savings the recommendations would bring,
+// per cluster.
+type SavingsFunc func(ctx context.Context) (map[string]float64, error)
+
+// AnomalyFunc detects the anomalies of the whole fleet, with minScore
+// overriding the default when it is positive.
+type AnomalyFunc func(ctx context.Context, minScore float64) ([]anomaly.Anomaly, error)
+
+// Evaluator checks every budget on an interval and notifies on changes.
+type Evaluator struct {
+	config    *Config
+	store     *history.Store
+	savings   SavingsFunc
+	anomalies AnomalyFunc
+	notifiers map[string]Notifier
+	// all is every notifier name, in config order.
+	all []string
+
+	// evaluating serializes evaluations; mu guards their results.
+	evaluating sync.Mutex
+	mu         sync.Mutex
+	alerts     map[string]*Alert
+	statuses   []Status
+}
+
+// NewEvaluator builds the notifiers of config and restores the alerts of
+// a previous run from store. savings and anomalies may be nil when the
+// config sets no savings target or anomaly alerts.
+func NewEvaluator(config *Config, store *history.Store, savings SavingsFunc, anomalies AnomalyFunc) (*Evaluator, error) {
+	e := &Evaluator{
+		config:    config,
+		store:     store,
+		savings:   savings,
+		anomalies: anomalies,
+		notifiers: map[string]Notifier{},
+		alerts:    map[string]*Alert{},
+	}
+	for _, nc := range config.Notifiers {
+		notifier, err := NewNotifier(nc)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", nc.Name, err)
+		}
+		e.notifiers[nc.Name] = notifier
+		e.all = append(e.all, nc.Name)
+	}
+
+	var saved []*Alert
+	if err := store.LoadState(stateKey, &saved); err != nil {
+		return nil, fmt.Errorf("failed to restore budget alerts: %w", err)
+	}
+	for _, alert := range saved {
+		if alert.Notified == nil {
+			alert.Notified = map[string]time.Time{}
+		}
+		e.alerts[alert.Key] = alert
+	}
+	return e, nil
+}
+
+// Run evaluates immediately and then on every interval until ctx is done.
+func (e *Evaluator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.config.interval)
+	defer ticker.Stop()
+	for {
+		e.Evaluate(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Statuses returns each budget as of the last evaluation.
+func (e *Evaluator) Statuses() []Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]Status(nil), e.statuses...)
+}
+
+// Alerts returns the firing alerts and the resolved ones not yet delivered,
+// oldest first.
+func (e *Evaluator) Alerts() []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alerts := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		alerts = append(alerts, *alert)
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].StartsAt.Equal(alerts[j].StartsAt) {
+			return alerts[i].StartsAt.Before(alerts[j].StartsAt)
+		}
+		return alerts[i].Key < alerts[j].Key
+	})
+	return alerts
+}
+
+// Evaluate checks every budget, the savings target and the anomalies as of
+// now, moves alerts between firing and resolved, and sends what is due.
+func (e *Evaluator) Evaluate(ctx context.Context, now time.Time) {
+	e.evaluating.Lock()
+	defer e.evaluating.Unlock()
+
+	firing := map[string]*Alert{}
+	// Alerts of budgets and kinds that couldn't be evaluated stay as they
+	// are rather than resolve.
+	unknownBudgets, unknownKinds := map[string]bool{}, map[string]bool{}
+
+	statuses := make([]Status, 0, len(e.config.Budgets))
+	for i := range e.config.Budgets {
+		b := &e.config.Budgets[i]
+		status := e.evaluateBudget(b, now)
+		statuses = append(statuses, status)
+		if status.Error != "" {
+			unknownBudgets[b.Name] = true
+			continue
+		}
+		for _, t := range b.Thresholds {
+			value := status.Actual
+			if t.Spend == SpendForecast {
+				value = status.Forecast
+			}
+			threshold := b.Amount * t.Percent / 100
+			if value < threshold {
+				continue
+			}
+			alert := budgetAlert(b, t, status, value, threshold)
+			if len(alert.Notifiers) == 0 {
+				alert.Notifiers = e.all
+			}
+			firing[alert.Key] = alert
+		}
+	}
+
+	if target := e.config.MonthlySavingsTarget; target > 0 && e.savings != nil {
+		savings, err := e.savings(ctx)
+		if err != nil {
+			slog.Warn("budget: failed to compute savings", "error", err)
+			unknownKinds[KindSavings] = true
+		}
+		for cluster, value := range savings {
+			if value >= target {
+				alert := savingsAlert(cluster, value, target)
+				alert.Notifiers = e.all
+				firing[alert.Key] = alert
+			}
+		}
+	}
+
+	if watch := e.config.Anomalies; watch != nil && e.anomalies != nil {
+		found, err := e.anomalies(ctx, watch.MinScore)
+		if err != nil {
+			slog.Warn("budget: failed to detect anomalies", "error", err)
+			unknownKinds[KindAnomaly] = true
+		}
+		for i := range found {
+			if !found[i].Ongoing || !watch.watches(found[i].Kind) {
+				continue
+			}
+			alert := anomalyAlert(&found[i])
+			alert.Notifiers = watch.Notifiers
+			if len(alert.Notifiers) == 0 {
+				alert.Notifiers = e.all
+			}
+			firing[alert.Key] = alert
+		}
+	}
+
+	e.mu.Lock()
+	for key, candidate := range firing {
+		alert := e.alerts[key]
+		if alert == nil {
+			candidate.StartsAt = now
+			candidate.Notified = map[string]time.Time{}
+			e.alerts[key] = candidate
+			continue
+		}
+		// A resolved alert that fires again before the resolution went out
+		// simply keeps firing for the notifiers that heard it.
+		alert.State, alert.EndsAt = StateFiring, nil
+		alert.Title, alert.Message, alert.Value, alert.Threshold = candidate.Title, candidate.Message, candidate.Value, candidate.Threshold
+		alert.Notifiers, alert.Anomaly = candidate.Notifiers, candidate.Anomaly
+	}
+	for key, alert := range e.alerts {
+		if firing[key] != nil || alert.State != StateFiring {
+			continue
+		}
+		if (alert.Budget != "" && unknownBudgets[alert.Budget]) || unknownKinds[alert.Kind] {
+			continue
+		}
+		ended := now
+		alert.State, alert.EndsAt = StateResolved, &ended
+		alert.Message = resolvedMessage(alert)
+	}
+	e.statuses = statuses
+	deliveries := e.due(now)
+	e.mu.Unlock()
+
+	e.deliver(ctx, deliveries, now)
+	e.save()
+}
+
+func (e *Evaluator) evaluateBudget(b *Budget, now time.Time) Status {
+	start, end := period(b.Period, now)
+	status := Status{Budget: *b, PeriodStart: start, PeriodEnd: end, EvaluatedAt: now}
+
+	from := now.Add(-forecastLookback)
+	if start.Before(from) {
+		from = start
+	}
+	dimension, value := b.dimension()
+	series, err := e.store.Daily(b.cluster(), dimension, from, now)
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+	if series == nil {
+		status.Error = "no cost history recorded yet"
+		return status
+	}
+	costs := series.Cost
+	if dimension != "" {
+		costs = series.Groups[value]
+		if costs == nil {
+			costs = make([]float64, len(series.Cost))
+		}
+	}
+
+	// Days before the history starts count as nothing spent.
+	today := len(costs) - 1
+	midnight := series.From.Add(time.Duration(today) * history.Day)
+	elapsed := float64(now.Sub(midnight)) / float64(history.Day)
+	for i := 0; i < today; i++ {
+		if !series.From.Add(time.Duration(i) * history.Day).Before(start) {
+			status.Actual += costs[i]
+		}
+	}
+	status.Actual += costs[today] * elapsed
+
+	status.Forecast = status.Actual + costs[today]*(1-elapsed)
+	if remaining := int(end.Sub(midnight)/history.Day) - 1; remaining > 0 {
+		model := forecast.FitSeasonal(costs, weeklySeason)
+		for _, estimate := range model.Forecast(remaining, 0) {
+			status.Forecast += max(estimate.Value, 0)
+		}
+	}
+
+	status.ActualPercent = status.Actual / b.Amount * 100
+	status.ForecastPercent = status.Forecast / b.Amount * 100
+	return status
+}
+
+func budgetAlert(b *Budget, t Threshold, status Status, value, threshold float64) *Alert {
+	alert := &Alert{
+		Key:       fmt.Sprintf("%s/%s/%g", b.Name, t.Spend, t.Percent),
+		Budget:    b.Name,
+		Cluster:   b.Cluster,
+		Kind:      t.Spend,
+		State:     StateFiring,
+		Value:     value,
+		Threshold: threshold,
+		Notifiers: b.Notifiers,
+	}
+	ends := status.PeriodEnd.Format(time.DateOnly)
+	if t.Spend == SpendForecast {
+		alert.Title = fmt.Sprintf("Budget %s forecast to reach %g%%", b.Name, t.Percent)
+		alert.Message = fmt.Sprintf("%s (%s) is forecast to spend %s of its %s budget of %s (%.0f%%) by %s, past the %g%% threshold. It has spent %s so far.",
+			b.Name, scope(b), money(value), b.Period, money(b.Amount), status.ForecastPercent, ends, t.Percent, money(status.Actual))
+	} else {
+		alert.Title = fmt.Sprintf("Budget %s at %g%%", b.Name, t.Percent)
+		alert.Message = fmt.Sprintf("%s (%s) has spent %s of its %s budget of %s (%.0f%%), past the %g%% threshold. The period ends %s.",
+			b.Name, scope(b), money(value), b.Period, money(b.Amount), status.ActualPercent, t.Percent, ends)
+	}
+	return alert
+}
+
+func savingsAlert(cluster string, value, target float64) *Alert {
+	return &Alert{
+		Key:       KindSavings + "/" + cluster,
+		Cluster:   cluster,
+		Kind:      KindSavings,
+		State:     StateFiring,
+		Value:     value,
+		Threshold: target,
+		Title:     fmt.Sprintf("Cluster %s could save %s a month", cluster, money(value)),
+		Message: fmt.Sprintf("The recommendations for cluster %s would save %s a month, past the savings target of %s. See /api/v1/recommendations/rebalancing?cluster=%s.",
+			cluster, money(value), money(target), cluster),
+	}
+}
+
+func anomalyAlert(a *anomaly.Anomaly) *Alert {
+	alert := &Alert{
+		Key:       fmt.Sprintf("%s/%s/%s/%s", KindAnomaly, a.Kind, a.Cluster, a.Subject),
+		Cluster:   a.Cluster,
+		Kind:      KindAnomaly,
+		State:     StateFiring,
+		Value:     a.Value,
+		Threshold: a.Baseline,
+		Title:     fmt.Sprintf("%s in cluster %s", a.Kind, a.Cluster),
+		Message:   a.Message,
+		Anomaly:   a,
+	}
+	if a.Subject != "" {
+		alert.Title = fmt.Sprintf("%s of %s in cluster %s", a.Kind, a.Subject, a.Cluster)
+	}
+	if len(a.Contributors) > 0 {
+		contributors := make([]string, len(a.Contributors))
+		for i, c := range a.Contributors {
+			name := c.Kind + " " + c.Name
+			if c.Namespace != "" {
+				name = fmt.Sprintf("%s %s/%s", c.Kind, c.Namespace, c.Name)
+			}
+			contributors[i] = fmt.Sprintf("%s (%s/h)", name, money(c.HourlyCost))
+		}
+		alert.Message += " Top contributors now: " + strings.Join(contributors, ", ") + "."
+	}
+	return alert
+}
+
+func resolvedMessage(alert *Alert) string {
+	switch alert.Kind {
+	case KindSavings:
+		return fmt.Sprintf("The recommendations for cluster %s no longer save %s a month.", alert.Cluster, money(alert.Threshold))
+	case KindAnomaly:
+		return fmt.Sprintf("The %s is over.", alert.Title)
+	}
+	return fmt.Sprintf("The %s spend of budget %s is back under %s, or its period ended.", alert.Kind, alert.Budget, money(alert.Threshold))
+}
+
+func scope(b *Budget) string {
+	where := "across the fleet"
+	if b.Cluster != "" {
+		where = "in cluster " + b.Cluster
+	}
+	switch {
+	case b.Namespace != "":
+		return fmt.Sprintf("namespace %s %s", b.Namespace, where)
+	case b.NodePool != "":
+		return fmt.Sprintf("NodePool %s %s", b.NodePool, where)
+	case b.Label != "":
+		return fmt.Sprintf("pods labelled %s %s", b.Label, where)
+	}
+	return "all nodes " + where
+}
+
+func money(v float64) string {
+	return fmt.Sprintf("$%.2f", v)
+}
+
+// delivery is one alert due at one notifier.
+type delivery struct {
+	notifier string
+	alert    Alert
+}
+
+// due lists the notifications to send: firing alerts to notifiers that
+// haven't heard them within the repeat interval, resolutions to those
+// that heard them fire. Resolved alerts nobody heard of are dropped.
+// e.mu must be held.
+func (e *Evaluator) due(now time.Time) []delivery {
+	var deliveries []delivery
+	for key, alert := range e.alerts {
+		switch alert.State {
+		case StateFiring:
+			for _, name := range alert.Notifiers {
+				if last, ok := alert.Notified[name]; !ok || now.Sub(last) >= e.config.repeatInterval {
+					deliveries = append(deliveries, delivery{notifier: name, alert: *alert})
+				}
+			}
+		case StateResolved:
+			if len(alert.Notified) == 0 {
+				delete(e.alerts, key)
+				continue
+			}
+			for name := range alert.Notified {
+				deliveries = append(deliveries, delivery{notifier: name, alert: *alert})
+			}
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].alert.Key != deliveries[j].alert.Key {
+			return deliveries[i].alert.Key < deliveries[j].alert.Key
+		}
+		return deliveries[i].notifier < deliveries[j].notifier
+	})
+	return deliveries
+}
+
+// deliver sends deliveries and records which went out. Failed ones are
+// due again on the next evaluation.
+func (e *Evaluator) deliver(ctx context.Context, deliveries []delivery, now time.Time) {
+	for _, d := range deliveries {
+		notifier := e.notifiers[d.notifier]
+		if notifier == nil {
+			// The notifier was removed from the config since.
+			e.delivered(d, now)
+			continue
+		}
+		notifyCtx, cancel := context.WithTimeout(ctx, notifyTimeout)
+		err := notifier.Notify(notifyCtx, &d.alert)
+		cancel()
+		if err != nil {
+			slog.Warn("budget: failed to notify", "notifier", d.notifier, "alert", d.alert.Key, "error", err)
+			continue
+		}
+		e.delivered(d, now)
+	}
+}
+
+func (e *Evaluator) delivered(d delivery, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	alert := e.alerts[d.alert.Key]
+	if alert == nil {
+		return
+	}
+	if d.alert.State == StateFiring {
+		alert.Notified[d.notifier] = now
+		return
+	}
+	delete(alert.Notified, d.notifier)
+	if len(alert.Notified) == 0 {
+		delete(e.alerts, d.alert.Key)
+	}
+}
+
+func (e *Evaluator) save() {
+	if err := e.store.SaveState(stateKey, e.Alerts()); err != nil {
+		slog.Error("budget: failed to save alerts", "error", err)
+	}
+}
@@ -0,0 +1,147 @@
+package budget
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+)
+
+// webhook records the alerts posted to it as "key state".
+type webhook struct {
+	mu       sync.Mutex
+	received []string
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var alert Alert
+	if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.mu.Lock()
+	w.received = append(w.received, alert.Key+" "+string(alert.State))
+	w.mu.Unlock()
+}
+
+func (w *webhook) take() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	received := w.received
+	w.received = nil
+	return received
+}
+
+// A cluster spending $10 an hour against a daily budget of $200 crosses
+// 80% at 16:00 and 100% at 20:00, and starts over at midnight.
+func TestEvaluatorAlerts(t *testing.T) {
+	hook := &webhook{}
+	server := httptest.NewServer(hook)
+	defer server.Close()
+
+	store, err := history.Open(filepath.Join(t.TempDir(), "history.db"), history.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	day := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	if err := store.Put(&history.Sample{Time: day, Cluster: "prod", Weight: 1, HourlyCost: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{
+		RepeatInterval: "6h",
+		Notifiers:      []NotifierConfig{{Name: "hook", Type: NotifierWebhook, URL: server.URL}},
+		Budgets: []Budget{{
+			Name:       "prod",
+			Cluster:    "prod",
+			Period:     PeriodDaily,
+			Amount:     200,
+			Thresholds: []Threshold{{Spend: SpendActual, Percent: 80}, {Spend: SpendActual, Percent: 100}},
+		}},
+	}
+	if err := config.validate(); err != nil {
+		t.Fatal(err)
+	}
+	newEvaluator := func() *Evaluator {
+		e, err := NewEvaluator(config, store, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	e := newEvaluator()
+
+	for _, step := range []struct {
+		at      time.Duration
+		restart bool
+		actual  float64
+		firing  []string
+		sent    []string
+	}{
+		{at: 12 * time.Hour, actual: 120},
+		{at: 17 * time.Hour, actual: 170, firing: []string{"prod/actual/80"}, sent: []string{"prod/actual/80 firing"}},
+		// Heard within the repeat interval, even by a restarted server.
+		{at: 18 * time.Hour, restart: true, actual: 180, firing: []string{"prod/actual/80"}},
+		{at: 21 * time.Hour, actual: 210, firing: []string{"prod/actual/80", "prod/actual/100"}, sent: []string{"prod/actual/100 firing"}},
+		{at: 23 * time.Hour, actual: 230, firing: []string{"prod/actual/80", "prod/actual/100"}, sent: []string{"prod/actual/80 firing"}},
+		// The next day's $10 is under both thresholds.
+		{at: 25 * time.Hour, actual: 10, sent: []string{"prod/actual/100 resolved", "prod/actual/80 resolved"}},
+		{at: 26 * time.Hour, actual: 20},
+	} {
+		if step.restart {
+			e = newEvaluator()
+		}
+		now := day.Add(step.at)
+		e.Evaluate(context.Background(), now)
+
+		if status := e.Statuses()[0]; status.Error != "" || !near(status.Actual, step.actual) {
+			t.Errorf("%s: status = %+v, want $%v spent", now.Format(time.Kitchen), status, step.actual)
+		}
+		var firing []string
+		for _, alert := range e.Alerts() {
+			if alert.State == StateFiring {
+				firing = append(firing, alert.Key)
+			}
+		}
+		if !slices.Equal(firing, step.firing) {
+			t.Errorf("%s: firing = %q, want %q", now.Format(time.Kitchen), firing, step.firing)
+		}
+		if sent := hook.take(); !slices.Equal(sent, step.sent) {
+			t.Errorf("%s: sent = %q, want %q", now.Format(time.Kitchen), sent, step.sent)
+		}
+	}
+	if alerts := e.Alerts(); len(alerts) != 0 {
+		t.Errorf("alerts = %+v, want the delivered resolutions dropped", alerts)
+	}
+}
+
+// A resolution nobody heard fire is dropped without a notification.
+func TestEvaluatorUndeliveredResolution(t *testing.T) {
+	e := &Evaluator{config: &Config{repeatInterval: time.Hour}, alerts: map[string]*Alert{
+		"quiet": {Key: "quiet", State: StateResolved, Notified: map[string]time.Time{}},
+		"heard": {Key: "heard", State: StateResolved, Notified: map[string]time.Time{"a": {}, "b": {}}},
+	}}
+	deliveries := e.due(time.Now())
+	var got []string
+	for _, d := range deliveries {
+		got = append(got, d.alert.Key+" "+d.notifier)
+	}
+	if want := []string{"heard a", "heard b"}; !slices.Equal(got, want) {
+		t.Errorf("due = %q, want %q", got, want)
+	}
+	if _, ok := e.alerts["quiet"]; ok {
+		t.Error("unheard resolution kept")
+	}
+}
+
+func near(a, b float64) bool {
+	return a-b < 1e-9 && b-a < 1e-9
+}
@@ -0,0 +1,121 @@
+package budget
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+const notifyTimeout = 10 * time.Second
+
+// Notifier delivers alerts to one destination.
+type Notifier interface {
+	Notify(ctx context.Context, alert *Alert) error
+}
+
+// NewNotifier builds the notifier a config describes.
+func NewNotifier(config NotifierConfig) (Notifier, error) {
+	client := &http.Client{Timeout: notifyTimeout}
+	switch config.Type {
+	case NotifierWebhook:
+		return &webhookNotifier{client: client, url: config.URL, headers: config.Headers}, nil
+	case NotifierSlack:
+		return &slackNotifier{client: client, url: config.URL}, nil
+	case NotifierSMTP:
+		return &smtpNotifier{config: *config.SMTP}, nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %q", config.Type)
+}
+
+// webhookNotifier posts the alert as JSON.
+type webhookNotifier struct {
+	client  *http.Client
+	url     string
+	headers map[string]string
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, alert *Alert) error {
+	return postJSON(ctx, n.client, n.url, n.headers, alert)
+}
+
+// slackNotifier posts the alert as a message to a Slack incoming webhook,
+// or anything accepting the same {"text": ...} payload.
+type slackNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func (n *slackNotifier) Notify(ctx context.Context, alert *Alert) error {
+	icon := ":rotating_light:"
+	if alert.State == StateResolved {
+		icon = ":white_check_mark:"
+	}
+	text := fmt.Sprintf("%s *%s* %s", icon, strings.ToUpper(string(alert.State)), alert.Message)
+	return postJSON(ctx, n.client, n.url, nil, map[string]string{"text": text})
+}
+
+func postJSON(ctx context.Context, client *http.Client, url string, headers map[string]string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// smtpNotifier mails the alert, upgrading to TLS when the server offers it.
+type smtpNotifier struct {
+	config SMTPConfig
+}
+
+func (n *smtpNotifier) Notify(ctx context.Context, alert *Alert) error {
+	var auth smtp.Auth
+	if n.config.Username != "" {
+		host, _, err := net.SplitHostPort(n.config.Host)
+		if err != nil {
+			return fmt.Errorf("invalid smtp host %q: %w", n.config.Host, err)
+		}
+		auth = smtp.PlainAuth("", n.config.Username, n.config.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.config.To, ", "))
+	fmt.Fprintf(&msg, "Subject: [%s] %s\r\n", strings.ToUpper(string(alert.State)), alert.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(alert.Message + "\r\n")
+
+	// net/smtp takes no context; bound it the way the webhooks are.
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(n.config.Host, auth, n.config.From, n.config.To, msg.Bytes())
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,448 @@
+// Package check evaluates a proposed set of Karpenter NodePools and
+// EC2NodeClasses against a baseline cluster: the projected cost change of
+// rolling them out, and rules that flag risky configurations. It backs both
+// `karpops-wiz check` and POST /api/v1/check, so GitOps pipelines can
+// comment on and gate Karpenter config changes.
+package check
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/simulator"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityNote    Severity = "note"
+	// SeverityNone as FailOn never fails on violations; cost thresholds
+	// still apply.
+	SeverityNone Severity = "none"
+)
+
+const (
+	RuleInvalidManifest   = "invalid-manifest"
+	RuleNoNodePools       = "no-nodepools"
+	RuleDuplicateResource = "duplicate-resource"
+	RuleUnknownNodeClass  = "unknown-nodeclass"
+	RuleNoInstanceTypes   = "no-instance-types"
+	RuleUnschedulablePods = "unschedulable-pods"
+	RuleSpotDiversity     = "spot-diversity"
+	RuleMissingLimits     = "missing-limits"
+	RuleDisruptionBlocked = "disruption-blocked"
+	RuleCostIncrease      = "cost-increase"
+)
+
+const (
+	minSpotInstanceTypes = 5
+	resourceLimitCPU     = "cpu"
+)
+
+// Rule describes one kind of violation.
+type Rule struct {
+	ID          string   `json:"id"`
+	Severity    Severity `json:"severity"`
+	Description string   `json:"description"`
+}
+
+// Rules lists every rule the check evaluates, in report order.
+var Rules = []Rule{
+	{RuleInvalidManifest, SeverityError, "Manifests must parse as Karpenter NodePools or EC2NodeClasses."},
+	{RuleNoNodePools, SeverityError, "The manifests must define at least one NodePool."},
+	{RuleDuplicateResource, SeverityError, "Each NodePool and EC2NodeClass may only be defined once."},
+	{RuleUnknownNodeClass, SeverityError, "NodePools must reference an EC2NodeClass defined in the manifests or the cluster."},
+	{RuleNoInstanceTypes, SeverityError, "NodePool requirements must allow at least one priced instance type."},
+	{RuleUnschedulablePods, SeverityError, "Pods that run today must still fit on the proposed NodePools."},
+	{RuleSpotDiversity, SeverityWarning, fmt.Sprintf("Spot NodePools should allow at least %d instance types to limit interruptions.", minSpotInstanceTypes)},
+	{RuleMissingLimits, SeverityWarning, "NodePools should set a CPU limit to cap runaway scaling."},
+	{RuleDisruptionBlocked, SeverityWarning, "A disruption budget of zero nodes without a schedule blocks consolidation and drift for good."},
+	{RuleCostIncrease, SeverityError, "The projected monthly cost increase must stay within the configured thresholds."},
+}
+
+// Thresholds decide when the check fails. Zero cost thresholds are off.
+type Thresholds struct {
+	// MaxMonthlyIncrease is the largest allowed increase in dollars.
+	MaxMonthlyIncrease float64 `json:"maxMonthlyIncrease"`
+	// MaxIncreasePercent is the largest allowed increase relative to the
+	// baseline.
+	MaxIncreasePercent float64 `json:"maxIncreasePercent"`
+	// FailOn is the lowest violation severity that fails the check,
+	// "error" by default.
+	FailOn Severity `json:"failOn"`
+}
+
+type Violation struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+	// Resource is "Kind/name" when the violation concerns one resource.
+	Resource string `json:"resource,omitempty"`
+	Location
+}
+
+type Input struct {
+	Manifests *Manifests
+	// The baseline cluster, usually a snapshot taken before the change.
+	Nodes       []k8s.NodeDetails
+	Pods        []k8s.PodDetails
+	PDBs        []k8s.PDBDetails
+	NodePools   []k8s.NodePoolDetails
+	NodeClasses []k8s.NodeClassDetails
+	Region      string
+	Thresholds  Thresholds
+}
+
+type Result struct {
+	Passed     bool           `json:"passed"`
+	Cost       CostImpact     `json:"cost"`
+	NodePools  []NodePoolCost `json:"nodePools"`
+	Violations []Violation    `json:"violations"`
+	// Failures are the violations that failed the check.
+	Failures      int                `json:"failures"`
+	Unschedulable []simulator.PodRef `json:"unschedulable"`
+	Thresholds    Thresholds         `json:"thresholds"`
+	Warnings      []string           `json:"warnings"`
+}
+
+// CostImpact compares the workload packed onto the cluster's NodePools with
+// the same workload packed onto the proposed ones. Both sides are
+// simulated, so the delta reflects the config change rather than how well
+// the cluster happens to be packed today.
+type CostImpact struct {
+	CurrentMonthly  float64 `json:"currentMonthly"`
+	BaselineMonthly float64 `json:"baselineMonthly"`
+	ProposedMonthly float64 `json:"proposedMonthly"`
+	MonthlyDelta    float64 `json:"monthlyDelta"`
+	Percentage      float64 `json:"percentage"`
+}
+
+type NodePoolCost struct {
+	Name            string  `json:"name"`
+	File            string  `json:"file,omitempty"`
+	BaselineNodes   int     `json:"baselineNodes"`
+	BaselineMonthly float64 `json:"baselineMonthly"`
+	ProposedNodes   int     `json:"proposedNodes"`
+	ProposedMonthly float64 `json:"proposedMonthly"`
+}
+
+// Run checks the manifests against the baseline. The manifests are taken
+// as the complete set of NodePools, as in a GitOps repository: a NodePool
+// missing from them is one the change removes.
+func Run(input Input, catalog *pricing.Catalog) (*Result, error) {
+	if input.Manifests == nil {
+		return nil, fmt.Errorf("no manifests to check")
+	}
+	if input.Region == "" {
+		input.Region = catalog.DefaultRegion
+	}
+	if input.Thresholds.FailOn == "" {
+		input.Thresholds.FailOn = SeverityError
+	}
+	if _, ok := severityRank[input.Thresholds.FailOn]; !ok {
+		return nil, fmt.Errorf("unknown severity %q", input.Thresholds.FailOn)
+	}
+
+	result := &Result{Thresholds: input.Thresholds}
+	result.Violations = append(result.Violations, input.Manifests.Invalid...)
+	result.Violations = append(result.Violations, lint(input, catalog)...)
+
+	nodes, pods := replaceable(input.Nodes, input.Pods)
+	if len(nodes) == 0 && len(input.Nodes) > 0 {
+		result.Warnings = append(result.Warnings, "the baseline has no Karpenter-managed nodes; all nodes are treated as replaceable")
+		nodes, pods = input.Nodes, input.Pods
+	}
+
+	proposed := proposedCandidates(input.Manifests.NodePools, input.Region, catalog)
+	if len(proposed) > 0 {
+		simulate(result, input, nodes, pods, proposed, catalog)
+	}
+
+	result.Passed = true
+	for _, v := range result.Violations {
+		if result.Fails(v) {
+			result.Failures++
+			result.Passed = false
+		}
+	}
+	return result, nil
+}
+
+func simulate(result *Result, input Input, nodes []k8s.NodeDetails, pods []k8s.PodDetails, proposed []simulator.CandidateConfig, catalog *pricing.Catalog) {
+	var baseline *simulator.Result
+	if candidates := orderedCandidates(input.NodePools, input.Region, catalog); len(candidates) > 0 {
+		var err error
+		baseline, err = simulator.Simulate(simulator.Input{Nodes: nodes, Pods: pods, PDBs: input.PDBs, Candidates: candidates}, catalog)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("failed to simulate the baseline NodePools: %v", err))
+		}
+	}
+
+	after, err := simulator.Simulate(simulator.Input{Nodes: nodes, Pods: pods, PDBs: input.PDBs, Candidates: proposed}, catalog)
+	if err != nil {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("failed to simulate the proposed NodePools: %v", err))
+		return
+	}
+	result.Warnings = append(result.Warnings, after.Warnings...)
+
+	result.Cost.CurrentMonthly = after.Current.MonthlyCost
+	result.Cost.ProposedMonthly = after.Projected.MonthlyCost
+	result.Cost.BaselineMonthly = after.Current.MonthlyCost
+	if baseline != nil {
+		result.Cost.BaselineMonthly = baseline.Projected.MonthlyCost
+	} else {
+		result.Warnings = append(result.Warnings, "the baseline has no simulatable NodePools; comparing against what its nodes cost today")
+	}
+	result.Cost.MonthlyDelta = result.Cost.ProposedMonthly - result.Cost.BaselineMonthly
+	if result.Cost.BaselineMonthly > 0 {
+		result.Cost.Percentage = result.Cost.MonthlyDelta / result.Cost.BaselineMonthly * 100
+	}
+
+	result.NodePools = nodePoolCosts(input.Manifests, baseline, after)
+	result.Unschedulable = after.Unschedulable
+	result.Violations = append(result.Violations, unschedulable(baseline, after)...)
+	if v, ok := costViolation(result.Cost, input.Thresholds); ok {
+		result.Violations = append(result.Violations, v)
+	}
+}
+
+// replaceable returns the nodes Karpenter manages and the pods that run on
+// them or are still pending: the part of the cluster a NodePool change
+// reshapes. Everything else stays where it is.
+func replaceable(nodes []k8s.NodeDetails, pods []k8s.PodDetails) ([]k8s.NodeDetails, []k8s.PodDetails) {
+	managed := map[string]bool{}
+	var keptNodes []k8s.NodeDetails
+	for _, node := range nodes {
+		if node.NodePool != "" {
+			managed[node.Name] = true
+			keptNodes = append(keptNodes, node)
+		}
+	}
+
+	var keptPods []k8s.PodDetails
+	for _, pod := range pods {
+		if pod.NodeName == "" || managed[pod.NodeName] {
+			keptPods = append(keptPods, pod)
+		}
+	}
+	return keptNodes, keptPods
+}
+
+func proposedCandidates(manifests []NodePoolManifest, region string, catalog *pricing.Catalog) []simulator.CandidateConfig {
+	nodePools := make([]k8s.NodePoolDetails, 0, len(manifests))
+	for _, m := range manifests {
+		nodePools = append(nodePools, m.NodePool)
+	}
+	return orderedCandidates(nodePools, region, catalog)
+}
+
+// orderedCandidates turns NodePools into candidates in the order Karpenter
+// tries them: highest weight first, then by name. Pools that can launch
+// nothing are dropped.
+func orderedCandidates(nodePools []k8s.NodePoolDetails, region string, catalog *pricing.Catalog) []simulator.CandidateConfig {
+	sorted := append([]k8s.NodePoolDetails(nil), nodePools...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Weight != sorted[j].Weight {
+			return sorted[i].Weight > sorted[j].Weight
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	var candidates []simulator.CandidateConfig
+	for _, np := range sorted {
+		candidate := simulator.CandidateFromNodePool(np, region)
+		if len(simulator.CandidateInstanceTypes(candidate, catalog)) > 0 {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+func nodePoolCosts(manifests *Manifests, baseline, after *simulator.Result) []NodePoolCost {
+	byName := map[string]*NodePoolCost{}
+	var costs []*NodePoolCost
+	get := func(name string) *NodePoolCost {
+		if cost, ok := byName[name]; ok {
+			return cost
+		}
+		cost := &NodePoolCost{Name: name}
+		byName[name] = cost
+		costs = append(costs, cost)
+		return cost
+	}
+
+	for _, m := range manifests.NodePools {
+		get(m.NodePool.Name).File = m.File
+	}
+	if baseline != nil {
+		for _, node := range baseline.Nodes {
+			cost := get(node.NodePool)
+			cost.BaselineNodes++
+			cost.BaselineMonthly += node.HourlyCost * pricing.HoursPerMonth
+		}
+	}
+	for _, node := range after.Nodes {
+		cost := get(node.NodePool)
+		cost.ProposedNodes++
+		cost.ProposedMonthly += node.HourlyCost * pricing.HoursPerMonth
+	}
+
+	result := make([]NodePoolCost, 0, len(costs))
+	for _, cost := range costs {
+		result = append(result, *cost)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// unschedulable reports pods the proposed NodePools can't host that the
+// baseline ones could. Pods that were already stuck aren't the change's
+// fault.
+func unschedulable(baseline, after *simulator.Result) []Violation {
+	stuck := map[string]bool{}
+	if baseline != nil {
+		for _, pod := range baseline.Unschedulable {
+			stuck[pod.Namespace+"/"+pod.Name] = true
+		}
+	}
+
+	var violations []Violation
+	for _, pod := range after.Unschedulable {
+		if stuck[pod.Namespace+"/"+pod.Name] {
+			continue
+		}
+		violations = append(violations, Violation{
+			Rule:     RuleUnschedulablePods,
+			Severity: SeverityError,
+			Message:  fmt.Sprintf("pod %s/%s no longer fits on any NodePool: %s", pod.Namespace, pod.Name, pod.Reason),
+			Resource: "Pod/" + pod.Namespace + "/" + pod.Name,
+		})
+	}
+	return violations
+}
+
+func costViolation(cost CostImpact, thresholds Thresholds) (Violation, bool) {
+	var exceeded []string
+	if thresholds.MaxMonthlyIncrease > 0 && cost.MonthlyDelta > thresholds.MaxMonthlyIncrease {
+		exceeded = append(exceeded, fmt.Sprintf("more than the allowed $%.2f", thresholds.MaxMonthlyIncrease))
+	}
+	if thresholds.MaxIncreasePercent > 0 && cost.Percentage > thresholds.MaxIncreasePercent {
+		exceeded = append(exceeded, fmt.Sprintf("more than the allowed %.1f%%", thresholds.MaxIncreasePercent))
+	}
+	if len(exceeded) == 0 {
+		return Violation{}, false
+	}
+	return Violation{
+		Rule:     RuleCostIncrease,
+		Severity: SeverityError,
+		Message: fmt.Sprintf("projected monthly cost rises by $%.2f (%.1f%%), %s",
+			cost.MonthlyDelta, cost.Percentage, strings.Join(exceeded, " and ")),
+	}, true
+}
+
+// lint applies the rules that only need the manifests themselves.
+func lint(input Input, catalog *pricing.Catalog) []Violation {
+	var violations []Violation
+	manifests := input.Manifests
+
+	if len(manifests.NodePools) == 0 && len(manifests.Invalid) == 0 {
+		violations = append(violations, Violation{
+			Rule:     RuleNoNodePools,
+			Severity: SeverityError,
+			Message:  "no NodePool manifests found",
+		})
+	}
+
+	nodeClasses := map[string]bool{}
+	for _, nc := range input.NodeClasses {
+		nodeClasses[nc.Name] = true
+	}
+	definedClasses := map[string]Location{}
+	for _, m := range manifests.NodeClasses {
+		resource := kindEC2NodeClass + "/" + m.NodeClass.Name
+		if first, ok := definedClasses[m.NodeClass.Name]; ok {
+			violations = append(violations, duplicate(resource, m.Location, first))
+			continue
+		}
+		definedClasses[m.NodeClass.Name] = m.Location
+		nodeClasses[m.NodeClass.Name] = true
+	}
+
+	definedPools := map[string]Location{}
+	for _, m := range manifests.NodePools {
+		np := m.NodePool
+		resource := kindNodePool + "/" + np.Name
+		violation := func(rule string, severity Severity, format string, args ...interface{}) {
+			violations = append(violations, Violation{
+				Rule:     rule,
+				Severity: severity,
+				Message:  fmt.Sprintf("NodePool %s: ", np.Name) + fmt.Sprintf(format, args...),
+				Resource: resource,
+				Location: m.Location,
+			})
+		}
+
+		if first, ok := definedPools[np.Name]; ok {
+			violations = append(violations, duplicate(resource, m.Location, first))
+			continue
+		}
+		definedPools[np.Name] = m.Location
+
+		if np.NodeClassRef == "" {
+			violation(RuleUnknownNodeClass, SeverityError, "no nodeClassRef")
+		} else if !nodeClasses[np.NodeClassRef] {
+			violation(RuleUnknownNodeClass, SeverityError, "EC2NodeClass %q is not defined", np.NodeClassRef)
+		}
+
+		candidate := simulator.CandidateFromNodePool(np, input.Region)
+		instanceTypes := simulator.CandidateInstanceTypes(candidate, catalog)
+		if len(instanceTypes) == 0 {
+			violation(RuleNoInstanceTypes, SeverityError, "requirements match no priced instance types in %s", input.Region)
+		} else if slices.Contains(candidate.CapacityTypes, simulator.CapacityTypeSpot) && len(instanceTypes) < minSpotInstanceTypes {
+			violation(RuleSpotDiversity, SeverityWarning, "spot capacity is limited to %d instance types (%s)",
+				len(instanceTypes), strings.Join(instanceTypes, ", "))
+		}
+
+		if _, ok := np.Limits[resourceLimitCPU]; !ok {
+			violation(RuleMissingLimits, SeverityWarning, "no cpu limit")
+		}
+
+		for _, budget := range np.Budgets {
+			if budget.Nodes == "0" && budget.Schedule == "" {
+				violation(RuleDisruptionBlocked, SeverityWarning, "a disruption budget of 0 nodes has no schedule, so nodes are never disrupted")
+				break
+			}
+		}
+	}
+
+	return violations
+}
+
+func duplicate(resource string, location, first Location) Violation {
+	return Violation{
+		Rule:     RuleDuplicateResource,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf("%s is already defined in %s:%d", resource, first.File, first.Line),
+		Resource: resource,
+		Location: location,
+	}
+}
+
+var severityRank = map[Severity]int{
+	SeverityNote:    1,
+	SeverityWarning: 2,
+	SeverityError:   3,
+	SeverityNone:    4,
+}
+
+// Fails reports whether the violation fails the check: cost threshold
+// violations always do, others when they reach the failOn severity.
+func (r *Result) Fails(v Violation) bool {
+	return v.Rule == RuleCostIncrease || severityRank[v.Severity] >= severityRank[r.Thresholds.FailOn]
+}
@@ -0,0 +1,173 @@
+package check
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	kindNodePool     = "NodePool"
+	kindEC2NodeClass = "EC2NodeClass"
+
+	groupKarpenter    = "karpenter.sh"
+	groupKarpenterAWS = "karpenter.k8s.aws"
+)
+
+// File is a manifest file, named relative to the manifest directory so
+// reports point at paths in the repository.
+type File struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// Location is where a resource or violation was found.
+type Location struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+}
+
+type NodePoolManifest struct {
+	Location
+	NodePool k8s.NodePoolDetails
+}
+
+type NodeClassManifest struct {
+	Location
+	NodeClass k8s.NodeClassDetails
+}
+
+// Manifests are the Karpenter resources found in a set of files. Documents
+// of other kinds are ignored; documents that fail to parse are kept as
+// violations so the check reports them instead of stopping at the first.
+type Manifests struct {
+	NodePools   []NodePoolManifest
+	NodeClasses []NodeClassManifest
+	Invalid     []Violation
+}
+
+// LoadDir reads every .yaml, .yml and .json file below dir.
+func LoadDir(dir string) (*Manifests, error) {
+	var files []File
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			name = path
+		}
+		files = append(files, File{Name: filepath.ToSlash(name), Content: string(data)})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifests: %w", err)
+	}
+	return Parse(files), nil
+}
+
+// Parse extracts NodePools and EC2NodeClasses from multi-document YAML or
+// JSON files.
+func Parse(files []File) *Manifests {
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	m := &Manifests{}
+	for _, file := range files {
+		decoder := yaml.NewDecoder(bytes.NewReader([]byte(file.Content)))
+		for {
+			var doc yaml.Node
+			err := decoder.Decode(&doc)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				m.Invalid = append(m.Invalid, invalidManifest(Location{File: file.Name}, err))
+				break
+			}
+			m.add(file.Name, &doc)
+		}
+	}
+	return m
+}
+
+func (m *Manifests) add(file string, doc *yaml.Node) {
+	location := Location{File: file, Line: doc.Line}
+	if len(doc.Content) > 0 {
+		location.Line = doc.Content[0].Line
+	}
+
+	data, err := yaml.Marshal(doc)
+	if err != nil {
+		m.Invalid = append(m.Invalid, invalidManifest(location, err))
+		return
+	}
+	data, err = sigsyaml.YAMLToJSON(data)
+	if err != nil {
+		m.Invalid = append(m.Invalid, invalidManifest(location, err))
+		return
+	}
+	if string(data) == "null" {
+		return
+	}
+
+	var object unstructured.Unstructured
+	if err := object.UnmarshalJSON(data); err != nil {
+		// Documents without apiVersion and kind aren't Kubernetes objects,
+		// e.g. Helm values or kustomize patches; they aren't ours to check.
+		return
+	}
+
+	gvk := object.GroupVersionKind()
+	switch {
+	case gvk.Group == groupKarpenter && gvk.Kind == kindNodePool:
+		np, err := k8s.DecodeNodePool(object.Object)
+		if err != nil {
+			m.Invalid = append(m.Invalid, invalidManifest(location, err))
+			return
+		}
+		m.NodePools = append(m.NodePools, NodePoolManifest{Location: location, NodePool: np})
+	case gvk.Group == groupKarpenterAWS && gvk.Kind == kindEC2NodeClass:
+		nc, err := k8s.DecodeNodeClass(object.Object)
+		if err != nil {
+			m.Invalid = append(m.Invalid, invalidManifest(location, err))
+			return
+		}
+		m.NodeClasses = append(m.NodeClasses, NodeClassManifest{Location: location, NodeClass: nc})
+	}
+}
+
+func invalidManifest(location Location, err error) Violation {
+	return Violation{
+		Rule:     RuleInvalidManifest,
+		Severity: SeverityError,
+		Message:  err.Error(),
+		Location: location,
+	}
+}
@@ -0,0 +1,254 @@
+package check
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	FormatJUnit    = "junit"
+	FormatSARIF    = "sarif"
+	FormatMarkdown = "markdown"
+
+	toolName = "karpops-wiz"
+	toolURI  = "https://github.com/edsf-foundation/karp-ops-wiz"
+)
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit reports one test case per rule. A rule fails when any of its
+// violations fails the check; violations below the failOn severity are
+// listed as output of a passing case.
+func WriteJUnit(w io.Writer, result *Result) error {
+	suite := junitSuite{Name: toolName + " check"}
+	for _, rule := range Rules {
+		tc := junitCase{Name: rule.ID, ClassName: toolName + ".check"}
+		var failed, passed []string
+		for _, v := range result.Violations {
+			if v.Rule != rule.ID {
+				continue
+			}
+			line := describe(v)
+			if result.Fails(v) {
+				failed = append(failed, line)
+			} else {
+				passed = append(passed, line)
+			}
+		}
+		if len(failed) > 0 {
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d violation(s): %s", len(failed), rule.Description),
+				Type:    rule.ID,
+				Text:    strings.Join(failed, "\n"),
+			}
+			suite.Failures++
+		}
+		if len(passed) > 0 {
+			tc.SystemOut = strings.Join(passed, "\n")
+		}
+		suite.Cases = append(suite.Cases, tc)
+		suite.Tests++
+	}
+
+	doc := junitSuites{
+		Name:     toolName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitSuite{suite},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string       `json:"id"`
+	ShortDescription     sarifMessage `json:"shortDescription"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation struct {
+		ArtifactLocation struct {
+			URI string `json:"uri"`
+		} `json:"artifactLocation"`
+		Region *sarifRegion `json:"region,omitempty"`
+	} `json:"physicalLocation"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// WriteSARIF writes the violations as a SARIF 2.1.0 log, with locations
+// relative to the manifest directory, for code scanning annotations.
+func WriteSARIF(w io.Writer, result *Result) error {
+	driver := sarifDriver{Name: toolName, InformationURI: toolURI}
+	for _, rule := range Rules {
+		r := sarifRule{ID: rule.ID, ShortDescription: sarifMessage{Text: rule.Description}}
+		r.DefaultConfiguration.Level = string(rule.Severity)
+		driver.Rules = append(driver.Rules, r)
+	}
+
+	results := []sarifResult{}
+	for _, v := range result.Violations {
+		r := sarifResult{RuleID: v.Rule, Level: string(v.Severity), Message: sarifMessage{Text: v.Message}}
+		if v.File != "" {
+			var location sarifLocation
+			location.PhysicalLocation.ArtifactLocation.URI = v.File
+			if v.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: v.Line}
+			}
+			r.Locations = append(r.Locations, location)
+		}
+		results = append(results, r)
+	}
+
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{{Tool: sarifTool{Driver: driver}, Results: results}},
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(log)
+}
+
+// WriteMarkdown renders the result as a pull request comment.
+func WriteMarkdown(w io.Writer, result *Result) error {
+	var b strings.Builder
+
+	status := "✅ Karpenter config check passed"
+	if !result.Passed {
+		status = fmt.Sprintf("❌ Karpenter config check failed (%d failing violation(s))", result.Failures)
+	}
+	fmt.Fprintf(&b, "### %s\n\n", status)
+
+	cost := result.Cost
+	fmt.Fprintf(&b, "**Projected monthly cost change: %s (%+.1f%%)**\n\n", signedDollars(cost.MonthlyDelta), cost.Percentage)
+	b.WriteString("| | Monthly cost |\n|---|---:|\n")
+	fmt.Fprintf(&b, "| Current cluster | $%.2f |\n", cost.CurrentMonthly)
+	fmt.Fprintf(&b, "| Baseline NodePools | $%.2f |\n", cost.BaselineMonthly)
+	fmt.Fprintf(&b, "| Proposed NodePools | $%.2f |\n\n", cost.ProposedMonthly)
+
+	if len(result.NodePools) > 0 {
+		b.WriteString("| NodePool | Nodes | Monthly cost | Change |\n|---|---:|---:|---:|\n")
+		for _, np := range result.NodePools {
+			name := np.Name
+			if name == "" {
+				name = "_unmanaged_"
+			}
+			fmt.Fprintf(&b, "| %s | %d → %d | $%.2f | %s |\n", name, np.BaselineNodes, np.ProposedNodes,
+				np.ProposedMonthly, signedDollars(np.ProposedMonthly-np.BaselineMonthly))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(result.Violations) > 0 {
+		b.WriteString("| Severity | Rule | Finding |\n|---|---|---|\n")
+		for _, v := range result.Violations {
+			fmt.Fprintf(&b, "| %s | `%s` | %s |\n", v.Severity, v.Rule, markdownEscape(describe(v)))
+		}
+		b.WriteString("\n")
+	}
+
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(&b, "> %s\n", markdownEscape(warning))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// describe is a violation's message prefixed with its location.
+func describe(v Violation) string {
+	switch {
+	case v.File != "" && v.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", v.File, v.Line, v.Message)
+	case v.File != "":
+		return fmt.Sprintf("%s: %s", v.File, v.Message)
+	default:
+		return v.Message
+	}
+}
+
+func signedDollars(amount float64) string {
+	if amount < 0 {
+		return fmt.Sprintf("-$%.2f", -amount)
+	}
+	return fmt.Sprintf("+$%.2f", amount)
+}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
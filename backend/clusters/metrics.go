@@ -0,0 +1,69 @@
+package clusters
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/metrics"
+)
+
+// NameOfHost returns the cluster whose API server is at host, given as
+// host:port like client-go reports it. Hosts of no registered cluster,
+// such as those of clients made before registration, are returned as
+// they are.
+func (r *Registry) NameOfHost(host string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for name, e := range r.clusters {
+		server := e.info.Server
+		if server == "" {
+			continue
+		}
+		if !strings.Contains(server, "://") {
+			server = "https://" + server
+		}
+		if u, err := url.Parse(server); err == nil && u.Host == host {
+			return name
+		}
+	}
+	return host
+}
+
+// CollectMetrics reports how old the answers cached clusters serve are.
+// Clusters that aren't cached are read on every request.
+func (r *Registry) CollectMetrics(context.Context) []metrics.Family {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	age := metrics.Gauge("karpops_cluster_data_age_seconds",
+		"Age of the cluster data the server answers from, by cluster and resource, while the cluster cache is enabled.")
+	for _, name := range sortedNames(r.clusters) {
+		cached, ok := r.clusters[name].source.(*k8s.CachedClient)
+		if !ok {
+			continue
+		}
+		fetched := cached.FetchedAt()
+		for _, resource := range sortedNames(fetched) {
+			age.Samples = append(age.Samples, metrics.Sample{
+				Labels: map[string]string{"cluster": name, "resource": resource},
+				Value:  now.Sub(fetched[resource]).Seconds(),
+			})
+		}
+	}
+	return []metrics.Family{age}
+}
+
+func sortedNames[T any](m map[string]T) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,166 @@
+// Package clusters keeps the set of clusters one KarpOps-Wiz instance
+// analyzes: the cluster it runs in or was pointed at, plus any number of
+// remote clusters reached through kubeconfig contexts or ServiceAccount
+// token Secrets.
+package clusters
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+)
+
+const (
+	OriginLocal    = "local"
+	OriginSnapshot = "snapshot"
+	OriginContext  = "kubeconfig"
+	OriginSecret   = "secret"
+
+	// AllContexts registers every context of the kubeconfig.
+	AllContexts = "*"
+)
+
+// Cluster describes a registered cluster.
+type Cluster struct {
+	Name    string `json:"name"`
+	Origin  string `json:"origin"`
+	Server  string `json:"server,omitempty"`
+	Default bool   `json:"default"`
+}
+
+type entry struct {
+	info   Cluster
+	source k8s.ClusterSource
+}
+
+// Registry maps cluster names to their sources. Requests that name no
+// cluster get the default one.
+type Registry struct {
+	mu          sync.RWMutex
+	clusters    map[string]entry
+	defaultName string
+}
+
+// New creates a registry whose default cluster is source.
+func New(info Cluster, source k8s.ClusterSource) *Registry {
+	info.Default = true
+	return &Registry{
+		clusters:    map[string]entry{info.Name: {info: info, source: source}},
+		defaultName: info.Name,
+	}
+}
+
+// Add registers another cluster. Names must be unique.
+func (r *Registry) Add(info Cluster, source k8s.ClusterSource) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.clusters[info.Name]; ok {
+		return fmt.Errorf("cluster %q is already registered", info.Name)
+	}
+	info.Default = false
+	r.clusters[info.Name] = entry{info: info, source: source}
+	return nil
+}
+
+// Get returns the named cluster, or the default one for an empty name.
+func (r *Registry) Get(name string) (k8s.ClusterSource, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if name == "" {
+		name = r.defaultName
+	}
+	e, ok := r.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("cluster %q not found", name)
+	}
+	return e.source, nil
+}
+
+// DefaultName is the name of the default cluster.
+func (r *Registry) DefaultName() string {
+	return r.defaultName
+}
+
+// List returns the registered clusters, the default one first.
+func (r *Registry) List() []Cluster {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	clusters := make([]Cluster, 0, len(r.clusters))
+	for _, e := range r.clusters {
+		clusters = append(clusters, e.info)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Default != clusters[j].Default {
+			return clusters[i].Default
+		}
+		return clusters[i].Name < clusters[j].Name
+	})
+	return clusters
+}
+
+// Cache makes the live clusters registered so far reuse what they report
+// for ttl. Snapshots are already in memory and stay as they are.
+func (r *Registry) Cache(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, e := range r.clusters {
+		if client, ok := e.source.(*k8s.K8sClient); ok {
+			e.source = k8s.NewCachedClient(client, ttl)
+			r.clusters[name] = e
+		}
+	}
+}
+
+// AddContexts registers kubeconfig contexts under their own names. The
+// context already registered as the default is skipped.
+func (r *Registry) AddContexts(kubeconfig string, contexts []string) error {
+	if len(contexts) == 1 && contexts[0] == AllContexts {
+		all, _, err := k8s.KubeconfigContexts(kubeconfig)
+		if err != nil {
+			return err
+		}
+		contexts = all
+	}
+
+	for _, name := range contexts {
+		if name == r.defaultName {
+			continue
+		}
+		client, err := k8s.NewK8sClientForContext(kubeconfig, name)
+		if err != nil {
+			return fmt.Errorf("context %s: %w", name, err)
+		}
+		if err := r.Add(Cluster{Name: name, Origin: OriginContext, Server: client.Host()}, client); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddSecrets registers the clusters described by the cluster Secrets in
+// namespace, read through client.
+func (r *Registry) AddSecrets(ctx context.Context, client *k8s.K8sClient, namespace string) error {
+	credentials, err := client.GetClusterCredentials(ctx, namespace)
+	if err != nil {
+		return err
+	}
+
+	for _, creds := range credentials {
+		remote, err := k8s.NewK8sClientForCredentials(creds)
+		if err != nil {
+			return fmt.Errorf("cluster %s: %w", creds.Name, err)
+		}
+		if err := r.Add(Cluster{Name: creds.Name, Origin: OriginSecret, Server: creds.Server}, remote); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/check"
+	"github.com/spf13/cobra"
+)
+
+func newCheckCommand(opts *globalOptions) *cobra.Command {
+	var manifestDir, failOn string
+	var thresholds check.Thresholds
+	reports := map[string]*string{
+		check.FormatJUnit:    new(string),
+		check.FormatSARIF:    new(string),
+		check.FormatMarkdown: new(string),
+	}
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check proposed NodePool and EC2NodeClass manifests for cost impact and risk",
+		Long: `Check proposed NodePool and EC2NodeClass manifests for cost impact and risk.
+
+The manifests in --manifests are taken as the complete set of NodePools and
+compared with the baseline cluster, usually a --snapshot captured before the
+change. The command exits non-zero when a violation reaches --fail-on or the
+projected monthly cost increase passes a threshold.
+
+Besides -o, reports can be written to files for CI, for example:
+
+  karpops-wiz check --snapshot baseline.json.gz --manifests karpenter/ \
+    --max-cost-increase-percent 10 --junit check.xml --markdown comment.md`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifests, err := check.LoadDir(manifestDir)
+			if err != nil {
+				return err
+			}
+			thresholds.FailOn = check.Severity(failOn)
+
+			service, source, err := opts.service()
+			if err != nil {
+				return err
+			}
+
+			result, err := service.Check(cmd.Context(), source, manifests, opts.region, thresholds)
+			if err != nil {
+				return err
+			}
+
+			for format, path := range reports {
+				if *path == "" {
+					continue
+				}
+				if err := writeCheckReportFile(*path, format, result); err != nil {
+					return err
+				}
+			}
+
+			switch opts.output {
+			case check.FormatJUnit, check.FormatSARIF, check.FormatMarkdown:
+				err = writeCheckReport(os.Stdout, opts.output, result)
+			default:
+				err = printResult(os.Stdout, opts.output, result, func(w *tabwriter.Writer) {
+					printCheckTable(w, result)
+				})
+			}
+			if err != nil {
+				return err
+			}
+
+			if !result.Passed {
+				return fmt.Errorf("check failed with %d violation(s)", result.Failures)
+			}
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&manifestDir, "manifests", "", "directory of NodePool and EC2NodeClass manifests")
+	flags.Float64Var(&thresholds.MaxMonthlyIncrease, "max-cost-increase", 0, "fail when the monthly cost rises by more than this many dollars")
+	flags.Float64Var(&thresholds.MaxIncreasePercent, "max-cost-increase-percent", 0, "fail when the monthly cost rises by more than this percentage")
+	flags.StringVar(&failOn, "fail-on", string(check.SeverityError), "lowest violation severity that fails the check: error, warning, note or none")
+	flags.StringVar(reports[check.FormatJUnit], "junit", "", "also write a JUnit XML report to this file")
+	flags.StringVar(reports[check.FormatSARIF], "sarif", "", "also write a SARIF report to this file")
+	flags.StringVar(reports[check.FormatMarkdown], "markdown", "", "also write a Markdown summary, e.g. for a pull request comment, to this file")
+	cmd.MarkFlagRequired("manifests")
+	return cmd
+}
+
+func writeCheckReport(w io.Writer, format string, result *check.Result) error {
+	switch format {
+	case check.FormatJUnit:
+		return check.WriteJUnit(w, result)
+	case check.FormatSARIF:
+		return check.WriteSARIF(w, result)
+	default:
+		return check.WriteMarkdown(w, result)
+	}
+}
+
+func writeCheckReportFile(path, format string, result *check.Result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to write %s report: %w", format, err)
+	}
+	if err := writeCheckReport(f, format, result); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s report: %w", format, err)
+	}
+	return f.Close()
+}
+
+func printCheckTable(w *tabwriter.Writer, result *check.Result) {
+	status := "PASSED"
+	if !result.Passed {
+		status = fmt.Sprintf("FAILED (%d violations)", result.Failures)
+	}
+	fmt.Fprintf(w, "Check %s\n\n", status)
+
+	fmt.Fprintln(w, "\tMONTHLY")
+	fmt.Fprintf(w, "Current\t%s\n", dollars(result.Cost.CurrentMonthly))
+	fmt.Fprintf(w, "Baseline\t%s\n", dollars(result.Cost.BaselineMonthly))
+	fmt.Fprintf(w, "Proposed\t%s\n", dollars(result.Cost.ProposedMonthly))
+	fmt.Fprintf(w, "Change\t%s (%+.1f%%)\n", signedDollars(result.Cost.MonthlyDelta), result.Cost.Percentage)
+	fmt.Fprintln(w)
+
+	if len(result.NodePools) > 0 {
+		fmt.Fprintln(w, "NODEPOOL\tBASELINE NODES\tPROPOSED NODES\tBASELINE\tPROPOSED")
+		for _, np := range result.NodePools {
+			name := np.Name
+			if name == "" {
+				name = "(unmanaged)"
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", name, np.BaselineNodes, np.ProposedNodes, dollars(np.BaselineMonthly), dollars(np.ProposedMonthly))
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(result.Violations) > 0 {
+		fmt.Fprintln(w, "SEVERITY\tRULE\tLOCATION\tMESSAGE")
+		for _, v := range result.Violations {
+			location := v.File
+			if v.Line > 0 {
+				location = fmt.Sprintf("%s:%d", v.File, v.Line)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", v.Severity, v.Rule, location, v.Message)
+		}
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+}
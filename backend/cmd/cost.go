@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/wizard"
+	"github.com/spf13/cobra"
+)
+
+func newCostCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "cost",
+		Short: "Show the cluster's current and potential cost",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			service, source, err := opts.service()
+			if err != nil {
+				return err
+			}
+
+			costs, err := service.ClusterCost(cmd.Context(), source)
+			if err != nil {
+				return err
+			}
+
+			return printResult(os.Stdout, opts.output, costs, func(w *tabwriter.Writer) {
+				printCostTable(w, costs)
+			})
+		},
+	}
+}
+
+func printCostTable(w *tabwriter.Writer, costs *wizard.CostAnalysis) {
+	fmt.Fprintln(w, "\tTOTAL\tON-DEMAND\tSPOT")
+	for _, row := range []struct {
+		name   string
+		values wizard.CostBreakdown
+	}{
+		{"Current", costs.Current},
+		{"Potential", costs.Potential},
+	} {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", row.name, dollars(row.values.Total), dollars(row.values.OnDemand), dollars(row.values.Spot))
+	}
+	fmt.Fprintf(w, "Savings\t%s (%.1f%%)\t\t\n", dollars(costs.Savings.Amount), costs.Savings.Percentage)
+	fmt.Fprintln(w)
+	for _, recommendation := range costs.Recommendations {
+		fmt.Fprintf(w, "- %s\n", recommendation)
+	}
+	for _, warning := range costs.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/wizard"
+	"github.com/spf13/cobra"
+)
+
+func newFleetCommand(opts *globalOptions) *cobra.Command {
+	var contexts []string
+
+	cmd := &cobra.Command{
+		Use:   "fleet",
+		Short: "Show cost and potential savings across several clusters",
+		Long: `Show cost and potential savings across several clusters.
+
+The selected cluster is always included; --clusters adds kubeconfig
+contexts, or every context with --clusters '*'.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			catalog, err := opts.catalog()
+			if err != nil {
+				return err
+			}
+			registry, _, err := opts.clusters()
+			if err != nil {
+				return err
+			}
+			if err := registry.AddContexts(opts.kubeconfig, contexts); err != nil {
+				return err
+			}
+
+			fleet := wizard.NewService(registry, catalog, nil, nil).FleetCost(cmd.Context())
+			return printResult(os.Stdout, opts.output, fleet, func(w *tabwriter.Writer) {
+				printFleetTable(w, fleet)
+			})
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&contexts, "clusters", nil, "kubeconfig contexts to include, or * for all of them")
+	return cmd
+}
+
+func printFleetTable(w *tabwriter.Writer, fleet *wizard.FleetCost) {
+	fmt.Fprintln(w, "CLUSTER\tNODES\tSPOT\tMONTHLY\tPOTENTIAL SAVINGS")
+	for _, cluster := range fleet.Clusters {
+		if cluster.Error != "" {
+			fmt.Fprintf(w, "%s\t\t\t\terror: %s\n", cluster.Cluster, cluster.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", cluster.Cluster, cluster.Nodes, cluster.SpotNodes, dollars(cluster.MonthlyCost), dollars(cluster.PotentialSavings))
+	}
+	total := fleet.Total
+	fmt.Fprintf(w, "TOTAL\t%d\t%d\t%s\t%s (%.1f%%)\n", total.Nodes, total.SpotNodes, dollars(total.MonthlyCost), dollars(total.PotentialSavings), total.Percentage)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/wizard"
+	"github.com/spf13/cobra"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+func newGenerateCommand(opts *globalOptions) *cobra.Command {
+	var req wizard.ConfigRequest
+	var features []string
+
+	cmd := &cobra.Command{
+		Use:   "generate",
+		Short: "Generate a Karpenter NodePool and EC2NodeClass for a preset",
+		Example: `  karpops-wiz generate --preset cost-optimized --region us-east-1 --zones us-east-1a,us-east-1b > nodepool.yaml
+  kubectl apply -f nodepool.yaml`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			catalog, err := opts.catalog()
+			if err != nil {
+				return err
+			}
+			req.Region = catalog.DefaultRegion
+			req.Features = map[string]bool{}
+			for _, feature := range features {
+				req.Features[feature] = true
+			}
+
+			config, err := wizard.NewService(nil, catalog, nil, nil).GenerateConfig(req)
+			if err != nil {
+				return err
+			}
+
+			if opts.output == outputJSON {
+				return printResult(os.Stdout, outputJSON, config, nil)
+			}
+			if opts.output != "" && opts.output != outputYAML {
+				return fmt.Errorf("generate supports the yaml and json outputs")
+			}
+			return writeManifests(config)
+		},
+	}
+
+	cmd.Flags().StringVar(&req.Preset, "preset", "", "preset: cost-optimized, performance or balanced")
+	cmd.Flags().StringSliceVar(&req.Zones, "zones", nil, "availability zones the NodePool may launch into")
+	cmd.Flags().StringSliceVar(&features, "features", nil, "features to enable, e.g. consolidation")
+	_ = cmd.MarkFlagRequired("preset")
+	return cmd
+}
+
+// writeManifests prints the NodePool and EC2NodeClass as one multi-document
+// YAML stream, ready for kubectl apply.
+func writeManifests(config *wizard.GeneratedConfig) error {
+	var buf bytes.Buffer
+	encoder := yamlv3.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config.Provisioner); err != nil {
+		return err
+	}
+	if err := encoder.Encode(config.NodeTemplate); err != nil {
+		return err
+	}
+	if err := encoder.Close(); err != nil {
+		return err
+	}
+	_, err := os.Stdout.Write(buf.Bytes())
+	return err
+}
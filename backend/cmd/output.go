@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+)
+
+// printResult writes v as JSON or YAML, or calls table for the human
+// readable form. YAML keys follow the JSON field names, like the API.
+func printResult(w io.Writer, format string, v interface{}, table func(*tabwriter.Writer)) error {
+	switch format {
+	case outputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case outputYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case outputTable, "":
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		table(tw)
+		return tw.Flush()
+	default:
+		return fmt.Errorf("unsupported output format %q (use table, json or yaml)", format)
+	}
+}
+
+func dollars(amount float64) string {
+	return fmt.Sprintf("$%.2f", amount)
+}
+
+// signedDollars shows the sign of a cost change either way.
+func signedDollars(amount float64) string {
+	if amount < 0 {
+		return "-" + dollars(-amount)
+	}
+	return "+" + dollars(amount)
+}
+
+// hourly keeps the precision hourly instance prices are quoted in.
+func hourly(amount float64) string {
+	return fmt.Sprintf("$%.4f", amount)
+}
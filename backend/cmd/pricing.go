@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/wizard"
+	"github.com/spf13/cobra"
+)
+
+func newPricingCommand(opts *globalOptions) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pricing",
+		Short: "Query the pricing catalog",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:     "lookup INSTANCE_TYPE...",
+		Short:   "Show the on-demand and spot prices of instance types",
+		Example: "  karpops-wiz pricing lookup m5.large c6g.large --region us-east-2",
+		Args:    cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			catalog, err := opts.catalog()
+			if err != nil {
+				return err
+			}
+
+			service := wizard.NewService(nil, catalog, nil, nil)
+			prices := make([]*wizard.Pricing, 0, len(args))
+			for _, name := range args {
+				p, err := service.Pricing(catalog.DefaultRegion, name)
+				if err != nil {
+					return err
+				}
+				prices = append(prices, p)
+			}
+
+			return printResult(os.Stdout, opts.output, prices, func(w *tabwriter.Writer) {
+				fmt.Fprintln(w, "INSTANCE TYPE\tVCPU\tMEMORY\tARCH\tON-DEMAND/HR\tSPOT/HR\tON-DEMAND/MO\tSPOT/MO")
+				for _, p := range prices {
+					fmt.Fprintf(w, "%s\t%d\t%dGiB\t%s\t%s\t%s\t%s\t%s\n", p.InstanceType, p.VCPU, p.MemoryGiB, p.Architecture,
+						hourly(p.OnDemand.Price), hourly(p.Spot.Price.Price), dollars(p.Monthly.OnDemand), dollars(p.Monthly.Spot))
+				}
+			})
+		},
+	})
+	return cmd
+}
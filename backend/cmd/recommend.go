@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/recommender"
+	"github.com/spf13/cobra"
+)
+
+func newRecommendCommand(opts *globalOptions) *cobra.Command {
+	return &cobra.Command{
+		Use:   "recommend",
+		Short: "List rebalancing recommendations for the cluster",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			service, source, err := opts.service()
+			if err != nil {
+				return err
+			}
+
+			state, err := service.ClusterState(cmd.Context(), source)
+			if err != nil {
+				return err
+			}
+			report := service.Recommend(state, opts.region)
+
+			return printResult(os.Stdout, opts.output, report, func(w *tabwriter.Writer) {
+				printRecommendationTable(w, report)
+			})
+		},
+	}
+}
+
+func printRecommendationTable(w *tabwriter.Writer, report *recommender.Report) {
+	fmt.Fprintln(w, "KIND\tSAVINGS/MO\tRISK\tCONFIDENCE\tNODES\tACTION")
+	for _, rec := range report.Recommendations {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.0f%%\t%d\t%s\n", rec.Kind, dollars(rec.MonthlySavings), rec.Risk, rec.Confidence*100, len(rec.Nodes), rec.Action)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Estimated savings: %s/month (%.1f%% of %s)\n", dollars(report.Summary.MonthlySavings), report.Summary.Percentage, dollars(report.Summary.CurrentMonthlyCost))
+	for _, warning := range report.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+}
@@ -0,0 +1,136 @@
+// Package cmd implements the karpops-wiz command line. Every subcommand
+// goes through wizard.Service, so the CLI and the HTTP API give the same
+// answers.
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/clusters"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/snapshot"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/wizard"
+	"github.com/spf13/cobra"
+)
+
+// inClusterName names the cluster KarpOps-Wiz runs in.
+const inClusterName = "in-cluster"
+
+// globalOptions are the flags shared by every subcommand.
+type globalOptions struct {
+	kubeconfig  string
+	kubecontext string
+	snapshot    string
+	pricingData string
+	region      string
+	output      string
+}
+
+func newRootCommand() *cobra.Command {
+	opts := &globalOptions{}
+
+	root := &cobra.Command{
+		Use:   "karpops-wiz",
+		Short: "Karpenter configuration wizard and Kubernetes cost optimizer",
+		// Without a subcommand the binary serves the API, as it always has.
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd.Flags(), opts, defaultServeOptions())
+		},
+		SilenceUsage: true,
+	}
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.kubeconfig, "kubeconfig", "", "path to the kubeconfig file (defaults to in-cluster config, then $KUBECONFIG or ~/.kube/config)")
+	flags.StringVar(&opts.kubecontext, "context", "", "kubeconfig context to use")
+	flags.StringVar(&opts.snapshot, "snapshot", "", "analyze a snapshot file instead of a live cluster")
+	flags.StringVar(&opts.pricingData, "pricing-data", os.Getenv("PRICING_DATA_PATH"), "path to the pricing catalog")
+	flags.StringVar(&opts.region, "region", os.Getenv("DEFAULT_REGION"), "AWS region used for pricing")
+	flags.StringVarP(&opts.output, "output", "o", "", "output format: table, json or yaml (check also takes junit, sarif or markdown)")
+
+	root.AddCommand(
+		newServeCommand(opts),
+		newGenerateCommand(opts),
+		newCostCommand(opts),
+		newRecommendCommand(opts),
+		newSimulateCommand(opts),
+		newPricingCommand(opts),
+		newCheckCommand(opts),
+		newFleetCommand(opts),
+	)
+	return root
+}
+
+// Execute runs the command line and exits non-zero on failure.
+func Execute() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+// catalog loads the pricing catalog, applying the --region override.
+func (o *globalOptions) catalog() (*pricing.Catalog, error) {
+	catalog, err := pricing.Load(o.pricingData)
+	if err != nil {
+		return nil, err
+	}
+	if o.region != "" {
+		catalog.DefaultRegion = o.region
+	}
+	return catalog, nil
+}
+
+// source returns the snapshot named by --snapshot, or a client for the
+// cluster selected by --kubeconfig and --context.
+func (o *globalOptions) source() (k8s.ClusterSource, error) {
+	if o.snapshot != "" {
+		return snapshot.Load(o.snapshot)
+	}
+	client, err := k8s.NewK8sClientForContext(o.kubeconfig, o.kubecontext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize Kubernetes client: %w", err)
+	}
+	return client, nil
+}
+
+// clusters registers the selected cluster or snapshot as the default
+// cluster, named after its kubeconfig context where there is one.
+func (o *globalOptions) clusters() (*clusters.Registry, k8s.ClusterSource, error) {
+	source, err := o.source()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info := clusters.Cluster{Name: o.kubecontext, Origin: clusters.OriginContext}
+	switch {
+	case o.snapshot != "":
+		info = clusters.Cluster{Name: filepath.Base(o.snapshot), Origin: clusters.OriginSnapshot}
+	case o.kubecontext == "" && o.kubeconfig == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "":
+		info = clusters.Cluster{Name: inClusterName, Origin: clusters.OriginLocal}
+	case o.kubecontext == "":
+		_, current, err := k8s.KubeconfigContexts(o.kubeconfig)
+		if err != nil {
+			return nil, nil, err
+		}
+		info.Name = current
+	}
+	if client, ok := source.(*k8s.K8sClient); ok {
+		info.Server = client.Host()
+	}
+	return clusters.New(info, source), source, nil
+}
+
+// service builds a wizard.Service over the selected cluster or snapshot.
+func (o *globalOptions) service() (*wizard.Service, k8s.ClusterSource, error) {
+	catalog, err := o.catalog()
+	if err != nil {
+		return nil, nil, err
+	}
+	registry, source, err := o.clusters()
+	if err != nil {
+		return nil, nil, err
+	}
+	return wizard.NewService(registry, catalog, nil, nil), source, nil
+}
@@ -0,0 +1,596 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/api"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/audit"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/auth"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/authz"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/budget"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/clusters"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/config"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/export"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/features"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/health"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/logging"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/metrics"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/registry"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/wizard"
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// serveOptions are the flags of serve, which the root command also runs
+// with their defaults. Flags given on the command line override the
+// config file and the environment.
+type serveOptions struct {
+	// configPath is the config file; empty runs on the defaults and the
+	// environment.
+	configPath string
+
+	port string
+	// contexts are extra kubeconfig contexts to register as clusters.
+	contexts []string
+	// secretNamespace holds the cluster Secrets of remote clusters.
+	secretNamespace string
+	// historyPath is the history database; empty disables history.
+	historyPath            string
+	historyInterval        config.Duration
+	historyRetention       config.Duration
+	historyDownsampleAfter config.Duration
+	// historyLabels are pod label keys to break recorded cost down by.
+	historyLabels []string
+	// budgetsPath is the budgets file; empty disables budget alerts.
+	budgetsPath string
+	// authPath is the authentication file; empty leaves the API open.
+	authPath string
+	// authzPath is the authorization policy; empty gives every
+	// authenticated caller full access.
+	authzPath string
+	// auditPath is the audit database; empty disables the audit log.
+	auditPath string
+}
+
+func newServeCommand(opts *globalOptions) *cobra.Command {
+	serveOpts := defaultServeOptions()
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve the API and the web UI",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd.Flags(), opts, serveOpts)
+		},
+	}
+	cmd.Flags().StringVar(&serveOpts.configPath, "config", serveOpts.configPath, "server config file, reloaded when it changes; environment variables and flags override it")
+	cmd.Flags().StringVar(&serveOpts.port, "port", serveOpts.port, "port to listen on")
+	cmd.Flags().StringSliceVar(&serveOpts.contexts, "clusters", serveOpts.contexts, "additional kubeconfig contexts to serve, or * for all of them")
+	cmd.Flags().StringVar(&serveOpts.secretNamespace, "cluster-secrets-namespace", serveOpts.secretNamespace,
+		"namespace of Secrets labelled "+k8s.ClusterSecretLabel+"=true that describe remote clusters")
+	cmd.Flags().StringVar(&serveOpts.historyPath, "history-path", serveOpts.historyPath, "file to record cost and utilization history in; empty disables history")
+	cmd.Flags().Var(&serveOpts.historyInterval, "history-interval", "how often the history collector samples every cluster")
+	cmd.Flags().Var(&serveOpts.historyRetention, "history-retention", "how long history is kept, e.g. 90d")
+	cmd.Flags().Var(&serveOpts.historyDownsampleAfter, "history-downsample-after", "age after which samples are averaged to hourly ones")
+	cmd.Flags().StringSliceVar(&serveOpts.historyLabels, "history-labels", serveOpts.historyLabels, "pod label keys to record cost by, e.g. team")
+	cmd.Flags().StringVar(&serveOpts.budgetsPath, "budgets", serveOpts.budgetsPath, "budgets and notifiers file to evaluate and alert on; needs --history-path")
+	cmd.Flags().StringVar(&serveOpts.authPath, "auth-config", serveOpts.authPath, "file of the API tokens, OIDC issuer and TokenReview settings that authenticate callers; empty leaves the API open")
+	cmd.Flags().StringVar(&serveOpts.authzPath, "authz-config", serveOpts.authzPath, "policy of the roles and namespaces callers are granted; needs --auth-config")
+	cmd.Flags().StringVar(&serveOpts.auditPath, "audit-path", serveOpts.auditPath, "file to record generate, check and simulation calls in; empty disables the audit log")
+	return cmd
+}
+
+// defaultServeOptions shows the defaults of the config in --help.
+func defaultServeOptions() serveOptions {
+	defaults := config.Default()
+	return serveOptions{
+		configPath:             os.Getenv("CONFIG_FILE"),
+		port:                   defaults.Server.Port,
+		historyInterval:        defaults.History.Interval,
+		historyRetention:       defaults.History.Retention,
+		historyDownsampleAfter: defaults.History.DownsampleAfter,
+	}
+}
+
+// override sets the flags given in flags over the config.
+func (o *serveOptions) override(flags *pflag.FlagSet, opts *globalOptions) func(*config.Config) {
+	return func(c *config.Config) {
+		set := func(name string, apply func()) {
+			if flags.Changed(name) {
+				apply()
+			}
+		}
+		set("port", func() { c.Server.Port = o.port })
+		set("clusters", func() { c.Clusters.Contexts = o.contexts })
+		set("cluster-secrets-namespace", func() { c.Clusters.SecretsNamespace = o.secretNamespace })
+		set("history-path", func() { c.History.Path = o.historyPath })
+		set("history-interval", func() { c.History.Interval = o.historyInterval })
+		set("history-retention", func() { c.History.Retention = o.historyRetention })
+		set("history-downsample-after", func() { c.History.DownsampleAfter = o.historyDownsampleAfter })
+		set("history-labels", func() { c.History.Labels = o.historyLabels })
+		set("budgets", func() { c.Budgets.Path = o.budgetsPath })
+		set("auth-config", func() { c.Auth.ConfigPath = o.authPath })
+		set("authz-config", func() { c.Auth.AuthorizationPath = o.authzPath })
+		set("audit-path", func() { c.Audit.Path = o.auditPath })
+		set("pricing-data", func() { c.Pricing.DataPath = opts.pricingData })
+		set("region", func() { c.Pricing.DefaultRegion = opts.region })
+	}
+}
+
+func runServe(flags *pflag.FlagSet, opts *globalOptions, serveOpts serveOptions) error {
+	// JSON logs, at the log level in effect
+	logLevel := new(slog.LevelVar)
+	slog.SetDefault(logging.New(os.Stderr, logLevel))
+
+	load := func() (*config.Config, error) {
+		return config.Load(serveOpts.configPath, serveOpts.override(flags, opts))
+	}
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	// Background workers run until the server has drained its requests
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	goWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workersCtx)
+		}()
+	}
+
+	watcher := config.NewWatcher(serveOpts.configPath, cfg, load)
+	setLogLevel(logLevel, cfg.Log)
+	watcher.OnChange(func(previous, current *config.Config) {
+		setLogLevel(logLevel, current.Log)
+	})
+	goWorker(func(ctx context.Context) { watcher.Run(ctx, config.DefaultWatchInterval) })
+
+	// Initialize the default cluster, or the snapshot standing in for it
+	clusterRegistry, source, err := opts.clusters()
+	if err != nil {
+		return err
+	}
+
+	// Metrics of the server, its Kubernetes clients by cluster, and the
+	// cost model
+	metricsRegistry := metrics.NewRegistry()
+	metricsRegistry.RegisterKubernetes(clusterRegistry.NameOfHost)
+	metricsRegistry.Register(clusterRegistry.CollectMetrics)
+
+	// Register the rest of the fleet
+	if len(cfg.Clusters.Contexts) > 0 {
+		if err := clusterRegistry.AddContexts(opts.kubeconfig, cfg.Clusters.Contexts); err != nil {
+			return fmt.Errorf("failed to register kubeconfig contexts: %w", err)
+		}
+	}
+	if cfg.Clusters.SecretsNamespace != "" {
+		client, ok := source.(*k8s.K8sClient)
+		if !ok {
+			return fmt.Errorf("cluster secrets need a live cluster to read them from")
+		}
+		if err := clusterRegistry.AddSecrets(context.Background(), client, cfg.Clusters.SecretsNamespace); err != nil {
+			return fmt.Errorf("failed to register clusters from secrets: %w", err)
+		}
+	}
+	if cache := cfg.Cache.ClusterMetrics; cache.Enabled {
+		clusterRegistry.Cache(time.Duration(cache.TTL))
+	}
+
+	// Readiness of the components the API needs: the default cluster, its
+	// first sync, the pricing catalog and the databases below
+	healthChecker := health.New()
+	if client, ok := source.(*k8s.K8sClient); ok {
+		healthChecker.Add("kubernetes", client.Check)
+	}
+	synced := health.NewLatch("the default cluster has not been listed yet")
+	healthChecker.Add("cluster-sync", synced.Check)
+	goWorker(func(ctx context.Context) { syncCluster(ctx, clusterRegistry, synced) })
+
+	// Load the instance pricing catalog, and read it again every
+	// pricing.refreshInterval
+	opts.pricingData, opts.region = cfg.Pricing.DataPath, cfg.Pricing.DefaultRegion
+	catalog, err := opts.catalog()
+	if err != nil {
+		return fmt.Errorf("failed to load pricing catalog: %w", err)
+	}
+	goWorker(func(ctx context.Context) { refreshPricing(ctx, catalog, watcher) })
+	metricsRegistry.Register(catalog.CollectMetrics)
+	healthChecker.Add("pricing-catalog", catalog.Check)
+
+	// Registry client for image architecture lookups
+	registryClient, err := registry.NewClient(registry.Options{Endpoint: cfg.Registry.Endpoint})
+	if err != nil {
+		return fmt.Errorf("failed to initialize registry client: %w", err)
+	}
+
+	// Authentication of API callers
+	var authenticator auth.Authenticator
+	if path := cfg.Auth.ConfigPath; path != "" {
+		authConfig, err := auth.Load(path)
+		if err != nil {
+			return err
+		}
+		// TokenReviews go to the cluster KarpOps-Wiz runs in.
+		var reviewer auth.TokenReviewer
+		if client, ok := source.(*k8s.K8sClient); ok {
+			reviewer = client
+		}
+		if authenticator, err = auth.New(authConfig, reviewer); err != nil {
+			return fmt.Errorf("invalid auth config %s: %w", path, err)
+		}
+	} else {
+		slog.Warn("Authentication is disabled: anyone who can reach the port can use the API. Set auth.configPath to require it.", "port", cfg.Server.Port)
+	}
+
+	// Authorization of authenticated callers, per route and per namespace
+	var authorizer *authz.Authorizer
+	if cfg.Auth.AuthorizationPath != "" {
+		policy, err := authz.Load(cfg.Auth.AuthorizationPath)
+		if err != nil {
+			return err
+		}
+		// SubjectAccessReviews go to the cluster a request is about.
+		authorizer = authz.New(policy, func(cluster string) (string, authz.AccessReviewer) {
+			if cluster == history.AllClusters {
+				return cluster, nil
+			}
+			if cluster == "" {
+				cluster = clusterRegistry.DefaultName()
+			}
+			source, _ := clusterRegistry.Get(cluster)
+			reviewer, _ := source.(authz.AccessReviewer)
+			return cluster, reviewer
+		})
+	}
+
+	// Audit log of the calls that produce or check configuration
+	var recorder *audit.Recorder
+	if cfg.Audit.Path != "" {
+		auditStore, err := audit.Open(cfg.Audit.Path)
+		if err != nil {
+			return err
+		}
+		defer auditStore.Close()
+		healthChecker.Add("audit-store", auditStore.Check)
+		recorder = audit.NewRecorder(auditStore, clusterRegistry.DefaultName())
+	}
+
+	// Budgets, which are evaluated against the history
+	var budgets *budget.Config
+	if cfg.Budgets.Path != "" {
+		if budgets, err = budget.Load(cfg.Budgets.Path); err != nil {
+			return err
+		}
+	}
+
+	// Features gate the routes below as the config is reloaded, and the
+	// background workers once, at startup
+	featureRegistry := features.New(watcher.Current, clusterRegistry.DefaultName())
+	dashboardEnabled := featureRegistry.Enabled(features.CostDashboard, "")
+	if !dashboardEnabled && (cfg.History.Path != "" || budgets != nil) {
+		slog.Info("The cost dashboard is disabled: not sampling history or evaluating budgets")
+	}
+
+	// Cost and utilization history, sampled in the background
+	var historyStore *history.Store
+	if h := cfg.History; h.Path != "" {
+		historyStore, err = history.Open(h.Path, history.Options{
+			Retention:       time.Duration(h.Retention),
+			DownsampleAfter: time.Duration(h.DownsampleAfter),
+		})
+		if err != nil {
+			return err
+		}
+		defer historyStore.Close()
+		healthChecker.Add("history-store", historyStore.Check)
+
+		labels := h.Labels
+		if budgets != nil {
+			labels = append(labels, budgets.LabelKeys()...)
+		}
+		if dashboardEnabled {
+			goWorker(history.NewCollector(historyStore, clusterRegistry, catalog, time.Duration(h.Interval), labels).Run)
+		}
+	}
+
+	// Initialize wizard service
+	wizardService := wizard.NewService(clusterRegistry, catalog, registryClient, historyStore)
+
+	// Cost metrics follow the cost dashboard feature, cluster by cluster
+	metricsRegistry.Register(func(ctx context.Context) []metrics.Family {
+		if !featureRegistry.Enabled(features.CostDashboard, "") {
+			return nil
+		}
+		return wizardService.CollectMetrics(ctx, func(cluster string) bool {
+			return featureRegistry.Enabled(features.CostDashboard, cluster)
+		})
+	})
+
+	// Cost allocation exports, written on a schedule
+	if e := cfg.Export; e.Interval > 0 && dashboardEnabled {
+		schedule := export.Schedule{
+			Interval:  time.Duration(e.Interval),
+			Step:      time.Duration(e.Step),
+			Aggregate: e.Aggregate,
+			Formats:   e.Formats,
+			Prefix:    e.Prefix,
+		}
+		include := func(cluster string) bool {
+			return featureRegistry.Enabled(features.CostDashboard, cluster)
+		}
+		goWorker(export.NewScheduler(historyStore, exportSink(e), schedule, include).Run)
+	}
+
+	if budgets != nil && dashboardEnabled {
+		evaluator, err := budget.NewEvaluator(budgets, historyStore, wizardService.MonthlySavings, wizardService.DetectAnomalies)
+		if err != nil {
+			return err
+		}
+		wizardService.SetBudgets(evaluator)
+		goWorker(evaluator.Run)
+	}
+
+	// Setup Gin router
+	if !cfg.Server.Debug {
+		gin.SetMode(gin.ReleaseMode)
+	}
+	r := gin.New()
+	r.Use(logging.Middleware("/livez", "/readyz", "/health"), logging.Recovery(), metricsRegistry.Middleware())
+
+	// Enable CORS for the frontend origins of the config
+	r.Use(corsMiddleware(watcher))
+
+	// Liveness and readiness probes; /health is the liveness probe of
+	// older charts
+	r.GET("/livez", healthChecker.HandleLivez)
+	r.GET("/readyz", healthChecker.HandleReadyz)
+	r.GET("/health", healthChecker.HandleLivez)
+
+	// allNamespaces guards answers about every workload of a cluster from
+	// callers limited to some namespaces.
+	allNamespaces := authorizer.RequireAllNamespaces()
+	// fleet guards answers that add up every cluster.
+	fleet := authorizer.RequireFleet()
+
+	// Prometheus metrics, which price every workload of every cluster
+	r.GET("/metrics", auth.Middleware(authenticator), authorizer.Middleware(), fleet, metricsRegistry.HandleMetrics)
+
+	// API routes
+	v1 := r.Group("/api/v1", auth.Middleware(authenticator), authorizer.Middleware())
+	operator := authorizer.Require(authz.RoleOperator)
+	admin := authorizer.Require(authz.RoleAdmin)
+	dashboard := v1.Group("", featureRegistry.Require(features.CostDashboard))
+	rebalancing := v1.Group("", featureRegistry.Require(features.Rebalancing))
+	simulation := v1.Group("", featureRegistry.Require(features.PricingSimulation))
+	karpenter := v1.Group("", featureRegistry.Require(features.KarpenterIntegration))
+	{
+		v1.GET("/whoami", auth.HandleWhoAmI)
+		v1.GET("/features", featureRegistry.HandleList)
+
+		// Karpenter config wizard
+		karpenter.GET("/presets", api.ListPresets)
+		karpenter.POST("/generate-config", recorder.Middleware("generate-config"), wizardService.HandleGenerateConfig)
+
+		// Cost and risk check of proposed Karpenter manifests, for CI
+		karpenter.POST("/check", recorder.Middleware("check"), allNamespaces, wizardService.HandleCheck)
+
+		// Cost optimization dashboard
+		dashboard.GET("/cluster/cost", allNamespaces, wizardService.HandleGetClusterCost)
+		dashboard.GET("/cluster/nodes", wizardService.HandleGetNodes)
+		dashboard.GET("/cluster/pods", wizardService.HandleGetPods)
+		dashboard.GET("/cost/history", wizardService.HandleGetCostHistory)
+		dashboard.GET("/cost/forecast", wizardService.HandleGetCostForecast)
+		dashboard.GET("/budgets", fleet, wizardService.HandleListBudgets)
+		dashboard.GET("/alerts", fleet, wizardService.HandleListAlerts)
+		dashboard.GET("/anomalies", wizardService.HandleGetAnomalies)
+		dashboard.GET("/fleet/cost", fleet, wizardService.HandleGetFleetCost)
+		dashboard.GET("/export/focus", wizardService.HandleExportFocus)
+
+		// Rebalancing recommendations
+		rebalancing.GET("/recommendations/rebalancing", allNamespaces, wizardService.HandleGetRebalancingRecommendations)
+		rebalancing.GET("/recommendations/spot", wizardService.HandleGetSpotEligibility)
+		rebalancing.GET("/recommendations/rightsizing/:namespace/:kind/:name", wizardService.HandleGetRightsizing)
+		rebalancing.GET("/recommendations/rightsizing/:namespace/:kind/:name/export", recorder.Middleware("export-rightsizing"), wizardService.HandleExportRightsizing)
+		rebalancing.GET("/analysis/graviton", wizardService.HandleGetGravitonReadiness)
+
+		// Pricing and what-if simulations
+		simulation.GET("/pricing/:region/:instance-type", wizardService.HandleGetPricing)
+		simulation.POST("/simulate/rebalancing", recorder.Middleware("simulate-rebalancing"), allNamespaces, wizardService.HandleSimulateRebalancing)
+		simulation.POST("/simulate/consolidation", recorder.Middleware("simulate-consolidation"), allNamespaces, wizardService.HandleSimulateConsolidation)
+
+		// Multi-cluster: every endpoint above accepts ?cluster=<name>
+		v1.GET("/clusters", wizardService.HandleListClusters)
+
+		// Cluster snapshots for offline analysis; every endpoint above also
+		// accepts ?snapshot=<id> to run against an uploaded one
+		v1.GET("/snapshots", wizardService.HandleListSnapshots)
+		v1.GET("/snapshots/capture", operator, allNamespaces, wizardService.HandleCaptureSnapshot)
+		v1.POST("/snapshots", operator, wizardService.HandleUploadSnapshot)
+
+		// Audit log of the calls above that produce or check configuration
+		v1.GET("/audit", admin, recorder.HandleList)
+		v1.GET("/audit/verify", admin, recorder.HandleVerify)
+		v1.GET("/audit/manifests/:sha256", admin, recorder.HandleGetManifest)
+	}
+
+	// Serve static files (if needed for frontend)
+	r.Static("/static", "./frontend/dist")
+	r.NoRoute(func(c *gin.Context) {
+		apierror.Abort(c, apierror.NotFoundf("no route %s %s", c.Request.Method, c.Request.URL.Path))
+	})
+
+	// Serve until SIGTERM or SIGINT, then drain
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stopSignals()
+	server := &http.Server{Addr: ":" + cfg.Server.Port, Handler: r}
+	served := make(chan error, 1)
+	go func() {
+		served <- server.ListenAndServe()
+	}()
+	slog.Info("Starting server", "port", cfg.Server.Port)
+
+	select {
+	case err := <-served:
+		return fmt.Errorf("failed to serve: %w", err)
+	case <-signals.Done():
+	}
+	// A second signal stops the server at once.
+	stopSignals()
+	return shutdown(server, healthChecker, watcher.Current().Server.Shutdown, stopWorkers, &workers)
+}
+
+// shutdown fails readiness, keeps serving for the drain delay while the
+// Service stops sending requests, then stops accepting them and waits for
+// in-flight requests and background workers until the timeout.
+func shutdown(server *http.Server, healthChecker *health.Checker, settings config.Shutdown, stopWorkers func(), workers *sync.WaitGroup) error {
+	slog.Info("Shutting down", "drainDelay", settings.DrainDelay.String(), "timeout", settings.Timeout.String())
+	healthChecker.Drain()
+	time.Sleep(time.Duration(settings.DrainDelay))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(settings.Timeout))
+	defer cancel()
+	drainErr := server.Shutdown(ctx)
+	stopWorkers()
+	stopped := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		slog.Warn("Background workers did not stop in time")
+	}
+	if drainErr != nil {
+		return fmt.Errorf("failed to drain requests: %w", drainErr)
+	}
+	slog.Info("Server stopped")
+	return nil
+}
+
+// syncCluster lists the nodes and pods of the default cluster until it
+// succeeds once, which also fills the cache, so that the pod is only ready
+// once the cluster answers and lets it read what the API needs.
+func syncCluster(ctx context.Context, registry *clusters.Registry, synced *health.Latch) {
+	for {
+		source, err := registry.Get(registry.DefaultName())
+		if err == nil {
+			_, err = source.GetNodes(ctx)
+		}
+		if err == nil {
+			_, err = source.GetPods(ctx)
+		}
+		synced.Set(err)
+		if err == nil {
+			return
+		}
+		slog.Warn("Failed to list the default cluster, retrying", "cluster", registry.DefaultName(), "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(10 * time.Second):
+		}
+	}
+}
+
+// exportSink is where scheduled exports are written.
+func exportSink(settings config.Export) export.Sink {
+	if settings.S3 == nil {
+		return export.Directory(settings.Directory)
+	}
+	s3 := settings.S3
+	sink := &export.S3{
+		Endpoint:        s3.Endpoint,
+		Region:          s3.Region,
+		Bucket:          s3.Bucket,
+		AccessKeyID:     s3.AccessKeyID,
+		SecretAccessKey: s3.SecretAccessKey,
+	}
+	if sink.AccessKeyID == "" && sink.SecretAccessKey == "" {
+		sink.AccessKeyID = os.Getenv("AWS_ACCESS_KEY_ID")
+		sink.SecretAccessKey = os.Getenv("AWS_SECRET_ACCESS_KEY")
+		sink.SessionToken = os.Getenv("AWS_SESSION_TOKEN")
+	}
+	if sink.Region == "" {
+		sink.Region = os.Getenv("AWS_REGION")
+	}
+	return sink
+}
+
+// setLogLevel applies the log level of settings, which the config has
+// validated.
+func setLogLevel(level *slog.LevelVar, settings config.Log) {
+	if err := level.UnmarshalText([]byte(settings.Level)); err != nil {
+		slog.Warn("Invalid log level, keeping the current one", "level", settings.Level, "error", err)
+	}
+}
+
+// corsMiddleware applies the CORS settings in effect, and the new ones
+// after the config is reloaded.
+func corsMiddleware(watcher *config.Watcher) gin.HandlerFunc {
+	var handler atomic.Value
+	build := func(settings config.CORS) {
+		if len(settings.AllowedOrigins) == 0 {
+			handler.Store(gin.HandlerFunc(func(c *gin.Context) { c.Next() }))
+			return
+		}
+		corsConfig := cors.DefaultConfig()
+		if len(settings.AllowedOrigins) == 1 && settings.AllowedOrigins[0] == "*" {
+			corsConfig.AllowAllOrigins = true
+		} else {
+			corsConfig.AllowOrigins = settings.AllowedOrigins
+		}
+		corsConfig.AllowCredentials = settings.AllowCredentials
+		corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
+		corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization", logging.RequestIDHeader}
+		corsConfig.ExposeHeaders = []string{logging.RequestIDHeader}
+		handler.Store(cors.New(corsConfig))
+	}
+	build(watcher.Current().Server.CORS)
+	watcher.OnChange(func(previous, current *config.Config) {
+		build(current.Server.CORS)
+	})
+	return func(c *gin.Context) {
+		handler.Load().(gin.HandlerFunc)(c)
+	}
+}
+
+// refreshPricing reads the pricing catalog again whenever the
+// pricing.refreshInterval in effect has passed.
+func refreshPricing(ctx context.Context, catalog *pricing.Catalog, watcher *config.Watcher) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			settings := watcher.Current().Pricing
+			if settings.RefreshInterval <= 0 || now.Sub(last) < time.Duration(settings.RefreshInterval) {
+				continue
+			}
+			last = now
+			if err := catalog.Refresh(settings.DataPath); err != nil {
+				slog.Warn("Failed to refresh the pricing catalog, keeping the current prices", "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/simulator"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/wizard"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/yaml"
+)
+
+func newSimulateCommand(opts *globalOptions) *cobra.Command {
+	var configPath, preset string
+
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Simulate repacking the cluster onto candidate instance types",
+		Long: `Simulate repacking the cluster onto candidate instance types.
+
+The candidate file takes the same fields as the body of
+POST /api/v1/simulate/rebalancing, for example:
+
+  preset: cost-optimized
+  instanceFamilies: [m6i, c6i, c6g]
+  capacityTypes: [spot, on-demand]
+  architectures: [amd64, arm64]`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := wizard.SimulationRequest{Preset: preset}
+			if configPath != "" {
+				data, err := os.ReadFile(configPath)
+				if err != nil {
+					return fmt.Errorf("failed to read candidate config: %w", err)
+				}
+				if err := yaml.UnmarshalStrict(data, &req); err != nil {
+					return fmt.Errorf("failed to parse candidate config: %w", err)
+				}
+			}
+			if req.Region == "" {
+				req.Region = opts.region
+			}
+
+			service, source, err := opts.service()
+			if err != nil {
+				return err
+			}
+
+			state, err := service.ClusterState(cmd.Context(), source)
+			if err != nil {
+				return err
+			}
+
+			result, err := service.SimulateRebalancing(state, req)
+			if err != nil {
+				return err
+			}
+
+			return printResult(os.Stdout, opts.output, result, func(w *tabwriter.Writer) {
+				printSimulationTable(w, result)
+			})
+		},
+	}
+
+	cmd.Flags().StringVar(&configPath, "config", "", "candidate config file (YAML or JSON)")
+	cmd.Flags().StringVar(&preset, "preset", "", "preset whose instance types are used when the config names none")
+	return cmd
+}
+
+func printSimulationTable(w *tabwriter.Writer, result *simulator.Result) {
+	fmt.Fprintln(w, "\tNODES\tHOURLY\tMONTHLY")
+	fmt.Fprintf(w, "Current\t%d\t%s\t%s\n", result.Current.Nodes, hourly(result.Current.HourlyCost), dollars(result.Current.MonthlyCost))
+	fmt.Fprintf(w, "Projected\t%d\t%s\t%s\n", result.Projected.Nodes, hourly(result.Projected.HourlyCost), dollars(result.Projected.MonthlyCost))
+	fmt.Fprintf(w, "Savings\t\t\t%s (%.1f%%)\n", dollars(result.Savings.Monthly), result.Savings.Percentage)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "NODE\tINSTANCE TYPE\tCAPACITY\tZONE\tPODS")
+	for _, node := range result.Nodes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", node.Name, node.InstanceType, node.CapacityType, node.Zone, len(node.Pods))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintf(w, "%d pod moves in %d steps, %d blocked, %d unschedulable\n", len(result.Moves), len(result.Steps), len(result.Blocked), len(result.Unschedulable))
+	for _, pod := range result.Unschedulable {
+		fmt.Fprintf(w, "unschedulable: %s/%s: %s\n", pod.Namespace, pod.Name, pod.Reason)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+}
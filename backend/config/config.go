@@ -0,0 +1,412 @@
+// Package config is the configuration of the server: what it listens on,
+// which features it serves, where pricing, history and the audit log
+// live, and how callers are authenticated. It is read from a YAML file,
+// typically mounted from a ConfigMap, overlaid with environment variables
+// and then command line flags, and reloaded when the file changes.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/export"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"sigs.k8s.io/yaml"
+)
+
+// Log levels, from the most to the least verbose.
+const (
+	LogDebug = "debug"
+	LogInfo  = "info"
+	LogWarn  = "warn"
+	LogError = "error"
+)
+
+// Config is the server configuration file. String values may reference
+// environment variables as ${NAME}.
+type Config struct {
+	Server   Server   `json:"server"`
+	Features Features `json:"features"`
+	Pricing  Pricing  `json:"pricing"`
+	Clusters Clusters `json:"clusters"`
+	History  History  `json:"history"`
+	Audit    Audit    `json:"audit"`
+	Budgets  Budgets  `json:"budgets"`
+	Export   Export   `json:"export"`
+	Auth     Auth     `json:"auth"`
+	Registry Registry `json:"registry"`
+	Cache    Cache    `json:"cache"`
+	Log      Log      `json:"log"`
+}
+
+type Server struct {
+	Port string `json:"port"`
+	// Debug runs the HTTP framework in debug mode, which lists every route
+	// at startup.
+	Debug    bool     `json:"debug,omitempty"`
+	CORS     CORS     `json:"cors"`
+	Shutdown Shutdown `json:"shutdown"`
+}
+
+// Shutdown is how the server stops on SIGTERM or SIGINT: /readyz fails at
+// once, requests are still served for DrainDelay, so that the Service
+// stops sending new ones, then in-flight requests and background workers
+// have Timeout to finish. The pod's termination grace period has to
+// cover both.
+type Shutdown struct {
+	DrainDelay Duration `json:"drainDelay"`
+	Timeout    Duration `json:"timeout"`
+}
+
+// CORS lists the browser origins allowed to call the API, such as the
+// UI's dev server; "*" allows any.
+type CORS struct {
+	AllowedOrigins   []string `json:"allowedOrigins"`
+	AllowCredentials bool     `json:"allowCredentials,omitempty"`
+}
+
+// Features switch parts of the API on and off.
+type Features struct {
+	CostDashboard        Feature `json:"costDashboard"`
+	Rebalancing          Feature `json:"rebalancing"`
+	PricingSimulation    Feature `json:"pricingSimulation"`
+	KarpenterIntegration Feature `json:"karpenterIntegration"`
+	// Apply is reserved for changing Karpenter resources in a cluster. No
+	// route serves that yet, so it gates nothing; it is off unless enabled.
+	Apply Feature `json:"apply"`
+}
+
+type Feature struct {
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description,omitempty"`
+	// DisabledClusters keeps the feature off for requests about these
+	// clusters even while it is enabled.
+	DisabledClusters []string `json:"disabledClusters,omitempty"`
+}
+
+// NamedFeature is a feature with the name the file gives it.
+type NamedFeature struct {
+	Name string
+	Feature
+}
+
+// Named lists the features in the order of the file.
+func (f *Features) Named() []NamedFeature {
+	return []NamedFeature{
+		{"costDashboard", f.CostDashboard},
+		{"rebalancing", f.Rebalancing},
+		{"pricingSimulation", f.PricingSimulation},
+		{"karpenterIntegration", f.KarpenterIntegration},
+		{"apply", f.Apply},
+	}
+}
+
+type Pricing struct {
+	// DataPath is the pricing catalog; empty searches the paths the image
+	// and `make run` put it at.
+	DataPath string `json:"dataPath,omitempty"`
+	// DefaultRegion prices requests that name no region; empty keeps the
+	// region of the catalog.
+	DefaultRegion string `json:"defaultRegion,omitempty"`
+	// RefreshInterval is how often the catalog is read again; zero keeps
+	// the one read at startup.
+	RefreshInterval Duration `json:"refreshInterval,omitempty"`
+}
+
+// Clusters are the clusters served next to the default one.
+type Clusters struct {
+	// Contexts are kubeconfig contexts, or * for all of them.
+	Contexts []string `json:"contexts,omitempty"`
+	// SecretsNamespace holds Secrets describing remote clusters.
+	SecretsNamespace string `json:"secretsNamespace,omitempty"`
+}
+
+type History struct {
+	// Path is the history database; empty disables history.
+	Path     string   `json:"path,omitempty"`
+	Interval Duration `json:"interval"`
+	// Retention is how long samples are kept, and DownsampleAfter the age
+	// after which they are averaged per hour.
+	Retention       Duration `json:"retention"`
+	DownsampleAfter Duration `json:"downsampleAfter"`
+	// Labels are pod label keys to break recorded cost down by.
+	Labels []string `json:"labels,omitempty"`
+}
+
+type Audit struct {
+	// Path is the audit database; empty disables the audit log.
+	Path string `json:"path,omitempty"`
+}
+
+type Budgets struct {
+	// Path is the budgets and notifiers file; empty disables budget
+	// alerts.
+	Path string `json:"path,omitempty"`
+}
+
+// Export writes the cost allocation of the history on a schedule, in the
+// FOCUS columns or OpenCost's JSON, to a directory or an S3 bucket.
+type Export struct {
+	// Interval is the span of each file, e.g. 24h for one a day; zero
+	// disables scheduled exports.
+	Interval Duration `json:"interval,omitempty"`
+	// Step is the charge period of each row.
+	Step Duration `json:"step"`
+	// Aggregate is namespace or label:<key>.
+	Aggregate string `json:"aggregate"`
+	// Formats are csv, parquet and json.
+	Formats []string `json:"formats"`
+	// Prefix starts the name of every file.
+	Prefix string `json:"prefix,omitempty"`
+	// Directory writes files under a local directory, S3 to a bucket; one
+	// of them is needed.
+	Directory string    `json:"directory,omitempty"`
+	S3        *ExportS3 `json:"s3,omitempty"`
+}
+
+// ExportS3 is an Amazon S3 bucket, or one of an S3-compatible store such
+// as MinIO. Empty credentials are read from AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN.
+type ExportS3 struct {
+	// Endpoint is the URL of an S3-compatible store; empty is Amazon S3.
+	Endpoint        string `json:"endpoint,omitempty"`
+	Region          string `json:"region,omitempty"`
+	Bucket          string `json:"bucket"`
+	AccessKeyID     string `json:"accessKeyID,omitempty"`
+	SecretAccessKey string `json:"secretAccessKey,omitempty"`
+}
+
+type Auth struct {
+	// ConfigPath is the authentication file; empty leaves the API open.
+	ConfigPath string `json:"configPath,omitempty"`
+	// AuthorizationPath is the authorization policy; empty gives every
+	// authenticated caller full access.
+	AuthorizationPath string `json:"authorizationPath,omitempty"`
+}
+
+type Registry struct {
+	// Endpoint answers every image manifest lookup, e.g. a pull-through
+	// mirror; empty queries the registries named in the images.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+type Cache struct {
+	// ClusterMetrics reuses what live clusters report, nodes, pods and
+	// their usage, for TTL instead of asking the API server on every
+	// request.
+	ClusterMetrics CacheEntry `json:"clusterMetrics"`
+}
+
+type CacheEntry struct {
+	Enabled bool     `json:"enabled"`
+	TTL     Duration `json:"ttl"`
+}
+
+type Log struct {
+	// Level is debug, info, warn or error.
+	Level string `json:"level"`
+}
+
+// Default returns the configuration of a server started without a file
+// or environment.
+func Default() *Config {
+	feature := Feature{Enabled: true}
+	return &Config{
+		Server: Server{
+			Port: "8080",
+			CORS: CORS{AllowedOrigins: []string{"http://localhost:3000", "http://localhost:5173"}},
+			Shutdown: Shutdown{
+				DrainDelay: Duration(5 * time.Second),
+				Timeout:    Duration(20 * time.Second),
+			},
+		},
+		Features: Features{
+			CostDashboard:        feature,
+			Rebalancing:          feature,
+			PricingSimulation:    feature,
+			KarpenterIntegration: feature,
+		},
+		History: History{
+			Interval:        Duration(history.DefaultInterval),
+			Retention:       Duration(90 * 24 * time.Hour),
+			DownsampleAfter: Duration(7 * 24 * time.Hour),
+		},
+		Export: Export{
+			Step:      Duration(time.Hour),
+			Aggregate: history.DimensionNamespace,
+			Formats:   []string{export.FormatCSV},
+		},
+		Cache: Cache{ClusterMetrics: CacheEntry{TTL: Duration(30 * time.Second)}},
+		Log:   Log{Level: LogInfo},
+	}
+}
+
+// Load reads the configuration: the defaults, then the file unless path
+// is empty, then the environment, then overrides, such as command line
+// flags. The result is validated.
+func Load(path string, overrides ...func(*Config)) (*Config, error) {
+	config := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(data))), config); err != nil {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+	if err := config.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		override(config)
+	}
+	if err := config.validate(); err != nil {
+		if path == "" {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return config, nil
+}
+
+func (c *Config) validate() error {
+	if port, err := strconv.Atoi(c.Server.Port); err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("server.port: %q is not a port number", c.Server.Port)
+	}
+	for _, origin := range c.Server.CORS.AllowedOrigins {
+		if origin == "*" {
+			if c.Server.CORS.AllowCredentials {
+				return fmt.Errorf("server.cors: allowCredentials can't be used with origin *")
+			}
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("server.cors.allowedOrigins: %q is not an origin such as https://karpops.example.com", origin)
+		}
+	}
+
+	if c.Server.Shutdown.DrainDelay < 0 || c.Server.Shutdown.Timeout <= 0 {
+		return fmt.Errorf("server.shutdown: drainDelay must not be negative and timeout must be positive")
+	}
+
+	for _, feature := range c.Features.Named() {
+		for _, cluster := range feature.DisabledClusters {
+			if cluster == "" {
+				return fmt.Errorf("features.%s.disabledClusters: cluster names must not be empty", feature.Name)
+			}
+		}
+	}
+
+	if c.Pricing.RefreshInterval < 0 {
+		return fmt.Errorf("pricing.refreshInterval must not be negative")
+	}
+
+	if c.History.Path != "" {
+		h := c.History
+		if h.Interval <= 0 {
+			return fmt.Errorf("history.interval must be positive")
+		}
+		if h.Retention <= 0 || h.DownsampleAfter <= 0 {
+			return fmt.Errorf("history.retention and history.downsampleAfter must be positive")
+		}
+		if h.DownsampleAfter > h.Retention {
+			return fmt.Errorf("history.downsampleAfter (%s) is after history.retention (%s)", h.DownsampleAfter, h.Retention)
+		}
+	}
+	if c.Budgets.Path != "" && c.History.Path == "" {
+		return fmt.Errorf("budgets need cost history: set history.path")
+	}
+	if err := c.Export.validate(c.History.Path != ""); err != nil {
+		return err
+	}
+	if c.Auth.AuthorizationPath != "" && c.Auth.ConfigPath == "" {
+		return fmt.Errorf("authorization needs authentication: set auth.configPath")
+	}
+
+	if c.Cache.ClusterMetrics.Enabled && c.Cache.ClusterMetrics.TTL <= 0 {
+		return fmt.Errorf("cache.clusterMetrics.ttl must be positive")
+	}
+
+	switch c.Log.Level {
+	case LogDebug, LogInfo, LogWarn, LogError:
+	default:
+		return fmt.Errorf("log.level: unknown level %q (want %s, %s, %s or %s)", c.Log.Level, LogDebug, LogInfo, LogWarn, LogError)
+	}
+	return nil
+}
+
+func (e *Export) validate(history bool) error {
+	if e.Interval < 0 {
+		return fmt.Errorf("export.interval must not be negative")
+	}
+	if e.Interval == 0 {
+		return nil
+	}
+	if !history {
+		return fmt.Errorf("scheduled exports need cost history: set history.path")
+	}
+	if e.Step <= 0 || e.Interval%e.Step != 0 {
+		return fmt.Errorf("export.step (%s) must be positive and divide export.interval (%s)", e.Step, e.Interval)
+	}
+	if !export.ValidAggregate(e.Aggregate) {
+		return fmt.Errorf("export.aggregate: unknown aggregate %q (want namespace or label:<key>)", e.Aggregate)
+	}
+	if len(e.Formats) == 0 {
+		return fmt.Errorf("export.formats must not be empty")
+	}
+	for _, format := range e.Formats {
+		if _, ok := export.ContentTypes[format]; !ok {
+			return fmt.Errorf("export.formats: unknown format %q (want %s)", format, strings.Join(export.Formats, ", "))
+		}
+	}
+	switch {
+	case e.Directory == "" && e.S3 == nil:
+		return fmt.Errorf("scheduled exports need export.directory or export.s3")
+	case e.Directory != "" && e.S3 != nil:
+		return fmt.Errorf("export.directory and export.s3 are exclusive")
+	case e.S3 != nil && e.S3.Bucket == "":
+		return fmt.Errorf("export.s3.bucket must not be empty")
+	}
+	return nil
+}
+
+// Duration is a duration written like "5m" or, with history's day unit,
+// "90d".
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("durations are strings such as \"5m\" or \"7d\"")
+	}
+	return d.Set(s)
+}
+
+// Set parses s into d, for flags and environment variables.
+func (d *Duration) Set(s string) error {
+	parsed, err := history.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d *Duration) Type() string {
+	return "duration"
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// applyEnv overlays the environment variables the server has always read,
+// and the ones the Helm chart sets, on c.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	str := func(key string, field *string) {
+		if v, ok := lookup(key); ok && v != "" {
+			*field = v
+		}
+	}
+	list := func(key string, field *[]string) {
+		if v, ok := lookup(key); ok && v != "" {
+			*field = strings.Split(v, ",")
+		}
+	}
+	flag := func(key string, field *bool) error {
+		if v, ok := lookup(key); ok && v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("invalid %s %q: want true or false", key, v)
+			}
+			*field = b
+		}
+		return nil
+	}
+	duration := func(key string, field *Duration) error {
+		if v, ok := lookup(key); ok && v != "" {
+			if err := field.Set(v); err != nil {
+				return fmt.Errorf("invalid %s: %w", key, err)
+			}
+		}
+		return nil
+	}
+
+	str("PORT", &c.Server.Port)
+	list("CORS_ALLOWED_ORIGINS", &c.Server.CORS.AllowedOrigins)
+
+	str("PRICING_DATA_PATH", &c.Pricing.DataPath)
+	str("DEFAULT_REGION", &c.Pricing.DefaultRegion)
+
+	list("CLUSTER_CONTEXTS", &c.Clusters.Contexts)
+	str("CLUSTER_SECRETS_NAMESPACE", &c.Clusters.SecretsNamespace)
+
+	str("HISTORY_PATH", &c.History.Path)
+	list("HISTORY_LABELS", &c.History.Labels)
+
+	str("AUDIT_PATH", &c.Audit.Path)
+	str("BUDGETS_CONFIG", &c.Budgets.Path)
+	str("AUTH_CONFIG", &c.Auth.ConfigPath)
+	str("AUTHZ_CONFIG", &c.Auth.AuthorizationPath)
+	str("REGISTRY_ENDPOINT", &c.Registry.Endpoint)
+	str("LOG_LEVEL", &c.Log.Level)
+
+	for key, field := range map[string]*bool{
+		"FEATURE_COST_DASHBOARD":        &c.Features.CostDashboard.Enabled,
+		"FEATURE_REBALANCING":           &c.Features.Rebalancing.Enabled,
+		"FEATURE_PRICING_SIMULATION":    &c.Features.PricingSimulation.Enabled,
+		"FEATURE_KARPENTER_INTEGRATION": &c.Features.KarpenterIntegration.Enabled,
+		"FEATURE_APPLY":                 &c.Features.Apply.Enabled,
+		"SERVER_DEBUG":                  &c.Server.Debug,
+	} {
+		if err := flag(key, field); err != nil {
+			return err
+		}
+	}
+	for key, field := range map[string]*Duration{
+		"PRICING_REFRESH_INTERVAL": &c.Pricing.RefreshInterval,
+		// The history is sampled as often as the chart refreshes metrics.
+		"METRICS_REFRESH_INTERVAL": &c.History.Interval,
+		"HISTORY_RETENTION":        &c.History.Retention,
+		"HISTORY_DOWNSAMPLE_AFTER": &c.History.DownsampleAfter,
+	} {
+		if err := duration(key, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is how often the file is checked for changes. A
+// mounted ConfigMap takes about a minute to change anyway.
+const DefaultWatchInterval = 10 * time.Second
+
+// Watcher holds the current configuration and reloads it when its file
+// changes. A reload that fails to read or validate is logged and the
+// previous configuration stays.
+type Watcher struct {
+	path string
+	load func() (*Config, error)
+
+	mu        sync.RWMutex
+	current   *Config
+	data      []byte
+	listeners []func(previous, current *Config)
+}
+
+// NewWatcher watches path, which current was loaded from with load.
+func NewWatcher(path string, current *Config, load func() (*Config, error)) *Watcher {
+	var data []byte
+	if path != "" {
+		data, _ = os.ReadFile(path)
+	}
+	return &Watcher{path: path, load: load, current: current, data: data}
+}
+
+// Current returns the configuration in effect. It must not be modified.
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// OnChange calls fn after every reload that changed the configuration.
+func (w *Watcher) OnChange(fn func(previous, current *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, fn)
+}
+
+// Run checks the file every interval until ctx is done. Without a file
+// it returns at once.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	if w.path == "" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+func (w *Watcher) check() {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		slog.Warn("config: failed to read the file, keeping the current configuration", "path", w.path, "error", err)
+		return
+	}
+	w.mu.RLock()
+	unchanged := bytes.Equal(data, w.data)
+	w.mu.RUnlock()
+	if unchanged {
+		return
+	}
+
+	config, err := w.load()
+	if err != nil {
+		slog.Warn("config: invalid file, keeping the current configuration", "path", w.path, "error", err)
+		return
+	}
+
+	w.mu.Lock()
+	previous := w.current
+	w.current, w.data = config, data
+	listeners := append([]func(previous, current *Config){}, w.listeners...)
+	w.mu.Unlock()
+
+	if reflect.DeepEqual(previous, config) {
+		return
+	}
+	slog.Info("config: reloaded", "path", w.path)
+	for _, fn := range listeners {
+		fn(previous, config)
+	}
+	if fields := RestartRequired(previous, config); len(fields) > 0 {
+		slog.Warn("config: restart to apply the changes", "path", w.path, "sections", fields)
+	}
+}
+
+// RestartRequired lists the sections that changed between previous and
+// current but are only read at startup. Features, CORS, the log level,
+// the shutdown settings and the pricing refresh interval apply as soon as
+// they are reloaded.
+func RestartRequired(previous, current *Config) []string {
+	var fields []string
+	changed := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			fields = append(fields, name)
+		}
+	}
+	// Routes follow the feature at once, but the history and budget
+	// workers are only started with the server.
+	changed("features.costDashboard.enabled", previous.Features.CostDashboard.Enabled, current.Features.CostDashboard.Enabled)
+	changed("server.port", previous.Server.Port, current.Server.Port)
+	changed("server.debug", previous.Server.Debug, current.Server.Debug)
+	changed("pricing.dataPath", previous.Pricing.DataPath, current.Pricing.DataPath)
+	changed("pricing.defaultRegion", previous.Pricing.DefaultRegion, current.Pricing.DefaultRegion)
+	changed("clusters", previous.Clusters, current.Clusters)
+	changed("history", previous.History, current.History)
+	changed("audit", previous.Audit, current.Audit)
+	changed("budgets", previous.Budgets, current.Budgets)
+	changed("export", previous.Export, current.Export)
+	changed("auth", previous.Auth, current.Auth)
+	changed("registry", previous.Registry, current.Registry)
+	changed("cache", previous.Cache, current.Cache)
+	return fields
+}
@@ -0,0 +1,112 @@
+// Package export writes the recorded cost allocation of clusters in the
+// formats FinOps tools merge with the cloud bill: the FOCUS columns as CSV
+// or Parquet, and the JSON of the OpenCost allocation API. Exports are
+// served on demand and written on a schedule to a directory or an
+// S3-compatible bucket.
+package export
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+)
+
+// Formats of an export.
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+	FormatJSON    = "json"
+)
+
+var Formats = []string{FormatCSV, FormatParquet, FormatJSON}
+
+// ContentTypes of the formats.
+var ContentTypes = map[string]string{
+	FormatCSV:     "text/csv; charset=utf-8",
+	FormatParquet: "application/vnd.apache.parquet",
+	FormatJSON:    "application/json",
+}
+
+// Query selects the allocations of an export.
+type Query struct {
+	Clusters []string
+	// Aggregate is history.DimensionNamespace or a label dimension.
+	Aggregate string
+	From      time.Time
+	To        time.Time
+	// Step is the charge period of each allocation.
+	Step time.Duration
+}
+
+// Allocation is the cost of one namespace, or label value, of a cluster
+// over one charge period. Unrequested capacity is allocated to
+// history.IdleNamespace.
+type Allocation struct {
+	Cluster   string
+	Aggregate string
+	Name      string
+	Start     time.Time
+	End       time.Time
+	Cost      float64
+}
+
+// ValidAggregate reports whether allocations can be broken down by
+// aggregate: namespaces and pod labels share out node cost, the other
+// dimensions of the history don't.
+func ValidAggregate(aggregate string) bool {
+	return aggregate == history.DimensionNamespace || strings.HasPrefix(aggregate, history.DimensionLabelPrefix)
+}
+
+// Allocations reads the allocations of q from the history, by cluster,
+// charge period and name.
+func Allocations(store *history.Store, q Query) ([]Allocation, error) {
+	if !ValidAggregate(q.Aggregate) {
+		return nil, fmt.Errorf("unknown aggregate %q: use %s or %s<key>", q.Aggregate, history.DimensionNamespace, history.DimensionLabelPrefix)
+	}
+	var allocations []Allocation
+	for _, cluster := range q.Clusters {
+		series, err := store.Query(history.Query{Cluster: cluster, From: q.From, To: q.To, Step: q.Step, GroupBy: q.Aggregate})
+		if err != nil {
+			return nil, err
+		}
+		for _, point := range series.Points {
+			end := point.Time.Add(q.Step)
+			if end.After(q.To) {
+				end = q.To
+			}
+			names := make([]string, 0, len(point.Groups))
+			for name := range point.Groups {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				allocations = append(allocations, Allocation{
+					Cluster:   cluster,
+					Aggregate: q.Aggregate,
+					Name:      name,
+					Start:     point.Time,
+					End:       end,
+					Cost:      point.Groups[name] * end.Sub(point.Time).Hours(),
+				})
+			}
+		}
+	}
+	return allocations, nil
+}
+
+// Write writes allocations in format.
+func Write(w io.Writer, format string, allocations []Allocation) error {
+	switch format {
+	case FormatCSV:
+		return WriteCSV(w, allocations)
+	case FormatParquet:
+		return WriteParquet(w, allocations)
+	case FormatJSON:
+		return WriteOpenCost(w, allocations)
+	}
+	return fmt.Errorf("unknown format %q (want %s)", format, strings.Join(Formats, ", "))
+}
@@ -0,0 +1,170 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+)
+
+// The FOCUS (FinOps Open Cost and Usage Specification) 1.0 columns an
+// allocation fills. The node cost behind allocations is already on the
+// cloud bill, so BilledCost is zero and the allocated cost goes to
+// EffectiveCost, ContractedCost and ListCost: merged with the bill, cluster
+// rows share out the cost of the nodes without counting it twice.
+var focusColumns = []focusColumn{
+	{"AvailabilityZone", kindString, nil},
+	{"BilledCost", kindDecimal, func(a *Allocation) any { return 0.0 }},
+	{"BillingAccountId", kindString, nil},
+	{"BillingAccountName", kindString, nil},
+	{"BillingCurrency", kindString, constant("USD")},
+	{"BillingPeriodEnd", kindTimestamp, func(a *Allocation) any { return billingPeriod(a.Start).AddDate(0, 1, 0) }},
+	{"BillingPeriodStart", kindTimestamp, func(a *Allocation) any { return billingPeriod(a.Start) }},
+	{"ChargeCategory", kindString, constant("Usage")},
+	{"ChargeClass", kindString, nil},
+	{"ChargeDescription", kindString, func(a *Allocation) any { return chargeDescription(a) }},
+	{"ChargeFrequency", kindString, constant("Usage-Based")},
+	{"ChargePeriodEnd", kindTimestamp, func(a *Allocation) any { return a.End }},
+	{"ChargePeriodStart", kindTimestamp, func(a *Allocation) any { return a.Start }},
+	{"ConsumedQuantity", kindDecimal, nil},
+	{"ConsumedUnit", kindString, nil},
+	{"ContractedCost", kindDecimal, func(a *Allocation) any { return a.Cost }},
+	{"EffectiveCost", kindDecimal, func(a *Allocation) any { return a.Cost }},
+	{"InvoiceIssuerName", kindString, constant("AWS")},
+	{"ListCost", kindDecimal, func(a *Allocation) any { return a.Cost }},
+	{"PricingCategory", kindString, nil},
+	{"PricingQuantity", kindDecimal, nil},
+	{"PricingUnit", kindString, nil},
+	{"ProviderName", kindString, constant("Kubernetes")},
+	{"PublisherName", kindString, constant("KarpOps-Wiz")},
+	{"RegionId", kindString, nil},
+	{"RegionName", kindString, nil},
+	{"ResourceId", kindString, func(a *Allocation) any { return a.Cluster + "/" + resourceName(a) }},
+	{"ResourceName", kindString, func(a *Allocation) any { return resourceName(a) }},
+	{"ResourceType", kindString, func(a *Allocation) any { return resourceType(a) }},
+	{"ServiceCategory", kindString, constant("Compute")},
+	{"ServiceName", kindString, constant("Kubernetes")},
+	{"SubAccountId", kindString, func(a *Allocation) any { return a.Cluster }},
+	{"SubAccountName", kindString, func(a *Allocation) any { return a.Cluster }},
+	{"Tags", kindString, func(a *Allocation) any { return tags(a) }},
+	{"x_KubernetesCluster", kindString, func(a *Allocation) any { return a.Cluster }},
+	{"x_KubernetesAggregate", kindString, func(a *Allocation) any { return a.Aggregate }},
+}
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindDecimal
+	kindTimestamp
+)
+
+// focusColumn is a column and how an allocation fills it: a string,
+// float64 or time.Time. Columns without value are null.
+type focusColumn struct {
+	name  string
+	kind  columnKind
+	value func(a *Allocation) any
+}
+
+func (c focusColumn) of(a *Allocation) any {
+	if c.value == nil {
+		return nil
+	}
+	return c.value(a)
+}
+
+func constant(s string) func(*Allocation) any {
+	return func(*Allocation) any { return s }
+}
+
+// billingPeriod is the calendar month, in UTC, t falls in.
+func billingPeriod(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// labelKey is the pod label key of a label aggregate.
+func labelKey(a *Allocation) (string, bool) {
+	return strings.CutPrefix(a.Aggregate, history.DimensionLabelPrefix)
+}
+
+func resourceName(a *Allocation) string {
+	if key, ok := labelKey(a); ok && a.Name != history.IdleNamespace {
+		return key + "=" + a.Name
+	}
+	return a.Name
+}
+
+func resourceType(a *Allocation) string {
+	switch _, ok := labelKey(a); {
+	case a.Name == history.IdleNamespace:
+		return "Kubernetes Idle Capacity"
+	case ok:
+		return "Kubernetes Label"
+	}
+	return "Kubernetes Namespace"
+}
+
+func chargeDescription(a *Allocation) string {
+	if a.Name == history.IdleNamespace {
+		return fmt.Sprintf("Node capacity no pod requests in cluster %s", a.Cluster)
+	}
+	if key, ok := labelKey(a); ok {
+		return fmt.Sprintf("Node cost allocated to pods labelled %s=%s in cluster %s", key, a.Name, a.Cluster)
+	}
+	return fmt.Sprintf("Node cost allocated to namespace %s in cluster %s", a.Name, a.Cluster)
+}
+
+// tags are the Kubernetes names of the allocation, as FOCUS's JSON object
+// of tags. Idle capacity belongs to the cluster only.
+func tags(a *Allocation) string {
+	t := map[string]string{"kubernetes_cluster": a.Cluster}
+	switch key, ok := labelKey(a); {
+	case a.Name == history.IdleNamespace:
+	case ok:
+		t[key] = a.Name
+	default:
+		t["kubernetes_namespace"] = a.Name
+	}
+	data, _ := json.Marshal(t)
+	return string(data)
+}
+
+// WriteCSV writes allocations as CSV with a header row of the FOCUS
+// columns. Null values are empty, times are RFC 3339 in UTC.
+func WriteCSV(w io.Writer, allocations []Allocation) error {
+	out := csv.NewWriter(w)
+	header := make([]string, len(focusColumns))
+	for i, column := range focusColumns {
+		header[i] = column.name
+	}
+	if err := out.Write(header); err != nil {
+		return err
+	}
+	record := make([]string, len(focusColumns))
+	for i := range allocations {
+		for j, column := range focusColumns {
+			switch v := column.of(&allocations[i]).(type) {
+			case nil:
+				record[j] = ""
+			case string:
+				record[j] = v
+			case float64:
+				record[j] = strconv.FormatFloat(v, 'f', -1, 64)
+			case time.Time:
+				record[j] = v.UTC().Format(time.RFC3339)
+			}
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
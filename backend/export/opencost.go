@@ -0,0 +1,94 @@
+package export
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+)
+
+// OpenCostResponse is the body of OpenCost's /allocation/compute: one set
+// of allocations per charge period, keyed like OpenCost's
+// aggregate=cluster,namespace (or cluster,label:<key>) names them.
+type OpenCostResponse struct {
+	Code int                             `json:"code"`
+	Data []map[string]OpenCostAllocation `json:"data"`
+}
+
+// OpenCostAllocation is an allocation with OpenCost's fields. The history
+// records the cost of what pods request as a whole, so it is reported as
+// the total only; the breakdown by resource stays zero.
+type OpenCostAllocation struct {
+	Name             string             `json:"name"`
+	Properties       OpenCostProperties `json:"properties"`
+	Window           OpenCostWindow     `json:"window"`
+	Start            time.Time          `json:"start"`
+	End              time.Time          `json:"end"`
+	Minutes          float64            `json:"minutes"`
+	CPUCost          float64            `json:"cpuCost"`
+	GPUCost          float64            `json:"gpuCost"`
+	RAMCost          float64            `json:"ramCost"`
+	PVCost           float64            `json:"pvCost"`
+	NetworkCost      float64            `json:"networkCost"`
+	LoadBalancerCost float64            `json:"loadBalancerCost"`
+	SharedCost       float64            `json:"sharedCost"`
+	ExternalCost     float64            `json:"externalCost"`
+	TotalCost        float64            `json:"totalCost"`
+}
+
+type OpenCostProperties struct {
+	Cluster   string            `json:"cluster"`
+	Namespace string            `json:"namespace,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+type OpenCostWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// OpenCost groups allocations by charge period.
+func OpenCost(allocations []Allocation) *OpenCostResponse {
+	response := &OpenCostResponse{Code: 200, Data: []map[string]OpenCostAllocation{}}
+	sets := map[time.Time]map[string]OpenCostAllocation{}
+	var starts []time.Time
+	for _, a := range allocations {
+		set, ok := sets[a.Start]
+		if !ok {
+			set = map[string]OpenCostAllocation{}
+			sets[a.Start] = set
+			starts = append(starts, a.Start)
+		}
+		name := a.Cluster + "/" + a.Name
+		properties := OpenCostProperties{Cluster: a.Cluster}
+		if key, ok := strings.CutPrefix(a.Aggregate, history.DimensionLabelPrefix); ok {
+			if a.Name != history.IdleNamespace {
+				properties.Labels = map[string]string{key: a.Name}
+			}
+		} else if a.Name != history.IdleNamespace {
+			properties.Namespace = a.Name
+		}
+		set[name] = OpenCostAllocation{
+			Name:       name,
+			Properties: properties,
+			Window:     OpenCostWindow{Start: a.Start, End: a.End},
+			Start:      a.Start,
+			End:        a.End,
+			Minutes:    a.End.Sub(a.Start).Minutes(),
+			TotalCost:  a.Cost,
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	for _, start := range starts {
+		response.Data = append(response.Data, sets[start])
+	}
+	return response
+}
+
+// WriteOpenCost writes allocations as OpenCost's allocation API answers.
+func WriteOpenCost(w io.Writer, allocations []Allocation) error {
+	return json.NewEncoder(w).Encode(OpenCost(allocations))
+}
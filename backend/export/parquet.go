@@ -0,0 +1,279 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"time"
+)
+
+// WriteParquet writes allocations as a Parquet file of the FOCUS columns:
+// one row group of uncompressed, PLAIN-encoded pages. Strings are UTF8
+// byte arrays, decimals doubles and timestamps milliseconds since the
+// epoch in UTC. Every column is optional.
+func WriteParquet(w io.Writer, allocations []Allocation) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	var chunks thriftList
+	var rowGroupSize int64
+	if len(allocations) > 0 {
+		for _, column := range focusColumns {
+			offset := int64(file.Len())
+			page := parquetPage(column, allocations)
+			header := thriftStruct{
+				{1, pageTypeData},
+				{2, int32(len(page))},
+				{3, int32(len(page))},
+				{5, thriftStruct{
+					{1, int32(len(allocations))},
+					{2, encodingPlain},
+					{3, encodingRLE},
+					{4, encodingRLE},
+				}},
+			}
+			file.Write(header.encode())
+			file.Write(page)
+			size := int64(file.Len()) - offset
+			rowGroupSize += size
+
+			chunks = append(chunks, thriftStruct{
+				{2, offset},
+				{3, thriftStruct{
+					{1, parquetTypes[column.kind]},
+					{2, thriftList{encodingPlain, encodingRLE}},
+					{3, thriftList{column.name}},
+					{4, codecUncompressed},
+					{5, int64(len(allocations))},
+					{6, size},
+					{7, size},
+					{9, offset},
+				}},
+			})
+		}
+	}
+
+	schema := thriftList{thriftStruct{
+		{4, "schema"},
+		{5, int32(len(focusColumns))},
+	}}
+	for _, column := range focusColumns {
+		schema = append(schema, parquetSchemaElement(column))
+	}
+	var rowGroups thriftList
+	if len(allocations) > 0 {
+		rowGroups = append(rowGroups, thriftStruct{
+			{1, chunks},
+			{2, rowGroupSize},
+			{3, int64(len(allocations))},
+		})
+	}
+	metadata := thriftStruct{
+		{1, int32(1)},
+		{2, schema},
+		{3, int64(len(allocations))},
+		{4, rowGroups},
+		{6, "karpops-wiz"},
+	}.encode()
+	file.Write(metadata)
+	binary.Write(&file, binary.LittleEndian, uint32(len(metadata)))
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+const parquetMagic = "PAR1"
+
+// Values of the Parquet format's enums.
+const (
+	typeInt64     int32 = 2
+	typeDouble    int32 = 5
+	typeByteArray int32 = 6
+
+	repetitionOptional int32 = 1
+
+	convertedUTF8            int32 = 0
+	convertedTimestampMillis int32 = 9
+
+	encodingPlain int32 = 0
+	encodingRLE   int32 = 3
+
+	codecUncompressed int32 = 0
+	pageTypeData      int32 = 0
+)
+
+var parquetTypes = map[columnKind]int32{
+	kindString:    typeByteArray,
+	kindDecimal:   typeDouble,
+	kindTimestamp: typeInt64,
+}
+
+func parquetSchemaElement(column focusColumn) thriftStruct {
+	element := thriftStruct{
+		{1, parquetTypes[column.kind]},
+		{3, repetitionOptional},
+		{4, column.name},
+	}
+	switch column.kind {
+	case kindString:
+		// LogicalType STRING
+		element = append(element, thriftField{6, convertedUTF8}, thriftField{10, thriftStruct{{1, thriftStruct{}}}})
+	case kindTimestamp:
+		// LogicalType TIMESTAMP(isAdjustedToUTC, MILLIS)
+		element = append(element, thriftField{6, convertedTimestampMillis}, thriftField{10, thriftStruct{
+			{8, thriftStruct{{1, true}, {2, thriftStruct{{1, thriftStruct{}}}}}},
+		}})
+	}
+	return element
+}
+
+// parquetPage is the data page of column: the definition levels of the
+// rows, 1 for values and 0 for nulls, then the values.
+func parquetPage(column focusColumn, allocations []Allocation) []byte {
+	levels := make([]byte, len(allocations))
+	var values bytes.Buffer
+	for i := range allocations {
+		v := column.of(&allocations[i])
+		if v == nil {
+			continue
+		}
+		levels[i] = 1
+		switch v := v.(type) {
+		case string:
+			binary.Write(&values, binary.LittleEndian, uint32(len(v)))
+			values.WriteString(v)
+		case float64:
+			binary.Write(&values, binary.LittleEndian, math.Float64bits(v))
+		case time.Time:
+			binary.Write(&values, binary.LittleEndian, v.UnixMilli())
+		}
+	}
+
+	encoded := rleLevels(levels)
+	var page bytes.Buffer
+	binary.Write(&page, binary.LittleEndian, uint32(len(encoded)))
+	page.Write(encoded)
+	page.Write(values.Bytes())
+	return page.Bytes()
+}
+
+// rleLevels encodes levels of bit width 1 as runs of the RLE/bit-packing
+// hybrid encoding.
+func rleLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+// thriftStruct is a Thrift struct in the compact protocol Parquet's
+// metadata is written in. Field values are bool, int32, int64, string,
+// thriftStruct or thriftList.
+type thriftStruct []thriftField
+
+type thriftField struct {
+	id    int16
+	value any
+}
+
+// thriftList is a list of values of one type.
+type thriftList []any
+
+// Compact protocol types.
+const (
+	compactTrue   = 1
+	compactFalse  = 2
+	compactI32    = 5
+	compactI64    = 6
+	compactBinary = 8
+	compactList   = 9
+	compactStruct = 12
+)
+
+func (s thriftStruct) encode() []byte {
+	var buf bytes.Buffer
+	s.write(&buf)
+	return buf.Bytes()
+}
+
+func (s thriftStruct) write(buf *bytes.Buffer) {
+	var last int16
+	for _, field := range s {
+		typ := compactType(field.value)
+		if b, ok := field.value.(bool); ok && !b {
+			typ = compactFalse
+		}
+		if delta := field.id - last; delta > 0 && delta <= 15 {
+			buf.WriteByte(byte(delta)<<4 | typ)
+		} else {
+			buf.WriteByte(typ)
+			writeVarint(buf, int64(field.id))
+		}
+		last = field.id
+		if _, ok := field.value.(bool); !ok {
+			writeThriftValue(buf, field.value)
+		}
+	}
+	buf.WriteByte(0)
+}
+
+func compactType(v any) byte {
+	switch v.(type) {
+	case bool:
+		return compactTrue
+	case int32:
+		return compactI32
+	case int64:
+		return compactI64
+	case string:
+		return compactBinary
+	case thriftList:
+		return compactList
+	case thriftStruct:
+		return compactStruct
+	}
+	panic("export: no thrift type for value")
+}
+
+func writeThriftValue(buf *bytes.Buffer, v any) {
+	switch v := v.(type) {
+	case int32:
+		writeVarint(buf, int64(v))
+	case int64:
+		writeVarint(buf, v)
+	case string:
+		buf.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		buf.WriteString(v)
+	case thriftStruct:
+		v.write(buf)
+	case thriftList:
+		elem := byte(compactStruct)
+		if len(v) > 0 {
+			elem = compactType(v[0])
+		}
+		if len(v) < 15 {
+			buf.WriteByte(byte(len(v))<<4 | elem)
+		} else {
+			buf.WriteByte(0xf0 | elem)
+			buf.Write(binary.AppendUvarint(nil, uint64(len(v))))
+		}
+		for _, item := range v {
+			writeThriftValue(buf, item)
+		}
+	}
+}
+
+// writeVarint writes a zigzag varint, as the compact protocol encodes
+// integers.
+func writeVarint(buf *bytes.Buffer, v int64) {
+	buf.Write(binary.AppendVarint(nil, v))
+}
@@ -0,0 +1,360 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+)
+
+// The tests read the files back by the Parquet format specification,
+// with a decoder of the Thrift compact protocol of their own, so they
+// don't share the writer's notion of the format.
+
+func TestWriteParquet(t *testing.T) {
+	start := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	allocations := []Allocation{
+		{Cluster: "prod", Aggregate: history.DimensionNamespace, Name: "shop", Start: start, End: start.Add(time.Hour), Cost: 1.25},
+		{Cluster: "prod", Aggregate: history.DimensionNamespace, Name: history.IdleNamespace, Start: start, End: start.Add(time.Hour), Cost: 0.5},
+		{Cluster: "prod", Aggregate: history.DimensionLabelPrefix + "team", Name: "payments", Start: start.Add(time.Hour), End: start.Add(2 * time.Hour), Cost: 3},
+	}
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, allocations); err != nil {
+		t.Fatal(err)
+	}
+	rows := readParquet(t, buf.Bytes())
+	if len(rows) != len(allocations) {
+		t.Fatalf("read %d rows, want %d", len(rows), len(allocations))
+	}
+
+	for i, row := range rows {
+		for _, column := range focusColumns {
+			want := column.of(&allocations[i])
+			if w, ok := want.(time.Time); ok {
+				want = w.UTC()
+			}
+			got := row[column.name]
+			if g, ok := got.(time.Time); ok {
+				if !g.Equal(want.(time.Time)) {
+					t.Errorf("row %d %s = %v, want %v", i, column.name, got, want)
+				}
+				continue
+			}
+			if got != want {
+				t.Errorf("row %d %s = %#v, want %#v", i, column.name, got, want)
+			}
+		}
+	}
+	// A few values spelled out, in case the columns change underneath.
+	for _, check := range []struct {
+		row    int
+		column string
+		want   any
+	}{
+		{0, "EffectiveCost", 1.25},
+		{0, "ResourceId", "prod/shop"},
+		{0, "BillingAccountId", nil},
+		{1, "ResourceType", "Kubernetes Idle Capacity"},
+		{2, "ResourceName", "team=payments"},
+		{2, "BillingPeriodStart", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		if got := rows[check.row][check.column]; fmt.Sprint(got) != fmt.Sprint(check.want) {
+			t.Errorf("row %d %s = %v, want %v", check.row, check.column, got, check.want)
+		}
+	}
+}
+
+func TestWriteParquetEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteParquet(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	if rows := readParquet(t, buf.Bytes()); len(rows) != 0 {
+		t.Errorf("read %d rows from an empty export", len(rows))
+	}
+}
+
+// readParquet checks the layout and metadata of a file of the FOCUS
+// columns and returns its rows by column name, with nil for nulls.
+func readParquet(t *testing.T, data []byte) []map[string]any {
+	t.Helper()
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatalf("file does not start and end with PAR1")
+	}
+	footer := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	metaStart := len(data) - 8 - footer
+	if metaStart < 4 {
+		t.Fatalf("footer length %d overruns the file", footer)
+	}
+	meta := &compactReader{data: data[metaStart : len(data)-8]}
+	file := meta.readStruct()
+	if meta.err != nil || meta.pos != len(meta.data) {
+		t.Fatalf("FileMetaData: read %d of %d bytes: %v", meta.pos, len(meta.data), meta.err)
+	}
+
+	if file[1] != int64(1) {
+		t.Errorf("version = %v, want 1", file[1])
+	}
+	numRows := file[3].(int64)
+
+	schema := file[2].([]any)
+	if len(schema) != len(focusColumns)+1 {
+		t.Fatalf("schema has %d elements, want the root and %d columns", len(schema), len(focusColumns))
+	}
+	root := schema[0].(map[int16]any)
+	if root[5] != int64(len(focusColumns)) {
+		t.Errorf("root num_children = %v, want %d", root[5], len(focusColumns))
+	}
+	physical := map[columnKind]int64{kindString: 6, kindDecimal: 5, kindTimestamp: 2}
+	for i, column := range focusColumns {
+		element := schema[i+1].(map[int16]any)
+		if element[4] != column.name || element[1] != physical[column.kind] || element[3] != int64(1) {
+			t.Errorf("schema element %d = %v, want optional %s of type %d", i+1, element, column.name, physical[column.kind])
+		}
+		switch column.kind {
+		case kindString:
+			logical, _ := element[10].(map[int16]any)
+			if _, ok := logical[1]; !ok || element[6] != int64(0) {
+				t.Errorf("%s is not annotated as a STRING: %v", column.name, element)
+			}
+		case kindTimestamp:
+			logical, _ := element[10].(map[int16]any)
+			timestamp, _ := logical[8].(map[int16]any)
+			unit, _ := timestamp[2].(map[int16]any)
+			if _, millis := unit[1]; timestamp[1] != true || !millis || element[6] != int64(9) {
+				t.Errorf("%s is not annotated as TIMESTAMP(UTC, MILLIS): %v", column.name, element)
+			}
+		}
+	}
+
+	rows := make([]map[string]any, numRows)
+	for i := range rows {
+		rows[i] = map[string]any{}
+	}
+	rowGroups := file[4].([]any)
+	if numRows == 0 {
+		if len(rowGroups) != 0 {
+			t.Errorf("an empty file has %d row groups", len(rowGroups))
+		}
+		return rows
+	}
+	if len(rowGroups) != 1 {
+		t.Fatalf("got %d row groups, want 1", len(rowGroups))
+	}
+	rowGroup := rowGroups[0].(map[int16]any)
+	if rowGroup[3] != numRows {
+		t.Errorf("row group num_rows = %v, want %d", rowGroup[3], numRows)
+	}
+	chunks := rowGroup[1].([]any)
+	if len(chunks) != len(focusColumns) {
+		t.Fatalf("got %d column chunks, want %d", len(chunks), len(focusColumns))
+	}
+
+	var totalSize int64
+	end := int64(4)
+	for i, column := range focusColumns {
+		chunk := chunks[i].(map[int16]any)
+		columnMeta := chunk[3].(map[int16]any)
+		offset, _ := columnMeta[9].(int64)
+		if chunk[2] != offset || offset != end {
+			t.Fatalf("%s: chunk at %v, data page at %d, want both right after the previous chunk at %d", column.name, chunk[2], offset, end)
+		}
+		if path := columnMeta[3].([]any); len(path) != 1 || path[0] != column.name {
+			t.Errorf("%s: path_in_schema = %v", column.name, path)
+		}
+		if columnMeta[1] != physical[column.kind] || columnMeta[4] != int64(0) || columnMeta[5] != numRows {
+			t.Errorf("%s: column metadata = %v, want type %d, UNCOMPRESSED and %d values", column.name, columnMeta, physical[column.kind], numRows)
+		}
+
+		pageReader := &compactReader{data: data[offset:metaStart]}
+		header := pageReader.readStruct()
+		if pageReader.err != nil {
+			t.Fatalf("%s: PageHeader: %v", column.name, pageReader.err)
+		}
+		size := header[3].(int64)
+		dataPage := header[5].(map[int16]any)
+		if header[1] != int64(0) || header[2] != size || dataPage[1] != numRows || dataPage[2] != int64(0) || dataPage[3] != int64(3) {
+			t.Errorf("%s: page header = %v, want an uncompressed PLAIN data page of %d values with RLE levels", column.name, header, numRows)
+		}
+		pageStart := offset + int64(pageReader.pos)
+		page := data[pageStart : pageStart+size]
+		end = pageStart + size
+		if columnMeta[6] != end-offset || columnMeta[7] != end-offset {
+			t.Errorf("%s: chunk sizes = %v, %v, want %d", column.name, columnMeta[6], columnMeta[7], end-offset)
+		}
+		totalSize += end - offset
+
+		levelsSize := binary.LittleEndian.Uint32(page)
+		levels := decodeLevels(t, page[4:4+levelsSize], int(numRows))
+		values := page[4+levelsSize:]
+		for row, level := range levels {
+			if level == 0 {
+				rows[row][column.name] = nil
+				continue
+			}
+			switch column.kind {
+			case kindString:
+				n := binary.LittleEndian.Uint32(values)
+				rows[row][column.name] = string(values[4 : 4+n])
+				values = values[4+n:]
+			case kindDecimal:
+				rows[row][column.name] = math.Float64frombits(binary.LittleEndian.Uint64(values))
+				values = values[8:]
+			case kindTimestamp:
+				rows[row][column.name] = time.UnixMilli(int64(binary.LittleEndian.Uint64(values))).UTC()
+				values = values[8:]
+			}
+		}
+		if len(values) != 0 {
+			t.Errorf("%s: %d bytes left after the values", column.name, len(values))
+		}
+	}
+	if end != int64(metaStart) {
+		t.Errorf("column chunks end at %d, metadata starts at %d", end, metaStart)
+	}
+	if rowGroup[2] != totalSize {
+		t.Errorf("row group total_byte_size = %v, want %d", rowGroup[2], totalSize)
+	}
+	return rows
+}
+
+// decodeLevels decodes n levels of bit width 1 in the RLE/bit-packing
+// hybrid encoding.
+func decodeLevels(t *testing.T, data []byte, n int) []byte {
+	t.Helper()
+	var levels []byte
+	for len(levels) < n {
+		header, size := binary.Uvarint(data)
+		if size <= 0 {
+			t.Fatalf("bad run header in definition levels")
+		}
+		data = data[size:]
+		if header&1 == 0 {
+			for i := uint64(0); i < header>>1; i++ {
+				levels = append(levels, data[0])
+			}
+			data = data[1:]
+			continue
+		}
+		for _, b := range data[:header>>1] {
+			for bit := 0; bit < 8; bit++ {
+				levels = append(levels, b>>bit&1)
+			}
+		}
+		data = data[header>>1:]
+	}
+	if len(data) != 0 {
+		t.Fatalf("%d bytes left after the definition levels", len(data))
+	}
+	return levels[:n]
+}
+
+// compactReader decodes the Thrift compact protocol into maps of field
+// IDs to values: bool, int64 for every integer type, string for binary,
+// []any for lists and map[int16]any for structs.
+type compactReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *compactReader) fail(format string, args ...any) {
+	if r.err == nil {
+		r.err = fmt.Errorf("at byte %d: %s", r.pos, fmt.Sprintf(format, args...))
+	}
+}
+
+func (r *compactReader) byte() byte {
+	if r.pos >= len(r.data) {
+		r.fail("unexpected end")
+		return 0
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		r.fail("bad varint")
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *compactReader) readStruct() map[int16]any {
+	fields := map[int16]any{}
+	var last int16
+	for r.err == nil {
+		header := r.byte()
+		if header == 0 {
+			break
+		}
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag())
+		}
+		last = id
+		switch typ := header & 0x0f; typ {
+		case 1, 2:
+			fields[id] = typ == 1
+		default:
+			fields[id] = r.readValue(typ)
+		}
+	}
+	return fields
+}
+
+func (r *compactReader) readValue(typ byte) any {
+	switch typ {
+	case 3:
+		return int64(int8(r.byte()))
+	case 4, 5, 6:
+		return r.zigzag()
+	case 7:
+		if r.pos+8 > len(r.data) {
+			r.fail("unexpected end")
+			return 0.0
+		}
+		r.pos += 8
+		return math.Float64frombits(binary.LittleEndian.Uint64(r.data[r.pos-8:]))
+	case 8:
+		n := int(r.uvarint())
+		if r.pos+n > len(r.data) {
+			r.fail("binary of %d bytes overruns the data", n)
+			return ""
+		}
+		r.pos += n
+		return string(r.data[r.pos-n : r.pos])
+	case 9, 10:
+		header := r.byte()
+		n := int(header >> 4)
+		if n == 15 {
+			n = int(r.uvarint())
+		}
+		list := []any{}
+		for i := 0; i < n && r.err == nil; i++ {
+			if elem := header & 0x0f; elem == 1 || elem == 2 {
+				list = append(list, r.byte() == 1)
+			} else {
+				list = append(list, r.readValue(elem))
+			}
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	r.fail("unsupported type %d", typ)
+	return nil
+}
@@ -0,0 +1,147 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+)
+
+const (
+	// stateKey is where the end of the last exported interval is kept in
+	// the history database, so a restart neither skips nor repeats one.
+	stateKey = "export"
+
+	// settleDelay leaves the collector time to record the last samples of
+	// an interval before it is exported.
+	settleDelay = 10 * time.Minute
+
+	// maxCatchUp bounds how many missed intervals are exported at once,
+	// e.g. after the server was down.
+	maxCatchUp = 31
+)
+
+// Schedule is what scheduled exports write.
+type Schedule struct {
+	// Interval is the span of each export, aligned to UTC, e.g. 24h for
+	// one file per day.
+	Interval time.Duration
+	// Step is the charge period of each allocation.
+	Step      time.Duration
+	Aggregate string
+	Formats   []string
+	// Prefix starts the name of every file.
+	Prefix string
+}
+
+// Scheduler exports every cluster of the history once each interval is
+// over.
+type Scheduler struct {
+	store    *history.Store
+	sink     Sink
+	schedule Schedule
+	// include leaves out clusters whose cost isn't exported.
+	include func(cluster string) bool
+}
+
+type schedulerState struct {
+	Exported time.Time `json:"exported"`
+}
+
+func NewScheduler(store *history.Store, sink Sink, schedule Schedule, include func(cluster string) bool) *Scheduler {
+	return &Scheduler{store: store, sink: sink, schedule: schedule, include: include}
+}
+
+// Run exports the intervals that are over, then checks again every minute
+// until ctx is done.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		s.exportDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) exportDue(ctx context.Context, now time.Time) {
+	var state schedulerState
+	if err := s.store.LoadState(stateKey, &state); err != nil {
+		slog.Error("export: failed to read the last export", "error", err)
+		return
+	}
+	end := now.UTC().Add(-settleDelay).Truncate(s.schedule.Interval)
+	start := state.Exported
+	switch earliest := end.Add(-maxCatchUp * s.schedule.Interval); {
+	case start.IsZero():
+		// The first export covers the last interval only.
+		start = end.Add(-s.schedule.Interval)
+	case start.Before(earliest):
+		start = earliest
+	}
+
+	for ; !start.Add(s.schedule.Interval).After(end); start = start.Add(s.schedule.Interval) {
+		if err := s.Export(ctx, start, start.Add(s.schedule.Interval)); err != nil {
+			slog.Error("export: failed to export", "from", start, "sink", s.sink.String(), "error", err)
+			return
+		}
+		if err := s.store.SaveState(stateKey, schedulerState{Exported: start.Add(s.schedule.Interval)}); err != nil {
+			slog.Error("export: failed to save the last export", "error", err)
+			return
+		}
+	}
+}
+
+// Export writes the allocations of every cluster between from and to to
+// the sink, in each format of the schedule.
+func (s *Scheduler) Export(ctx context.Context, from, to time.Time) error {
+	recorded, err := s.store.Clusters()
+	if err != nil {
+		return err
+	}
+	var clusters []string
+	for _, cluster := range recorded {
+		if s.include(cluster) {
+			clusters = append(clusters, cluster)
+		}
+	}
+	allocations, err := Allocations(s.store, Query{
+		Clusters:  clusters,
+		Aggregate: s.schedule.Aggregate,
+		From:      from,
+		To:        to,
+		Step:      s.schedule.Step,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, format := range s.schedule.Formats {
+		var buf bytes.Buffer
+		if err := Write(&buf, format, allocations); err != nil {
+			return err
+		}
+		if err := s.sink.Put(ctx, FileName(s.schedule.Prefix, format, from), ContentTypes[format], buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileName names the export of the interval starting at from, by year and
+// month so that warehouses can load a month at a time, e.g.
+// karpops/2024/05/focus-20240501T0000Z.csv.
+func FileName(prefix, format string, from time.Time) string {
+	from = from.UTC()
+	kind := "focus"
+	if format == FormatJSON {
+		kind = "opencost"
+	}
+	return fmt.Sprintf("%s%s/%s-%s.%s", prefix, from.Format("2006/01"), kind, from.Format("20060102T1504Z"), format)
+}
@@ -0,0 +1,119 @@
+// Package features decides which parts of the API a server serves. Each
+// feature gates a group of routes and the background workers behind it.
+// Routes follow the config as it is reloaded; workers are started, or not,
+// with the server.
+package features
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/config"
+	"github.com/gin-gonic/gin"
+)
+
+// Names of the features, as the config file and the API give them.
+const (
+	CostDashboard        = "costDashboard"
+	Rebalancing          = "rebalancing"
+	PricingSimulation    = "pricingSimulation"
+	KarpenterIntegration = "karpenterIntegration"
+	Apply                = "apply"
+)
+
+// descriptions describe features the config gives no description.
+var descriptions = map[string]string{
+	CostDashboard:        "Cluster and fleet cost, cost history and forecasts, budgets, alerts and anomalies",
+	Rebalancing:          "Rebalancing, spot and rightsizing recommendations and the Graviton readiness analysis",
+	PricingSimulation:    "Instance pricing lookups and rebalancing and consolidation simulations",
+	KarpenterIntegration: "Karpenter presets, config generation and manifest checks",
+	Apply:                "Applying Karpenter changes to clusters. Reserved: the server has no apply routes yet, so it gates nothing",
+}
+
+// Registry answers which features are on, from the config in effect.
+type Registry struct {
+	current func() *config.Config
+	// defaultCluster is the cluster of requests without ?cluster=.
+	defaultCluster string
+}
+
+func New(current func() *config.Config, defaultCluster string) *Registry {
+	return &Registry{current: current, defaultCluster: defaultCluster}
+}
+
+// Status is a feature as GET /api/v1/features reports it.
+type Status struct {
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Description string `json:"description"`
+	// DisabledClusters are clusters the feature is off for while it is
+	// enabled.
+	DisabledClusters []string `json:"disabledClusters,omitempty"`
+}
+
+// List returns every feature.
+func (r *Registry) List() []Status {
+	features := r.current().Features
+	var statuses []Status
+	for _, f := range features.Named() {
+		status := Status{Name: f.Name, Enabled: f.Enabled, Description: f.Description, DisabledClusters: f.DisabledClusters}
+		if status.Description == "" {
+			status.Description = descriptions[f.Name]
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Enabled reports whether the feature is on for cluster; an empty
+// cluster only checks that it is enabled.
+func (r *Registry) Enabled(name, cluster string) bool {
+	return r.check(name, cluster) == nil
+}
+
+func (r *Registry) check(name, cluster string) *disabled {
+	features := r.current().Features
+	for _, f := range features.Named() {
+		if f.Name != name {
+			continue
+		}
+		if !f.Enabled {
+			return &disabled{status: http.StatusNotFound, reason: fmt.Sprintf("the %s feature is disabled on this server", name)}
+		}
+		for _, c := range f.DisabledClusters {
+			if c == cluster {
+				return &disabled{status: http.StatusForbidden, reason: fmt.Sprintf("the %s feature is disabled for cluster %s", name, cluster)}
+			}
+		}
+		return nil
+	}
+	return &disabled{status: http.StatusNotFound, reason: fmt.Sprintf("unknown feature %s", name)}
+}
+
+type disabled struct {
+	status int
+	reason string
+}
+
+// Require answers 404 on the routes of a disabled feature, and 403 when
+// the feature is disabled for the cluster the request is about.
+func (r *Registry) Require(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Uploaded snapshots belong to no cluster.
+		cluster := c.Query("cluster")
+		if cluster == "" && c.Query("snapshot") == "" {
+			cluster = r.defaultCluster
+		}
+		if d := r.check(name, cluster); d != nil {
+			apierror.Abort(c, apierror.New(d.status, apierror.CodeFeatureDisabled, "%s", d.reason).With("feature", name))
+			return
+		}
+		c.Next()
+	}
+}
+
+// HandleList serves GET /api/v1/features.
+func (r *Registry) HandleList(c *gin.Context) {
+	c.JSON(http.StatusOK, r.List())
+}
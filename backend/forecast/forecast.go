@@ -0,0 +1,233 @@
+// Package forecast projects a regularly spaced series with exponential
+// smoothing: additive Holt-Winters once the series covers two seasons,
+// Holt's damped trend on shorter series, and the mean below that.
+// Prediction intervals follow from the fitted model's one-step errors.
+package forecast
+
+import (
+	"math"
+)
+
+const (
+	MethodHoltWinters = "holt-winters"
+	MethodHolt        = "holt"
+	MethodMean        = "mean"
+
+	// damping keeps a trend from compounding over long horizons.
+	damping = 0.98
+	// minTrendPoints is the shortest series a trend is fitted to; the
+	// slope of fewer points is mostly noise.
+	minTrendPoints = 7
+)
+
+var (
+	levelWeights    = []float64{0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9}
+	trendWeights    = []float64{0.01, 0.05, 0.1, 0.2, 0.3}
+	seasonalWeights = []float64{0.01, 0.05, 0.1, 0.2, 0.3}
+)
+
+// Model is a smoothing model fitted to a series.
+type Model struct {
+	Method string  `json:"method"`
+	Season int     `json:"season,omitempty"`
+	Alpha  float64 `json:"alpha,omitempty"`
+	Beta   float64 `json:"beta,omitempty"`
+	Gamma  float64 `json:"gamma,omitempty"`
+	// Sigma is the standard deviation of the one-step-ahead errors.
+	Sigma float64 `json:"sigma"`
+
+	level    float64
+	trend    float64
+	seasonal []float64
+	// next is the index into seasonal of the first forecast step.
+	next int
+}
+
+// Estimate is a forecast with its prediction interval.
+type Estimate struct {
+	Value float64 `json:"value"`
+	Lower float64 `json:"lower"`
+	Upper float64 `json:"upper"`
+}
+
+// Fit fits a series without a seasonal cycle.
+func Fit(values []float64) *Model {
+	return FitSeasonal(values, 0)
+}
+
+// FitSeasonal is Fit for series with a seasonal cycle of season steps. The
+// weights are chosen by grid search on the one-step-ahead squared error.
+func FitSeasonal(values []float64, season int) *Model {
+	switch {
+	case season > 1 && len(values) >= 2*season:
+		return fitBest(values, season, seasonalWeights)
+	case len(values) >= minTrendPoints:
+		return fitBest(values, 0, []float64{0})
+	default:
+		return fitMean(values)
+	}
+}
+
+func fitBest(values []float64, season int, gammas []float64) *Model {
+	var best *Model
+	bestSSE := math.Inf(1)
+	for _, alpha := range levelWeights {
+		for _, beta := range trendWeights {
+			for _, gamma := range gammas {
+				m, sse := smooth(values, season, alpha, beta, gamma)
+				if sse < bestSSE {
+					best, bestSSE = m, sse
+				}
+			}
+		}
+	}
+	return best
+}
+
+// smooth runs damped additive Holt-Winters over values and returns the
+// final state and the sum of squared one-step-ahead errors.
+func smooth(values []float64, season int, alpha, beta, gamma float64) (*Model, float64) {
+	m := &Model{Method: MethodHolt, Alpha: alpha, Beta: beta}
+	start := 1
+	m.level = values[0]
+	m.trend = values[1] - values[0]
+	if season > 0 {
+		m.Method, m.Season, m.Gamma = MethodHoltWinters, season, gamma
+		first, second := mean(values[:season]), mean(values[season:2*season])
+		m.level = first
+		m.trend = (second - first) / float64(season)
+		m.seasonal = make([]float64, season)
+		for i := range m.seasonal {
+			m.seasonal[i] = values[i] - first
+		}
+		start = season
+	}
+
+	sse, n := 0.0, 0
+	for t := start; t < len(values); t++ {
+		s := 0.0
+		if season > 0 {
+			s = m.seasonal[t%season]
+		}
+		predicted := m.level + damping*m.trend + s
+		e := values[t] - predicted
+		sse += e * e
+		n++
+
+		level := alpha*(values[t]-s) + (1-alpha)*(m.level+damping*m.trend)
+		m.trend = beta*(level-m.level) + (1-beta)*damping*m.trend
+		m.level = level
+		if season > 0 {
+			m.seasonal[t%season] = gamma*(values[t]-level) + (1-gamma)*s
+		}
+	}
+	if season > 0 {
+		m.next = len(values) % season
+	}
+	if n > 0 {
+		m.Sigma = math.Sqrt(sse / float64(n))
+	}
+	return m, sse
+}
+
+func fitMean(values []float64) *Model {
+	m := &Model{Method: MethodMean, level: mean(values)}
+	if len(values) > 1 {
+		ss := 0.0
+		for _, v := range values {
+			ss += (v - m.level) * (v - m.level)
+		}
+		m.Sigma = math.Sqrt(ss / float64(len(values)-1))
+	}
+	return m
+}
+
+// Forecast returns the next h steps. confidence is the coverage of the
+// prediction intervals, e.g. 0.95.
+func (m *Model) Forecast(h int, confidence float64) []Estimate {
+	z := zScore(confidence)
+	c := m.errorWeights(h)
+	estimates := make([]Estimate, h)
+	variance := 0.0
+	for i := range estimates {
+		if i == 0 {
+			variance = 1
+		} else {
+			variance += c[i] * c[i]
+		}
+		value := m.point(i + 1)
+		spread := z * m.Sigma * math.Sqrt(variance)
+		estimates[i] = Estimate{Value: value, Lower: value - spread, Upper: value + spread}
+	}
+	return estimates
+}
+
+// Total returns the sum of the next h steps. Its interval accounts for the
+// forecast errors of consecutive steps being correlated.
+func (m *Model) Total(h int, confidence float64) Estimate {
+	c := m.errorWeights(h)
+	total, variance, cumulative := 0.0, 0.0, 0.0
+	for i := 0; i < h; i++ {
+		total += m.point(i + 1)
+		// The error of the i-th step from the end shifts that step and,
+		// by c[1..i], the ones after it.
+		if i > 0 {
+			cumulative += c[i]
+		}
+		variance += (1 + cumulative) * (1 + cumulative)
+	}
+	spread := zScore(confidence) * m.Sigma * math.Sqrt(variance)
+	return Estimate{Value: total, Lower: total - spread, Upper: total + spread}
+}
+
+// point is the forecast h steps after the last value.
+func (m *Model) point(h int) float64 {
+	if m.Method == MethodMean {
+		return m.level
+	}
+	value := m.level + dampedSum(h)*m.trend
+	if m.Season > 0 {
+		value += m.seasonal[(m.next+h-1)%m.Season]
+	}
+	return value
+}
+
+// errorWeights returns c[j], how much of a one-step error still shows j
+// steps later (Hyndman et al., Forecasting with Exponential Smoothing,
+// class 1 models). c[0] is unused.
+func (m *Model) errorWeights(h int) []float64 {
+	c := make([]float64, max(h, 1))
+	if m.Method == MethodMean {
+		return c
+	}
+	for j := 1; j < h; j++ {
+		c[j] = m.Alpha * (1 + m.Alpha*m.Beta*dampedSum(j))
+		if m.Season > 0 && j%m.Season == 0 {
+			c[j] += (1 - m.Alpha) * m.Gamma
+		}
+	}
+	return c
+}
+
+// dampedSum is damping + damping² + ... + damping^h.
+func dampedSum(h int) float64 {
+	return damping * (1 - math.Pow(damping, float64(h))) / (1 - damping)
+}
+
+func zScore(confidence float64) float64 {
+	if confidence <= 0 || confidence >= 1 {
+		return 0
+	}
+	return math.Sqrt2 * math.Erfinv(confidence)
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
@@ -0,0 +1,97 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+)
+
+const z95 = 1.959963984540054
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+// A season repeated exactly is fitted without error: the level stays at
+// the season's mean and every step forecasts its own offset.
+func TestFitSeasonal(t *testing.T) {
+	var values []float64
+	for i := 0; i < 4; i++ {
+		values = append(values, 10, 20, 30, 20)
+	}
+	m := FitSeasonal(values, 4)
+	if m.Method != MethodHoltWinters || m.Season != 4 || m.Sigma != 0 {
+		t.Fatalf("model = %+v, want an exact Holt-Winters fit", m)
+	}
+	want := []float64{10, 20, 30, 20, 10, 20}
+	for i, e := range m.Forecast(6, 0.95) {
+		if !near(e.Value, want[i]) || e.Lower != e.Value || e.Upper != e.Value {
+			t.Errorf("step %d = %+v, want %v exactly", i+1, e, want[i])
+		}
+	}
+	if total := m.Total(4, 0.95); !near(total.Value, 80) {
+		t.Errorf("total = %+v, want 80", total)
+	}
+}
+
+// One pass of damped Holt by hand, level 10 and trend 2 to start:
+//
+//	t=1: predicted 10+0.98·2 = 11.96, error 0.04
+//	     level 0.5·12 + 0.5·11.96 = 11.98
+//	     trend 0.1·1.98 + 0.9·0.98·2 = 1.962
+//	t=2: predicted 11.98+0.98·1.962 = 13.90276, error 0.09724
+//	     level 0.5·14 + 0.5·13.90276 = 13.95138
+//	     trend 0.1·1.97138 + 0.9·0.98·1.962 = 1.927622
+func TestSmoothTrend(t *testing.T) {
+	m, sse := smooth([]float64{10, 12, 14}, 0, 0.5, 0.1, 0)
+	if !near(m.level, 13.95138) || !near(m.trend, 1.927622) {
+		t.Errorf("level, trend = %v, %v, want 13.95138, 1.927622", m.level, m.trend)
+	}
+	if !near(sse, 0.04*0.04+0.09724*0.09724) {
+		t.Errorf("sse = %v", sse)
+	}
+	if !near(m.Sigma, math.Sqrt(sse/2)) {
+		t.Errorf("sigma = %v, want the RMS of two errors", m.Sigma)
+	}
+}
+
+// From level 100 and trend 2 the trend is damped by 0.98 a step, and the
+// second step's interval widens by c1 = α(1 + αβ·0.98) = 0.5245.
+func TestForecastTrend(t *testing.T) {
+	m := &Model{Method: MethodHolt, Alpha: 0.5, Beta: 0.1, Sigma: 1, level: 100, trend: 2}
+	got := m.Forecast(2, 0.95)
+	if !near(got[0].Value, 101.96) || !near(got[1].Value, 103.8808) {
+		t.Errorf("values = %v, %v, want 101.96, 103.8808", got[0].Value, got[1].Value)
+	}
+	if !near(got[0].Upper-got[0].Value, z95) || !near(got[1].Upper-got[1].Value, z95*math.Sqrt(1+0.5245*0.5245)) {
+		t.Errorf("intervals = %+v", got)
+	}
+	// The first step's error shifts both steps of the total.
+	total := m.Total(2, 0.95)
+	if !near(total.Value, 205.8408) || !near(total.Upper-total.Value, z95*math.Sqrt(1+1.5245*1.5245)) {
+		t.Errorf("total = %+v", total)
+	}
+}
+
+// The seasonal offset of each step wraps around from where the series
+// ended.
+func TestForecastSeason(t *testing.T) {
+	m := &Model{Method: MethodHoltWinters, Season: 4, level: 50, seasonal: []float64{-5, 0, 5, 0}, next: 2}
+	want := []float64{55, 50, 45, 50, 55}
+	for i, e := range m.Forecast(5, 0.95) {
+		if !near(e.Value, want[i]) {
+			t.Errorf("step %d = %v, want %v", i+1, e.Value, want[i])
+		}
+	}
+}
+
+// Too short for a trend: the mean, with the sample standard deviation.
+func TestFitMean(t *testing.T) {
+	m := Fit([]float64{1, 2, 3})
+	if m.Method != MethodMean || !near(m.Sigma, 1) {
+		t.Fatalf("model = %+v, want the mean with sigma 1", m)
+	}
+	if got := m.Forecast(2, 0.95); !near(got[1].Value, 2) || !near(got[1].Upper, 2+z95) {
+		t.Errorf("forecast = %+v, want 2 ± %v", got, z95)
+	}
+	if total := m.Total(3, 0.95); !near(total.Value, 6) || !near(total.Upper, 6+z95*math.Sqrt(3)) {
+		t.Errorf("total = %+v, want 6 ± %v", total, z95*math.Sqrt(3))
+	}
+}
@@ -0,0 +1,277 @@
+package graviton
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/registry"
+)
+
+const (
+	labelArch = "kubernetes.io/arch"
+
+	// lookupConcurrency bounds parallel registry requests, which public
+	// registries rate-limit.
+	lookupConcurrency = 8
+)
+
+type Status string
+
+const (
+	StatusReady    Status = "ready"
+	StatusNotReady Status = "not-ready"
+	StatusUnknown  Status = "unknown"
+)
+
+// PlatformResolver returns the platforms an image is published for.
+// registry.Client implements it.
+type PlatformResolver interface {
+	Platforms(ctx context.Context, image string) ([]registry.Platform, error)
+}
+
+type Input struct {
+	Nodes  []k8s.NodeDetails
+	Pods   []k8s.PodDetails
+	Region string
+}
+
+type ImageStatus struct {
+	Image     string   `json:"image"`
+	Status    Status   `json:"status"`
+	Platforms []string `json:"platforms,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+type WorkloadReadiness struct {
+	Namespace string        `json:"namespace"`
+	Kind      string        `json:"kind"`
+	Name      string        `json:"name"`
+	Status    Status        `json:"status"`
+	Images    []ImageStatus `json:"images"`
+	Blockers  []string      `json:"blockers,omitempty"`
+	Nodes     []string      `json:"nodes"`
+	// MonthlySavings is the workload's share of the savings from moving the
+	// x86 nodes it runs on to their Graviton equivalents.
+	MonthlySavings float64 `json:"monthlySavings"`
+}
+
+type Report struct {
+	Workloads []WorkloadReadiness `json:"workloads"`
+	Summary   Summary             `json:"summary"`
+	Warnings  []string            `json:"warnings"`
+}
+
+type Summary struct {
+	Workloads int `json:"workloads"`
+	Ready     int `json:"ready"`
+	NotReady  int `json:"notReady"`
+	Unknown   int `json:"unknown"`
+	// ReadyMonthlySavings counts only arm64-ready workloads;
+	// PotentialMonthlySavings assumes every blocker gets fixed.
+	ReadyMonthlySavings     float64 `json:"readyMonthlySavings"`
+	PotentialMonthlySavings float64 `json:"potentialMonthlySavings"`
+}
+
+// Analyze reports, per workload, whether it can run on arm64: every
+// container image must publish linux/arm64, and no pod may be pinned to
+// amd64.
+func Analyze(ctx context.Context, input Input, resolver PlatformResolver, catalog *pricing.Catalog) *Report {
+	if input.Region == "" {
+		input.Region = catalog.DefaultRegion
+	}
+	report := &Report{Workloads: []WorkloadReadiness{}}
+
+	var images []string
+	seen := map[string]bool{}
+	for _, pod := range input.Pods {
+		for _, container := range pod.Containers {
+			if !seen[container.Image] {
+				seen[container.Image] = true
+				images = append(images, container.Image)
+			}
+		}
+	}
+	statuses := resolveImages(ctx, images, resolver)
+
+	savings := nodeSavings(input, catalog, report)
+	nodes := map[string]k8s.NodeDetails{}
+	for _, node := range input.Nodes {
+		nodes[node.Name] = node
+	}
+
+	byWorkload := map[string]*WorkloadReadiness{}
+	var keys []string
+	for _, pod := range input.Pods {
+		if pod.Status == "Succeeded" || pod.Status == "Failed" {
+			continue
+		}
+		kind, name := pod.OwnerKind, pod.OwnerName
+		if kind == "" {
+			kind, name = "Pod", pod.Name
+		}
+		key := pod.Namespace + "/" + kind + "/" + name
+		w, ok := byWorkload[key]
+		if !ok {
+			w = &WorkloadReadiness{Namespace: pod.Namespace, Kind: kind, Name: name, Images: []ImageStatus{}, Nodes: []string{}}
+			byWorkload[key] = w
+			keys = append(keys, key)
+		}
+
+		for _, container := range pod.Containers {
+			if !hasImage(w.Images, container.Image) {
+				w.Images = append(w.Images, statuses[container.Image])
+			}
+		}
+		if reason := PinnedToAMD64(pod); reason != "" && !slices.Contains(w.Blockers, reason) {
+			w.Blockers = append(w.Blockers, reason)
+		}
+		if pod.NodeName != "" && !slices.Contains(w.Nodes, pod.NodeName) {
+			w.Nodes = append(w.Nodes, pod.NodeName)
+		}
+		if node, ok := nodes[pod.NodeName]; ok {
+			w.MonthlySavings += savings[node.Name] * pod.NodeShare(node)
+		}
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		w := byWorkload[key]
+		w.Status = StatusReady
+		for _, image := range w.Images {
+			switch image.Status {
+			case StatusNotReady:
+				w.Blockers = append(w.Blockers, fmt.Sprintf("image %s has no linux/arm64 variant", image.Image))
+			case StatusUnknown:
+				if w.Status == StatusReady {
+					w.Status = StatusUnknown
+				}
+			}
+		}
+		if len(w.Blockers) > 0 {
+			w.Status = StatusNotReady
+		}
+		sort.Strings(w.Nodes)
+
+		report.Summary.Workloads++
+		report.Summary.PotentialMonthlySavings += w.MonthlySavings
+		switch w.Status {
+		case StatusReady:
+			report.Summary.Ready++
+			report.Summary.ReadyMonthlySavings += w.MonthlySavings
+		case StatusNotReady:
+			report.Summary.NotReady++
+		default:
+			report.Summary.Unknown++
+		}
+		report.Workloads = append(report.Workloads, *w)
+	}
+
+	return report
+}
+
+func resolveImages(ctx context.Context, images []string, resolver PlatformResolver) map[string]ImageStatus {
+	statuses := make(map[string]ImageStatus, len(images))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, lookupConcurrency)
+
+	for _, image := range images {
+		wg.Add(1)
+		go func(image string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			status := ImageStatus{Image: image, Status: StatusNotReady}
+			platforms, err := resolver.Platforms(ctx, image)
+			if err != nil {
+				status.Status = StatusUnknown
+				status.Error = err.Error()
+			}
+			for _, p := range platforms {
+				status.Platforms = append(status.Platforms, p.String())
+				if p.OS == "linux" && p.Architecture == "arm64" {
+					status.Status = StatusReady
+				}
+			}
+
+			mu.Lock()
+			statuses[image] = status
+			mu.Unlock()
+		}(image)
+	}
+	wg.Wait()
+	return statuses
+}
+
+// nodeSavings returns, per x86 node, the monthly savings of its cheapest
+// Graviton equivalent.
+func nodeSavings(input Input, catalog *pricing.Catalog, report *Report) map[string]float64 {
+	savings := map[string]float64{}
+	for _, node := range input.Nodes {
+		region := node.Region
+		if region == "" || region == "unknown" {
+			region = input.Region
+		}
+		current, ok := catalog.Lookup(region, node.InstanceType)
+		if !ok {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("no price for %s on node %s", node.InstanceType, node.Name))
+			continue
+		}
+		if current.Architecture != "amd64" || node.Labels[labelArch] == "arm64" {
+			continue
+		}
+		target, ok := catalog.Equivalent(region, current, node.IsSpot, func(t pricing.InstanceType) bool {
+			return t.Architecture == "arm64"
+		})
+		if ok {
+			savings[node.Name] = (current.HourlyPrice(node.IsSpot) - target.HourlyPrice(node.IsSpot)) * pricing.HoursPerMonth
+		}
+	}
+	return savings
+}
+
+// PinnedToAMD64 explains why the pod can only run on amd64 nodes, or
+// returns an empty string if it can run on arm64.
+func PinnedToAMD64(pod k8s.PodDetails) string {
+	if pod.NodeSelector[labelArch] == "amd64" {
+		return "nodeSelector pins kubernetes.io/arch=amd64"
+	}
+	if pod.Affinity == nil || pod.Affinity.NodeAffinity == nil || pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	// Terms are ORed, so the pod is pinned only if every term rules arm64 out.
+	terms := pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) == 0 {
+		return ""
+	}
+	for _, term := range terms {
+		excluded := false
+		for _, expr := range term.MatchExpressions {
+			if expr.Key != labelArch {
+				continue
+			}
+			if (expr.Operator == "In" && !slices.Contains(expr.Values, "arm64")) || (expr.Operator == "NotIn" && slices.Contains(expr.Values, "arm64")) {
+				excluded = true
+			}
+		}
+		if !excluded {
+			return ""
+		}
+	}
+	return "required node affinity excludes kubernetes.io/arch=arm64"
+}
+
+func hasImage(images []ImageStatus, image string) bool {
+	for _, i := range images {
+		if i.Image == image {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,20 @@
+package health
+
+import (
+	"fmt"
+	"os"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bolt reads the database and makes sure its file is still there, e.g. on
+// a volume that is still mounted. Stores built on bbolt check with it.
+func Bolt(db *bolt.DB, name string) error {
+	if err := db.View(func(*bolt.Tx) error { return nil }); err != nil {
+		return err
+	}
+	if _, err := os.Stat(db.Path()); err != nil {
+		return fmt.Errorf("%s database: %w", name, err)
+	}
+	return nil
+}
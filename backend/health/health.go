@@ -0,0 +1,147 @@
+// Package health serves the liveness and readiness of the server. /livez
+// answers as long as the process serves HTTP, so Kubernetes only restarts
+// a pod that hangs; /readyz answers only while every component the API
+// needs works, so a broken or stopping pod leaves the Service instead.
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkTimeout bounds each check, well within the probe timeout.
+const checkTimeout = 2 * time.Second
+
+// Check reports whether a component works.
+type Check func(ctx context.Context) error
+
+// Checker runs the readiness checks of the server's components.
+type Checker struct {
+	mu     sync.RWMutex
+	checks []namedCheck
+	// draining fails readiness once the server is stopping.
+	draining atomic.Bool
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name  string `json:"name"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report is the body of /readyz, and of /livez without checks.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+func New() *Checker {
+	return &Checker{}
+}
+
+// Add checks the component name for readiness.
+func (h *Checker) Add(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, namedCheck{name, check})
+}
+
+// Drain fails readiness from now on, while the server finishes the
+// requests it has.
+func (h *Checker) Drain() {
+	h.draining.Store(true)
+}
+
+// Ready runs every check at once and reports whether all of them passed.
+func (h *Checker) Ready(ctx context.Context) (bool, []Result) {
+	h.mu.RLock()
+	checks := append([]namedCheck(nil), h.checks...)
+	h.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+			defer cancel()
+			results[i] = Result{Name: c.name, OK: true}
+			if err := c.check(checkCtx); err != nil {
+				results[i].OK, results[i].Error = false, err.Error()
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	ready := !h.draining.Load()
+	results = append(results, Result{Name: "shutdown", OK: ready})
+	if !ready {
+		results[len(results)-1].Error = "the server is shutting down"
+	}
+	for _, result := range results {
+		ready = ready && result.OK
+	}
+	return ready, results
+}
+
+// HandleLivez answers as long as the server handles requests.
+func (h *Checker) HandleLivez(c *gin.Context) {
+	c.JSON(http.StatusOK, Report{Status: "ok"})
+}
+
+// HandleReadyz answers 200 when every check passed and 503 otherwise,
+// with the result of each check.
+func (h *Checker) HandleReadyz(c *gin.Context) {
+	ready, results := h.Ready(c.Request.Context())
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, Report{Status: "not ready", Checks: results})
+		return
+	}
+	c.JSON(http.StatusOK, Report{Status: "ready", Checks: results})
+}
+
+// Latch is a check that fails until a task succeeded once, such as the
+// first sync of the cluster.
+type Latch struct {
+	mu   sync.Mutex
+	done bool
+	err  error
+}
+
+// NewLatch returns a latch that fails with pending until the task ends.
+func NewLatch(pending string) *Latch {
+	return &Latch{err: errors.New(pending)}
+}
+
+// Set records the outcome of an attempt; nil passes the latch for good.
+func (l *Latch) Set(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.done {
+		return
+	}
+	if err == nil {
+		l.done, l.err = true, nil
+		return
+	}
+	l.err = err
+}
+
+func (l *Latch) Check(context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
@@ -0,0 +1,89 @@
+package history
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/clusters"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+)
+
+const (
+	DefaultInterval = 5 * time.Minute
+
+	compactInterval = time.Hour
+	collectTimeout  = time.Minute
+)
+
+// Collector samples every registered cluster into the store.
+type Collector struct {
+	store    *Store
+	clusters *clusters.Registry
+	catalog  *pricing.Catalog
+	interval time.Duration
+	// labels are the pod label keys cost is broken down by.
+	labels []string
+	// previous is the last sample of each cluster, for node churn.
+	previous map[string]*Sample
+}
+
+func NewCollector(store *Store, registry *clusters.Registry, catalog *pricing.Catalog, interval time.Duration, labels []string) *Collector {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Collector{store: store, clusters: registry, catalog: catalog, interval: interval, labels: labels, previous: map[string]*Sample{}}
+}
+
+// Run collects immediately and then on every interval until ctx is done.
+// Failures are logged and retried on the next tick, so one unreachable
+// cluster leaves a gap in its own history only.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	lastCompaction := time.Time{}
+	for {
+		c.collect(ctx)
+		if now := time.Now(); now.Sub(lastCompaction) >= compactInterval {
+			if err := c.store.Compact(now); err != nil {
+				slog.Error("history: compaction failed", "error", err)
+			}
+			lastCompaction = now
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *Collector) collect(ctx context.Context) {
+	for _, cluster := range c.clusters.List() {
+		// A snapshot never changes; sampling it would only repeat itself.
+		if cluster.Origin == clusters.OriginSnapshot {
+			continue
+		}
+		source, err := c.clusters.Get(cluster.Name)
+		if err != nil {
+			continue
+		}
+
+		collectCtx, cancel := context.WithTimeout(ctx, collectTimeout)
+		sample, err := Collect(collectCtx, cluster.Name, source, c.catalog, c.labels)
+		cancel()
+		if err != nil {
+			slog.Warn("history: failed to sample cluster", "cluster", cluster.Name, "error", err)
+			continue
+		}
+		if previous := c.previous[cluster.Name]; previous != nil {
+			sample.addChurn(previous)
+		}
+		c.previous[cluster.Name] = sample
+		if err := c.store.Put(sample); err != nil {
+			slog.Error("history: failed to record cluster", "cluster", cluster.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package history
+
+import (
+	"time"
+)
+
+const (
+	Day = 24 * time.Hour
+
+	// GroupByCluster breaks a daily series of the fleet down per cluster.
+	GroupByCluster = "cluster"
+)
+
+// DailySeries is recorded cost at one value per day, starting at the first
+// recorded day. Days without samples repeat the day before.
+type DailySeries struct {
+	From time.Time
+	Cost []float64
+	// VCPUs is the average CPU capacity of each day.
+	VCPUs []float64
+	// Groups is the cost of each value of the series' groupBy dimension.
+	Groups map[string][]float64
+}
+
+// Daily reads the daily cost of cluster between from and to, optionally
+// per value of groupBy: one of Dimensions, a label dimension, or
+// GroupByCluster. It returns nil when nothing was recorded.
+func (s *Store) Daily(cluster, groupBy string, from, to time.Time) (*DailySeries, error) {
+	from = from.Truncate(Day)
+	q := Query{Cluster: cluster, From: from, To: to, Step: Day}
+	if groupBy != GroupByCluster {
+		q.GroupBy = groupBy
+	}
+	total, err := s.Query(q)
+	if err != nil || len(total.Points) == 0 {
+		return nil, err
+	}
+
+	// Per-cluster groups are the clusters' own totals.
+	clusters := map[string]*Series{}
+	if groupBy == GroupByCluster {
+		names := []string{cluster}
+		if cluster == AllClusters {
+			if names, err = s.Clusters(); err != nil {
+				return nil, err
+			}
+		}
+		for _, name := range names {
+			q.Cluster = name
+			if clusters[name], err = s.Query(q); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	start := total.Points[0].Time
+	days := int(to.Sub(start)/Day) + 1
+	series := &DailySeries{
+		From:   start,
+		Cost:   make([]float64, days),
+		VCPUs:  make([]float64, days),
+		Groups: map[string][]float64{},
+	}
+	recorded := make([]bool, days)
+	for _, point := range total.Points {
+		i := int(point.Time.Sub(start) / Day)
+		recorded[i] = true
+		series.Cost[i] = point.Cost
+		series.VCPUs[i] = point.CPU.Capacity / 1000
+		for name, hourly := range point.Groups {
+			if series.Groups[name] == nil {
+				series.Groups[name] = make([]float64, days)
+			}
+			series.Groups[name][i] = hourly * 24
+		}
+	}
+	for name, group := range clusters {
+		series.Groups[name] = make([]float64, days)
+		for _, point := range group.Points {
+			series.Groups[name][int(point.Time.Sub(start)/Day)] = point.Cost
+		}
+	}
+
+	// A day without samples is a collection gap, not a day without cost.
+	for i := 1; i < days; i++ {
+		if recorded[i] {
+			continue
+		}
+		series.Cost[i] = series.Cost[i-1]
+		series.VCPUs[i] = series.VCPUs[i-1]
+		for _, group := range series.Groups {
+			group[i] = group[i-1]
+		}
+	}
+	return series, nil
+}
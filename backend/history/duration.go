@@ -0,0 +1,20 @@
+package history
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ParseDuration extends time.ParseDuration with a "d" unit for days, the
+// unit retention periods and reporting ranges are usually given in.
+func ParseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, &time.ParseError{Value: s, Message: ": invalid duration " + strconv.Quote(s)}
+		}
+		return time.Duration(n * float64(24*time.Hour)), nil
+	}
+	return time.ParseDuration(s)
+}
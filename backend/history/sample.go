@@ -0,0 +1,256 @@
+// Package history records how a cluster's inventory, cost and utilization
+// develop over time. A collector samples every registered cluster on an
+// interval into an embedded bbolt database, which keeps raw samples for a
+// while, downsamples them to hourly averages and drops them after the
+// retention period.
+package history
+
+import (
+	"context"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/simulator"
+)
+
+// Dimensions cost can be grouped by.
+const (
+	DimensionNodePool     = "nodepool"
+	DimensionInstanceType = "instanceType"
+	DimensionCapacityType = "capacityType"
+	DimensionZone         = "zone"
+	DimensionNamespace    = "namespace"
+	// DimensionLabelPrefix followed by a pod label key groups cost by the
+	// values of that label, for the keys the collector records.
+	DimensionLabelPrefix = "label:"
+
+	// IdleNamespace holds the part of node cost no pod requests, in the
+	// namespace and label dimensions.
+	IdleNamespace = "__idle__"
+	// Unlabelled is the label value of pods without the label.
+	Unlabelled = "__unlabelled__"
+	// Unmanaged is the nodepool value of nodes no NodePool owns.
+	Unmanaged = "__unmanaged__"
+)
+
+// Dimensions lists the valid groupBy values.
+var Dimensions = []string{DimensionNodePool, DimensionInstanceType, DimensionCapacityType, DimensionZone, DimensionNamespace}
+
+// Sample is one observation of a cluster. Downsampled samples average
+// Weight raw ones.
+type Sample struct {
+	Time       time.Time `json:"time"`
+	Cluster    string    `json:"cluster"`
+	Weight     int       `json:"weight"`
+	Nodes      float64   `json:"nodes"`
+	SpotNodes  float64   `json:"spotNodes"`
+	Pods       float64   `json:"pods"`
+	HourlyCost float64   `json:"hourlyCost"`
+	CPU        Usage     `json:"cpu"`
+	Memory     Usage     `json:"memory"`
+	// Costs is the hourly cost per dimension and value.
+	Costs map[string]map[string]float64 `json:"costs"`
+	// NodePools are the nodes per NodePool, Unmanaged for the rest.
+	NodePools map[string]PoolStats `json:"nodePools,omitempty"`
+
+	// nodes maps node names to their NodePool, to tell the churn since
+	// the previous sample.
+	nodes map[string]string
+}
+
+// PoolStats counts the nodes of one NodePool. Launches and Terminations
+// are per hour since the previous sample, and zero in the first sample a
+// collector takes of a cluster.
+type PoolStats struct {
+	Nodes        float64 `json:"nodes"`
+	SpotNodes    float64 `json:"spotNodes"`
+	Launches     float64 `json:"launches"`
+	Terminations float64 `json:"terminations"`
+}
+
+func (p *PoolStats) add(other PoolStats, factor float64) {
+	p.Nodes += other.Nodes * factor
+	p.SpotNodes += other.SpotNodes * factor
+	p.Launches += other.Launches * factor
+	p.Terminations += other.Terminations * factor
+}
+
+// Usage is capacity, requests and measured use of one resource, in
+// millicores or bytes. Used stays zero without metrics-server.
+type Usage struct {
+	Capacity  float64 `json:"capacity"`
+	Requested float64 `json:"requested"`
+	Used      float64 `json:"used"`
+}
+
+// LabelDimension is the dimension of the pod label key.
+func LabelDimension(key string) string {
+	return DimensionLabelPrefix + key
+}
+
+// Collect takes a sample of the cluster behind source, breaking cost down
+// by the values of the given pod label keys next to the fixed Dimensions.
+func Collect(ctx context.Context, cluster string, source k8s.ClusterSource, catalog *pricing.Catalog, labels []string) (*Sample, error) {
+	nodeInfo, err := source.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	podInfo, err := source.GetPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Metrics are optional; without them only requests are recorded.
+	usage, _ := source.GetPodMetrics(ctx, "")
+
+	sample := &Sample{
+		Time:      time.Now().UTC().Truncate(time.Second),
+		Cluster:   cluster,
+		Weight:    1,
+		Costs:     map[string]map[string]float64{},
+		NodePools: map[string]PoolStats{},
+		nodes:     map[string]string{},
+	}
+	for _, dimension := range Dimensions {
+		sample.Costs[dimension] = map[string]float64{}
+	}
+	for _, key := range labels {
+		sample.Costs[LabelDimension(key)] = map[string]float64{}
+	}
+
+	podsByNode := map[string][]k8s.PodDetails{}
+	for _, pod := range podInfo.Pods {
+		if pod.Status == "Succeeded" || pod.Status == "Failed" {
+			continue
+		}
+		sample.Pods++
+		if pod.NodeName != "" {
+			podsByNode[pod.NodeName] = append(podsByNode[pod.NodeName], pod)
+		}
+		for _, container := range usage[pod.Namespace+"/"+pod.Name].Containers {
+			sample.CPU.Used += float64(container.CPU)
+			sample.Memory.Used += float64(container.Memory)
+		}
+	}
+
+	for _, node := range nodeInfo.Nodes {
+		sample.Nodes++
+		capacityType := "on-demand"
+		if node.IsSpot {
+			sample.SpotNodes++
+			capacityType = "spot"
+		}
+
+		price := NodePrice(&node, catalog)
+		sample.HourlyCost += price
+		sample.CPU.Capacity += float64(node.AllocatableCPU)
+		sample.Memory.Capacity += float64(node.AllocatableMemory)
+
+		nodePool := node.NodePool
+		if nodePool == "" {
+			nodePool = Unmanaged
+		}
+		sample.Costs[DimensionNodePool][nodePool] += price
+		sample.nodes[node.Name] = nodePool
+		stats := sample.NodePools[nodePool]
+		stats.Nodes++
+		if node.IsSpot {
+			stats.SpotNodes++
+		}
+		sample.NodePools[nodePool] = stats
+		sample.Costs[DimensionInstanceType][node.InstanceType] += price
+		sample.Costs[DimensionCapacityType][capacityType] += price
+		sample.Costs[DimensionZone][node.Zone] += price
+
+		pods := podsByNode[node.Name]
+		for _, pod := range pods {
+			sample.CPU.Requested += float64(pod.CPURequest)
+			sample.Memory.Requested += float64(pod.MemoryRequest)
+		}
+		shares, idle := PodCosts(node, pods, price)
+		sample.Costs[DimensionNamespace][IdleNamespace] += idle
+		for _, key := range labels {
+			sample.Costs[LabelDimension(key)][IdleNamespace] += idle
+		}
+		for i, pod := range pods {
+			if shares[i] == 0 {
+				continue
+			}
+			sample.Costs[DimensionNamespace][pod.Namespace] += shares[i]
+			for _, key := range labels {
+				value, ok := pod.Labels[key]
+				if !ok {
+					value = Unlabelled
+				}
+				sample.Costs[LabelDimension(key)][value] += shares[i]
+			}
+		}
+	}
+
+	return sample, nil
+}
+
+// NodePrice is the hourly price of node, zero when the catalog doesn't
+// know it. Nodes that don't report allocatable get Karpenter's estimate.
+func NodePrice(node *k8s.NodeDetails, catalog *pricing.Catalog) float64 {
+	region := node.Region
+	if region == "" || region == "unknown" {
+		region = catalog.DefaultRegion
+	}
+	instanceType, ok := catalog.Lookup(region, node.InstanceType)
+	if !ok {
+		return 0
+	}
+	if node.AllocatableCPU == 0 || node.AllocatableMemory == 0 {
+		node.AllocatableCPU, node.AllocatableMemory = simulator.Allocatable(instanceType)
+	}
+	return instanceType.HourlyPrice(node.IsSpot)
+}
+
+// addChurn records the nodes launched and terminated since previous.
+func (s *Sample) addChurn(previous *Sample) {
+	hours := s.Time.Sub(previous.Time).Hours()
+	if hours <= 0 {
+		return
+	}
+	for name, nodePool := range s.nodes {
+		if _, ok := previous.nodes[name]; !ok {
+			stats := s.NodePools[nodePool]
+			stats.Launches += 1 / hours
+			s.NodePools[nodePool] = stats
+		}
+	}
+	for name, nodePool := range previous.nodes {
+		if _, ok := s.nodes[name]; !ok {
+			stats := s.NodePools[nodePool]
+			stats.Terminations += 1 / hours
+			s.NodePools[nodePool] = stats
+		}
+	}
+}
+
+// PodCosts splits a node's price between its pods by the larger of each
+// pod's CPU and memory share of the node. What no pod requests is idle.
+func PodCosts(node k8s.NodeDetails, pods []k8s.PodDetails, price float64) (costs []float64, idle float64) {
+	costs = make([]float64, len(pods))
+	allocated := 0.0
+	for i, pod := range pods {
+		share := 0.0
+		if node.AllocatableCPU > 0 {
+			share = float64(pod.CPURequest) / float64(node.AllocatableCPU)
+		}
+		if node.AllocatableMemory > 0 {
+			share = max(share, float64(pod.MemoryRequest)/float64(node.AllocatableMemory))
+		}
+		share = min(share, 1-allocated)
+		if share <= 0 {
+			continue
+		}
+		allocated += share
+		costs[i] = price * share
+	}
+	if allocated < 1 {
+		idle = price * (1 - allocated)
+	}
+	return costs, idle
+}
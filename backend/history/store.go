@@ -0,0 +1,464 @@
+package history
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/health"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	DefaultRetention       = 90 * 24 * time.Hour
+	DefaultDownsampleAfter = 7 * 24 * time.Hour
+
+	// AllClusters queries the sum over every recorded cluster.
+	AllClusters = "*"
+
+	downsampleStep = time.Hour
+	maxPoints      = 10000
+)
+
+var (
+	bucketRaw    = []byte("raw")
+	bucketHourly = []byte("hourly")
+	bucketState  = []byte("state")
+)
+
+type Options struct {
+	// Retention is how long samples are kept at all.
+	Retention time.Duration
+	// DownsampleAfter is the age at which raw samples are averaged into
+	// hourly ones.
+	DownsampleAfter time.Duration
+}
+
+// Store persists samples in a bbolt file: one bucket per resolution, one
+// nested bucket per cluster, keyed by big-endian Unix seconds so range
+// scans come out in time order.
+type Store struct {
+	db   *bolt.DB
+	opts Options
+}
+
+func Open(path string, opts Options) (*Store, error) {
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultRetention
+	}
+	if opts.DownsampleAfter <= 0 || opts.DownsampleAfter > opts.Retention {
+		opts.DownsampleAfter = min(DefaultDownsampleAfter, opts.Retention)
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database: %w", err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketRaw, bucketHourly, bucketState} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history database: %w", err)
+	}
+	return &Store{db: db, opts: opts}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Check reads the database and makes sure its file is still there.
+func (s *Store) Check(context.Context) error {
+	return health.Bolt(s.db, "history")
+}
+
+// Put records a raw sample.
+func (s *Store) Put(sample *Sample) error {
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.Bucket(bucketRaw).CreateBucketIfNotExists([]byte(sample.Cluster))
+		if err != nil {
+			return err
+		}
+		return b.Put(timeKey(sample.Time), data)
+	})
+}
+
+// Compact averages raw samples older than DownsampleAfter into hourly
+// samples and deletes everything older than Retention.
+func (s *Store) Compact(now time.Time) error {
+	downsampleBefore := now.Add(-s.opts.DownsampleAfter).Truncate(downsampleStep)
+	retainAfter := now.Add(-s.opts.Retention)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		raw, hourly := tx.Bucket(bucketRaw), tx.Bucket(bucketHourly)
+		return raw.ForEachBucket(func(cluster []byte) error {
+			rawCluster := raw.Bucket(cluster)
+			hourlyCluster, err := hourly.CreateBucketIfNotExists(cluster)
+			if err != nil {
+				return err
+			}
+
+			hours := map[int64]*aggregate{}
+			var stale [][]byte
+			c := rawCluster.Cursor()
+			for k, v := c.First(); k != nil && keyTime(k).Before(downsampleBefore); k, v = c.Next() {
+				stale = append(stale, append([]byte(nil), k...))
+				var sample Sample
+				if err := json.Unmarshal(v, &sample); err != nil {
+					continue
+				}
+				hour := sample.Time.Truncate(downsampleStep).Unix()
+				if hours[hour] == nil {
+					hours[hour] = &aggregate{}
+					if existing := hourlyCluster.Get(timeKey(time.Unix(hour, 0))); existing != nil {
+						var previous Sample
+						if json.Unmarshal(existing, &previous) == nil {
+							hours[hour].add(&previous)
+						}
+					}
+				}
+				hours[hour].add(&sample)
+			}
+			for _, k := range stale {
+				if err := rawCluster.Delete(k); err != nil {
+					return err
+				}
+			}
+			for hour, agg := range hours {
+				sample := agg.sample(time.Unix(hour, 0).UTC(), string(cluster))
+				data, err := json.Marshal(sample)
+				if err != nil {
+					return err
+				}
+				if err := hourlyCluster.Put(timeKey(sample.Time), data); err != nil {
+					return err
+				}
+			}
+
+			for _, b := range []*bolt.Bucket{rawCluster, hourlyCluster} {
+				if err := deleteBefore(b, retainAfter); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
+// Clusters lists the clusters with recorded samples.
+func (s *Store) Clusters() ([]string, error) {
+	seen := map[string]bool{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketRaw, bucketHourly} {
+			err := tx.Bucket(name).ForEachBucket(func(k []byte) error {
+				seen[string(k)] = true
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	clusters := make([]string, 0, len(seen))
+	for cluster := range seen {
+		clusters = append(clusters, cluster)
+	}
+	sort.Strings(clusters)
+	return clusters, err
+}
+
+// SaveState keeps v, JSON encoded, under name. Subsystems that build on
+// the history, such as budget alerting, persist their own state with it.
+func (s *Store) SaveState(name string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketState).Put([]byte(name), data)
+	})
+}
+
+// LoadState decodes what SaveState kept under name into v, and leaves v
+// alone when nothing was saved.
+func (s *Store) LoadState(name string, v interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketState).Get([]byte(name))
+		if data == nil {
+			return nil
+		}
+		return json.Unmarshal(data, v)
+	})
+}
+
+type Query struct {
+	// Cluster is a cluster name, or AllClusters to sum the fleet.
+	Cluster string
+	From    time.Time
+	To      time.Time
+	Step    time.Duration
+	// GroupBy is one of Dimensions, or empty for totals only.
+	GroupBy string
+}
+
+type Series struct {
+	Cluster string    `json:"cluster"`
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Step    string    `json:"step"`
+	GroupBy string    `json:"groupBy,omitempty"`
+	Points  []Point   `json:"points"`
+	// TotalCost is the spend over the whole range.
+	TotalCost float64 `json:"totalCost"`
+}
+
+// Point averages the samples of one step. Cost is the spend over the step,
+// assuming the average hourly cost held throughout it.
+type Point struct {
+	Time       time.Time            `json:"time"`
+	Samples    int                  `json:"samples"`
+	Nodes      float64              `json:"nodes"`
+	SpotNodes  float64              `json:"spotNodes"`
+	Pods       float64              `json:"pods"`
+	HourlyCost float64              `json:"hourlyCost"`
+	Cost       float64              `json:"cost"`
+	CPU        Usage                `json:"cpu"`
+	Memory     Usage                `json:"memory"`
+	Groups     map[string]float64   `json:"groups,omitempty"`
+	NodePools  map[string]PoolStats `json:"nodePools,omitempty"`
+}
+
+// Query returns the samples in [From, To) averaged per step. Steps without
+// samples are left out rather than reported as zero.
+func (s *Store) Query(q Query) (*Series, error) {
+	if !q.To.After(q.From) {
+		return nil, fmt.Errorf("from must be before to")
+	}
+	if q.Step <= 0 {
+		return nil, fmt.Errorf("step must be positive")
+	}
+	if q.To.Sub(q.From)/q.Step > maxPoints {
+		return nil, fmt.Errorf("range and step give more than %d points", maxPoints)
+	}
+	if q.GroupBy != "" && !validDimension(q.GroupBy) {
+		return nil, fmt.Errorf("unknown groupBy %q", q.GroupBy)
+	}
+
+	clusters := []string{q.Cluster}
+	if q.Cluster == AllClusters {
+		var err error
+		if clusters, err = s.Clusters(); err != nil {
+			return nil, err
+		}
+	}
+
+	// steps[cluster][step] aggregates one cluster's samples in one step.
+	steps := map[string]map[int64]*aggregate{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		for _, cluster := range clusters {
+			steps[cluster] = map[int64]*aggregate{}
+			for _, name := range [][]byte{bucketHourly, bucketRaw} {
+				b := tx.Bucket(name).Bucket([]byte(cluster))
+				if b == nil {
+					continue
+				}
+				c := b.Cursor()
+				for k, v := c.Seek(timeKey(q.From)); k != nil && keyTime(k).Before(q.To); k, v = c.Next() {
+					var sample Sample
+					if err := json.Unmarshal(v, &sample); err != nil {
+						continue
+					}
+					step := int64(sample.Time.Sub(q.From) / q.Step)
+					if steps[cluster][step] == nil {
+						steps[cluster][step] = &aggregate{}
+					}
+					steps[cluster][step].add(&sample)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Average each cluster within a step, then add the clusters up.
+	points := map[int64]*Point{}
+	for _, clusterSteps := range steps {
+		for step, agg := range clusterSteps {
+			sample := agg.sample(q.From.Add(time.Duration(step)*q.Step), q.Cluster)
+			point := points[step]
+			if point == nil {
+				point = &Point{Time: sample.Time}
+				points[step] = point
+			}
+			point.addSample(sample, q.GroupBy)
+		}
+	}
+
+	series := &Series{
+		Cluster: q.Cluster,
+		From:    q.From,
+		To:      q.To,
+		Step:    q.Step.String(),
+		GroupBy: q.GroupBy,
+		Points:  []Point{},
+	}
+	for _, point := range points {
+		point.Cost = point.HourlyCost * q.Step.Hours()
+		series.TotalCost += point.Cost
+		series.Points = append(series.Points, *point)
+	}
+	sort.Slice(series.Points, func(i, j int) bool { return series.Points[i].Time.Before(series.Points[j].Time) })
+	return series, nil
+}
+
+func (p *Point) addSample(sample *Sample, groupBy string) {
+	p.Samples += sample.Weight
+	p.Nodes += sample.Nodes
+	p.SpotNodes += sample.SpotNodes
+	p.Pods += sample.Pods
+	p.HourlyCost += sample.HourlyCost
+	p.CPU.add(sample.CPU, 1)
+	p.Memory.add(sample.Memory, 1)
+	for nodePool, stats := range sample.NodePools {
+		if p.NodePools == nil {
+			p.NodePools = map[string]PoolStats{}
+		}
+		sum := p.NodePools[nodePool]
+		sum.add(stats, 1)
+		p.NodePools[nodePool] = sum
+	}
+	if groupBy != "" {
+		if p.Groups == nil {
+			p.Groups = map[string]float64{}
+		}
+		for value, cost := range sample.Costs[groupBy] {
+			p.Groups[value] += cost
+		}
+	}
+}
+
+// aggregate is a weighted running sum of samples.
+type aggregate struct {
+	weight int
+	sum    Sample
+}
+
+func (a *aggregate) add(sample *Sample) {
+	w := max(sample.Weight, 1)
+	a.weight += w
+	f := float64(w)
+	a.sum.Nodes += sample.Nodes * f
+	a.sum.SpotNodes += sample.SpotNodes * f
+	a.sum.Pods += sample.Pods * f
+	a.sum.HourlyCost += sample.HourlyCost * f
+	a.sum.CPU.add(sample.CPU, f)
+	a.sum.Memory.add(sample.Memory, f)
+	if a.sum.Costs == nil {
+		a.sum.Costs = map[string]map[string]float64{}
+	}
+	if a.sum.NodePools == nil {
+		a.sum.NodePools = map[string]PoolStats{}
+	}
+	for nodePool, stats := range sample.NodePools {
+		sum := a.sum.NodePools[nodePool]
+		sum.add(stats, f)
+		a.sum.NodePools[nodePool] = sum
+	}
+	for dimension, values := range sample.Costs {
+		if a.sum.Costs[dimension] == nil {
+			a.sum.Costs[dimension] = map[string]float64{}
+		}
+		for value, cost := range values {
+			a.sum.Costs[dimension][value] += cost * f
+		}
+	}
+}
+
+// sample returns the weighted average as a sample carrying the weight.
+func (a *aggregate) sample(t time.Time, cluster string) *Sample {
+	f := float64(a.weight)
+	avg := &Sample{
+		Time:       t,
+		Cluster:    cluster,
+		Weight:     a.weight,
+		Nodes:      a.sum.Nodes / f,
+		SpotNodes:  a.sum.SpotNodes / f,
+		Pods:       a.sum.Pods / f,
+		HourlyCost: a.sum.HourlyCost / f,
+		Costs:      map[string]map[string]float64{},
+		NodePools:  map[string]PoolStats{},
+	}
+	for nodePool, stats := range a.sum.NodePools {
+		var pool PoolStats
+		pool.add(stats, 1/f)
+		avg.NodePools[nodePool] = pool
+	}
+	avg.CPU.add(a.sum.CPU, 1/f)
+	avg.Memory.add(a.sum.Memory, 1/f)
+	for dimension, values := range a.sum.Costs {
+		avg.Costs[dimension] = map[string]float64{}
+		for value, cost := range values {
+			avg.Costs[dimension][value] = cost / f
+		}
+	}
+	return avg
+}
+
+func (u *Usage) add(other Usage, factor float64) {
+	u.Capacity += other.Capacity * factor
+	u.Requested += other.Requested * factor
+	u.Used += other.Used * factor
+}
+
+func deleteBefore(b *bolt.Bucket, before time.Time) error {
+	var stale [][]byte
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && keyTime(k).Before(before); k, _ = c.Next() {
+		stale = append(stale, append([]byte(nil), k...))
+	}
+	for _, k := range stale {
+		if err := b.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.Unix()))
+	return key
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(int64(binary.BigEndian.Uint64(key)), 0).UTC()
+}
+
+func validDimension(dimension string) bool {
+	if key, ok := strings.CutPrefix(dimension, DimensionLabelPrefix); ok {
+		return key != ""
+	}
+	for _, d := range Dimensions {
+		if d == dimension {
+			return true
+		}
+	}
+	return false
+}
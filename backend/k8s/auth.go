@@ -0,0 +1,47 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ReviewToken asks the API server whether token is valid for audiences,
+// and whose it is.
+func (c *K8sClient) ReviewToken(ctx context.Context, token string, audiences []string) (bool, string, []string, error) {
+	review := &authenticationv1.TokenReview{
+		Spec: authenticationv1.TokenReviewSpec{Token: token, Audiences: audiences},
+	}
+	result, err := c.clientset.AuthenticationV1().TokenReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", nil, fmt.Errorf("failed to review token: %w", err)
+	}
+	if !result.Status.Authenticated {
+		return false, "", nil, nil
+	}
+	return true, result.Status.User.Username, result.Status.User.Groups, nil
+}
+
+// ReviewAccess asks the API server whether user, a member of groups, may
+// verb resource in namespace, or in every namespace when it is empty.
+func (c *K8sClient) ReviewAccess(ctx context.Context, user string, groups []string, verb, resource, namespace string) (bool, error) {
+	review := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   user,
+			Groups: groups,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      verb,
+				Resource:  resource,
+			},
+		},
+	}
+	result, err := c.clientset.AuthorizationV1().SubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to review access: %w", err)
+	}
+	return result.Status.Allowed, nil
+}
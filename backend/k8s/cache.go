@@ -0,0 +1,89 @@
+package k8s
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// CachedClient is a K8sClient that reuses what the cluster reported for a
+// while, so a dashboard refresh doesn't list every pod of the cluster
+// again. Callers treat the answers as read-only, as they already do with
+// snapshots.
+type CachedClient struct {
+	*K8sClient
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   interface{}
+	fetched time.Time
+}
+
+var _ ClusterSource = (*CachedClient)(nil)
+
+func NewCachedClient(client *K8sClient, ttl time.Duration) *CachedClient {
+	return &CachedClient{K8sClient: client, ttl: ttl, entries: map[string]cacheEntry{}}
+}
+
+func (c *CachedClient) GetNodes(ctx context.Context) (*NodeInfo, error) {
+	return cached(c, "nodes", func() (*NodeInfo, error) { return c.K8sClient.GetNodes(ctx) })
+}
+
+func (c *CachedClient) GetPods(ctx context.Context) (*PodInfo, error) {
+	return cached(c, "pods", func() (*PodInfo, error) { return c.K8sClient.GetPods(ctx) })
+}
+
+func (c *CachedClient) GetPDBs(ctx context.Context) ([]PDBDetails, error) {
+	return cached(c, "pdbs", func() ([]PDBDetails, error) { return c.K8sClient.GetPDBs(ctx) })
+}
+
+func (c *CachedClient) GetNodePools(ctx context.Context) ([]NodePoolDetails, error) {
+	return cached(c, "nodepools", func() ([]NodePoolDetails, error) { return c.K8sClient.GetNodePools(ctx) })
+}
+
+func (c *CachedClient) GetNodeClasses(ctx context.Context) ([]NodeClassDetails, error) {
+	return cached(c, "nodeclasses", func() ([]NodeClassDetails, error) { return c.K8sClient.GetNodeClasses(ctx) })
+}
+
+func (c *CachedClient) GetPodMetrics(ctx context.Context, namespace string) (map[string]PodUsage, error) {
+	return cached(c, "metrics/"+namespace, func() (map[string]PodUsage, error) {
+		return c.K8sClient.GetPodMetrics(ctx, namespace)
+	})
+}
+
+// FetchedAt returns when each cached answer was fetched from the API
+// server, by the key it is stored under, such as "pods".
+func (c *CachedClient) FetchedAt() map[string]time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fetched := make(map[string]time.Time, len(c.entries))
+	for key, entry := range c.entries {
+		fetched[key] = entry.fetched
+	}
+	return fetched
+}
+
+// cached returns the answer stored under key, or fetches and stores it
+// when there is none or it expired. Errors aren't stored.
+func cached[T any](c *CachedClient, key string, fetch func() (T, error)) (T, error) {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && now.Before(entry.fetched.Add(c.ttl)) {
+		return entry.value.(T), nil
+	}
+
+	value, err := fetch()
+	if err != nil {
+		return value, err
+	}
+	c.mu.Lock()
+	c.entries[key] = cacheEntry{value: value, fetched: now}
+	c.mu.Unlock()
+	return value, nil
+}
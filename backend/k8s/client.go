@@ -5,8 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
+	"github.com/edsf-foundation/karp-ops-wiz/backend/logging"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -15,42 +20,94 @@ import (
 
 type K8sClient struct {
 	clientset *kubernetes.Clientset
+	dynamic   dynamic.Interface
 	config    *rest.Config
 }
 
 func NewK8sClient() (*K8sClient, error) {
+	return NewK8sClientForContext("", "")
+}
+
+// NewK8sClientForContext connects with an explicit kubeconfig file and
+// context. When neither is given, the in-cluster config is tried first and
+// then the default kubeconfig, honouring $KUBECONFIG.
+func NewK8sClientForContext(kubeconfig, kubecontext string) (*K8sClient, error) {
 	var config *rest.Config
 	var err error
 
-	// Try in-cluster config first
-	config, err = rest.InClusterConfig()
-	if err != nil {
-		// Fall back to kubeconfig
-		var kubeconfig string
-		if home := homedir.HomeDir(); home != "" {
-			kubeconfig = filepath.Join(home, ".kube", "config")
-		}
-		if kubeconfigEnv := os.Getenv("KUBECONFIG"); kubeconfigEnv != "" {
-			kubeconfig = kubeconfigEnv
-		}
-
-		config, err = clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if kubeconfig == "" && kubecontext == "" {
+		config, err = rest.InClusterConfig()
+	}
+	if config == nil {
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: kubecontext}
+		config, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules(kubeconfig), overrides).ClientConfig()
 		if err != nil {
 			return nil, fmt.Errorf("failed to build kubeconfig: %w", err)
 		}
 	}
 
+	return NewK8sClientForConfig(config)
+}
+
+// NewK8sClientForConfig connects with a ready-made REST config. Calls
+// made for a request carry its request ID.
+func NewK8sClientForConfig(config *rest.Config) (*K8sClient, error) {
+	config = rest.CopyConfig(config)
+	config.Wrap(logging.Transport)
+
 	clientset, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create clientset: %w", err)
 	}
 
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
 	return &K8sClient{
 		clientset: clientset,
+		dynamic:   dynamicClient,
 		config:    config,
 	}, nil
 }
 
+// KubeconfigContexts lists the contexts of a kubeconfig file, resolved the
+// same way as NewK8sClientForContext, along with the current context.
+func KubeconfigContexts(kubeconfig string) ([]string, string, error) {
+	raw, err := loadingRules(kubeconfig).Load()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	contexts := make([]string, 0, len(raw.Contexts))
+	for name := range raw.Contexts {
+		contexts = append(contexts, name)
+	}
+	sort.Strings(contexts)
+	return contexts, raw.CurrentContext, nil
+}
+
+func loadingRules(kubeconfig string) *clientcmd.ClientConfigLoadingRules {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if kubeconfig != "" {
+		rules.ExplicitPath = kubeconfig
+	} else if os.Getenv("KUBECONFIG") == "" {
+		if home := homedir.HomeDir(); home != "" {
+			rules.ExplicitPath = filepath.Join(home, ".kube", "config")
+		}
+	}
+	return rules
+}
+
+// Check asks the API server for its version, which every client may
+// read, to tell whether it can be reached.
+func (c *K8sClient) Check(ctx context.Context) error {
+	if err := c.clientset.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Error(); err != nil {
+		return fmt.Errorf("failed to reach the API server: %w", err)
+	}
+	return nil
+}
+
 func (c *K8sClient) GetNodes(ctx context.Context) (*NodeInfo, error) {
 	nodes, err := c.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
@@ -69,6 +126,11 @@ func (c *K8sClient) GetNodes(ctx context.Context) (*NodeInfo, error) {
 			Zone:      c.getZoneFromLabels(node.Labels),
 			IsSpot:    c.isSpotInstance(node.Labels),
 			State:     string(node.Status.Conditions[0].Type),
+			Labels:    node.Labels,
+			Taints:    node.Spec.Taints,
+			NodePool:  node.Labels[NodePoolLabel],
+			DoNotDisrupt: node.Annotations[DoNotDisruptAnnotation] == "true",
+			CreatedAt: node.CreationTimestamp.Time,
 		}
 
         // Extract CPU (milli) and memory (bytes) from resources
@@ -84,6 +146,16 @@ func (c *K8sClient) GetNodes(ctx context.Context) (*NodeInfo, error) {
             nodeDetail.MemoryGB = memoryBytes / (1024 * 1024 * 1024)
         }
 
+		if cpuQ, ok := node.Status.Allocatable["cpu"]; ok {
+			nodeDetail.AllocatableCPU = cpuQ.MilliValue()
+		}
+		if memQ, ok := node.Status.Allocatable["memory"]; ok {
+			nodeDetail.AllocatableMemory = memQ.Value()
+		}
+		if podsQ, ok := node.Status.Allocatable["pods"]; ok {
+			nodeDetail.MaxPods = podsQ.Value()
+		}
+
 		if nodeDetail.IsSpot {
 			spotNodes++
 		} else {
@@ -109,6 +181,11 @@ func (c *K8sClient) GetPods(ctx context.Context) (*PodInfo, error) {
 		return nil, fmt.Errorf("failed to list pods: %w", err)
 	}
 
+	owners, err := c.getOwnerIndex(ctx)
+	if err != nil {
+		return nil, err
+	}
+
     var totalCPUMilli, totalMemoryBytes int64
 	podDetails := []PodDetails{}
 
@@ -118,17 +195,58 @@ func (c *K8sClient) GetPods(ctx context.Context) (*PodInfo, error) {
 			Namespace: pod.Namespace,
 			NodeName:  pod.Spec.NodeName,
 			Status:    string(pod.Status.Phase),
+			Labels:    pod.Labels,
+			NodeSelector:              pod.Spec.NodeSelector,
+			Affinity:                  pod.Spec.Affinity,
+			Tolerations:               pod.Spec.Tolerations,
+			TopologySpreadConstraints: pod.Spec.TopologySpreadConstraints,
+			DoNotDisrupt:              pod.Annotations[DoNotDisruptAnnotation] == "true",
+			PriorityClassName:         pod.Spec.PriorityClassName,
+		}
+		podDetail.OwnerKind, podDetail.OwnerName = owners.resolve(pod.Namespace, pod.OwnerReferences)
+		if pod.Spec.TerminationGracePeriodSeconds != nil {
+			podDetail.TerminationGracePeriodSeconds = *pod.Spec.TerminationGracePeriodSeconds
+		}
+		if pod.Spec.Priority != nil {
+			podDetail.Priority = *pod.Spec.Priority
+		}
+		for _, volume := range pod.Spec.Volumes {
+			if volume.HostPath != nil || (volume.EmptyDir != nil && volume.EmptyDir.Medium != corev1.StorageMediumMemory) {
+				podDetail.LocalVolumes = append(podDetail.LocalVolumes, volume.Name)
+			}
 		}
 
         // Calculate resource requests (CPU in milli, memory in bytes)
         var podCPUMilli, podMemoryBytes int64
 		for _, container := range pod.Spec.Containers {
+			containerDetail := ContainerDetails{
+				Name:  container.Name,
+				Image: container.Image,
+			}
             if cpuQ, ok := container.Resources.Requests["cpu"]; ok {
                 podCPUMilli += cpuQ.MilliValue()
+				containerDetail.CPURequest = cpuQ.MilliValue()
             }
             if memQ, ok := container.Resources.Requests["memory"]; ok {
                 podMemoryBytes += memQ.Value()
+				containerDetail.MemoryRequest = memQ.Value()
             }
+			if cpuQ, ok := container.Resources.Limits["cpu"]; ok {
+				containerDetail.CPULimit = cpuQ.MilliValue()
+			}
+			if memQ, ok := container.Resources.Limits["memory"]; ok {
+				containerDetail.MemoryLimit = memQ.Value()
+			}
+			podDetail.Containers = append(podDetail.Containers, containerDetail)
+
+			for _, port := range container.Ports {
+				if port.HostPort > 0 {
+					podDetail.HostPorts = append(podDetail.HostPorts, HostPort{
+						Port:     port.HostPort,
+						Protocol: string(port.Protocol),
+					})
+				}
+			}
 		}
 
         podDetail.CPURequest = podCPUMilli
@@ -202,6 +320,14 @@ type NodeDetails struct {
 	State        string `json:"state"`
 	CPUCores     int64  `json:"cpuCores"`
 	MemoryGB     int64  `json:"memoryGb"`
+	AllocatableCPU    int64 `json:"allocatableCpu"`    // millicores
+	AllocatableMemory int64 `json:"allocatableMemory"` // bytes
+	MaxPods           int64 `json:"maxPods"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	Taints            []corev1.Taint    `json:"taints,omitempty"`
+	NodePool          string            `json:"nodePool,omitempty"`
+	DoNotDisrupt      bool              `json:"doNotDisrupt,omitempty"`
+	CreatedAt         time.Time         `json:"createdAt"`
 }
 
 type PodInfo struct {
@@ -218,4 +344,49 @@ type PodDetails struct {
 	Status        string `json:"status"`
 	CPURequest    int64  `json:"cpuRequest"`
 	MemoryRequest int64  `json:"memoryRequest"`
+	OwnerKind     string `json:"ownerKind,omitempty"`
+	OwnerName     string `json:"ownerName,omitempty"`
+	Containers    []ContainerDetails `json:"containers,omitempty"`
+
+	// Scheduling constraints, used by the rebalancing simulator
+	Labels                    map[string]string                   `json:"labels,omitempty"`
+	NodeSelector              map[string]string                   `json:"nodeSelector,omitempty"`
+	Affinity                  *corev1.Affinity                    `json:"affinity,omitempty"`
+	Tolerations               []corev1.Toleration                 `json:"tolerations,omitempty"`
+	TopologySpreadConstraints []corev1.TopologySpreadConstraint   `json:"topologySpreadConstraints,omitempty"`
+	HostPorts                 []HostPort                          `json:"hostPorts,omitempty"`
+	DoNotDisrupt              bool                                `json:"doNotDisrupt,omitempty"`
+
+	// Interruption tolerance, used by spot eligibility scoring
+	TerminationGracePeriodSeconds int64    `json:"terminationGracePeriodSeconds"`
+	PriorityClassName             string   `json:"priorityClassName,omitempty"`
+	Priority                      int32    `json:"priority,omitempty"`
+	LocalVolumes                  []string `json:"localVolumes,omitempty"` // emptyDir on disk and hostPath
+}
+
+// NodeShare is the fraction of the node the pod reserves, by its dominant
+// resource.
+func (p PodDetails) NodeShare(node NodeDetails) float64 {
+	var share float64
+	if node.AllocatableCPU > 0 {
+		share = float64(p.CPURequest) / float64(node.AllocatableCPU)
+	}
+	if node.AllocatableMemory > 0 {
+		share = max(share, float64(p.MemoryRequest)/float64(node.AllocatableMemory))
+	}
+	return min(share, 1)
+}
+
+type HostPort struct {
+	Port     int32  `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+type ContainerDetails struct {
+	Name          string `json:"name"`
+	Image         string `json:"image"`
+	CPURequest    int64  `json:"cpuRequest"`
+	MemoryRequest int64  `json:"memoryRequest"`
+	CPULimit      int64  `json:"cpuLimit,omitempty"`
+	MemoryLimit   int64  `json:"memoryLimit,omitempty"`
 }
@@ -0,0 +1,73 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// ClusterSecretLabel marks Secrets that describe a remote cluster.
+	ClusterSecretLabel = "karpops-wiz.io/cluster"
+
+	clusterSecretName   = "name"
+	clusterSecretServer = "server"
+	clusterSecretToken  = "token"
+	clusterSecretCA     = "ca.crt"
+)
+
+// ClusterCredentials reach a remote cluster with a ServiceAccount token.
+type ClusterCredentials struct {
+	Name   string
+	Server string
+	Token  string
+	CAData []byte
+}
+
+// GetClusterCredentials reads the Secrets labelled ClusterSecretLabel=true
+// in namespace. Each holds "server", "token" and optionally "ca.crt" and
+// "name", which defaults to the Secret's name.
+func (c *K8sClient) GetClusterCredentials(ctx context.Context, namespace string) ([]ClusterCredentials, error) {
+	secrets, err := c.clientset.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: ClusterSecretLabel + "=true",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list cluster secrets: %w", err)
+	}
+
+	credentials := make([]ClusterCredentials, 0, len(secrets.Items))
+	for _, secret := range secrets.Items {
+		creds := ClusterCredentials{
+			Name:   string(secret.Data[clusterSecretName]),
+			Server: string(secret.Data[clusterSecretServer]),
+			Token:  string(secret.Data[clusterSecretToken]),
+			CAData: secret.Data[clusterSecretCA],
+		}
+		if creds.Name == "" {
+			creds.Name = secret.Name
+		}
+		if creds.Server == "" || creds.Token == "" {
+			return nil, fmt.Errorf("cluster secret %s/%s needs %q and %q", secret.Namespace, secret.Name, clusterSecretServer, clusterSecretToken)
+		}
+		credentials = append(credentials, creds)
+	}
+	return credentials, nil
+}
+
+// NewK8sClientForCredentials connects to a remote cluster.
+func NewK8sClientForCredentials(creds ClusterCredentials) (*K8sClient, error) {
+	return NewK8sClientForConfig(&rest.Config{
+		Host:        creds.Server,
+		BearerToken: creds.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: creds.CAData,
+		},
+	})
+}
+
+// Host is the API server the client talks to.
+func (c *K8sClient) Host() string {
+	return c.config.Host
+}
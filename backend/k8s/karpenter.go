@@ -0,0 +1,195 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	NodePoolLabel          = "karpenter.sh/nodepool"
+	DoNotDisruptAnnotation = "karpenter.sh/do-not-disrupt"
+)
+
+// nodePoolVersions are tried in order, so clusters still on the beta API
+// keep working.
+var nodePoolVersions = []schema.GroupVersionResource{
+	{Group: "karpenter.sh", Version: "v1", Resource: "nodepools"},
+	{Group: "karpenter.sh", Version: "v1beta1", Resource: "nodepools"},
+}
+
+var nodeClassVersions = []schema.GroupVersionResource{
+	{Group: "karpenter.k8s.aws", Version: "v1", Resource: "ec2nodeclasses"},
+	{Group: "karpenter.k8s.aws", Version: "v1beta1", Resource: "ec2nodeclasses"},
+}
+
+// GetNodePools lists Karpenter NodePools. A cluster without the Karpenter
+// CRDs has no NodePools rather than an error.
+func (c *K8sClient) GetNodePools(ctx context.Context) ([]NodePoolDetails, error) {
+	for _, gvr := range nodePoolVersions {
+		list, err := c.dynamic.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list nodepools: %w", err)
+		}
+
+		nodePools := make([]NodePoolDetails, 0, len(list.Items))
+		for _, item := range list.Items {
+			np, err := DecodeNodePool(item.Object)
+			if err != nil {
+				return nil, err
+			}
+			np.APIVersion = gvr.GroupVersion().String()
+			nodePools = append(nodePools, np)
+		}
+		return nodePools, nil
+	}
+	return []NodePoolDetails{}, nil
+}
+
+// GetNodeClasses lists EC2NodeClasses, with the same fallback as
+// GetNodePools.
+func (c *K8sClient) GetNodeClasses(ctx context.Context) ([]NodeClassDetails, error) {
+	for _, gvr := range nodeClassVersions {
+		list, err := c.dynamic.Resource(gvr).List(ctx, metav1.ListOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list ec2nodeclasses: %w", err)
+		}
+
+		nodeClasses := make([]NodeClassDetails, 0, len(list.Items))
+		for _, item := range list.Items {
+			nc, err := DecodeNodeClass(item.Object)
+			if err != nil {
+				return nil, err
+			}
+			nc.APIVersion = gvr.GroupVersion().String()
+			nodeClasses = append(nodeClasses, nc)
+		}
+		return nodeClasses, nil
+	}
+	return []NodeClassDetails{}, nil
+}
+
+// DecodeNodePool converts an unstructured NodePool, as listed from the API
+// server or read from a manifest, into NodePoolDetails.
+func DecodeNodePool(object map[string]interface{}) (NodePoolDetails, error) {
+	var np nodePoolObject
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, &np); err != nil {
+		return NodePoolDetails{}, fmt.Errorf("failed to decode nodepool %s: %w", (&unstructured.Unstructured{Object: object}).GetName(), err)
+	}
+	return NodePoolDetails{
+		Name:                np.Metadata.Name,
+		APIVersion:          np.APIVersion,
+		Labels:              np.Spec.Template.Metadata.Labels,
+		Taints:              np.Spec.Template.Spec.Taints,
+		Requirements:        np.Spec.Template.Spec.Requirements,
+		NodeClassRef:        np.Spec.Template.Spec.NodeClassRef.Name,
+		ConsolidationPolicy: np.Spec.Disruption.ConsolidationPolicy,
+		ConsolidateAfter:    np.Spec.Disruption.ConsolidateAfter,
+		Budgets:             np.Spec.Disruption.Budgets,
+		Limits:              np.Spec.Limits,
+		Weight:              np.Spec.Weight,
+	}, nil
+}
+
+// DecodeNodeClass is DecodeNodePool for EC2NodeClasses.
+func DecodeNodeClass(object map[string]interface{}) (NodeClassDetails, error) {
+	var nc nodeClassObject
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(object, &nc); err != nil {
+		return NodeClassDetails{}, fmt.Errorf("failed to decode ec2nodeclass %s: %w", (&unstructured.Unstructured{Object: object}).GetName(), err)
+	}
+	return NodeClassDetails{
+		Name:            nc.Metadata.Name,
+		APIVersion:      nc.APIVersion,
+		AMIFamily:       nc.Spec.AMIFamily,
+		Role:            nc.Spec.Role,
+		InstanceProfile: nc.Spec.InstanceProfile,
+		Tags:            nc.Spec.Tags,
+	}, nil
+}
+
+// nodePoolObject covers the fields shared by karpenter.sh/v1 and v1beta1.
+type nodePoolObject struct {
+	APIVersion string            `json:"apiVersion"`
+	Metadata   metav1.ObjectMeta `json:"metadata"`
+	Spec       struct {
+		Template struct {
+			Metadata struct {
+				Labels map[string]string `json:"labels"`
+			} `json:"metadata"`
+			Spec struct {
+				Requirements []NodeSelectorRequirement `json:"requirements"`
+				Taints       []corev1.Taint            `json:"taints"`
+				NodeClassRef struct {
+					Name string `json:"name"`
+				} `json:"nodeClassRef"`
+			} `json:"spec"`
+		} `json:"template"`
+		Disruption struct {
+			ConsolidationPolicy string             `json:"consolidationPolicy"`
+			ConsolidateAfter    string             `json:"consolidateAfter"`
+			Budgets             []DisruptionBudget `json:"budgets"`
+		} `json:"disruption"`
+		Limits map[string]resource.Quantity `json:"limits"`
+		Weight int32                        `json:"weight"`
+	} `json:"spec"`
+}
+
+type NodePoolDetails struct {
+	Name                string                       `json:"name"`
+	APIVersion          string                       `json:"apiVersion"`
+	Labels              map[string]string            `json:"labels,omitempty"`
+	Taints              []corev1.Taint               `json:"taints,omitempty"`
+	Requirements        []NodeSelectorRequirement    `json:"requirements,omitempty"`
+	NodeClassRef        string                       `json:"nodeClassRef"`
+	ConsolidationPolicy string                       `json:"consolidationPolicy"`
+	ConsolidateAfter    string                       `json:"consolidateAfter,omitempty"`
+	Budgets             []DisruptionBudget           `json:"budgets,omitempty"`
+	Limits              map[string]resource.Quantity `json:"limits,omitempty"`
+	Weight              int32                        `json:"weight"`
+}
+
+type NodeSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+type DisruptionBudget struct {
+	Nodes    string   `json:"nodes"`
+	Schedule string   `json:"schedule,omitempty"`
+	Duration string   `json:"duration,omitempty"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+type nodeClassObject struct {
+	APIVersion string            `json:"apiVersion"`
+	Metadata   metav1.ObjectMeta `json:"metadata"`
+	Spec       struct {
+		AMIFamily       string            `json:"amiFamily"`
+		Role            string            `json:"role"`
+		InstanceProfile string            `json:"instanceProfile"`
+		Tags            map[string]string `json:"tags"`
+	} `json:"spec"`
+}
+
+type NodeClassDetails struct {
+	Name            string            `json:"name"`
+	APIVersion      string            `json:"apiVersion"`
+	AMIFamily       string            `json:"amiFamily,omitempty"`
+	Role            string            `json:"role,omitempty"`
+	InstanceProfile string            `json:"instanceProfile,omitempty"`
+	Tags            map[string]string `json:"tags,omitempty"`
+}
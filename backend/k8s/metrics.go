@@ -0,0 +1,70 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// podMetricsList mirrors the subset of metrics.k8s.io/v1beta1 PodMetricsList
+// we need, so we don't have to pull in the metrics client module.
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name      string `json:"name"`
+			Namespace string `json:"namespace"`
+		} `json:"metadata"`
+		Containers []struct {
+			Name  string `json:"name"`
+			Usage struct {
+				CPU    resource.Quantity `json:"cpu"`
+				Memory resource.Quantity `json:"memory"`
+			} `json:"usage"`
+		} `json:"containers"`
+	} `json:"items"`
+}
+
+// GetPodMetrics returns the current container usage reported by
+// metrics-server, keyed by "namespace/pod". An empty namespace lists all
+// namespaces.
+func (c *K8sClient) GetPodMetrics(ctx context.Context, namespace string) (map[string]PodUsage, error) {
+	path := "/apis/metrics.k8s.io/v1beta1/pods"
+	if namespace != "" {
+		path = fmt.Sprintf("/apis/metrics.k8s.io/v1beta1/namespaces/%s/pods", namespace)
+	}
+
+	raw, err := c.clientset.CoreV1().RESTClient().Get().AbsPath(path).DoRaw(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod metrics: %w", err)
+	}
+
+	var list podMetricsList
+	if err := json.Unmarshal(raw, &list); err != nil {
+		return nil, fmt.Errorf("failed to decode pod metrics: %w", err)
+	}
+
+	usage := make(map[string]PodUsage, len(list.Items))
+	for _, item := range list.Items {
+		podUsage := PodUsage{Containers: map[string]ContainerUsage{}}
+		for _, container := range item.Containers {
+			podUsage.Containers[container.Name] = ContainerUsage{
+				CPU:    container.Usage.CPU.MilliValue(),
+				Memory: container.Usage.Memory.Value(),
+			}
+		}
+		usage[item.Metadata.Namespace+"/"+item.Metadata.Name] = podUsage
+	}
+
+	return usage, nil
+}
+
+type PodUsage struct {
+	Containers map[string]ContainerUsage `json:"containers"`
+}
+
+type ContainerUsage struct {
+	CPU    int64 `json:"cpu"`    // millicores
+	Memory int64 `json:"memory"` // bytes
+}
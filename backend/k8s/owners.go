@@ -0,0 +1,72 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ownerIndex maps intermediate controllers (ReplicaSets, Jobs) to the
+// workload that manages them, so pods can be attributed to the object a
+// user actually edits.
+type ownerIndex struct {
+	replicaSets map[string]metav1.OwnerReference
+	jobs        map[string]metav1.OwnerReference
+}
+
+func (c *K8sClient) getOwnerIndex(ctx context.Context) (*ownerIndex, error) {
+	index := &ownerIndex{
+		replicaSets: map[string]metav1.OwnerReference{},
+		jobs:        map[string]metav1.OwnerReference{},
+	}
+
+	replicaSets, err := c.clientset.AppsV1().ReplicaSets("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list replicasets: %w", err)
+	}
+	for _, rs := range replicaSets.Items {
+		if owner := metav1.GetControllerOf(&rs); owner != nil {
+			index.replicaSets[rs.Namespace+"/"+rs.Name] = *owner
+		}
+	}
+
+	jobs, err := c.clientset.BatchV1().Jobs("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %w", err)
+	}
+	for _, job := range jobs.Items {
+		if owner := metav1.GetControllerOf(&job); owner != nil {
+			index.jobs[job.Namespace+"/"+job.Name] = *owner
+		}
+	}
+
+	return index, nil
+}
+
+// resolve returns the kind and name of the top-level workload owning a pod.
+// Pods without a controller are reported as bare pods with an empty owner.
+func (o *ownerIndex) resolve(namespace string, refs []metav1.OwnerReference) (string, string) {
+	var controller *metav1.OwnerReference
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			controller = &refs[i]
+			break
+		}
+	}
+	if controller == nil {
+		return "", ""
+	}
+
+	switch controller.Kind {
+	case "ReplicaSet":
+		if owner, ok := o.replicaSets[namespace+"/"+controller.Name]; ok {
+			return owner.Kind, owner.Name
+		}
+	case "Job":
+		if owner, ok := o.jobs[namespace+"/"+controller.Name]; ok {
+			return owner.Kind, owner.Name
+		}
+	}
+	return controller.Kind, controller.Name
+}
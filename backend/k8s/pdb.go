@@ -0,0 +1,51 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+func (c *K8sClient) GetPDBs(ctx context.Context) ([]PDBDetails, error) {
+	pdbs, err := c.clientset.PolicyV1().PodDisruptionBudgets("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list poddisruptionbudgets: %w", err)
+	}
+
+	details := make([]PDBDetails, 0, len(pdbs.Items))
+	for _, pdb := range pdbs.Items {
+		details = append(details, PDBDetails{
+			Name:               pdb.Name,
+			Namespace:          pdb.Namespace,
+			Selector:           pdb.Spec.Selector,
+			DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+			CurrentHealthy:     pdb.Status.CurrentHealthy,
+			DesiredHealthy:     pdb.Status.DesiredHealthy,
+		})
+	}
+	return details, nil
+}
+
+type PDBDetails struct {
+	Name               string                `json:"name"`
+	Namespace          string                `json:"namespace"`
+	Selector           *metav1.LabelSelector `json:"selector,omitempty"`
+	DisruptionsAllowed int32                 `json:"disruptionsAllowed"`
+	CurrentHealthy     int32                 `json:"currentHealthy"`
+	DesiredHealthy     int32                 `json:"desiredHealthy"`
+}
+
+// Matches reports whether the budget covers the pod. As in policy/v1, an
+// empty selector covers every pod in the namespace.
+func (p PDBDetails) Matches(pod PodDetails) bool {
+	if p.Namespace != pod.Namespace || p.Selector == nil {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(p.Selector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(pod.Labels))
+}
@@ -0,0 +1,16 @@
+package k8s
+
+import "context"
+
+// ClusterSource is everything the analysis reads from a cluster. K8sClient
+// serves it live; a snapshot serves it from a captured export.
+type ClusterSource interface {
+	GetNodes(ctx context.Context) (*NodeInfo, error)
+	GetPods(ctx context.Context) (*PodInfo, error)
+	GetPDBs(ctx context.Context) ([]PDBDetails, error)
+	GetNodePools(ctx context.Context) ([]NodePoolDetails, error)
+	GetNodeClasses(ctx context.Context) ([]NodeClassDetails, error)
+	GetPodMetrics(ctx context.Context, namespace string) (map[string]PodUsage, error)
+}
+
+var _ ClusterSource = (*K8sClient)(nil)
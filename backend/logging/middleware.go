@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/auth"
+	"github.com/gin-gonic/gin"
+)
+
+// sensitiveHeaders are left out of request logs.
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// Middleware gives every request an ID, the caller's X-Request-ID if it
+// sent a valid one, puts it in the request context and the response, and
+// logs the request once it is served: at debug level for the paths in
+// quiet, such as health checks, at error level when it failed with a
+// server error, and at info level otherwise. Debug logs add the headers.
+func Middleware(quiet ...string) gin.HandlerFunc {
+	quietPaths := map[string]bool{}
+	for _, path := range quiet {
+		quietPaths[path] = true
+	}
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+
+		start := time.Now()
+		c.Next()
+
+		ctx := c.Request.Context()
+		level := slog.LevelInfo
+		switch {
+		case quietPaths[c.Request.URL.Path]:
+			level = slog.LevelDebug
+		case c.Writer.Status() >= http.StatusInternalServerError:
+			level = slog.LevelError
+		}
+		logger := slog.Default()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", route),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("query", c.Request.URL.RawQuery),
+			slog.Int("status", c.Writer.Status()),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", c.ClientIP()),
+		}
+		if identity := auth.FromContext(ctx); identity != nil {
+			attrs = append(attrs, slog.String("user", identity.Name))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		if logger.Enabled(ctx, slog.LevelDebug) {
+			attrs = append(attrs, slog.Any("headers", redact(c.Request.Header)))
+		}
+		logger.LogAttrs(ctx, level, "request", attrs...)
+	}
+}
+
+// redact returns the headers of a request with the values of credentials
+// replaced.
+func redact(header http.Header) map[string]string {
+	redacted := make(map[string]string, len(header))
+	for name, values := range header {
+		if sensitiveHeaders[name] {
+			redacted[name] = "[REDACTED]"
+			continue
+		}
+		redacted[name] = strings.Join(values, ", ")
+	}
+	return redacted
+}
+
+// Recovery answers requests whose handler panicked with an internal error,
+// and logs the panic with its stack.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "panic serving request",
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"error", fmt.Sprint(err),
+			"stack", string(debug.Stack()))
+		apierror.Abort(c, apierror.New(http.StatusInternalServerError, apierror.CodeInternal, "internal server error"))
+	})
+}
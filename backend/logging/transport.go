@@ -0,0 +1,45 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Transport wraps the transport of a Kubernetes client: calls made with
+// the context of a request send its ID as X-Request-ID, and every call is
+// logged at debug level.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripper{next}
+}
+
+type roundTripper struct {
+	next http.RoundTripper
+}
+
+func (t roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if id := RequestID(ctx); id != "" && req.Header.Get(RequestIDHeader) == "" {
+		// A RoundTripper must not change the request it is given.
+		req = req.Clone(ctx)
+		req.Header.Set(RequestIDHeader, id)
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if logger := slog.Default(); logger.Enabled(ctx, slog.LevelDebug) {
+		attrs := []slog.Attr{
+			slog.String("method", req.Method),
+			slog.String("host", req.URL.Host),
+			slog.String("path", req.URL.Path),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		} else {
+			attrs = append(attrs, slog.Int("status", resp.StatusCode))
+		}
+		logger.LogAttrs(ctx, slog.LevelDebug, "kubernetes request", attrs...)
+	}
+	return resp, err
+}
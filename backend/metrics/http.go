@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests no route matched, so unknown paths don't
+// create a series each.
+const unmatchedRoute = "unmatched"
+
+// Middleware records the latency of every request by its route pattern,
+// such as /api/v1/recommendations/rightsizing/:namespace/:kind/:name.
+func (r *Registry) Middleware() gin.HandlerFunc {
+	latency := r.NewHistogramVec("karpops_http_request_duration_seconds",
+		"Latency of the HTTP requests served, by route.", DefaultBuckets, "method", "route", "code")
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		latency.Observe(time.Since(start).Seconds(), c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+	}
+}
@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	clientmetrics "k8s.io/client-go/tools/metrics"
+)
+
+// RegisterKubernetes counts the calls every Kubernetes client of the
+// process makes, by the cluster that cluster names from the API server's
+// host:port. client-go takes the first registration only, so this is
+// called once per process.
+func (r *Registry) RegisterKubernetes(cluster func(host string) string) {
+	requests := r.NewCounterVec("karpops_kubernetes_api_requests_total",
+		"Kubernetes API requests, by cluster, method and response code.", "cluster", "method", "code")
+	errors := r.NewCounterVec("karpops_kubernetes_api_request_errors_total",
+		"Kubernetes API requests that failed or were answered with an error status.", "cluster", "method")
+	latency := r.NewHistogramVec("karpops_kubernetes_api_request_duration_seconds",
+		"Latency of Kubernetes API requests, by cluster and verb.", DefaultBuckets, "cluster", "verb")
+
+	clientmetrics.Register(clientmetrics.RegisterOpts{
+		RequestResult:  kubernetesResults{requests: requests, errors: errors, cluster: cluster},
+		RequestLatency: kubernetesLatency{latency: latency, cluster: cluster},
+	})
+}
+
+type kubernetesResults struct {
+	requests, errors *CounterVec
+	cluster          func(host string) string
+}
+
+// Increment is given "<error>" as code when no response came back.
+func (k kubernetesResults) Increment(_ context.Context, code, method, host string) {
+	cluster := k.cluster(host)
+	k.requests.Inc(cluster, method, code)
+	if status, err := strconv.Atoi(code); err != nil || status >= 400 {
+		k.errors.Inc(cluster, method)
+	}
+}
+
+type kubernetesLatency struct {
+	latency *HistogramVec
+	cluster func(host string) string
+}
+
+func (k kubernetesLatency) Observe(_ context.Context, verb string, u url.URL, latency time.Duration) {
+	k.latency.Observe(latency.Seconds(), k.cluster(u.Host), verb)
+}
@@ -0,0 +1,150 @@
+// Package metrics exposes the server and its cost model to Prometheus. It
+// writes the text exposition format itself: counters and histograms the
+// server updates as it goes, and collectors that compute gauges, such as
+// node costs, when /metrics is scraped.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metric types, as # TYPE gives them.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// ContentType is the version of the text format written.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Family is a metric and its samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Sample is one value of a family. Suffix is appended to the family name,
+// as histograms do with _bucket, _sum and _count.
+type Sample struct {
+	Suffix string
+	Labels map[string]string
+	Value  float64
+}
+
+// Collector computes families when the metrics are scraped.
+type Collector func(ctx context.Context) []Family
+
+// Registry holds the metrics of the server.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register adds a collector, which is called on every scrape.
+func (r *Registry) Register(collector Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collector)
+}
+
+// Gather calls every collector, in the order they were registered.
+func (r *Registry) Gather(ctx context.Context) []Family {
+	r.mu.Lock()
+	collectors := append([]Collector{}, r.collectors...)
+	r.mu.Unlock()
+
+	var families []Family
+	for _, collect := range collectors {
+		families = append(families, collect(ctx)...)
+	}
+	return families
+}
+
+// HandleMetrics serves GET /metrics.
+func (r *Registry) HandleMetrics(c *gin.Context) {
+	families := r.Gather(c.Request.Context())
+	c.Header("Content-Type", ContentType)
+	c.Status(http.StatusOK)
+	if err := Write(c.Writer, families); err != nil {
+		c.Error(err)
+	}
+}
+
+// Write writes families in the text exposition format.
+func Write(w io.Writer, families []Family) error {
+	buf := bufio.NewWriter(w)
+	for _, family := range families {
+		fmt.Fprintf(buf, "# HELP %s %s\n", family.Name, escapeHelp(family.Help))
+		fmt.Fprintf(buf, "# TYPE %s %s\n", family.Name, family.Type)
+		for _, sample := range family.Samples {
+			buf.WriteString(family.Name + sample.Suffix)
+			writeLabels(buf, sample.Labels)
+			buf.WriteByte(' ')
+			buf.WriteString(formatValue(sample.Value))
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Flush()
+}
+
+func writeLabels(buf *bufio.Writer, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	buf.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		fmt.Fprintf(buf, "%s=\"%s\"", name, escapeLabel(labels[name]))
+	}
+	buf.WriteByte('}')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Gauge is a family of one gauge sample per labels.
+func Gauge(name, help string, samples ...Sample) Family {
+	return Family{Name: name, Help: help, Type: TypeGauge, Samples: samples}
+}
@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"testing"
+)
+
+func gather(t *testing.T, r *Registry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, r.Gather(context.Background())); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestWriteEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, []Family{Gauge("karpops_test", `Cost in "USD" per C:\ drive`+"\nper hour",
+		Sample{Labels: map[string]string{"path": `C:\tmp`, "quote": `say "hi"`, "lines": "a\nb"}, Value: 1.5},
+	)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// HELP escapes backslashes and line feeds; label values also escape
+	// double quotes.
+	want := `# HELP karpops_test Cost in "USD" per C:\\ drive\nper hour
+# TYPE karpops_test gauge
+karpops_test{lines="a\nb",path="C:\\tmp",quote="say \"hi\""} 1.5
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteValues(t *testing.T) {
+	var buf bytes.Buffer
+	err := Write(&buf, []Family{Gauge("karpops_test", "Values.",
+		Sample{Labels: map[string]string{"v": "inf"}, Value: math.Inf(1)},
+		Sample{Labels: map[string]string{"v": "-inf"}, Value: math.Inf(-1)},
+		Sample{Labels: map[string]string{"v": "nan"}, Value: math.NaN()},
+		Sample{Labels: map[string]string{"v": "large"}, Value: 1234567},
+		Sample{Labels: map[string]string{"v": "small"}, Value: 0.000125},
+		Sample{Value: 0},
+	)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP karpops_test Values.
+# TYPE karpops_test gauge
+karpops_test{v="inf"} +Inf
+karpops_test{v="-inf"} -Inf
+karpops_test{v="nan"} NaN
+karpops_test{v="large"} 1.234567e+06
+karpops_test{v="small"} 0.000125
+karpops_test 0
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCounterVecLabelOrder(t *testing.T) {
+	r := NewRegistry()
+	// Label names out of order, series added out of order: both come out
+	// sorted, so scrapes are stable.
+	requests := r.NewCounterVec("karpops_requests_total", "Requests.", "status", "method")
+	requests.Inc("500", "POST")
+	requests.Add(2, "200", "GET")
+	requests.Inc("200", "POST")
+	requests.Inc("200", "GET")
+
+	want := `# HELP karpops_requests_total Requests.
+# TYPE karpops_requests_total counter
+karpops_requests_total{method="GET",status="200"} 3
+karpops_requests_total{method="POST",status="200"} 1
+karpops_requests_total{method="POST",status="500"} 1
+`
+	if got := gather(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	latency := r.NewHistogramVec("karpops_request_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, "/a")
+	// Bounds are inclusive: 1 falls in le="1".
+	latency.Observe(1, "/a")
+	// Above the last bound, only +Inf counts it.
+	latency.Observe(5, "/a")
+	latency.Observe(0.5, "/b")
+
+	want := `# HELP karpops_request_seconds Latency.
+# TYPE karpops_request_seconds histogram
+karpops_request_seconds_bucket{le="0.1",route="/a"} 1
+karpops_request_seconds_bucket{le="1",route="/a"} 2
+karpops_request_seconds_bucket{le="+Inf",route="/a"} 3
+karpops_request_seconds_sum{route="/a"} 6.05
+karpops_request_seconds_count{route="/a"} 3
+karpops_request_seconds_bucket{le="0.1",route="/b"} 0
+karpops_request_seconds_bucket{le="1",route="/b"} 1
+karpops_request_seconds_bucket{le="+Inf",route="/b"} 1
+karpops_request_seconds_sum{route="/b"} 0.5
+karpops_request_seconds_count{route="/b"} 1
+`
+	if got := gather(t, r); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSeriesKeyPanicsOnWrongArity(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounterVec("karpops_requests_total", "Requests.", "method", "status")
+	defer func() {
+		if recover() == nil {
+			t.Error("Inc with one value for two labels did not panic")
+		}
+	}()
+	requests.Inc("GET")
+}
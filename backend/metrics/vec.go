@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of latency histograms.
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	name, help string
+	labels     []string
+
+	mu     sync.Mutex
+	values map[string]*counter
+}
+
+type counter struct {
+	values []string
+	value  float64
+}
+
+// NewCounterVec registers a counter with the given label names.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{name: name, help: help, labels: labels, values: map[string]*counter{}}
+	r.Register(v.collect)
+	return v
+}
+
+// Inc adds one to the counter of the label values, given in the order of
+// the label names.
+func (v *CounterVec) Inc(values ...string) {
+	v.Add(1, values...)
+}
+
+func (v *CounterVec) Add(delta float64, values ...string) {
+	key := seriesKey(v.labels, values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c, ok := v.values[key]
+	if !ok {
+		c = &counter{values: values}
+		v.values[key] = c
+	}
+	c.value += delta
+}
+
+func (v *CounterVec) collect(context.Context) []Family {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	family := Family{Name: v.name, Help: v.help, Type: TypeCounter}
+	for _, key := range slices.Sorted(maps.Keys(v.values)) {
+		c := v.values[key]
+		family.Samples = append(family.Samples, Sample{Labels: labelMap(v.labels, c.values), Value: c.value})
+	}
+	return []Family{family}
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	name, help string
+	labels     []string
+	buckets    []float64
+
+	mu     sync.Mutex
+	values map[string]*histogram
+}
+
+type histogram struct {
+	values []string
+	// counts are per bucket, not cumulative.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec registers a histogram with the given upper bounds, in
+// increasing order, and label names.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	v := &HistogramVec{name: name, help: help, labels: labels, buckets: buckets, values: map[string]*histogram{}}
+	r.Register(v.collect)
+	return v
+}
+
+// Observe records value for the label values, given in the order of the
+// label names.
+func (v *HistogramVec) Observe(value float64, values ...string) {
+	key := seriesKey(v.labels, values)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	h, ok := v.values[key]
+	if !ok {
+		h = &histogram{values: values, counts: make([]uint64, len(v.buckets))}
+		v.values[key] = h
+	}
+	if i := sort.SearchFloat64s(v.buckets, value); i < len(v.buckets) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += value
+}
+
+func (v *HistogramVec) collect(context.Context) []Family {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	family := Family{Name: v.name, Help: v.help, Type: TypeHistogram}
+	for _, key := range slices.Sorted(maps.Keys(v.values)) {
+		h := v.values[key]
+		var cumulative uint64
+		for i, bound := range v.buckets {
+			cumulative += h.counts[i]
+			labels := labelMap(v.labels, h.values)
+			labels["le"] = formatValue(bound)
+			family.Samples = append(family.Samples, Sample{Suffix: "_bucket", Labels: labels, Value: float64(cumulative)})
+		}
+		labels := labelMap(v.labels, h.values)
+		labels["le"] = "+Inf"
+		family.Samples = append(family.Samples,
+			Sample{Suffix: "_bucket", Labels: labels, Value: float64(h.count)},
+			Sample{Suffix: "_sum", Labels: labelMap(v.labels, h.values), Value: h.sum},
+			Sample{Suffix: "_count", Labels: labelMap(v.labels, h.values), Value: float64(h.count)},
+		)
+	}
+	return []Family{family}
+}
+
+// seriesKey identifies the label values of a series. A wrong number of
+// values is a bug in the caller.
+func seriesKey(labels, values []string) string {
+	if len(values) != len(labels) {
+		panic(fmt.Sprintf("metrics: %d label values for %d labels %v", len(values), len(labels), labels))
+	}
+	return strings.Join(values, "\xff")
+}
+
+func labelMap(labels, values []string) map[string]string {
+	m := make(map[string]string, len(labels)+1)
+	for i, label := range labels {
+		m[label] = values[i]
+	}
+	return m
+}
@@ -0,0 +1,291 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HoursPerMonth matches the 30-day month used for the monthly figures
+// shown in the dashboard.
+const HoursPerMonth = 24 * 30
+
+// DefaultDataPaths are searched in order when no explicit path is given:
+// the container image copies the file to ./data, while `make run` starts
+// from the backend directory.
+var DefaultDataPaths = []string{"data/aws-pricing.json", "../data/aws-pricing.json"}
+
+type InstanceType struct {
+	Name         string  `json:"name"`
+	Family       string  `json:"family"`
+	Size         string  `json:"size"`
+	VCPU         int64   `json:"vcpu"`
+	MemoryGiB    int64   `json:"memoryGib"`
+	Architecture string  `json:"architecture"`
+	OnDemand     float64 `json:"onDemand"`
+	Spot         float64 `json:"spot"`
+}
+
+// HourlyPrice returns the on-demand or spot price of the instance type.
+func (t InstanceType) HourlyPrice(spot bool) float64 {
+	if spot {
+		return t.Spot
+	}
+	return t.OnDemand
+}
+
+// Accelerated reports GPU and ML accelerator families, whose workloads
+// can't move to a general-purpose type.
+func (t InstanceType) Accelerated() bool {
+	for _, prefix := range []string{"g", "p", "inf", "trn", "dl", "f1", "vt1"} {
+		if strings.HasPrefix(t.Family, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t InstanceType) Burstable() bool {
+	return strings.HasPrefix(t.Family, "t")
+}
+
+type Catalog struct {
+	Version       string
+	LastUpdated   string
+	DefaultRegion string
+
+	// mu guards regions and loaded, which Refresh replaces.
+	mu      sync.RWMutex
+	regions map[string]map[string]InstanceType
+	// loaded is when the catalog was read.
+	loaded time.Time
+}
+
+type catalogFile struct {
+	Metadata struct {
+		Version     string `json:"version"`
+		LastUpdated string `json:"lastUpdated"`
+		Region      string `json:"region"`
+	} `json:"metadata"`
+	Regions map[string]struct {
+		Pricing map[string]map[string]struct {
+			OnDemand float64 `json:"ondemand"`
+			Spot     float64 `json:"spot"`
+		} `json:"pricing"`
+	} `json:"regions"`
+	InstanceFamilies map[string]struct {
+		Architectures []string `json:"architectures"`
+	} `json:"instanceFamilies"`
+}
+
+// Load reads the pricing catalog. An empty path searches DefaultDataPaths.
+func Load(path string) (*Catalog, error) {
+	paths := DefaultDataPaths
+	if path != "" {
+		paths = []string{path}
+	}
+
+	var data []byte
+	var err error
+	for _, p := range paths {
+		if data, err = os.ReadFile(p); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pricing data: %w", err)
+	}
+
+	return Parse(data)
+}
+
+// Refresh reads the catalog at path again and replaces the prices of c
+// with it. The default region stays as it is.
+func (c *Catalog) Refresh(path string) error {
+	fresh, err := Load(path)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Version, c.LastUpdated, c.regions, c.loaded = fresh.Version, fresh.LastUpdated, fresh.regions, fresh.loaded
+	return nil
+}
+
+func Parse(data []byte) (*Catalog, error) {
+	var file catalogFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse pricing data: %w", err)
+	}
+
+	catalog := &Catalog{
+		Version:       file.Metadata.Version,
+		LastUpdated:   file.Metadata.LastUpdated,
+		DefaultRegion: file.Metadata.Region,
+		regions:       map[string]map[string]InstanceType{},
+		loaded:        time.Now(),
+	}
+	if catalog.DefaultRegion == "" {
+		catalog.DefaultRegion = "us-east-1"
+	}
+
+	for region, regionData := range file.Regions {
+		types := map[string]InstanceType{}
+		for family, sizes := range regionData.Pricing {
+			arch := architectureOf(family, file.InstanceFamilies[family].Architectures)
+			for size, price := range sizes {
+				vcpu, memory := specsOf(family, size)
+				name := family + "." + size
+				types[name] = InstanceType{
+					Name:         name,
+					Family:       family,
+					Size:         size,
+					VCPU:         vcpu,
+					MemoryGiB:    memory,
+					Architecture: arch,
+					OnDemand:     price.OnDemand,
+					Spot:         price.Spot,
+				}
+			}
+		}
+		catalog.regions[region] = types
+	}
+
+	return catalog, nil
+}
+
+// Check fails unless the catalog was read and prices instance types,
+// which an empty file doesn't.
+func (c *Catalog) Check(context.Context) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.loaded.IsZero() {
+		return fmt.Errorf("the pricing catalog is not loaded")
+	}
+	for _, types := range c.regions {
+		if len(types) > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("the pricing catalog has no instance types")
+}
+
+// Lookup returns the instance type in the region, falling back to the
+// default region when the region has no data for it.
+func (c *Catalog) Lookup(region, instanceType string) (InstanceType, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if t, ok := c.regions[region][instanceType]; ok {
+		return t, true
+	}
+	t, ok := c.regions[c.DefaultRegion][instanceType]
+	return t, ok
+}
+
+// InstanceTypes lists the priced instance types of a region, sorted by name.
+func (c *Catalog) InstanceTypes(region string) []InstanceType {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	types, ok := c.regions[region]
+	if !ok {
+		types = c.regions[c.DefaultRegion]
+	}
+
+	result := make([]InstanceType, 0, len(types))
+	for _, t := range types {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Equivalent returns the cheapest instance type of the region, priced for
+// the capacity type, that is at least as large as current, costs less and
+// passes the filter. Accelerated types are never suggested, and burstable
+// ones only stand in for burstable ones.
+func (c *Catalog) Equivalent(region string, current InstanceType, spot bool, allowed func(InstanceType) bool) (InstanceType, bool) {
+	var best InstanceType
+	found := false
+	for _, t := range c.InstanceTypes(region) {
+		price := t.HourlyPrice(spot)
+		if price <= 0 || price >= current.HourlyPrice(spot) || t.Accelerated() || !allowed(t) {
+			continue
+		}
+		if t.VCPU < current.VCPU || t.MemoryGiB < current.MemoryGiB {
+			continue
+		}
+		if t.Burstable() && !current.Burstable() {
+			continue
+		}
+		if !found || price < best.HourlyPrice(spot) {
+			best, found = t, true
+		}
+	}
+	return best, found
+}
+
+func (c *Catalog) Regions() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	regions := make([]string, 0, len(c.regions))
+	for region := range c.regions {
+		regions = append(regions, region)
+	}
+	sort.Strings(regions)
+	return regions
+}
+
+func architectureOf(family string, architectures []string) string {
+	for _, arch := range architectures {
+		if arch == "arm64" {
+			return "arm64"
+		}
+	}
+	if len(architectures) == 0 && strings.Contains(strings.TrimLeft(family, "abcdefghijklmnopqrstuvwxyz"), "g") {
+		return "arm64"
+	}
+	return "amd64"
+}
+
+// specsOf derives vCPU and memory from the EC2 naming scheme, since the
+// pricing file only carries prices.
+func specsOf(family, size string) (int64, int64) {
+	var vcpu int64
+	switch size {
+	case "micro", "small", "medium", "large":
+		vcpu = 2
+	case "xlarge":
+		vcpu = 4
+	case "metal":
+		vcpu = 64
+	default:
+		n, err := strconv.ParseInt(strings.TrimSuffix(size, "xlarge"), 10, 64)
+		if err != nil {
+			return 0, 0
+		}
+		vcpu = n * 4
+	}
+
+	if strings.HasPrefix(family, "t") {
+		burstable := map[string]int64{"micro": 1, "small": 2, "medium": 4}
+		if memory, ok := burstable[size]; ok {
+			return vcpu, memory
+		}
+		return vcpu, vcpu * 4
+	}
+
+	switch family[0] {
+	case 'c':
+		return vcpu, vcpu * 2
+	case 'r':
+		return vcpu, vcpu * 8
+	default:
+		return vcpu, vcpu * 4
+	}
+}
@@ -0,0 +1,31 @@
+package pricing
+
+import (
+	"context"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/metrics"
+)
+
+// CollectMetrics reports which catalog prices are looked up in and how old
+// it is.
+func (c *Catalog) CollectMetrics(context.Context) []metrics.Family {
+	c.mu.RLock()
+	version, lastUpdated, loaded := c.Version, c.LastUpdated, c.loaded
+	c.mu.RUnlock()
+
+	now := time.Now()
+	families := []metrics.Family{
+		metrics.Gauge("karpops_pricing_catalog_info", "The pricing catalog in use.",
+			metrics.Sample{Labels: map[string]string{"version": version, "last_updated": lastUpdated}, Value: 1}),
+		metrics.Gauge("karpops_pricing_catalog_loaded_age_seconds", "Time since the pricing catalog was last read.",
+			metrics.Sample{Value: now.Sub(loaded).Seconds()}),
+	}
+	// The age of the prices themselves, when the catalog says.
+	if updated, err := time.Parse(time.RFC3339, lastUpdated); err == nil {
+		families = append(families, metrics.Gauge("karpops_pricing_catalog_age_seconds",
+			"Age of the prices in the pricing catalog, from its lastUpdated.",
+			metrics.Sample{Value: now.Sub(updated).Seconds()}))
+	}
+	return families
+}
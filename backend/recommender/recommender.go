@@ -0,0 +1,234 @@
+package recommender
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+)
+
+type Kind string
+
+const (
+	KindFamilyMigration Kind = "family-migration"
+	KindGraviton        Kind = "graviton"
+	KindSpot            Kind = "spot"
+	KindConsolidation   Kind = "consolidation"
+)
+
+type Risk string
+
+const (
+	RiskLow    Risk = "low"
+	RiskMedium Risk = "medium"
+	RiskHigh   Risk = "high"
+)
+
+type Input struct {
+	Nodes     []k8s.NodeDetails
+	Pods      []k8s.PodDetails
+	PDBs      []k8s.PDBDetails
+	NodePools []k8s.NodePoolDetails
+	Region    string
+}
+
+// Recommendation is one actionable change. ID is stable across runs for the
+// same cluster state, so clients can track or dismiss recommendations.
+type Recommendation struct {
+	ID             string   `json:"id"`
+	Kind           Kind     `json:"kind"`
+	Title          string   `json:"title"`
+	Action         string   `json:"action"`
+	Nodes          []string `json:"nodes"`
+	Workloads      []string `json:"workloads"`
+	MonthlySavings float64  `json:"monthlySavings"`
+	Risk           Risk     `json:"risk"`
+	// Confidence is how likely the savings are to materialize, from 0 to 1.
+	Confidence float64  `json:"confidence"`
+	Notes      []string `json:"notes,omitempty"`
+}
+
+type Report struct {
+	Recommendations []Recommendation `json:"recommendations"`
+	Summary         Summary          `json:"summary"`
+	Warnings        []string         `json:"warnings"`
+}
+
+// Summary totals the savings of recommendations that don't touch the same
+// nodes, so alternatives for one node group are not counted twice.
+type Summary struct {
+	CurrentMonthlyCost float64 `json:"currentMonthlyCost"`
+	MonthlySavings     float64 `json:"monthlySavings"`
+	Percentage         float64 `json:"percentage"`
+}
+
+// rule inspects the cluster and returns its recommendations. Rules must be
+// deterministic: the same input always yields the same output, in order.
+type rule func(r *run) []Recommendation
+
+var rules = []rule{
+	familyMigration,
+	gravitonMigration,
+	spotMigration,
+	consolidation,
+}
+
+type run struct {
+	input    Input
+	catalog  *pricing.Catalog
+	pods     map[string][]k8s.PodDetails
+	warnings []string
+}
+
+// Recommend evaluates every rule against the cluster. Recommendations are
+// ordered by savings, highest first.
+func Recommend(input Input, catalog *pricing.Catalog) *Report {
+	if input.Region == "" {
+		input.Region = catalog.DefaultRegion
+	}
+	r := &run{
+		input:   input,
+		catalog: catalog,
+		pods:    map[string][]k8s.PodDetails{},
+	}
+	for _, pod := range input.Pods {
+		if pod.NodeName != "" && pod.Status != "Succeeded" && pod.Status != "Failed" {
+			r.pods[pod.NodeName] = append(r.pods[pod.NodeName], pod)
+		}
+	}
+
+	report := &Report{Recommendations: []Recommendation{}}
+	for _, evaluate := range rules {
+		report.Recommendations = append(report.Recommendations, evaluate(r)...)
+	}
+	sort.SliceStable(report.Recommendations, func(i, j int) bool {
+		a, b := report.Recommendations[i], report.Recommendations[j]
+		if a.MonthlySavings != b.MonthlySavings {
+			return a.MonthlySavings > b.MonthlySavings
+		}
+		return a.ID < b.ID
+	})
+
+	for _, node := range input.Nodes {
+		t, ok := r.lookup(node)
+		if !ok {
+			r.warnings = append(r.warnings, fmt.Sprintf("no price for %s on node %s", node.InstanceType, node.Name))
+			continue
+		}
+		report.Summary.CurrentMonthlyCost += t.HourlyPrice(node.IsSpot) * pricing.HoursPerMonth
+	}
+	for _, rec := range report.Independent() {
+		report.Summary.MonthlySavings += rec.MonthlySavings
+	}
+	if report.Summary.CurrentMonthlyCost > 0 {
+		report.Summary.Percentage = report.Summary.MonthlySavings / report.Summary.CurrentMonthlyCost * 100
+	}
+	report.Warnings = r.warnings
+
+	return report
+}
+
+// Independent returns the recommendations the summary counts: in order,
+// each one that touches no node of a recommendation taken before it.
+func (report *Report) Independent() []Recommendation {
+	var independent []Recommendation
+	claimed := map[string]bool{}
+	for _, rec := range report.Recommendations {
+		overlaps := false
+		for _, node := range rec.Nodes {
+			overlaps = overlaps || claimed[node]
+		}
+		if overlaps {
+			continue
+		}
+		for _, node := range rec.Nodes {
+			claimed[node] = true
+		}
+		independent = append(independent, rec)
+	}
+	return independent
+}
+
+func (r *run) region(node k8s.NodeDetails) string {
+	if node.Region == "" || node.Region == "unknown" {
+		return r.input.Region
+	}
+	return node.Region
+}
+
+func (r *run) lookup(node k8s.NodeDetails) (pricing.InstanceType, bool) {
+	return r.catalog.Lookup(r.region(node), node.InstanceType)
+}
+
+// workloads returns the owners of the non-DaemonSet pods on the nodes as
+// sorted "namespace/Kind/name" strings.
+func (r *run) workloads(nodes []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, node := range nodes {
+		for _, pod := range r.pods[node] {
+			if pod.OwnerKind == "DaemonSet" {
+				continue
+			}
+			key := workloadKey(pod)
+			if !seen[key] {
+				seen[key] = true
+				result = append(result, key)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func workloadKey(pod k8s.PodDetails) string {
+	if pod.OwnerKind == "" {
+		return pod.Namespace + "/Pod/" + pod.Name
+	}
+	return pod.Namespace + "/" + pod.OwnerKind + "/" + pod.OwnerName
+}
+
+// nodeGroup is a set of nodes sharing instance type, capacity type and
+// region, which rules recommend on as a unit.
+type nodeGroup struct {
+	instanceType pricing.InstanceType
+	spot         bool
+	region       string
+	nodes        []string
+}
+
+// groups buckets the priced nodes; unpriced nodes are left out.
+func (r *run) groups() []*nodeGroup {
+	byKey := map[string]*nodeGroup{}
+	var keys []string
+	for _, node := range r.input.Nodes {
+		t, ok := r.lookup(node)
+		if !ok {
+			continue
+		}
+		key := r.region(node) + "/" + t.Name + "/" + capacityType(node.IsSpot)
+		g, ok := byKey[key]
+		if !ok {
+			g = &nodeGroup{instanceType: t, spot: node.IsSpot, region: r.region(node)}
+			byKey[key] = g
+			keys = append(keys, key)
+		}
+		g.nodes = append(g.nodes, node.Name)
+	}
+
+	sort.Strings(keys)
+	groups := make([]*nodeGroup, 0, len(keys))
+	for _, key := range keys {
+		sort.Strings(byKey[key].nodes)
+		groups = append(groups, byKey[key])
+	}
+	return groups
+}
+
+func capacityType(spot bool) string {
+	if spot {
+		return "spot"
+	}
+	return "on-demand"
+}
@@ -0,0 +1,264 @@
+package recommender_test
+
+import (
+	"math"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/recommender"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const gib = 1 << 30
+
+func loadCatalog(t *testing.T) *pricing.Catalog {
+	t.Helper()
+	catalog, err := pricing.Load("../pricing/testdata/catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return catalog
+}
+
+// node is an on-demand or spot node of the test region whose allocatable
+// resources are the instance type's, less a little for the system.
+func node(t *testing.T, catalog *pricing.Catalog, name, instanceType string, spot bool) k8s.NodeDetails {
+	t.Helper()
+	it, ok := catalog.Lookup("us-east-1", instanceType)
+	if !ok {
+		t.Fatalf("no %s in the catalog", instanceType)
+	}
+	return k8s.NodeDetails{
+		Name:              name,
+		InstanceType:      instanceType,
+		Region:            "us-east-1",
+		Zone:              "us-east-1a",
+		IsSpot:            spot,
+		State:             "Ready",
+		AllocatableCPU:    it.VCPU*1000 - 100,
+		AllocatableMemory: it.MemoryGiB*gib - gib/2,
+		MaxPods:           58,
+	}
+}
+
+// deployment returns replicas of a Deployment spread over the nodes, one
+// after the other, each requesting cpu millicores and memory GiB.
+func deployment(name string, replicas int, cpu, memory int64, nodes ...string) []k8s.PodDetails {
+	pods := make([]k8s.PodDetails, replicas)
+	for i := range pods {
+		pods[i] = k8s.PodDetails{
+			Name:                          name + "-" + string(rune('a'+i)),
+			Namespace:                     "shop",
+			NodeName:                      nodes[i%len(nodes)],
+			Status:                        "Running",
+			CPURequest:                    cpu,
+			MemoryRequest:                 memory * gib,
+			OwnerKind:                     "Deployment",
+			OwnerName:                     name,
+			Labels:                        map[string]string{"app": name},
+			TerminationGracePeriodSeconds: 30,
+		}
+	}
+	return pods
+}
+
+func daemonSet(nodes ...string) []k8s.PodDetails {
+	pods := make([]k8s.PodDetails, len(nodes))
+	for i, node := range nodes {
+		pods[i] = k8s.PodDetails{
+			Name:          "node-exporter-" + node,
+			Namespace:     "monitoring",
+			NodeName:      node,
+			Status:        "Running",
+			CPURequest:    100,
+			MemoryRequest: gib / 8,
+			OwnerKind:     "DaemonSet",
+			OwnerName:     "node-exporter",
+		}
+	}
+	return pods
+}
+
+func pdb(app string, allowed int32) k8s.PDBDetails {
+	return k8s.PDBDetails{
+		Name:               app,
+		Namespace:          "shop",
+		Selector:           &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+		DisruptionsAllowed: allowed,
+	}
+}
+
+func pods(groups ...[]k8s.PodDetails) []k8s.PodDetails {
+	var all []k8s.PodDetails
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
+
+// hours is a month of an hourly price difference.
+func hours(hourly float64) float64 {
+	return hourly * pricing.HoursPerMonth
+}
+
+type want struct {
+	id      string
+	nodes   []string
+	savings float64
+}
+
+func TestRules(t *testing.T) {
+	catalog := loadCatalog(t)
+	m5 := func(name string, spot bool) k8s.NodeDetails { return node(t, catalog, name, "m5.xlarge", spot) }
+
+	pinned := deployment("legacy", 1, 500, 1, "a")
+	pinned[0].NodeSelector = map[string]string{"kubernetes.io/arch": "amd64"}
+	db := deployment("db", 1, 500, 1, "b")
+	db[0].OwnerKind = "StatefulSet"
+
+	for _, tc := range []struct {
+		name  string
+		kind  recommender.Kind
+		input recommender.Input
+		want  []want
+	}{
+		{
+			name:  "family migration to a cheaper generation",
+			kind:  recommender.KindFamilyMigration,
+			input: recommender.Input{Nodes: []k8s.NodeDetails{m5("a", false), m5("b", false)}},
+			want:  []want{{"family-migration/us-east-1/m5.xlarge/on-demand", []string{"a", "b"}, hours(0.192-0.1728) * 2}},
+		},
+		{
+			name:  "family migration priced at spot",
+			kind:  recommender.KindFamilyMigration,
+			input: recommender.Input{Nodes: []k8s.NodeDetails{m5("a", true)}},
+		},
+		{
+			name:  "family migration from the cheapest family",
+			kind:  recommender.KindFamilyMigration,
+			input: recommender.Input{Nodes: []k8s.NodeDetails{node(t, catalog, "a", "m6a.xlarge", false)}},
+		},
+		{
+			name:  "graviton",
+			kind:  recommender.KindGraviton,
+			input: recommender.Input{Nodes: []k8s.NodeDetails{m5("a", false), m5("b", false)}},
+			want:  []want{{"graviton/us-east-1/m5.xlarge/on-demand", []string{"a", "b"}, hours(0.192-0.154) * 2}},
+		},
+		{
+			name: "graviton skips nodes with pods pinned to amd64",
+			kind: recommender.KindGraviton,
+			input: recommender.Input{
+				Nodes: []k8s.NodeDetails{m5("a", false), m5("b", false)},
+				Pods:  pinned,
+			},
+			want: []want{{"graviton/us-east-1/m5.xlarge/on-demand", []string{"b"}, hours(0.192 - 0.154)}},
+		},
+		{
+			name:  "graviton from arm64",
+			kind:  recommender.KindGraviton,
+			input: recommender.Input{Nodes: []k8s.NodeDetails{node(t, catalog, "a", "m6g.xlarge", false)}},
+		},
+		{
+			name: "spot for eligible workloads",
+			kind: recommender.KindSpot,
+			input: recommender.Input{
+				Nodes: []k8s.NodeDetails{m5("a", false), m5("b", false)},
+				Pods:  pods(deployment("web", 4, 500, 1, "a", "b"), daemonSet("a", "b")),
+				PDBs:  []k8s.PDBDetails{pdb("web", 1)},
+			},
+			want: []want{{"spot/us-east-1/m5.xlarge", []string{"a", "b"}, hours(0.192-0.0576) * 2}},
+		},
+		{
+			name: "spot skips nodes running ineligible workloads",
+			kind: recommender.KindSpot,
+			input: recommender.Input{
+				Nodes: []k8s.NodeDetails{m5("a", false), m5("b", false)},
+				Pods:  pods(deployment("web", 4, 500, 1, "a", "b"), db),
+				PDBs:  []k8s.PDBDetails{pdb("web", 1)},
+			},
+			want: []want{{"spot/us-east-1/m5.xlarge", []string{"a"}, hours(0.192 - 0.0576)}},
+		},
+		{
+			name:  "spot on spot nodes",
+			kind:  recommender.KindSpot,
+			input: recommender.Input{Nodes: []k8s.NodeDetails{m5("a", true)}},
+		},
+		{
+			name: "consolidation deletes an empty node and replaces an underused one",
+			kind: recommender.KindConsolidation,
+			input: recommender.Input{
+				Nodes: []k8s.NodeDetails{m5("a", false), m5("b", false)},
+				Pods:  pods(deployment("web", 2, 1500, 6, "a"), daemonSet("a", "b")),
+			},
+			want: []want{{"consolidation/cluster", []string{"a", "b"}, hours(0.192) + hours(0.192-0.154)}},
+		},
+		{
+			name: "consolidation of a full cluster",
+			kind: recommender.KindConsolidation,
+			input: recommender.Input{
+				Nodes: []k8s.NodeDetails{node(t, catalog, "a", "m6g.xlarge", false), node(t, catalog, "b", "m6g.xlarge", false)},
+				Pods:  pods(deployment("web", 4, 1800, 6, "a", "b"), daemonSet("a", "b")),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			report := recommender.Recommend(tc.input, catalog)
+			var got []want
+			for _, rec := range report.Recommendations {
+				if rec.Kind == tc.kind {
+					got = append(got, want{rec.ID, rec.Nodes, rec.MonthlySavings})
+				}
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %+v, want %+v", got, tc.want)
+			}
+			for i := range got {
+				if got[i].id != tc.want[i].id || !slices.Equal(got[i].nodes, tc.want[i].nodes) || math.Abs(got[i].savings-tc.want[i].savings) > 1e-6 {
+					t.Errorf("got %+v, want %+v", got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecommendOrdering(t *testing.T) {
+	catalog := loadCatalog(t)
+	input := recommender.Input{
+		Nodes: []k8s.NodeDetails{
+			node(t, catalog, "a", "m5.xlarge", false),
+			node(t, catalog, "b", "m5.xlarge", false),
+			node(t, catalog, "c", "m5.large", false),
+			node(t, catalog, "d", "c5.xlarge", true),
+		},
+		Pods: pods(deployment("web", 3, 500, 1, "a", "c", "d"), daemonSet("a", "b", "c", "d")),
+		PDBs: []k8s.PDBDetails{pdb("web", 1)},
+	}
+	report := recommender.Recommend(input, catalog)
+	if len(report.Recommendations) < 4 {
+		t.Fatalf("got %d recommendations, want one of each kind at least", len(report.Recommendations))
+	}
+	if !slices.IsSortedFunc(report.Recommendations, func(a, b recommender.Recommendation) int {
+		if a.MonthlySavings != b.MonthlySavings {
+			if a.MonthlySavings > b.MonthlySavings {
+				return -1
+			}
+			return 1
+		}
+		if a.ID < b.ID {
+			return -1
+		}
+		return 1
+	}) {
+		t.Errorf("recommendations are not ordered by savings, then ID")
+	}
+
+	// Rules iterate maps, which must not leak into the report.
+	for i := 0; i < 10; i++ {
+		if again := recommender.Recommend(input, catalog); !reflect.DeepEqual(report, again) {
+			t.Fatalf("report changed between runs:\n%+v\n%+v", report.Recommendations, again.Recommendations)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package recommender
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/graviton"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/simulator"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/spot"
+)
+
+// familyMigration suggests a cheaper instance type of the same architecture
+// that is at least as large, typically a newer generation.
+func familyMigration(r *run) []Recommendation {
+	var recs []Recommendation
+	for _, g := range r.groups() {
+		current := g.instanceType
+		if current.Accelerated() {
+			continue
+		}
+		target, ok := r.catalog.Equivalent(g.region, current, g.spot, func(t pricing.InstanceType) bool {
+			return t.Architecture == current.Architecture && t.Family != current.Family
+		})
+		if !ok {
+			continue
+		}
+
+		recs = append(recs, Recommendation{
+			ID:             fmt.Sprintf("%s/%s/%s/%s", KindFamilyMigration, g.region, current.Name, capacityType(g.spot)),
+			Kind:           KindFamilyMigration,
+			Title:          fmt.Sprintf("Move %s nodes to %s", current.Name, target.Name),
+			Action:         fmt.Sprintf("Replace %d %s %s node(s) with %s by allowing it in the NodePool's instance types", len(g.nodes), capacityType(g.spot), current.Name, target.Name),
+			Nodes:          g.nodes,
+			Workloads:      r.workloads(g.nodes),
+			MonthlySavings: g.savings(target),
+			Risk:           RiskLow,
+			Confidence:     0.9,
+		})
+	}
+	return recs
+}
+
+// gravitonMigration suggests arm64 instance types for x86 nodes. Nodes running pods
+// pinned to amd64 are left out; image architectures are not checked here.
+func gravitonMigration(r *run) []Recommendation {
+	var recs []Recommendation
+	for _, g := range r.groups() {
+		current := g.instanceType
+		if current.Architecture != "amd64" || current.Accelerated() {
+			continue
+		}
+
+		var eligible, pinned []string
+		for _, node := range g.nodes {
+			if r.pinnedToAMD64(node) {
+				pinned = append(pinned, node)
+			} else {
+				eligible = append(eligible, node)
+			}
+		}
+		if len(eligible) == 0 {
+			continue
+		}
+
+		target, ok := r.catalog.Equivalent(g.region, current, g.spot, func(t pricing.InstanceType) bool {
+			return t.Architecture == "arm64"
+		})
+		if !ok {
+			continue
+		}
+
+		rec := Recommendation{
+			ID:             fmt.Sprintf("%s/%s/%s/%s", KindGraviton, g.region, current.Name, capacityType(g.spot)),
+			Kind:           KindGraviton,
+			Title:          fmt.Sprintf("Run %s workloads on Graviton (%s)", current.Name, target.Name),
+			Action:         fmt.Sprintf("Add arm64 to the NodePool's architectures and allow %s for %d %s node(s)", target.Name, len(eligible), capacityType(g.spot)),
+			Nodes:          eligible,
+			Workloads:      r.workloads(eligible),
+			MonthlySavings: (g.instanceType.HourlyPrice(g.spot) - target.HourlyPrice(g.spot)) * pricing.HoursPerMonth * float64(len(eligible)),
+			Risk:           RiskMedium,
+			Confidence:     0.6,
+			Notes:          []string{"Every container image must publish a linux/arm64 variant; check the Graviton readiness analysis"},
+		}
+		if len(pinned) > 0 {
+			rec.Notes = append(rec.Notes, fmt.Sprintf("%d node(s) skipped: they run pods pinned to amd64", len(pinned)))
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// spotMigration suggests moving on-demand nodes to spot when every workload
+// on them scores as spot-eligible.
+func spotMigration(r *run) []Recommendation {
+	classes := spot.Classify(r.input.Pods, r.input.PDBs)
+
+	var recs []Recommendation
+	for _, g := range r.groups() {
+		if g.spot || g.instanceType.Spot <= 0 || g.instanceType.Spot >= g.instanceType.OnDemand {
+			continue
+		}
+
+		var eligible []string
+		blockers := map[string]bool{}
+		for _, node := range g.nodes {
+			ok := true
+			for _, pod := range r.pods[node] {
+				if pod.OwnerKind == "DaemonSet" {
+					continue
+				}
+				if class := classes[workloadKey(pod)]; class != spot.ClassEligible {
+					blockers[fmt.Sprintf("%s: %s", workloadKey(pod), class)] = true
+					ok = false
+				}
+			}
+			if ok {
+				eligible = append(eligible, node)
+			}
+		}
+		if len(eligible) == 0 {
+			continue
+		}
+
+		rec := Recommendation{
+			ID:             fmt.Sprintf("%s/%s/%s", KindSpot, g.region, g.instanceType.Name),
+			Kind:           KindSpot,
+			Title:          fmt.Sprintf("Run %s workloads on spot", g.instanceType.Name),
+			Action:         fmt.Sprintf("Allow the spot capacity type for %d %s node(s) and diversify across instance types", len(eligible), g.instanceType.Name),
+			Nodes:          eligible,
+			Workloads:      r.workloads(eligible),
+			MonthlySavings: (g.instanceType.OnDemand - g.instanceType.Spot) * pricing.HoursPerMonth * float64(len(eligible)),
+			Risk:           RiskMedium,
+			Confidence:     0.7,
+		}
+		if len(blockers) > 0 {
+			rec.Notes = append(rec.Notes, fmt.Sprintf("%d node(s) skipped because of workloads not eligible for spot:", len(g.nodes)-len(eligible)))
+			rec.Notes = append(rec.Notes, slices.Sorted(maps.Keys(blockers))...)
+		}
+		recs = append(recs, rec)
+	}
+	return recs
+}
+
+// consolidation reports what Karpenter consolidation would save, including
+// on nodes no NodePool manages yet.
+func consolidation(r *run) []Recommendation {
+	result, err := simulator.Consolidate(simulator.ConsolidationInput{
+		Nodes:     r.input.Nodes,
+		Pods:      r.input.Pods,
+		PDBs:      r.input.PDBs,
+		NodePools: r.input.NodePools,
+		Options: simulator.ConsolidationOptions{
+			Region:                r.input.Region,
+			IncludeUnmanagedNodes: true,
+		},
+	}, r.catalog)
+	if err != nil {
+		r.warnings = append(r.warnings, fmt.Sprintf("consolidation: %v", err))
+		return nil
+	}
+	if result.Savings.Monthly <= 0 {
+		return nil
+	}
+
+	nodes := map[string]bool{}
+	moved := map[string]bool{}
+	risk := RiskLow
+	replacements := 0
+	for _, action := range result.Actions {
+		for _, node := range action.Nodes {
+			nodes[node] = true
+		}
+		for _, pod := range action.PodsMoved {
+			moved[pod] = true
+		}
+		if action.Method != simulator.ConsolidationMethodEmptiness {
+			risk = RiskMedium
+		}
+		if action.Replacement != nil {
+			replacements++
+		}
+	}
+
+	var workloads []string
+	seen := map[string]bool{}
+	for _, pod := range r.input.Pods {
+		if moved[pod.Namespace+"/"+pod.Name] && !seen[workloadKey(pod)] {
+			seen[workloadKey(pod)] = true
+			workloads = append(workloads, workloadKey(pod))
+		}
+	}
+	sort.Strings(workloads)
+
+	action := fmt.Sprintf("Enable consolidation (WhenEmptyOrUnderutilized): it would remove %d node(s)", len(nodes))
+	if replacements > 0 {
+		action += fmt.Sprintf(" and launch %d cheaper replacement(s)", replacements)
+	}
+	rec := Recommendation{
+		ID:             string(KindConsolidation) + "/cluster",
+		Kind:           KindConsolidation,
+		Title:          fmt.Sprintf("Consolidate %d underused node(s)", len(nodes)),
+		Action:         action,
+		Nodes:          slices.Sorted(maps.Keys(nodes)),
+		Workloads:      workloads,
+		MonthlySavings: result.Savings.Monthly,
+		Risk:           risk,
+		Confidence:     0.8,
+	}
+	if workloads == nil {
+		rec.Workloads = []string{}
+	}
+	for _, skipped := range result.Skipped {
+		if skipped.Reason != "not managed by a Karpenter NodePool" {
+			rec.Notes = append(rec.Notes, skipped.Node+": "+skipped.Reason)
+		}
+	}
+	return []Recommendation{rec}
+}
+
+func (g *nodeGroup) savings(target pricing.InstanceType) float64 {
+	return (g.instanceType.HourlyPrice(g.spot) - target.HourlyPrice(g.spot)) * pricing.HoursPerMonth * float64(len(g.nodes))
+}
+
+// pinnedToAMD64 reports whether a pod on the node requires amd64.
+func (r *run) pinnedToAMD64(node string) bool {
+	for _, pod := range r.pods[node] {
+		if graviton.PinnedToAMD64(pod) != "" {
+			return true
+		}
+	}
+	return false
+}
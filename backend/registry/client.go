@@ -0,0 +1,281 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	maxManifestSize         = 4 << 20
+	defaultTimeout          = 15 * time.Second
+)
+
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	s := p.OS + "/" + p.Architecture
+	if p.Variant != "" {
+		s += "/" + p.Variant
+	}
+	return s
+}
+
+type Options struct {
+	// Endpoint, when set, receives every lookup regardless of the registry
+	// named in the image, e.g. "http://localhost:5000" for a local registry
+	// or pull-through mirror standing in for the real ones.
+	Endpoint string
+	Timeout  time.Duration
+}
+
+// Client reads image manifests from OCI distribution registries. It only
+// performs anonymous pulls, using the token flow registries such as Docker
+// Hub and GHCR require for public images. Results are cached per image.
+type Client struct {
+	endpoint *url.URL
+	http     *http.Client
+
+	mu     sync.Mutex
+	cache  map[string][]Platform
+	tokens map[string]string
+}
+
+func NewClient(opts Options) (*Client, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	c := &Client{
+		http:   &http.Client{Timeout: opts.Timeout},
+		cache:  map[string][]Platform{},
+		tokens: map[string]string{},
+	}
+	if opts.Endpoint != "" {
+		endpoint, err := url.Parse(opts.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid registry endpoint %q", opts.Endpoint)
+		}
+		c.endpoint = endpoint
+	}
+	return c, nil
+}
+
+// Platforms returns the platforms the image is published for. A manifest
+// list or OCI index lists them directly; for a single-platform image the
+// platform is read from its config blob.
+func (c *Client) Platforms(ctx context.Context, image string) ([]Platform, error) {
+	c.mu.Lock()
+	platforms, ok := c.cache[image]
+	c.mu.Unlock()
+	if ok {
+		return platforms, nil
+	}
+
+	ref, err := ParseReference(image)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+		Manifests []struct {
+			Platform *Platform `json:"platform"`
+		} `json:"manifests"`
+		Config struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+	}
+	accept := strings.Join([]string{mediaTypeOCIIndex, mediaTypeDockerList, mediaTypeOCIManifest, mediaTypeDockerManifest}, ", ")
+	if err := c.get(ctx, ref, "manifests/"+ref.Identifier(), accept, &manifest); err != nil {
+		return nil, err
+	}
+
+	if len(manifest.Manifests) > 0 {
+		for _, m := range manifest.Manifests {
+			// Attestation manifests in buildx indexes use unknown/unknown.
+			if m.Platform != nil && m.Platform.Architecture != "unknown" {
+				platforms = append(platforms, *m.Platform)
+			}
+		}
+	} else {
+		if manifest.Config.Digest == "" {
+			return nil, fmt.Errorf("%s: manifest has neither platforms nor a config", ref)
+		}
+		var config Platform
+		if err := c.get(ctx, ref, "blobs/"+manifest.Config.Digest, "*/*", &config); err != nil {
+			return nil, err
+		}
+		platforms = []Platform{config}
+	}
+
+	c.mu.Lock()
+	c.cache[image] = platforms
+	c.mu.Unlock()
+	return platforms, nil
+}
+
+// get fetches a registry API path for the repository and decodes the JSON
+// response, obtaining an anonymous bearer token when challenged.
+func (c *Client) get(ctx context.Context, ref Reference, path, accept string, out interface{}) error {
+	u := c.baseURL(ref)
+	u.Path = fmt.Sprintf("/v2/%s/%s", ref.Repository, path)
+
+	resp, err := c.do(ctx, u.String(), accept, c.token(ref))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err := c.authenticate(ctx, ref, challenge)
+		if err != nil {
+			return err
+		}
+		if resp, err = c.do(ctx, u.String(), accept, token); err != nil {
+			return err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: registry returned %s", ref, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(out); err != nil {
+		return fmt.Errorf("%s: failed to decode %s: %w", ref, path, err)
+	}
+	return nil
+}
+
+func (c *Client) do(ctx context.Context, u, accept, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", accept)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// authenticate follows a Bearer challenge to the registry's token service.
+func (c *Client) authenticate(ctx context.Context, ref Reference, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	if !strings.EqualFold(scheme, "bearer") || params["realm"] == "" {
+		return "", fmt.Errorf("%s: registry requires credentials", ref)
+	}
+
+	u, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", fmt.Errorf("%s: invalid token realm: %w", ref, err)
+	}
+	q := u.Query()
+	if params["service"] != "" {
+		q.Set("service", params["service"])
+	}
+	q.Set("scope", fmt.Sprintf("repository:%s:pull", ref.Repository))
+	u.RawQuery = q.Encode()
+
+	resp, err := c.do(ctx, u.String(), "application/json", "")
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: token request returned %s", ref, resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("%s: failed to decode token: %w", ref, err)
+	}
+	token := body.Token
+	if token == "" {
+		token = body.AccessToken
+	}
+
+	c.mu.Lock()
+	c.tokens[ref.Registry+"/"+ref.Repository] = token
+	c.mu.Unlock()
+	return token, nil
+}
+
+func (c *Client) token(ref Reference) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[ref.Registry+"/"+ref.Repository]
+}
+
+func (c *Client) baseURL(ref Reference) *url.URL {
+	if c.endpoint != nil {
+		u := *c.endpoint
+		return &u
+	}
+	host := ref.Registry
+	if host == dockerHub {
+		host = dockerHubHost
+	}
+	scheme := "https"
+	if strings.HasPrefix(host, "localhost") || strings.HasPrefix(host, "127.0.0.1") {
+		scheme = "http"
+	}
+	return &url.URL{Scheme: scheme, Host: host}
+}
+
+// parseChallenge splits a WWW-Authenticate header such as
+// `Bearer realm="https://auth.docker.io/token",service="registry.docker.io"`.
+func parseChallenge(header string) (string, map[string]string) {
+	params := map[string]string{}
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	for _, part := range splitParams(rest) {
+		key, value, ok := strings.Cut(part, "=")
+		if ok {
+			params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+	return scheme, params
+}
+
+// splitParams splits on commas outside quotes; scope values contain commas.
+func splitParams(s string) []string {
+	var parts []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case r == ',' && !quoted:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
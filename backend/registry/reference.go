@@ -0,0 +1,83 @@
+package registry
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	dockerHub       = "docker.io"
+	dockerHubHost   = "registry-1.docker.io"
+	defaultTag      = "latest"
+	officialLibrary = "library/"
+)
+
+// Reference is a parsed image reference such as
+// "ghcr.io/org/app:1.2" or "nginx@sha256:...".
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference normalizes an image reference the way the container
+// runtime does: Docker Hub is the default registry, single-name Docker Hub
+// repositories live under library/, and the default tag is latest.
+func ParseReference(image string) (Reference, error) {
+	if image == "" {
+		return Reference{}, fmt.Errorf("empty image reference")
+	}
+
+	var ref Reference
+	name := image
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+
+	ref.Registry = dockerHub
+	if i := strings.Index(name, "/"); i >= 0 {
+		first := name[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			ref.Registry = first
+			name = name[i+1:]
+		}
+	}
+	if ref.Registry == dockerHub && !strings.Contains(name, "/") {
+		name = officialLibrary + name
+	}
+	if name == "" {
+		return Reference{}, fmt.Errorf("invalid image reference %q", image)
+	}
+	ref.Repository = name
+
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultTag
+	}
+	return ref, nil
+}
+
+// Identifier is the tag or digest to request from the manifests endpoint.
+// A digest wins over a tag, as it does for the runtime.
+func (r Reference) Identifier() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
@@ -0,0 +1,570 @@
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+)
+
+const (
+	ConsolidationMethodEmptiness  = "emptiness"
+	ConsolidationMethodMultiNode  = "multi-node"
+	ConsolidationMethodSingleNode = "single-node"
+
+	ConsolidationActionDelete  = "delete"
+	ConsolidationActionReplace = "replace"
+
+	consolidationPolicyWhenEmpty = "WhenEmpty"
+	defaultDisruptionBudget      = "10%"
+	defaultConsolidationRounds   = 10
+	maxMultiNodeCandidates       = 100
+)
+
+type ConsolidationOptions struct {
+	Region string `json:"region"`
+	// IncludeUnmanagedNodes also considers nodes that no NodePool owns, to
+	// preview what consolidation would do once they are migrated.
+	IncludeUnmanagedNodes bool `json:"includeUnmanagedNodes"`
+	// SpotToSpot mirrors Karpenter's SpotToSpotConsolidation feature gate.
+	SpotToSpot bool `json:"spotToSpot"`
+	MaxRounds  int  `json:"maxRounds"`
+}
+
+type ConsolidationInput struct {
+	Nodes     []k8s.NodeDetails
+	Pods      []k8s.PodDetails
+	PDBs      []k8s.PDBDetails
+	NodePools []k8s.NodePoolDetails
+	Options   ConsolidationOptions
+}
+
+type ConsolidationResult struct {
+	Current   CostSummary           `json:"current"`
+	Projected CostSummary           `json:"projected"`
+	Savings   Savings               `json:"savings"`
+	Actions   []ConsolidationAction `json:"actions"`
+	Skipped   []SkippedNode         `json:"skipped"`
+	Warnings  []string              `json:"warnings"`
+}
+
+type ConsolidationAction struct {
+	Round          int          `json:"round"`
+	Method         string       `json:"method"`
+	Action         string       `json:"action"`
+	Nodes          []string     `json:"nodes"`
+	Replacement    *Replacement `json:"replacement,omitempty"`
+	PodsMoved      []string     `json:"podsMoved"`
+	HourlySavings  float64      `json:"hourlySavings"`
+	MonthlySavings float64      `json:"monthlySavings"`
+}
+
+type Replacement struct {
+	InstanceType string  `json:"instanceType"`
+	CapacityType string  `json:"capacityType"`
+	Zone         string  `json:"zone"`
+	HourlyCost   float64 `json:"hourlyCost"`
+}
+
+type SkippedNode struct {
+	Node   string `json:"node"`
+	Reason string `json:"reason"`
+}
+
+type consolidator struct {
+	input    ConsolidationInput
+	catalog  *pricing.Catalog
+	state    *scheduler
+	nodes    map[string]k8s.NodeDetails
+	pools    map[string]k8s.NodePoolDetails
+	poolSize map[string]int
+	daemons  overhead
+
+	// Per-round disruption accounting.
+	poolDisrupted map[string]int
+	pdbDisrupted  map[string]int32
+	replacements  int
+}
+
+// Consolidate emulates Karpenter's disruption controller against a cluster
+// snapshot: it deletes empty nodes, then looks for multi-node and
+// single-node consolidations that either delete nodes outright or replace
+// them with one cheaper node. Each round takes at most one action, from the
+// first of those methods that finds one, as Karpenter does. Do-not-disrupt
+// annotations, PodDisruptionBudgets and NodePool disruption budgets are
+// respected.
+func Consolidate(input ConsolidationInput, catalog *pricing.Catalog) (*ConsolidationResult, error) {
+	if input.Options.Region == "" {
+		input.Options.Region = catalog.DefaultRegion
+	}
+	if input.Options.MaxRounds <= 0 {
+		input.Options.MaxRounds = defaultConsolidationRounds
+	}
+
+	c := &consolidator{
+		input:    input,
+		catalog:  catalog,
+		nodes:    map[string]k8s.NodeDetails{},
+		pools:    map[string]k8s.NodePoolDetails{},
+		poolSize: map[string]int{},
+	}
+	for _, np := range input.NodePools {
+		c.pools[np.Name] = np
+	}
+	c.daemons, _ = splitDaemonSetPods(input.Pods)
+
+	result := &ConsolidationResult{}
+	c.state = &scheduler{daemons: c.daemons}
+	for _, node := range input.Nodes {
+		c.nodes[node.Name] = node
+		c.poolSize[node.NodePool]++
+
+		instanceType, ok := catalog.Lookup(regionOf(node, input.Options.Region), node.InstanceType)
+		if !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("no price for %s on node %s", node.InstanceType, node.Name))
+		}
+		capacityType := CapacityTypeOnDemand
+		if node.IsSpot {
+			capacityType = CapacityTypeSpot
+		}
+		cpu, memory := node.AllocatableCPU, node.AllocatableMemory
+		if cpu == 0 {
+			cpu, memory = Allocatable(instanceType)
+		}
+		b := newBin(node.Name, offering{
+			instanceType: instanceType,
+			capacityType: capacityType,
+			price:        instanceType.HourlyPrice(node.IsSpot),
+			cpu:          cpu,
+			memory:       memory,
+		}, true, overhead{})
+		b.labels = node.Labels
+		b.taints = node.Taints
+		if node.MaxPods > 0 {
+			b.maxPods = node.MaxPods
+		}
+		c.state.bins = append(c.state.bins, b)
+
+		result.Current.Nodes++
+		result.Current.HourlyCost += b.offering.price
+	}
+
+	byNode := map[string]*bin{}
+	for _, b := range c.state.bins {
+		byNode[b.name] = b
+	}
+	for i := range input.Pods {
+		pod := &input.Pods[i]
+		if pod.Status == "Succeeded" || pod.Status == "Failed" {
+			continue
+		}
+		if b, ok := byNode[pod.NodeName]; ok {
+			b.add(pod)
+		}
+	}
+
+	for _, b := range c.state.bins {
+		if reason := c.blockedReason(b); reason != "" {
+			result.Skipped = append(result.Skipped, SkippedNode{Node: b.name, Reason: reason})
+		}
+	}
+	for _, np := range input.NodePools {
+		for _, budget := range np.Budgets {
+			if budget.Schedule != "" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("nodepool %s: scheduled budget %q assumed active", np.Name, budget.Schedule))
+			}
+		}
+	}
+
+	for round := 1; round <= input.Options.MaxRounds; round++ {
+		c.poolDisrupted = map[string]int{}
+		c.pdbDisrupted = map[string]int32{}
+
+		// Like Karpenter, try each method in turn and stop at the first that
+		// finds something to do.
+		action := c.emptiness()
+		if action == nil {
+			action = c.multiNode()
+		}
+		if action == nil {
+			action = c.singleNode()
+		}
+		if action == nil {
+			break
+		}
+		action.Round = round
+		action.MonthlySavings = action.HourlySavings * pricing.HoursPerMonth
+		result.Actions = append(result.Actions, *action)
+	}
+
+	var hourlySavings float64
+	for _, action := range result.Actions {
+		hourlySavings += action.HourlySavings
+	}
+	result.Projected.Nodes = len(c.state.bins)
+	result.Projected.HourlyCost = result.Current.HourlyCost - hourlySavings
+	result.Current.MonthlyCost = result.Current.HourlyCost * pricing.HoursPerMonth
+	result.Projected.MonthlyCost = result.Projected.HourlyCost * pricing.HoursPerMonth
+	result.Savings.Monthly = result.Current.MonthlyCost - result.Projected.MonthlyCost
+	if result.Current.MonthlyCost > 0 {
+		result.Savings.Percentage = result.Savings.Monthly / result.Current.MonthlyCost * 100
+	}
+
+	return result, nil
+}
+
+// blockedReason explains why a node can never be a consolidation candidate.
+func (c *consolidator) blockedReason(b *bin) string {
+	if !b.existing {
+		return "launched by this simulation"
+	}
+	node := c.nodes[b.name]
+	if node.NodePool == "" && !c.input.Options.IncludeUnmanagedNodes {
+		return "not managed by a Karpenter NodePool"
+	}
+	if node.DoNotDisrupt {
+		return "node has " + k8s.DoNotDisruptAnnotation
+	}
+	for _, pod := range b.pods {
+		if pod.DoNotDisrupt {
+			return fmt.Sprintf("pod %s/%s has %s", pod.Namespace, pod.Name, k8s.DoNotDisruptAnnotation)
+		}
+		if pod.OwnerKind == "DaemonSet" {
+			continue
+		}
+		for _, pdb := range c.input.PDBs {
+			if pdb.DisruptionsAllowed <= 0 && pdb.Matches(*pod) {
+				return fmt.Sprintf("PodDisruptionBudget %s/%s blocks eviction of %s", pdb.Namespace, pdb.Name, pod.Name)
+			}
+		}
+	}
+	return ""
+}
+
+// candidates lists the nodes that may be disrupted for the given reason,
+// cheapest to disrupt first.
+func (c *consolidator) candidates(reason string) []*bin {
+	var result []*bin
+	for _, b := range c.state.bins {
+		if c.blockedReason(b) != "" {
+			continue
+		}
+		if reason == "Underutilized" && c.pools[c.nodes[b.name].NodePool].ConsolidationPolicy == consolidationPolicyWhenEmpty {
+			continue
+		}
+		result = append(result, b)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		ci, cj := len(movablePods(result[i])), len(movablePods(result[j]))
+		if ci != cj {
+			return ci < cj
+		}
+		return result[i].name < result[j].name
+	})
+	return result
+}
+
+func (c *consolidator) emptiness() *ConsolidationAction {
+	var empty []*bin
+	for _, b := range c.candidates("Empty") {
+		if len(movablePods(b)) == 0 && c.budgetAllows(b, "Empty", 1) {
+			c.poolDisrupted[c.nodes[b.name].NodePool]++
+			empty = append(empty, b)
+		}
+	}
+	if len(empty) == 0 {
+		return nil
+	}
+
+	action := &ConsolidationAction{
+		Method: ConsolidationMethodEmptiness,
+		Action: ConsolidationActionDelete,
+	}
+	for _, b := range empty {
+		action.Nodes = append(action.Nodes, b.name)
+		action.HourlySavings += b.offering.price
+	}
+	c.state.bins = withoutBins(c.state.bins, empty)
+	return action
+}
+
+// multiNode binary-searches the longest prefix of candidates that can be
+// consolidated together, like Karpenter's multi-node consolidation.
+func (c *consolidator) multiNode() *ConsolidationAction {
+	var candidates []*bin
+	counted := map[string]int{}
+	for _, b := range c.candidates("Underutilized") {
+		pool := c.nodes[b.name].NodePool
+		if !c.budgetAllows(b, "Underutilized", counted[pool]+1) {
+			continue
+		}
+		counted[pool]++
+		candidates = append(candidates, b)
+		if len(candidates) == maxMultiNodeCandidates {
+			break
+		}
+	}
+	if len(candidates) < 2 {
+		return nil
+	}
+
+	var best *ConsolidationAction
+	var bestState *scheduler
+	lo, hi := 2, len(candidates)
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		action, state := c.evaluate(candidates[:mid])
+		if action != nil {
+			best, bestState = action, state
+			lo = mid + 1
+		} else {
+			hi = mid - 1
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	best.Method = ConsolidationMethodMultiNode
+	c.commit(best, bestState)
+	return best
+}
+
+func (c *consolidator) singleNode() *ConsolidationAction {
+	for _, b := range c.candidates("Underutilized") {
+		if !c.budgetAllows(b, "Underutilized", 1) {
+			continue
+		}
+		if action, state := c.evaluate([]*bin{b}); action != nil {
+			action.Method = ConsolidationMethodSingleNode
+			c.commit(action, state)
+			return action
+		}
+	}
+	return nil
+}
+
+// evaluate checks whether the candidates' pods fit on the remaining nodes,
+// or on the remaining nodes plus a single cheaper replacement. It returns
+// the action and the resulting cluster state without committing either.
+func (c *consolidator) evaluate(candidates []*bin) (*ConsolidationAction, *scheduler) {
+	trial := c.state.clone()
+	trial.bins = withoutBins(trial.bins, candidates)
+
+	var pods []*k8s.PodDetails
+	var hourlyCost float64
+	allSpot := true
+	for _, b := range candidates {
+		pods = append(pods, movablePods(b)...)
+		hourlyCost += b.offering.price
+		allSpot = allSpot && b.offering.capacityType == CapacityTypeSpot
+	}
+	if !c.pdbsAllow(pods) {
+		return nil, nil
+	}
+	var largest offering
+	for _, b := range c.state.bins {
+		largest.cpu = max(largest.cpu, b.offering.cpu)
+		largest.memory = max(largest.memory, b.offering.memory)
+	}
+	sortDecreasing(pods, largest)
+
+	var leftovers []*k8s.PodDetails
+	for _, pod := range pods {
+		if b, _ := trial.findBin(pod); b != nil {
+			b.add(pod)
+		} else {
+			leftovers = append(leftovers, pod)
+		}
+	}
+
+	action := &ConsolidationAction{
+		Action:        ConsolidationActionDelete,
+		HourlySavings: hourlyCost,
+	}
+	for _, b := range candidates {
+		action.Nodes = append(action.Nodes, b.name)
+	}
+	for _, pod := range pods {
+		action.PodsMoved = append(action.PodsMoved, pod.Namespace+"/"+pod.Name)
+	}
+	if len(leftovers) == 0 {
+		return action, trial
+	}
+
+	replacement := c.replacement(trial, candidates[0], leftovers, allSpot, hourlyCost)
+	if replacement == nil {
+		return nil, nil
+	}
+	action.Action = ConsolidationActionReplace
+	action.Replacement = &Replacement{
+		InstanceType: replacement.offering.instanceType.Name,
+		CapacityType: replacement.offering.capacityType,
+		Zone:         replacement.labels[labelZone],
+		HourlyCost:   replacement.offering.price,
+	}
+	action.HourlySavings = hourlyCost - replacement.offering.price
+	return action, trial
+}
+
+// replacement launches the cheapest single node the candidate's NodePool
+// allows that holds every leftover pod and costs less than what it replaces.
+func (c *consolidator) replacement(trial *scheduler, candidate *bin, pods []*k8s.PodDetails, allSpot bool, budget float64) *bin {
+	config := CandidateConfig{Region: c.input.Options.Region}
+	if np, ok := c.pools[c.nodes[candidate.name].NodePool]; ok {
+		config = CandidateFromNodePool(np, c.input.Options.Region)
+	}
+	if allSpot && !c.input.Options.SpotToSpot {
+		config.CapacityTypes = []string{CapacityTypeOnDemand}
+	}
+	offerings := candidateOfferings(config, c.catalog)
+	sort.SliceStable(offerings, func(i, j int) bool { return offerings[i].price < offerings[j].price })
+	trial.pools = []candidatePool{{config: config, offerings: offerings, zones: candidateZones(config, c.input.Nodes)}}
+
+	name := fmt.Sprintf("replacement-%d", c.replacements+1)
+	for _, o := range offerings {
+		if o.price >= budget {
+			break
+		}
+		for _, zone := range trial.pools[0].zones {
+			b := trial.newCandidateBin(name, config, o, zone)
+			trial.bins = append(trial.bins, b)
+			fits := true
+			for _, pod := range pods {
+				if trial.fit(pod, b) != "" {
+					fits = false
+					break
+				}
+				b.add(pod)
+			}
+			if fits {
+				return b
+			}
+			trial.bins = trial.bins[:len(trial.bins)-1]
+		}
+	}
+	return nil
+}
+
+func (c *consolidator) commit(action *ConsolidationAction, state *scheduler) {
+	for _, name := range action.Nodes {
+		c.poolDisrupted[c.nodes[name].NodePool]++
+	}
+	for _, key := range action.PodsMoved {
+		for _, pdb := range c.input.PDBs {
+			if pdb.Matches(c.podByKey(key)) {
+				c.pdbDisrupted[pdb.Namespace+"/"+pdb.Name]++
+			}
+		}
+	}
+	if action.Replacement != nil {
+		c.replacements++
+	}
+	c.state = state
+}
+
+// budgetAllows reports whether the node's NodePool may have `count` nodes
+// disrupted for the reason in this round.
+func (c *consolidator) budgetAllows(b *bin, reason string, count int) bool {
+	pool := c.nodes[b.name].NodePool
+	budgets := c.pools[pool].Budgets
+	if len(budgets) == 0 {
+		budgets = []k8s.DisruptionBudget{{Nodes: defaultDisruptionBudget}}
+	}
+
+	allowed := math.MaxInt
+	for _, budget := range budgets {
+		if len(budget.Reasons) > 0 && !slices.Contains(budget.Reasons, reason) {
+			continue
+		}
+		allowed = min(allowed, scaledBudget(budget.Nodes, c.poolSize[pool]))
+	}
+	return c.poolDisrupted[pool]+count <= allowed
+}
+
+func (c *consolidator) pdbsAllow(pods []*k8s.PodDetails) bool {
+	evictions := map[string]int32{}
+	for _, pod := range pods {
+		for _, pdb := range c.input.PDBs {
+			if !pdb.Matches(*pod) {
+				continue
+			}
+			key := pdb.Namespace + "/" + pdb.Name
+			evictions[key]++
+			if c.pdbDisrupted[key]+evictions[key] > pdb.DisruptionsAllowed {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (c *consolidator) podByKey(key string) k8s.PodDetails {
+	for _, pod := range c.input.Pods {
+		if pod.Namespace+"/"+pod.Name == key {
+			return pod
+		}
+	}
+	return k8s.PodDetails{}
+}
+
+// scaledBudget resolves a budget's "nodes" value, either an absolute count
+// or a percentage of the pool rounded up, as Karpenter does.
+func scaledBudget(nodes string, poolSize int) int {
+	if strings.HasSuffix(nodes, "%") {
+		percent, err := strconv.Atoi(strings.TrimSuffix(nodes, "%"))
+		if err != nil {
+			return 0
+		}
+		return int(math.Ceil(float64(poolSize) * float64(percent) / 100))
+	}
+	count, err := strconv.Atoi(nodes)
+	if err != nil {
+		return 0
+	}
+	return count
+}
+
+func movablePods(b *bin) []*k8s.PodDetails {
+	var pods []*k8s.PodDetails
+	for _, pod := range b.pods {
+		if pod.OwnerKind != "DaemonSet" {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+func withoutBins(bins []*bin, remove []*bin) []*bin {
+	removed := map[string]bool{}
+	for _, b := range remove {
+		removed[b.name] = true
+	}
+	result := make([]*bin, 0, len(bins))
+	for _, b := range bins {
+		if !removed[b.name] {
+			result = append(result, b)
+		}
+	}
+	return result
+}
+
+func (s *scheduler) clone() *scheduler {
+	c := *s
+	c.bins = make([]*bin, len(s.bins))
+	for i, b := range s.bins {
+		copied := *b
+		copied.pods = append([]*k8s.PodDetails(nil), b.pods...)
+		copied.hostPorts = make(map[string]bool, len(b.hostPorts))
+		for k, v := range b.hostPorts {
+			copied.hostPorts[k] = v
+		}
+		c.bins[i] = &copied
+	}
+	return &c
+}
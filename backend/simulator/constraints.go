@@ -0,0 +1,293 @@
+package simulator
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	labelHostname     = "kubernetes.io/hostname"
+	labelZone         = "topology.kubernetes.io/zone"
+	labelRegion       = "topology.kubernetes.io/region"
+	labelInstanceType = "node.kubernetes.io/instance-type"
+	labelArch         = "kubernetes.io/arch"
+	labelCapacityType = "karpenter.sh/capacity-type"
+
+	defaultMaxPods = 110
+)
+
+// nodeFit checks the constraints that only depend on the pod and the node:
+// resources, pod count, taints, node selectors, required node affinity and
+// host ports.
+func nodeFit(pod *k8s.PodDetails, b *bin) string {
+	if !b.fits(pod) {
+		return "insufficient cpu or memory"
+	}
+	if int64(len(b.pods)+b.daemonPods) >= b.maxPods {
+		return "node has reached its max-pods limit"
+	}
+	if reason := staticFit(pod, b); reason != "" {
+		return reason
+	}
+	for _, port := range pod.HostPorts {
+		if b.hostPorts[hostPortKey(port)] {
+			return fmt.Sprintf("host port %d/%s already in use", port.Port, port.Protocol)
+		}
+	}
+	return ""
+}
+
+// podFit checks the constraints that depend on the other pods already placed:
+// inter-pod (anti-)affinity and topology spread.
+func (s *scheduler) podFit(pod *k8s.PodDetails, b *bin) string {
+	if pod.Affinity != nil && pod.Affinity.PodAffinity != nil {
+		for _, term := range pod.Affinity.PodAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if !s.termSatisfied(pod, term, b) {
+				return "required pod affinity is not satisfied"
+			}
+		}
+	}
+	if pod.Affinity != nil && pod.Affinity.PodAntiAffinity != nil {
+		for _, term := range pod.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+			if s.termMatchesDomain(pod, term, b) {
+				return "required pod anti-affinity is violated"
+			}
+		}
+	}
+	// Anti-affinity is symmetric: pods already on the domain can also keep
+	// the incoming pod away.
+	for _, other := range s.bins {
+		for _, placed := range other.pods {
+			if placed.Affinity == nil || placed.Affinity.PodAntiAffinity == nil {
+				continue
+			}
+			for _, term := range placed.Affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+				domain, ok := other.labels[term.TopologyKey]
+				if ok && b.labels[term.TopologyKey] == domain && termSelects(placed, term, pod) {
+					return fmt.Sprintf("violates anti-affinity of %s/%s", placed.Namespace, placed.Name)
+				}
+			}
+		}
+	}
+	for _, constraint := range pod.TopologySpreadConstraints {
+		if constraint.WhenUnsatisfiable != corev1.DoNotSchedule {
+			continue
+		}
+		if !s.spreadSatisfied(pod, constraint, b) {
+			return fmt.Sprintf("topology spread on %s would exceed max skew %d", constraint.TopologyKey, constraint.MaxSkew)
+		}
+	}
+	return ""
+}
+
+func (s *scheduler) termSatisfied(pod *k8s.PodDetails, term corev1.PodAffinityTerm, b *bin) bool {
+	if s.termMatchesDomain(pod, term, b) {
+		return true
+	}
+	// Like kube-scheduler, allow the first pod of a group whose affinity
+	// selects itself, otherwise it could never be scheduled.
+	if !termSelects(pod, term, pod) {
+		return false
+	}
+	for _, other := range s.bins {
+		for _, placed := range other.pods {
+			if termSelects(pod, term, placed) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// termMatchesDomain reports whether any placed pod selected by the term runs
+// in the same topology domain as the bin.
+func (s *scheduler) termMatchesDomain(pod *k8s.PodDetails, term corev1.PodAffinityTerm, b *bin) bool {
+	domain, ok := b.labels[term.TopologyKey]
+	if !ok {
+		return false
+	}
+	for _, other := range s.bins {
+		if other.labels[term.TopologyKey] != domain {
+			continue
+		}
+		for _, placed := range other.pods {
+			if termSelects(pod, term, placed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *scheduler) spreadSatisfied(pod *k8s.PodDetails, constraint corev1.TopologySpreadConstraint, b *bin) bool {
+	domain, ok := b.labels[constraint.TopologyKey]
+	if !ok {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(constraint.LabelSelector)
+	if err != nil {
+		return true
+	}
+
+	counts := map[string]int32{domain: 0}
+	for _, d := range s.domains(pod, constraint.TopologyKey) {
+		counts[d] = 0
+	}
+	for _, other := range s.bins {
+		d, ok := other.labels[constraint.TopologyKey]
+		if !ok {
+			continue
+		}
+		for _, placed := range other.pods {
+			if placed.Namespace == pod.Namespace && selector.Matches(labels.Set(placed.Labels)) {
+				counts[d]++
+			}
+		}
+	}
+
+	minimum := int32(-1)
+	for _, count := range counts {
+		if minimum < 0 || count < minimum {
+			minimum = count
+		}
+	}
+	return counts[domain]+1-minimum <= constraint.MaxSkew
+}
+
+// domains lists the topology domains eligible for the pod: those of bins the
+// pod could run on, plus the zones the candidate NodePools may launch into.
+func (s *scheduler) domains(pod *k8s.PodDetails, key string) []string {
+	var domains []string
+	for _, b := range s.bins {
+		if d, ok := b.labels[key]; ok && staticFit(pod, b) == "" {
+			domains = append(domains, d)
+		}
+	}
+	if key == labelZone {
+		for _, pool := range s.pools {
+			domains = append(domains, pool.zones...)
+		}
+	}
+	return domains
+}
+
+// staticFit checks taints, node selectors and required node affinity. It is
+// also used on its own to decide which domains count towards topology spread.
+func staticFit(pod *k8s.PodDetails, b *bin) string {
+	for i := range b.taints {
+		taint := &b.taints[i]
+		if taint.Effect != corev1.TaintEffectPreferNoSchedule && !toleratesTaint(pod.Tolerations, taint) {
+			return fmt.Sprintf("untolerated taint %s=%s:%s", taint.Key, taint.Value, taint.Effect)
+		}
+	}
+	if len(pod.NodeSelector) > 0 && !labels.SelectorFromSet(pod.NodeSelector).Matches(labels.Set(b.labels)) {
+		return "node selector does not match"
+	}
+	if pod.Affinity != nil && pod.Affinity.NodeAffinity != nil {
+		required := pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+		if required != nil && !matchNodeSelectorTerms(required.NodeSelectorTerms, b.labels) {
+			return "required node affinity does not match"
+		}
+	}
+	return ""
+}
+
+// termSelects reports whether the affinity term declared by owner selects
+// the candidate pod.
+func termSelects(owner *k8s.PodDetails, term corev1.PodAffinityTerm, candidate *k8s.PodDetails) bool {
+	if len(term.Namespaces) > 0 {
+		if !slices.Contains(term.Namespaces, candidate.Namespace) {
+			return false
+		}
+	} else if term.NamespaceSelector == nil && candidate.Namespace != owner.Namespace {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(term.LabelSelector)
+	if err != nil || term.LabelSelector == nil {
+		return false
+	}
+	return selector.Matches(labels.Set(candidate.Labels))
+}
+
+func toleratesTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNodeSelectorTerms(terms []corev1.NodeSelectorTerm, nodeLabels map[string]string) bool {
+	for _, term := range terms {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			continue
+		}
+		matched := true
+		for _, expr := range term.MatchExpressions {
+			if !matchRequirement(expr, nodeLabels[expr.Key], hasKey(nodeLabels, expr.Key)) {
+				matched = false
+				break
+			}
+		}
+		for _, expr := range term.MatchFields {
+			if expr.Key != "metadata.name" || !matchRequirement(expr, nodeLabels[labelHostname], true) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func matchRequirement(expr corev1.NodeSelectorRequirement, value string, present bool) bool {
+	switch expr.Operator {
+	case corev1.NodeSelectorOpIn:
+		return present && slices.Contains(expr.Values, value)
+	case corev1.NodeSelectorOpNotIn:
+		return !present || !slices.Contains(expr.Values, value)
+	case corev1.NodeSelectorOpExists:
+		return present
+	case corev1.NodeSelectorOpDoesNotExist:
+		return !present
+	case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+		if !present || len(expr.Values) != 1 {
+			return false
+		}
+		actual, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return false
+		}
+		bound, err := strconv.ParseInt(expr.Values[0], 10, 64)
+		if err != nil {
+			return false
+		}
+		if expr.Operator == corev1.NodeSelectorOpGt {
+			return actual > bound
+		}
+		return actual < bound
+	}
+	return false
+}
+
+func hasKey(m map[string]string, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func hostPortKey(port k8s.HostPort) string {
+	protocol := port.Protocol
+	if protocol == "" {
+		protocol = string(corev1.ProtocolTCP)
+	}
+	return fmt.Sprintf("%s/%d", protocol, port.Port)
+}
@@ -0,0 +1,79 @@
+package simulator
+
+import (
+	"fmt"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+)
+
+type MoveStep struct {
+	Step  int       `json:"step"`
+	Moves []PodMove `json:"moves"`
+}
+
+type BlockedMove struct {
+	PodMove
+	Reason string `json:"reason"`
+}
+
+// planSteps batches moves so that no step evicts more pods covered by a
+// PodDisruptionBudget than the budget currently allows. Pending pods are not
+// disruptions and always go in the first step; moves covered by a budget
+// that allows no disruptions at all are reported as blocked.
+func planSteps(moves []PodMove, pods []k8s.PodDetails, pdbs []k8s.PDBDetails) ([]MoveStep, []BlockedMove) {
+	byKey := map[string]k8s.PodDetails{}
+	for _, pod := range pods {
+		byKey[pod.Namespace+"/"+pod.Name] = pod
+	}
+
+	var steps []MoveStep
+	var blocked []BlockedMove
+	used := []map[string]int32{}
+
+	for _, move := range moves {
+		var budgets []k8s.PDBDetails
+		if move.From != "" {
+			for _, pdb := range pdbs {
+				if pdb.Matches(byKey[move.Namespace+"/"+move.Name]) {
+					budgets = append(budgets, pdb)
+				}
+			}
+		}
+
+		var reason string
+		for _, pdb := range budgets {
+			if pdb.DisruptionsAllowed <= 0 {
+				reason = fmt.Sprintf("PodDisruptionBudget %s/%s allows no disruptions", pdb.Namespace, pdb.Name)
+				break
+			}
+		}
+		if reason != "" {
+			blocked = append(blocked, BlockedMove{PodMove: move, Reason: reason})
+			continue
+		}
+
+		step := 0
+		for ; step < len(steps); step++ {
+			fits := true
+			for _, pdb := range budgets {
+				if used[step][pdb.Namespace+"/"+pdb.Name] >= pdb.DisruptionsAllowed {
+					fits = false
+					break
+				}
+			}
+			if fits {
+				break
+			}
+		}
+		if step == len(steps) {
+			steps = append(steps, MoveStep{Step: step + 1})
+			used = append(used, map[string]int32{})
+		}
+		steps[step].Moves = append(steps[step].Moves, move)
+		for _, pdb := range budgets {
+			used[step][pdb.Namespace+"/"+pdb.Name]++
+		}
+	}
+
+	return steps, blocked
+}
@@ -0,0 +1,86 @@
+package simulator
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+)
+
+const (
+	requirementInstanceFamily   = "karpenter.k8s.aws/instance-family"
+	requirementInstanceCategory = "karpenter.k8s.aws/instance-category"
+)
+
+// CandidateFromNodePool translates a NodePool's requirements, labels and
+// taints into the candidate config the packer understands. Requirements on
+// keys the catalog knows nothing about are ignored. Like Karpenter, a pool
+// without capacity-type or arch requirements launches on-demand amd64 nodes.
+func CandidateFromNodePool(np k8s.NodePoolDetails, region string) CandidateConfig {
+	candidate := CandidateConfig{
+		Region:        region,
+		CapacityTypes: []string{CapacityTypeOnDemand},
+		Architectures: []string{"amd64"},
+		Labels:        map[string]string{k8s.NodePoolLabel: np.Name},
+		Taints:        np.Taints,
+	}
+	for k, v := range np.Labels {
+		candidate.Labels[k] = v
+	}
+
+	for _, req := range np.Requirements {
+		switch req.Operator {
+		case "In":
+			switch req.Key {
+			case labelInstanceType:
+				candidate.InstanceTypes = req.Values
+			case requirementInstanceFamily:
+				candidate.InstanceFamilies = req.Values
+			case requirementInstanceCategory:
+				candidate.InstanceCategories = req.Values
+			case labelCapacityType:
+				candidate.CapacityTypes = req.Values
+			case labelArch:
+				candidate.Architectures = req.Values
+			case labelZone:
+				candidate.Zones = req.Values
+			}
+		case "NotIn":
+			switch req.Key {
+			case labelInstanceType:
+				candidate.ExcludeInstanceTypes = req.Values
+			case requirementInstanceFamily:
+				candidate.ExcludeInstanceFamilies = req.Values
+			}
+		}
+	}
+
+	return candidate
+}
+
+// categoryOf returns the letters before the generation number, e.g. "c" for
+// c6g and "g" for g4dn.
+func categoryOf(family string) string {
+	if i := strings.IndexAny(family, "0123456789"); i > 0 {
+		return family[:i]
+	}
+	return family
+}
+
+// CandidateInstanceTypes lists the priced instance types the candidate may
+// launch, cheapest first.
+func CandidateInstanceTypes(candidate CandidateConfig, catalog *pricing.Catalog) []string {
+	offerings := candidateOfferings(candidate, catalog)
+	sort.SliceStable(offerings, func(i, j int) bool { return offerings[i].price < offerings[j].price })
+
+	seen := map[string]bool{}
+	var names []string
+	for _, o := range offerings {
+		if !seen[o.instanceType.Name] {
+			seen[o.instanceType.Name] = true
+			names = append(names, o.instanceType.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,610 @@
+package simulator
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	StrategyFirstFitDecreasing = "first-fit-decreasing"
+	StrategyBestFit            = "best-fit"
+
+	CapacityTypeSpot     = "spot"
+	CapacityTypeOnDemand = "on-demand"
+)
+
+// CandidateConfig describes the NodePool shape the pods are packed onto.
+type CandidateConfig struct {
+	Region           string   `json:"region"`
+	InstanceTypes    []string `json:"instanceTypes"`
+	InstanceFamilies []string `json:"instanceFamilies"`
+	// InstanceCategories are family prefixes such as "c", "m" or "r".
+	InstanceCategories      []string          `json:"instanceCategories,omitempty"`
+	ExcludeInstanceTypes    []string          `json:"excludeInstanceTypes,omitempty"`
+	ExcludeInstanceFamilies []string          `json:"excludeInstanceFamilies,omitempty"`
+	CapacityTypes           []string          `json:"capacityTypes"`
+	Architectures           []string          `json:"architectures"`
+	Zones                   []string          `json:"zones"`
+	Labels                  map[string]string `json:"labels"`
+	Taints                  []corev1.Taint    `json:"taints"`
+	MaxPods                 int64             `json:"maxPods"`
+	Strategy                string            `json:"strategy"`
+}
+
+type Input struct {
+	Nodes     []k8s.NodeDetails
+	Pods      []k8s.PodDetails
+	PDBs      []k8s.PDBDetails
+	Candidate CandidateConfig
+	// Candidates, when set, replaces Candidate with several NodePool shapes
+	// tried in order, the way Karpenter tries NodePools by weight. The
+	// strategy of the first one applies.
+	Candidates []CandidateConfig
+}
+
+type Result struct {
+	Strategy      string          `json:"strategy"`
+	Current       CostSummary     `json:"current"`
+	Projected     CostSummary     `json:"projected"`
+	Savings       Savings         `json:"savings"`
+	Nodes         []SimulatedNode `json:"nodes"`
+	Moves         []PodMove       `json:"moves"`
+	Steps         []MoveStep      `json:"steps"`
+	Blocked       []BlockedMove   `json:"blocked"`
+	Unschedulable []PodRef        `json:"unschedulable"`
+	Warnings      []string        `json:"warnings"`
+}
+
+type CostSummary struct {
+	Nodes       int     `json:"nodes"`
+	HourlyCost  float64 `json:"hourlyCost"`
+	MonthlyCost float64 `json:"monthlyCost"`
+}
+
+type Savings struct {
+	Monthly    float64 `json:"monthly"`
+	Percentage float64 `json:"percentage"`
+}
+
+type SimulatedNode struct {
+	Name         string   `json:"name"`
+	InstanceType string   `json:"instanceType"`
+	CapacityType string   `json:"capacityType"`
+	Zone         string   `json:"zone"`
+	NodePool     string   `json:"nodePool,omitempty"`
+	Existing     bool     `json:"existing"`
+	HourlyCost   float64  `json:"hourlyCost"`
+	CPUCapacity  int64    `json:"cpuCapacity"`
+	CPURequested int64    `json:"cpuRequested"`
+	MemCapacity  int64    `json:"memoryCapacity"`
+	MemRequested int64    `json:"memoryRequested"`
+	Pods         []string `json:"pods"`
+}
+
+type PodRef struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+type PodMove struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	From      string `json:"from"`
+	To        string `json:"to"`
+}
+
+// offering is an instance type the candidate NodePool may launch, priced for
+// one capacity type.
+type offering struct {
+	instanceType pricing.InstanceType
+	capacityType string
+	price        float64
+	cpu          int64
+	memory       int64
+}
+
+// overhead is what DaemonSets consume on every node.
+type overhead struct {
+	cpu       int64
+	memory    int64
+	pods      int
+	hostPorts []k8s.HostPort
+}
+
+type bin struct {
+	name       string
+	offering   offering
+	existing   bool
+	cpuUsed    int64
+	memUsed    int64
+	daemonPods int
+	maxPods    int64
+	labels     map[string]string
+	taints     []corev1.Taint
+	hostPorts  map[string]bool
+	pods       []*k8s.PodDetails
+}
+
+func newBin(name string, o offering, existing bool, daemons overhead) *bin {
+	b := &bin{
+		name:       name,
+		offering:   o,
+		existing:   existing,
+		cpuUsed:    daemons.cpu,
+		memUsed:    daemons.memory,
+		daemonPods: daemons.pods,
+		maxPods:    defaultMaxPods,
+		hostPorts:  map[string]bool{},
+	}
+	for _, port := range daemons.hostPorts {
+		b.hostPorts[hostPortKey(port)] = true
+	}
+	return b
+}
+
+func (b *bin) fits(pod *k8s.PodDetails) bool {
+	return b.cpuUsed+pod.CPURequest <= b.offering.cpu && b.memUsed+pod.MemoryRequest <= b.offering.memory
+}
+
+func (b *bin) add(pod *k8s.PodDetails) {
+	b.cpuUsed += pod.CPURequest
+	b.memUsed += pod.MemoryRequest
+	b.pods = append(b.pods, pod)
+	for _, port := range pod.HostPorts {
+		b.hostPorts[hostPortKey(port)] = true
+	}
+}
+
+// candidatePool is one candidate config with its priced offerings and the
+// zones it may launch into.
+type candidatePool struct {
+	config    CandidateConfig
+	offerings []offering
+	zones     []string
+}
+
+type scheduler struct {
+	strategy string
+	pools    []candidatePool
+	daemons  overhead
+	bins     []*bin
+	newNodes int
+}
+
+func (s *scheduler) fit(pod *k8s.PodDetails, b *bin) string {
+	if reason := nodeFit(pod, b); reason != "" {
+		return reason
+	}
+	return s.podFit(pod, b)
+}
+
+// Simulate repacks the cluster's workload pods from scratch onto nodes
+// allowed by the candidate config, honouring the pods' scheduling
+// constraints. The resulting nodes are then matched to existing nodes of the
+// same shape, so the move list only contains pods that actually have to be
+// rescheduled. Moves are batched into steps that respect the
+// PodDisruptionBudgets covering them.
+func Simulate(input Input, catalog *pricing.Catalog) (*Result, error) {
+	candidates := input.Candidates
+	if len(candidates) == 0 {
+		candidates = []CandidateConfig{input.Candidate}
+	}
+
+	strategy := candidates[0].Strategy
+	if strategy == "" {
+		strategy = StrategyFirstFitDecreasing
+	}
+	if strategy != StrategyFirstFitDecreasing && strategy != StrategyBestFit {
+		return nil, fmt.Errorf("unknown strategy %q", strategy)
+	}
+
+	daemons, workloads := splitDaemonSetPods(input.Pods)
+	s := &scheduler{strategy: strategy, daemons: daemons}
+	var offerings []offering
+	for _, candidate := range candidates {
+		if candidate.Region == "" {
+			candidate.Region = catalog.DefaultRegion
+		}
+		pool := candidatePool{
+			config:    candidate,
+			offerings: candidateOfferings(candidate, catalog),
+			zones:     candidateZones(candidate, input.Nodes),
+		}
+		if len(pool.offerings) == 0 {
+			if len(candidates) == 1 {
+				return nil, fmt.Errorf("candidate config matches no priced instance types in %s", candidate.Region)
+			}
+			continue
+		}
+		s.pools = append(s.pools, pool)
+		offerings = append(offerings, pool.offerings...)
+	}
+	if len(s.pools) == 0 {
+		return nil, fmt.Errorf("no candidate config matches priced instance types")
+	}
+	region := s.pools[0].config.Region
+
+	result := &Result{Strategy: strategy}
+
+	// Price the cluster as it runs today.
+	for _, node := range input.Nodes {
+		instanceType, ok := catalog.Lookup(regionOf(node, region), node.InstanceType)
+		if !ok {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("no price for %s on node %s", node.InstanceType, node.Name))
+		}
+		result.Current.Nodes++
+		result.Current.HourlyCost += instanceType.HourlyPrice(node.IsSpot)
+	}
+
+	sortDecreasing(workloads, largestOffering(offerings))
+
+	// Pods whose affinity targets a workload that hasn't been placed yet get
+	// a second chance once everything else is packed.
+	placed := map[*k8s.PodDetails]*bin{}
+	reasons := map[*k8s.PodDetails]string{}
+	pending := workloads
+	for pass := 0; pass < 2 && len(pending) > 0; pass++ {
+		var retry []*k8s.PodDetails
+		for i, pod := range pending {
+			target, reason := s.place(pod, pending[i:], placed)
+			if target == nil {
+				reasons[pod] = reason
+				retry = append(retry, pod)
+				continue
+			}
+			target.add(pod)
+			placed[pod] = target
+		}
+		pending = retry
+	}
+	for _, pod := range pending {
+		result.Unschedulable = append(result.Unschedulable, PodRef{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			Reason:    reasons[pod],
+		})
+	}
+
+	adoptExistingNodes(s.bins, input.Nodes)
+
+	for _, b := range s.bins {
+		node := SimulatedNode{
+			Name:         b.name,
+			InstanceType: b.offering.instanceType.Name,
+			CapacityType: b.offering.capacityType,
+			Zone:         b.labels[labelZone],
+			NodePool:     b.labels[k8s.NodePoolLabel],
+			Existing:     b.existing,
+			HourlyCost:   b.offering.price,
+			CPUCapacity:  b.offering.cpu,
+			CPURequested: b.cpuUsed,
+			MemCapacity:  b.offering.memory,
+			MemRequested: b.memUsed,
+		}
+		for _, pod := range b.pods {
+			node.Pods = append(node.Pods, pod.Namespace+"/"+pod.Name)
+		}
+		result.Nodes = append(result.Nodes, node)
+		result.Projected.Nodes++
+		result.Projected.HourlyCost += b.offering.price
+	}
+
+	for _, pod := range workloads {
+		if b, ok := placed[pod]; ok && b.name != pod.NodeName {
+			result.Moves = append(result.Moves, PodMove{
+				Namespace: pod.Namespace,
+				Name:      pod.Name,
+				From:      pod.NodeName,
+				To:        b.name,
+			})
+		}
+	}
+	sort.Slice(result.Moves, func(i, j int) bool {
+		if result.Moves[i].Namespace != result.Moves[j].Namespace {
+			return result.Moves[i].Namespace < result.Moves[j].Namespace
+		}
+		return result.Moves[i].Name < result.Moves[j].Name
+	})
+	result.Steps, result.Blocked = planSteps(result.Moves, input.Pods, input.PDBs)
+
+	result.Current.MonthlyCost = result.Current.HourlyCost * pricing.HoursPerMonth
+	result.Projected.MonthlyCost = result.Projected.HourlyCost * pricing.HoursPerMonth
+	result.Savings.Monthly = result.Current.MonthlyCost - result.Projected.MonthlyCost
+	if result.Current.MonthlyCost > 0 {
+		result.Savings.Percentage = result.Savings.Monthly / result.Current.MonthlyCost * 100
+	}
+
+	return result, nil
+}
+
+// place finds a node for the pod: an open node chosen by the strategy, or a
+// newly launched one.
+func (s *scheduler) place(pod *k8s.PodDetails, pending []*k8s.PodDetails, placed map[*k8s.PodDetails]*bin) (*bin, string) {
+	b, reason := s.findBin(pod)
+	if b != nil {
+		return b, ""
+	}
+	if b = s.launch(pod, pending, placed); b != nil {
+		return b, ""
+	}
+	return nil, reason
+}
+
+// findBin returns the open node the strategy picks for the pod, or the
+// reason the last node was rejected.
+func (s *scheduler) findBin(pod *k8s.PodDetails) (*bin, string) {
+	reason := "no candidate instance type satisfies the pod's constraints"
+
+	var best *bin
+	var bestSlack float64
+	for _, b := range s.bins {
+		if r := s.fit(pod, b); r != "" {
+			reason = r
+			continue
+		}
+		if s.strategy != StrategyBestFit {
+			return b, ""
+		}
+		slack := max(
+			float64(b.offering.cpu-b.cpuUsed-pod.CPURequest)/float64(b.offering.cpu),
+			float64(b.offering.memory-b.memUsed-pod.MemoryRequest)/float64(b.offering.memory),
+		)
+		if best == nil || slack < bestSlack {
+			best, bestSlack = b, slack
+		}
+	}
+	if best != nil {
+		return best, ""
+	}
+	return nil, reason
+}
+
+// adoptExistingNodes renames simulated nodes after existing nodes with the
+// same instance type, capacity type and zone, pairing them greedily by the
+// number of pods that already run there.
+func adoptExistingNodes(bins []*bin, nodes []k8s.NodeDetails) {
+	type pair struct {
+		bin     *bin
+		node    k8s.NodeDetails
+		overlap int
+	}
+
+	var pairs []pair
+	for _, b := range bins {
+		for _, node := range nodes {
+			capacityType := CapacityTypeOnDemand
+			if node.IsSpot {
+				capacityType = CapacityTypeSpot
+			}
+			if node.InstanceType != b.offering.instanceType.Name || capacityType != b.offering.capacityType || node.Zone != b.labels[labelZone] {
+				continue
+			}
+			overlap := 0
+			for _, pod := range b.pods {
+				if pod.NodeName == node.Name {
+					overlap++
+				}
+			}
+			pairs = append(pairs, pair{bin: b, node: node, overlap: overlap})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].overlap > pairs[j].overlap })
+
+	adopted := map[string]bool{}
+	for _, p := range pairs {
+		if p.bin.existing || adopted[p.node.Name] {
+			continue
+		}
+		adopted[p.node.Name] = true
+		p.bin.existing = true
+		p.bin.name = p.node.Name
+		p.bin.labels[labelHostname] = p.node.Name
+	}
+}
+
+// launch opens a new node for the pod. Pools are tried in order and the
+// first one that can host the pod wins. Within it, each offering is tried
+// in every zone; among the feasible ones it packs the remaining pods into
+// an empty node and takes the lowest price per normalized resource unit
+// (1 vCPU ~ 4 GiB) actually used.
+func (s *scheduler) launch(pod *k8s.PodDetails, pending []*k8s.PodDetails, placed map[*k8s.PodDetails]*bin) *bin {
+	for _, pool := range s.pools {
+		if b := s.launchFrom(pool, pod, pending, placed); b != nil {
+			s.newNodes++
+			s.bins = append(s.bins, b)
+			return b
+		}
+	}
+	return nil
+}
+
+func (s *scheduler) launchFrom(pool candidatePool, pod *k8s.PodDetails, pending []*k8s.PodDetails, placed map[*k8s.PodDetails]*bin) *bin {
+	name := fmt.Sprintf("simulated-%d", s.newNodes+1)
+
+	var best *bin
+	bestScore := -1.0
+	for _, o := range pool.offerings {
+		for _, zone := range pool.zones {
+			trial := s.newCandidateBin(name, pool.config, o, zone)
+			if s.fit(pod, trial) != "" {
+				continue
+			}
+			for _, p := range pending {
+				if _, done := placed[p]; !done && nodeFit(p, trial) == "" {
+					trial.add(p)
+				}
+			}
+			units := float64(trial.cpuUsed-s.daemons.cpu)/1000 + float64(trial.memUsed-s.daemons.memory)/(4*1024*1024*1024)
+			if units <= 0 {
+				units = 1e-6
+			}
+			score := o.price / units
+			if bestScore < 0 || score < bestScore || (score == bestScore && o.price < best.offering.price) {
+				best, bestScore = s.newCandidateBin(name, pool.config, o, zone), score
+			}
+		}
+	}
+	return best
+}
+
+func (s *scheduler) newCandidateBin(name string, candidate CandidateConfig, o offering, zone string) *bin {
+	b := newBin(name, o, false, s.daemons)
+	b.labels = map[string]string{
+		labelHostname:     name,
+		labelInstanceType: o.instanceType.Name,
+		labelZone:         zone,
+		labelRegion:       candidate.Region,
+		labelArch:         o.instanceType.Architecture,
+		labelCapacityType: o.capacityType,
+	}
+	for k, v := range candidate.Labels {
+		b.labels[k] = v
+	}
+	b.taints = candidate.Taints
+	if candidate.MaxPods > 0 {
+		b.maxPods = candidate.MaxPods
+	}
+	return b
+}
+
+func candidateOfferings(candidate CandidateConfig, catalog *pricing.Catalog) []offering {
+	capacityTypes := candidate.CapacityTypes
+	if len(capacityTypes) == 0 {
+		capacityTypes = []string{CapacityTypeOnDemand}
+	}
+
+	var offerings []offering
+	for _, t := range catalog.InstanceTypes(candidate.Region) {
+		if len(candidate.InstanceTypes) > 0 && !slices.Contains(candidate.InstanceTypes, t.Name) {
+			continue
+		}
+		if len(candidate.InstanceFamilies) > 0 && !slices.Contains(candidate.InstanceFamilies, t.Family) {
+			continue
+		}
+		if len(candidate.InstanceCategories) > 0 && !slices.Contains(candidate.InstanceCategories, categoryOf(t.Family)) {
+			continue
+		}
+		if slices.Contains(candidate.ExcludeInstanceTypes, t.Name) || slices.Contains(candidate.ExcludeInstanceFamilies, t.Family) {
+			continue
+		}
+		if len(candidate.Architectures) > 0 && !slices.Contains(candidate.Architectures, t.Architecture) {
+			continue
+		}
+		cpu, memory := Allocatable(t)
+		for _, capacityType := range capacityTypes {
+			price := t.HourlyPrice(capacityType == CapacityTypeSpot)
+			if price <= 0 {
+				continue
+			}
+			offerings = append(offerings, offering{
+				instanceType: t,
+				capacityType: capacityType,
+				price:        price,
+				cpu:          cpu,
+				memory:       memory,
+			})
+		}
+	}
+	return offerings
+}
+
+// Allocatable approximates what the kubelet leaves for pods on a fresh node:
+// a fixed CPU reservation plus the VM, kube-reserved and eviction memory
+// overheads Karpenter assumes when it launches capacity.
+func Allocatable(t pricing.InstanceType) (int64, int64) {
+	cpu := t.VCPU*1000 - 100
+	memory := t.MemoryGiB * 1024 * 1024 * 1024
+	memory = memory - memory*75/1000 - 355*1024*1024
+	return max(cpu, 0), max(memory, 0)
+}
+
+// splitDaemonSetPods separates per-node DaemonSet overhead from the pods the
+// simulator is free to move. Completed pods are dropped.
+func splitDaemonSetPods(pods []k8s.PodDetails) (overhead, []*k8s.PodDetails) {
+	daemonSets := map[string]*k8s.PodDetails{}
+	var workloads []*k8s.PodDetails
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Status == "Succeeded" || pod.Status == "Failed" {
+			continue
+		}
+		if pod.OwnerKind == "DaemonSet" {
+			key := pod.Namespace + "/" + pod.OwnerName
+			if current, ok := daemonSets[key]; !ok || pod.CPURequest+pod.MemoryRequest > current.CPURequest+current.MemoryRequest {
+				daemonSets[key] = pod
+			}
+			continue
+		}
+		workloads = append(workloads, pod)
+	}
+
+	var daemons overhead
+	for _, pod := range daemonSets {
+		daemons.cpu += pod.CPURequest
+		daemons.memory += pod.MemoryRequest
+		daemons.pods++
+		daemons.hostPorts = append(daemons.hostPorts, pod.HostPorts...)
+	}
+	return daemons, workloads
+}
+
+// candidateZones returns the zones new nodes may launch into: the
+// candidate's own list, else the zones the cluster already uses.
+func candidateZones(candidate CandidateConfig, nodes []k8s.NodeDetails) []string {
+	if len(candidate.Zones) > 0 {
+		return candidate.Zones
+	}
+	seen := map[string]bool{}
+	var zones []string
+	for _, node := range nodes {
+		if node.Zone != "" && node.Zone != "unknown" && !seen[node.Zone] {
+			seen[node.Zone] = true
+			zones = append(zones, node.Zone)
+		}
+	}
+	if len(zones) == 0 {
+		return []string{candidate.Region + "a"}
+	}
+	sort.Strings(zones)
+	return zones
+}
+
+func sortDecreasing(pods []*k8s.PodDetails, largest offering) {
+	share := func(pod *k8s.PodDetails) float64 {
+		return max(float64(pod.CPURequest)/float64(largest.cpu), float64(pod.MemoryRequest)/float64(largest.memory))
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		si, sj := share(pods[i]), share(pods[j])
+		if si != sj {
+			return si > sj
+		}
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+}
+
+func largestOffering(offerings []offering) offering {
+	largest := offerings[0]
+	for _, o := range offerings[1:] {
+		if o.cpu > largest.cpu || (o.cpu == largest.cpu && o.memory > largest.memory) {
+			largest = o
+		}
+	}
+	return largest
+}
+
+func regionOf(node k8s.NodeDetails, fallback string) string {
+	if node.Region == "" || node.Region == "unknown" {
+		return fallback
+	}
+	return node.Region
+}
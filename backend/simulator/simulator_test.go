@@ -0,0 +1,613 @@
+package simulator_test
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/simulator"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/snapshot"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const gib = 1 << 30
+
+func loadCatalog(t *testing.T) *pricing.Catalog {
+	t.Helper()
+	catalog, err := pricing.Load("../pricing/testdata/catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return catalog
+}
+
+// loadFixture reads the snapshot the replay tests use: m5.2xlarge
+// ip-10-0-1-10 and spot m5.xlarge ip-10-0-1-30 in us-east-1a, m5.xlarge
+// ip-10-0-2-20 and the unmanaged r5.large ip-10-0-2-40 in us-east-1b. Three
+// web replicas (500m, 512Mi) are behind a PDB allowing one disruption; two
+// api replicas (1 CPU, 2Gi), a batch worker (250m, 256Mi) and a
+// do-not-disrupt db-0 (1 CPU, 8Gi) run beside a node-exporter DaemonSet
+// (100m, 128Mi).
+func loadFixture(t *testing.T) *snapshot.Snapshot {
+	t.Helper()
+	snap, err := snapshot.Load("../snapshot/testdata/cluster.json.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return snap
+}
+
+func pod(name string, cpu, memory int64) k8s.PodDetails {
+	return k8s.PodDetails{
+		Name:          name,
+		Namespace:     "shop",
+		NodeName:      "old",
+		Status:        "Running",
+		CPURequest:    cpu,
+		MemoryRequest: memory,
+		OwnerKind:     "Deployment",
+		OwnerName:     strings.TrimRight(name, "0123456789"),
+		Labels:        map[string]string{"app": strings.TrimRight(name, "0123456789")},
+	}
+}
+
+// placements describes each node of the result as "instance-type zone:
+// pods", pods sorted, in the order the nodes were opened.
+func placements(result *simulator.Result) []string {
+	var nodes []string
+	for _, node := range result.Nodes {
+		pods := slices.Clone(node.Pods)
+		slices.Sort(pods)
+		nodes = append(nodes, fmt.Sprintf("%s %s: %s", node.InstanceType, node.Zone, strings.Join(pods, " ")))
+	}
+	return nodes
+}
+
+func checkPlacements(t *testing.T, result *simulator.Result, want ...string) {
+	t.Helper()
+	if got := placements(result); !slices.Equal(got, want) {
+		t.Errorf("placements:\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+	}
+}
+
+func TestSimulateStrategies(t *testing.T) {
+	catalog := loadCatalog(t)
+	// m5.large leaves 1900m and about 7.05Gi for pods. Decreasing by their
+	// dominant share, the pods come in the order big, wide, small; small
+	// fits on either node, but with less room to spare on the second.
+	pods := []k8s.PodDetails{
+		pod("small", 50, 1*gib),
+		pod("wide", 1000, 5*gib),
+		pod("big", 1800, 1*gib),
+	}
+	old := k8s.NodeDetails{Name: "old", InstanceType: "t3.2xlarge", Region: "us-east-1", Zone: "us-east-1a"}
+
+	for _, tc := range []struct {
+		strategy string
+		want     []string
+	}{
+		{simulator.StrategyFirstFitDecreasing, []string{
+			"m5.large us-east-1a: shop/big shop/small",
+			"m5.large us-east-1a: shop/wide",
+		}},
+		{simulator.StrategyBestFit, []string{
+			"m5.large us-east-1a: shop/big",
+			"m5.large us-east-1a: shop/small shop/wide",
+		}},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			result, err := simulator.Simulate(simulator.Input{
+				Nodes: []k8s.NodeDetails{old},
+				Pods:  slices.Clone(pods),
+				Candidate: simulator.CandidateConfig{
+					InstanceTypes: []string{"m5.large"},
+					Strategy:      tc.strategy,
+				},
+			}, catalog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPlacements(t, result, tc.want...)
+
+			if len(result.Moves) != 3 || len(result.Steps) != 1 || len(result.Unschedulable) != 0 {
+				t.Errorf("moves, steps, unschedulable = %v, %v, %v, want every pod moved off old in one step", result.Moves, result.Steps, result.Unschedulable)
+			}
+			if result.Current.HourlyCost != 0.3328 || result.Projected.HourlyCost != 2*0.096 {
+				t.Errorf("hourly cost = %v now, %v projected, want 0.3328 and 0.192", result.Current.HourlyCost, result.Projected.HourlyCost)
+			}
+		})
+	}
+}
+
+// A new node goes to the zone where it can take the most of the pods still
+// to place, not the first zone the pod fits in.
+func TestSimulateLaunchZone(t *testing.T) {
+	catalog := loadCatalog(t)
+	inB := func(p k8s.PodDetails) k8s.PodDetails {
+		p.NodeSelector = map[string]string{"topology.kubernetes.io/zone": "us-east-1b"}
+		return p
+	}
+	result, err := simulator.Simulate(simulator.Input{
+		Pods: []k8s.PodDetails{
+			pod("anywhere", 800, gib),
+			inB(pod("pinned1", 500, gib)),
+			inB(pod("pinned2", 500, gib)),
+		},
+		Candidate: simulator.CandidateConfig{
+			InstanceTypes: []string{"m5.large"},
+			Zones:         []string{"us-east-1a", "us-east-1b"},
+		},
+	}, catalog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlacements(t, result, "m5.large us-east-1b: shop/anywhere shop/pinned1 shop/pinned2")
+}
+
+func TestSimulateUnschedulable(t *testing.T) {
+	result, err := simulator.Simulate(simulator.Input{
+		Pods: []k8s.PodDetails{pod("huge", 4000, gib), pod("web", 500, gib)},
+		Candidate: simulator.CandidateConfig{
+			InstanceTypes: []string{"m5.large"},
+		},
+	}, loadCatalog(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPlacements(t, result, "m5.large us-east-1a: shop/web")
+	want := []simulator.PodRef{{Namespace: "shop", Name: "huge", Reason: "insufficient cpu or memory"}}
+	if !slices.Equal(result.Unschedulable, want) {
+		t.Errorf("unschedulable = %+v, want %+v", result.Unschedulable, want)
+	}
+}
+
+func TestSimulateRejects(t *testing.T) {
+	catalog := loadCatalog(t)
+	for name, candidate := range map[string]simulator.CandidateConfig{
+		"unknown strategy":     {Strategy: "worst-fit"},
+		"no priced type":       {InstanceTypes: []string{"m9.large"}},
+		"architecture missing": {InstanceFamilies: []string{"m5"}, Architectures: []string{"arm64"}},
+	} {
+		if _, err := simulator.Simulate(simulator.Input{Candidate: candidate}, catalog); err == nil {
+			t.Errorf("%s: Simulate succeeded", name)
+		}
+	}
+}
+
+func TestSimulateConstraints(t *testing.T) {
+	catalog := loadCatalog(t)
+	m5 := simulator.CandidateConfig{InstanceTypes: []string{"m5.large"}}
+	twoZones := simulator.CandidateConfig{InstanceTypes: []string{"m5.large"}, Zones: []string{"us-east-1a", "us-east-1b"}}
+	selectApp := func(app string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}}
+	}
+	daemon := pod("exporter", 100, 128<<20)
+	daemon.OwnerKind = "DaemonSet"
+	daemon.HostPorts = []k8s.HostPort{{Port: 9100, Protocol: "TCP"}}
+
+	for _, tc := range []struct {
+		name          string
+		candidates    []simulator.CandidateConfig
+		pods          []k8s.PodDetails
+		want          []string
+		unschedulable []simulator.PodRef
+	}{
+		{
+			// Karpenter falls through to the next NodePool when a pod
+			// doesn't tolerate the first one's taints.
+			name: "taints",
+			candidates: []simulator.CandidateConfig{
+				{InstanceTypes: []string{"m5.large"}, Taints: []corev1.Taint{{Key: "dedicated", Value: "batch", Effect: corev1.TaintEffectNoSchedule}}},
+				{InstanceTypes: []string{"c5.large"}},
+			},
+			pods: []k8s.PodDetails{
+				with(pod("batch", 1000, gib), func(p *k8s.PodDetails) {
+					p.Tolerations = []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "batch", Effect: corev1.TaintEffectNoSchedule}}
+				}),
+				pod("web", 500, gib),
+			},
+			want: []string{
+				"m5.large us-east-1a: shop/batch",
+				"c5.large us-east-1a: shop/web",
+			},
+		},
+		{
+			name:       "node affinity",
+			candidates: []simulator.CandidateConfig{twoZones},
+			pods: []k8s.PodDetails{
+				with(pod("web", 500, gib), func(p *k8s.PodDetails) {
+					p.Affinity = &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "topology.kubernetes.io/zone", Operator: corev1.NodeSelectorOpNotIn, Values: []string{"us-east-1a"}}},
+						}}},
+					}}
+				}),
+			},
+			want: []string{"m5.large us-east-1b: shop/web"},
+		},
+		{
+			name:       "pod anti-affinity",
+			candidates: []simulator.CandidateConfig{m5},
+			pods: []k8s.PodDetails{
+				spreadByHost(pod("web1", 100, gib)),
+				spreadByHost(pod("web2", 100, gib)),
+				spreadByHost(pod("web3", 100, gib)),
+			},
+			want: []string{
+				"m5.large us-east-1a: shop/web1",
+				"m5.large us-east-1a: shop/web2",
+				"m5.large us-east-1a: shop/web3",
+			},
+		},
+		{
+			// The client is packed first, finds no cache yet, and is
+			// placed next to it on the second pass.
+			name:       "pod affinity",
+			candidates: []simulator.CandidateConfig{twoZones},
+			pods: []k8s.PodDetails{
+				with(pod("client", 1000, gib), func(p *k8s.PodDetails) {
+					p.Affinity = &corev1.Affinity{PodAffinity: &corev1.PodAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{LabelSelector: selectApp("cache"), TopologyKey: "topology.kubernetes.io/zone"}},
+					}}
+				}),
+				with(pod("cache", 200, gib), func(p *k8s.PodDetails) {
+					p.NodeSelector = map[string]string{"topology.kubernetes.io/zone": "us-east-1b"}
+				}),
+			},
+			want: []string{"m5.large us-east-1b: shop/cache shop/client"},
+		},
+		{
+			name:       "topology spread",
+			candidates: []simulator.CandidateConfig{twoZones},
+			pods: []k8s.PodDetails{
+				spreadByZone(pod("spread1", 500, gib)),
+				spreadByZone(pod("spread2", 500, gib)),
+				spreadByZone(pod("spread3", 500, gib)),
+				spreadByZone(pod("spread4", 500, gib)),
+			},
+			want: []string{
+				"m5.large us-east-1a: shop/spread1 shop/spread3",
+				"m5.large us-east-1b: shop/spread2 shop/spread4",
+			},
+		},
+		{
+			// Every node already runs the DaemonSet holding port 9100.
+			name:       "host ports",
+			candidates: []simulator.CandidateConfig{m5},
+			pods: []k8s.PodDetails{
+				daemon,
+				withPort(pod("proxy1", 200, gib), 8080),
+				withPort(pod("proxy2", 200, gib), 8080),
+				withPort(pod("metrics", 100, gib), 9100),
+			},
+			want: []string{
+				"m5.large us-east-1a: shop/proxy1",
+				"m5.large us-east-1a: shop/proxy2",
+			},
+			unschedulable: []simulator.PodRef{{Namespace: "shop", Name: "metrics", Reason: "host port 9100/TCP already in use"}},
+		},
+		{
+			// Three pods per node, one of them the DaemonSet's.
+			name:       "max pods",
+			candidates: []simulator.CandidateConfig{{InstanceTypes: []string{"m5.large"}, MaxPods: 3}},
+			pods: []k8s.PodDetails{
+				daemon,
+				pod("small1", 10, 16<<20),
+				pod("small2", 10, 16<<20),
+				pod("small3", 10, 16<<20),
+				pod("small4", 10, 16<<20),
+				pod("small5", 10, 16<<20),
+			},
+			want: []string{
+				"m5.large us-east-1a: shop/small1 shop/small2",
+				"m5.large us-east-1a: shop/small3 shop/small4",
+				"m5.large us-east-1a: shop/small5",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := simulator.Simulate(simulator.Input{Pods: tc.pods, Candidates: tc.candidates}, catalog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkPlacements(t, result, tc.want...)
+			if !slices.Equal(result.Unschedulable, tc.unschedulable) {
+				t.Errorf("unschedulable = %+v, want %+v", result.Unschedulable, tc.unschedulable)
+			}
+		})
+	}
+}
+
+// Each step evicts no more pods than their PodDisruptionBudget allows.
+// Pending pods aren't disruptions, and a budget allowing none blocks the
+// move outright.
+func TestSimulateSteps(t *testing.T) {
+	pending := pod("web4", 500, gib)
+	pending.NodeName = ""
+	result, err := simulator.Simulate(simulator.Input{
+		Nodes: []k8s.NodeDetails{{Name: "old", InstanceType: "t3.2xlarge", Region: "us-east-1", Zone: "us-east-1a"}},
+		Pods: []k8s.PodDetails{
+			pod("api1", 500, gib),
+			pod("db1", 500, gib),
+			pod("web1", 500, gib),
+			pod("web2", 500, gib),
+			pod("web3", 500, gib),
+			pending,
+		},
+		PDBs: []k8s.PDBDetails{
+			{Name: "web", Namespace: "shop", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}, DisruptionsAllowed: 1},
+			{Name: "db", Namespace: "shop", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+		},
+		Candidate: simulator.CandidateConfig{InstanceTypes: []string{"m5.xlarge"}},
+	}, loadCatalog(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var steps []string
+	for _, step := range result.Steps {
+		var names []string
+		for _, move := range step.Moves {
+			names = append(names, move.Name)
+		}
+		steps = append(steps, fmt.Sprintf("%d: %s", step.Step, strings.Join(names, " ")))
+	}
+	if want := []string{"1: api1 web1 web4", "2: web2", "3: web3"}; !slices.Equal(steps, want) {
+		t.Errorf("steps = %q, want %q", steps, want)
+	}
+	if len(result.Blocked) != 1 || result.Blocked[0].Name != "db1" || result.Blocked[0].Reason != "PodDisruptionBudget shop/db allows no disruptions" {
+		t.Errorf("blocked = %+v, want db1 held by shop/db", result.Blocked)
+	}
+}
+
+func with(p k8s.PodDetails, edit func(*k8s.PodDetails)) k8s.PodDetails {
+	edit(&p)
+	return p
+}
+
+func withPort(p k8s.PodDetails, port int32) k8s.PodDetails {
+	p.HostPorts = []k8s.HostPort{{Port: port, Protocol: "TCP"}}
+	return p
+}
+
+func spreadByHost(p k8s.PodDetails) k8s.PodDetails {
+	p.Affinity = &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+			LabelSelector: &metav1.LabelSelector{MatchLabels: p.Labels},
+			TopologyKey:   "kubernetes.io/hostname",
+		}},
+	}}
+	return p
+}
+
+func spreadByZone(p k8s.PodDetails) k8s.PodDetails {
+	p.TopologySpreadConstraints = []corev1.TopologySpreadConstraint{{
+		MaxSkew:           1,
+		TopologyKey:       "topology.kubernetes.io/zone",
+		WhenUnsatisfiable: corev1.DoNotSchedule,
+		LabelSelector:     &metav1.LabelSelector{MatchLabels: p.Labels},
+	}}
+	return p
+}
+
+// actions describes each consolidation action as "round method action
+// nodes [-> replacement]: pods moved, hourly savings".
+func actions(result *simulator.ConsolidationResult) []string {
+	var descriptions []string
+	for _, a := range result.Actions {
+		description := fmt.Sprintf("%d %s %s %s", a.Round, a.Method, a.Action, strings.Join(a.Nodes, " "))
+		if a.Replacement != nil {
+			description += fmt.Sprintf(" -> %s %s", a.Replacement.InstanceType, a.Replacement.CapacityType)
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s: %s, %.4f/h", description, strings.Join(a.PodsMoved, " "), a.HourlySavings))
+	}
+	return descriptions
+}
+
+func TestConsolidateFixture(t *testing.T) {
+	catalog := loadCatalog(t)
+	exhausted := func(snap *snapshot.Snapshot) {
+		snap.PDBs[0].DisruptionsAllowed = 0
+	}
+	for _, tc := range []struct {
+		name    string
+		edit    func(*snapshot.Snapshot)
+		want    []string
+		skipped []simulator.SkippedNode
+	}{
+		{
+			// The spot node's worker and then the on-demand m5.xlarge's web
+			// replica fit on the remaining nodes; the pool's budget of one
+			// node allows one deletion per round.
+			name: "fixture",
+			want: []string{
+				"1 single-node delete ip-10-0-1-30: batch/worker-6f7a8-z1, 0.0576/h",
+				"2 single-node delete ip-10-0-2-20: shop/web-7d9f8-bx, 0.1920/h",
+			},
+			skipped: []simulator.SkippedNode{{Node: "ip-10-0-2-40", Reason: "not managed by a Karpenter NodePool"}},
+		},
+		{
+			name: "web budget exhausted",
+			edit: exhausted,
+			want: []string{"1 single-node delete ip-10-0-1-30: batch/worker-6f7a8-z1, 0.0576/h"},
+			skipped: []simulator.SkippedNode{
+				{Node: "ip-10-0-1-10", Reason: "PodDisruptionBudget shop/web blocks eviction of web-7d9f8-ax"},
+				{Node: "ip-10-0-2-20", Reason: "PodDisruptionBudget shop/web blocks eviction of web-7d9f8-bx"},
+				{Node: "ip-10-0-2-40", Reason: "not managed by a Karpenter NodePool"},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			snap := loadFixture(t)
+			if tc.edit != nil {
+				tc.edit(snap)
+			}
+			result, err := simulator.Consolidate(simulator.ConsolidationInput{
+				Nodes:     snap.Nodes.Nodes,
+				Pods:      snap.Pods.Pods,
+				PDBs:      snap.PDBs,
+				NodePools: snap.NodePools,
+			}, catalog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := actions(result); !slices.Equal(got, tc.want) {
+				t.Errorf("actions:\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(tc.want, "\n  "))
+			}
+			if !slices.Equal(result.Skipped, tc.skipped) {
+				t.Errorf("skipped = %+v, want %+v", result.Skipped, tc.skipped)
+			}
+		})
+	}
+}
+
+func TestConsolidate(t *testing.T) {
+	catalog := loadCatalog(t)
+	node := func(name, instanceType string) k8s.NodeDetails {
+		return k8s.NodeDetails{Name: name, InstanceType: instanceType, Region: "us-east-1", Zone: "us-east-1a", NodePool: "default"}
+	}
+	on := func(p k8s.PodDetails, node string) k8s.PodDetails {
+		p.NodeName = node
+		return p
+	}
+	pool := func(budget string) k8s.NodePoolDetails {
+		return k8s.NodePoolDetails{
+			Name:                "default",
+			Requirements:        []k8s.NodeSelectorRequirement{{Key: "karpenter.k8s.aws/instance-family", Operator: "In", Values: []string{"m5"}}},
+			ConsolidationPolicy: "WhenEmptyOrUnderutilized",
+			Budgets:             []k8s.DisruptionBudget{{Nodes: budget}},
+		}
+	}
+	// a fits beside b on n2; b on its own needs all of an m5.xlarge.
+	twoNodes := simulator.ConsolidationInput{
+		Nodes:     []k8s.NodeDetails{node("n1", "m5.xlarge"), node("n2", "m5.xlarge")},
+		Pods:      []k8s.PodDetails{on(pod("a", 500, gib), "n1"), on(pod("b", 3000, gib), "n2")},
+		NodePools: []k8s.NodePoolDetails{pool("1")},
+	}
+	edit := func(input simulator.ConsolidationInput, change func(*simulator.ConsolidationInput)) simulator.ConsolidationInput {
+		input.Nodes = slices.Clone(input.Nodes)
+		input.Pods = slices.Clone(input.Pods)
+		input.NodePools = slices.Clone(input.NodePools)
+		change(&input)
+		return input
+	}
+	spot := func(spotToSpot bool) simulator.ConsolidationInput {
+		n1 := node("n1", "m5.xlarge")
+		n1.IsSpot = true
+		np := pool("1")
+		np.Requirements = append(np.Requirements, k8s.NodeSelectorRequirement{Key: "karpenter.sh/capacity-type", Operator: "In", Values: []string{"spot", "on-demand"}})
+		return simulator.ConsolidationInput{
+			Nodes:     []k8s.NodeDetails{n1},
+			Pods:      []k8s.PodDetails{on(pod("a", 500, gib), "n1")},
+			NodePools: []k8s.NodePoolDetails{np},
+			Options:   simulator.ConsolidationOptions{SpotToSpot: spotToSpot},
+		}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		input   simulator.ConsolidationInput
+		want    []string
+		skipped []simulator.SkippedNode
+	}{
+		{
+			// One node per round leaves no room for multi-node, and b alone
+			// can't move to anything cheaper than its m5.xlarge.
+			name:  "single-node delete",
+			input: twoNodes,
+			want:  []string{"1 single-node delete n1: shop/a, 0.1920/h"},
+		},
+		{
+			name: "multi-node replace",
+			input: simulator.ConsolidationInput{
+				Nodes: []k8s.NodeDetails{node("n1", "m5.xlarge"), node("n2", "m5.xlarge"), node("n3", "m5.xlarge")},
+				Pods: []k8s.PodDetails{
+					on(pod("a", 500, gib), "n1"),
+					on(pod("b", 500, gib), "n2"),
+					on(pod("c", 500, gib), "n3"),
+				},
+				NodePools: []k8s.NodePoolDetails{pool("100%")},
+			},
+			want: []string{"1 multi-node replace n1 n2 n3 -> m5.large on-demand: shop/a shop/b shop/c, 0.4800/h"},
+		},
+		{
+			name: "emptiness first",
+			input: simulator.ConsolidationInput{
+				Nodes:     []k8s.NodeDetails{node("n1", "m5.xlarge"), node("n2", "m5.2xlarge")},
+				Pods:      []k8s.PodDetails{on(pod("a", 500, gib), "n2")},
+				NodePools: []k8s.NodePoolDetails{pool("100%")},
+			},
+			want: []string{
+				"1 emptiness delete n1: , 0.1920/h",
+				"2 single-node replace n2 -> m5.large on-demand: shop/a, 0.2880/h",
+			},
+		},
+		{
+			name: "when empty",
+			input: edit(twoNodes, func(input *simulator.ConsolidationInput) {
+				input.NodePools[0].ConsolidationPolicy = "WhenEmpty"
+			}),
+		},
+		{
+			// A node that can't be disrupted still takes pods from the others.
+			name: "do-not-disrupt pod",
+			input: edit(twoNodes, func(input *simulator.ConsolidationInput) {
+				input.Pods[0].DoNotDisrupt = true
+			}),
+			want:    []string{"1 single-node delete n2: shop/b, 0.1920/h"},
+			skipped: []simulator.SkippedNode{{Node: "n1", Reason: "pod shop/a has " + k8s.DoNotDisruptAnnotation}},
+		},
+		{
+			name: "do-not-disrupt node",
+			input: edit(twoNodes, func(input *simulator.ConsolidationInput) {
+				input.Nodes[0].DoNotDisrupt = true
+			}),
+			want:    []string{"1 single-node delete n2: shop/b, 0.1920/h"},
+			skipped: []simulator.SkippedNode{{Node: "n1", Reason: "node has " + k8s.DoNotDisruptAnnotation}},
+		},
+		{
+			name: "pdb allows no disruptions",
+			input: edit(twoNodes, func(input *simulator.ConsolidationInput) {
+				input.PDBs = []k8s.PDBDetails{{Name: "a", Namespace: "shop", Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "a"}}}}
+			}),
+			want:    []string{"1 single-node delete n2: shop/b, 0.1920/h"},
+			skipped: []simulator.SkippedNode{{Node: "n1", Reason: "PodDisruptionBudget shop/a blocks eviction of a"}},
+		},
+		{
+			name: "unmanaged",
+			input: edit(twoNodes, func(input *simulator.ConsolidationInput) {
+				input.Nodes[0].NodePool = ""
+			}),
+			want:    []string{"1 single-node delete n2: shop/b, 0.1920/h"},
+			skipped: []simulator.SkippedNode{{Node: "n1", Reason: "not managed by a Karpenter NodePool"}},
+		},
+		{
+			// Spot nodes are only replaced with on-demand, and an
+			// on-demand m5.large costs more than a spot m5.xlarge.
+			name:  "spot",
+			input: spot(false),
+		},
+		{
+			name:  "spot to spot",
+			input: spot(true),
+			want:  []string{"1 single-node replace n1 -> m5.large spot: shop/a, 0.0288/h"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := simulator.Consolidate(tc.input, catalog)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := actions(result); !slices.Equal(got, tc.want) {
+				t.Errorf("actions:\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(tc.want, "\n  "))
+			}
+			if !slices.Equal(result.Skipped, tc.skipped) {
+				t.Errorf("skipped = %+v, want %+v", result.Skipped, tc.skipped)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package snapshot
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+)
+
+// FormatVersion is bumped whenever a change to the captured types would make
+// older readers misinterpret a snapshot.
+const FormatVersion = 1
+
+// Snapshot is a point-in-time export of everything the analysis reads from
+// a cluster. Pods carry their resolved owners, so the export needs no
+// access to the workload controllers when it is replayed.
+type Snapshot struct {
+	Version     int                     `json:"version"`
+	CapturedAt  time.Time               `json:"capturedAt"`
+	Nodes       *k8s.NodeInfo           `json:"nodes"`
+	Pods        *k8s.PodInfo            `json:"pods"`
+	PDBs        []k8s.PDBDetails        `json:"pdbs"`
+	NodePools   []k8s.NodePoolDetails   `json:"nodePools"`
+	NodeClasses []k8s.NodeClassDetails  `json:"nodeClasses"`
+	Usage       map[string]k8s.PodUsage `json:"usage"`
+	Warnings    []string                `json:"warnings,omitempty"`
+}
+
+var _ k8s.ClusterSource = (*Snapshot)(nil)
+
+// Capture reads a snapshot from the source. Usage is optional: clusters
+// without metrics-server still produce a snapshot, with a warning.
+func Capture(ctx context.Context, source k8s.ClusterSource) (*Snapshot, error) {
+	snap := &Snapshot{
+		Version:    FormatVersion,
+		CapturedAt: time.Now().UTC(),
+	}
+
+	var err error
+	if snap.Nodes, err = source.GetNodes(ctx); err != nil {
+		return nil, err
+	}
+	if snap.Pods, err = source.GetPods(ctx); err != nil {
+		return nil, err
+	}
+	if snap.PDBs, err = source.GetPDBs(ctx); err != nil {
+		return nil, err
+	}
+	if snap.NodePools, err = source.GetNodePools(ctx); err != nil {
+		return nil, err
+	}
+	if snap.NodeClasses, err = source.GetNodeClasses(ctx); err != nil {
+		return nil, err
+	}
+	if snap.Usage, err = source.GetPodMetrics(ctx, ""); err != nil {
+		snap.Usage = map[string]k8s.PodUsage{}
+		snap.Warnings = append(snap.Warnings, fmt.Sprintf("usage not captured: %v", err))
+	}
+
+	return snap, nil
+}
+
+// Write encodes the snapshot as gzip-compressed JSON.
+func (s *Snapshot) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	if err := json.NewEncoder(gz).Encode(s); err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	return gz.Close()
+}
+
+// Read decodes a snapshot written by Write.
+func Read(r io.Reader) (*Snapshot, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot is not gzip-compressed: %w", err)
+	}
+	defer gz.Close()
+
+	var snap Snapshot
+	if err := json.NewDecoder(gz).Decode(&snap); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if snap.Version < 1 || snap.Version > FormatVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d (this build reads up to %d)", snap.Version, FormatVersion)
+	}
+	if snap.Nodes == nil || snap.Pods == nil {
+		return nil, fmt.Errorf("snapshot is missing nodes or pods")
+	}
+	if snap.Usage == nil {
+		snap.Usage = map[string]k8s.PodUsage{}
+	}
+	return &snap, nil
+}
+
+// Load reads a snapshot file from disk.
+func Load(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close()
+	return Read(f)
+}
+
+func (s *Snapshot) GetNodes(ctx context.Context) (*k8s.NodeInfo, error) {
+	return s.Nodes, nil
+}
+
+func (s *Snapshot) GetPods(ctx context.Context) (*k8s.PodInfo, error) {
+	return s.Pods, nil
+}
+
+func (s *Snapshot) GetPDBs(ctx context.Context) ([]k8s.PDBDetails, error) {
+	return s.PDBs, nil
+}
+
+func (s *Snapshot) GetNodePools(ctx context.Context) ([]k8s.NodePoolDetails, error) {
+	return s.NodePools, nil
+}
+
+func (s *Snapshot) GetNodeClasses(ctx context.Context) ([]k8s.NodeClassDetails, error) {
+	return s.NodeClasses, nil
+}
+
+// GetPodMetrics returns the captured usage, keyed by "namespace/pod" like
+// the live client. An empty namespace returns all of it.
+func (s *Snapshot) GetPodMetrics(ctx context.Context, namespace string) (map[string]k8s.PodUsage, error) {
+	if namespace == "" {
+		return s.Usage, nil
+	}
+	usage := map[string]k8s.PodUsage{}
+	prefix := namespace + "/"
+	for key, value := range s.Usage {
+		if strings.HasPrefix(key, prefix) {
+			usage[key] = value
+		}
+	}
+	return usage, nil
+}
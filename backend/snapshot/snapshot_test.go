@@ -0,0 +1,146 @@
+package snapshot_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/simulator"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/snapshot"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testdata/cluster.json.gz is a small cluster: three NodePool nodes, one of
+// them spot, and an unmanaged node running a do-not-disrupt database, with
+// a DaemonSet, Deployments behind a PDB, a finished Job and usage.
+const fixture = "testdata/cluster.json.gz"
+
+func loadFixture(t *testing.T) *snapshot.Snapshot {
+	t.Helper()
+	snap, err := snapshot.Load(fixture)
+	if err != nil {
+		t.Fatalf("Load(%s): %v", fixture, err)
+	}
+	return snap
+}
+
+func loadCatalog(t *testing.T) *pricing.Catalog {
+	t.Helper()
+	catalog, err := pricing.Load("../pricing/testdata/catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return catalog
+}
+
+func TestLoadRoundTrip(t *testing.T) {
+	snap := loadFixture(t)
+	if got := len(snap.Nodes.Nodes); got != 4 {
+		t.Errorf("nodes = %d, want 4", got)
+	}
+	if got := len(snap.Pods.Pods); got != 12 {
+		t.Errorf("pods = %d, want 12", got)
+	}
+	if len(snap.PDBs) != 1 || len(snap.NodePools) != 1 || len(snap.NodeClasses) != 1 {
+		t.Errorf("pdbs, nodePools, nodeClasses = %d, %d, %d, want 1 each", len(snap.PDBs), len(snap.NodePools), len(snap.NodeClasses))
+	}
+
+	var buf bytes.Buffer
+	if err := snap.Write(&buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	again, err := snapshot.Read(&buf)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !reflect.DeepEqual(snap, again) {
+		t.Error("snapshot changed on a Write and Read round trip")
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	for name, content := range map[string][]byte{
+		"not gzip":       []byte(`{"version":1}`),
+		"future version": gzipped(t, `{"version":99,"nodes":{},"pods":{}}`),
+		"no pods":        gzipped(t, `{"version":1,"nodes":{}}`),
+	} {
+		if _, err := snapshot.Read(bytes.NewReader(content)); err == nil {
+			t.Errorf("%s: Read succeeded", name)
+		}
+	}
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	snap := &snapshot.Snapshot{}
+	if err := json.Unmarshal([]byte(content), snap); err != nil {
+		t.Fatal(err)
+	}
+	if err := snap.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReplayRebalancing(t *testing.T) {
+	snap := loadFixture(t)
+	result, err := simulator.Simulate(simulator.Input{
+		Nodes: snap.Nodes.Nodes,
+		Pods:  snap.Pods.Pods,
+		PDBs:  snap.PDBs,
+		Candidate: simulator.CandidateConfig{
+			InstanceFamilies: []string{"m5", "c5"},
+			CapacityTypes:    []string{"on-demand"},
+		},
+	}, loadCatalog(t))
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	golden(t, "rebalancing.golden.json", result)
+}
+
+func TestReplayConsolidation(t *testing.T) {
+	snap := loadFixture(t)
+	result, err := simulator.Consolidate(simulator.ConsolidationInput{
+		Nodes:     snap.Nodes.Nodes,
+		Pods:      snap.Pods.Pods,
+		PDBs:      snap.PDBs,
+		NodePools: snap.NodePools,
+	}, loadCatalog(t))
+	if err != nil {
+		t.Fatalf("Consolidate: %v", err)
+	}
+	golden(t, "consolidation.golden.json", result)
+}
+
+// golden compares v as indented JSON with testdata/name, or rewrites the
+// file with -update.
+func golden(t *testing.T, name string, v any) {
+	t.Helper()
+	got, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = append(got, '\n')
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("result differs from %s (run go test -update after checking the change):\n%s", path, got)
+	}
+}
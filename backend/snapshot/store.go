@@ -0,0 +1,85 @@
+package snapshot
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// DefaultStoreSize is how many uploaded snapshots are kept in memory before
+// the oldest is evicted.
+const DefaultStoreSize = 10
+
+// Store holds uploaded snapshots in memory, keyed by a random ID.
+type Store struct {
+	mu        sync.RWMutex
+	limit     int
+	snapshots map[string]*Snapshot
+	order     []string
+}
+
+type Summary struct {
+	ID         string    `json:"id"`
+	CapturedAt time.Time `json:"capturedAt"`
+	Nodes      int       `json:"nodes"`
+	Pods       int       `json:"pods"`
+	Warnings   []string  `json:"warnings,omitempty"`
+}
+
+func NewStore(limit int) *Store {
+	if limit <= 0 {
+		limit = DefaultStoreSize
+	}
+	return &Store{
+		limit:     limit,
+		snapshots: map[string]*Snapshot{},
+	}
+}
+
+// Put stores the snapshot and returns its ID.
+func (s *Store) Put(snap *Snapshot) (string, error) {
+	raw := make([]byte, 8)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[id] = snap
+	s.order = append(s.order, id)
+	for len(s.order) > s.limit {
+		delete(s.snapshots, s.order[0])
+		s.order = s.order[1:]
+	}
+	return id, nil
+}
+
+func (s *Store) Get(id string) (*Snapshot, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	snap, ok := s.snapshots[id]
+	return snap, ok
+}
+
+// List summarizes the stored snapshots, oldest first.
+func (s *Store) List() []Summary {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	summaries := make([]Summary, 0, len(s.order))
+	for _, id := range s.order {
+		summaries = append(summaries, Summarize(id, s.snapshots[id]))
+	}
+	return summaries
+}
+
+func Summarize(id string, snap *Snapshot) Summary {
+	return Summary{
+		ID:         id,
+		CapturedAt: snap.CapturedAt,
+		Nodes:      snap.Nodes.TotalNodes,
+		Pods:       snap.Pods.TotalPods,
+		Warnings:   snap.Warnings,
+	}
+}
@@ -0,0 +1,341 @@
+package spot
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+)
+
+type Class string
+
+const (
+	ClassEligible   Class = "eligible"
+	ClassCaution    Class = "caution"
+	ClassIneligible Class = "ineligible"
+
+	eligibleScore = 70
+	cautionScore  = 40
+
+	// interruptionNotice is the warning EC2 gives before reclaiming a spot
+	// instance.
+	interruptionNotice = 120
+
+	// highPriority is where user-defined priority classes usually start
+	// signalling business-critical workloads.
+	highPriority = 100000
+)
+
+// capacityTypeLabels are the node labels workloads use to select a capacity
+// type, with the values meaning spot and on-demand.
+var capacityTypeLabels = []struct {
+	key      string
+	spot     string
+	onDemand string
+}{
+	{"karpenter.sh/capacity-type", "spot", "on-demand"},
+	{"eks.amazonaws.com/capacityType", "SPOT", "ON_DEMAND"},
+}
+
+type Input struct {
+	Nodes  []k8s.NodeDetails
+	Pods   []k8s.PodDetails
+	PDBs   []k8s.PDBDetails
+	Region string
+}
+
+// Reason is one factor of the score. Impact is the number of points it
+// added or removed; zero-impact reasons are context for service owners.
+type Reason struct {
+	Factor string `json:"factor"`
+	Impact int    `json:"impact"`
+	Detail string `json:"detail"`
+}
+
+type WorkloadScore struct {
+	Namespace string   `json:"namespace"`
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Replicas  int      `json:"replicas"`
+	Score     int      `json:"score"`
+	Class     Class    `json:"class"`
+	Reasons   []Reason `json:"reasons"`
+	// OnSpot counts the replicas already running on spot nodes.
+	OnSpot int `json:"onSpot"`
+	// MonthlySavings is the workload's share of the on-demand nodes it runs
+	// on, priced at the spot discount.
+	MonthlySavings float64 `json:"monthlySavings"`
+}
+
+type Report struct {
+	Workloads []WorkloadScore `json:"workloads"`
+	Summary   Summary         `json:"summary"`
+	Warnings  []string        `json:"warnings"`
+}
+
+type Summary struct {
+	Eligible                int     `json:"eligible"`
+	Caution                 int     `json:"caution"`
+	Ineligible              int     `json:"ineligible"`
+	EligibleMonthlySavings  float64 `json:"eligibleMonthlySavings"`
+	PotentialMonthlySavings float64 `json:"potentialMonthlySavings"`
+}
+
+// Score rates every workload's tolerance for spot interruptions from 0 to
+// 100. DaemonSets are left out: they run wherever their nodes do.
+// Workloads are ordered by score, best candidates first.
+func Score(input Input, catalog *pricing.Catalog) *Report {
+	if input.Region == "" {
+		input.Region = catalog.DefaultRegion
+	}
+	report := &Report{Workloads: []WorkloadScore{}}
+
+	nodes := map[string]k8s.NodeDetails{}
+	discount := map[string]float64{}
+	for _, node := range input.Nodes {
+		nodes[node.Name] = node
+		if node.IsSpot {
+			continue
+		}
+		region := node.Region
+		if region == "" || region == "unknown" {
+			region = input.Region
+		}
+		t, ok := catalog.Lookup(region, node.InstanceType)
+		if !ok {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("no price for %s on node %s", node.InstanceType, node.Name))
+			continue
+		}
+		if t.Spot > 0 && t.Spot < t.OnDemand {
+			discount[node.Name] = (t.OnDemand - t.Spot) * pricing.HoursPerMonth
+		}
+	}
+
+	groups := map[string][]k8s.PodDetails{}
+	var keys []string
+	for _, pod := range input.Pods {
+		if !scored(pod) {
+			continue
+		}
+		key := workloadKey(pod)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], pod)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		pods := groups[key]
+		w := scoreWorkload(pods, input.PDBs)
+		for _, pod := range pods {
+			node, ok := nodes[pod.NodeName]
+			if !ok {
+				continue
+			}
+			if node.IsSpot {
+				w.OnSpot++
+				continue
+			}
+			w.MonthlySavings += discount[node.Name] * pod.NodeShare(node)
+		}
+
+		report.Summary.PotentialMonthlySavings += w.MonthlySavings
+		switch w.Class {
+		case ClassEligible:
+			report.Summary.Eligible++
+			report.Summary.EligibleMonthlySavings += w.MonthlySavings
+		case ClassCaution:
+			report.Summary.Caution++
+		default:
+			report.Summary.Ineligible++
+		}
+		report.Workloads = append(report.Workloads, w)
+	}
+
+	sort.SliceStable(report.Workloads, func(i, j int) bool {
+		return report.Workloads[i].Score > report.Workloads[j].Score
+	})
+	return report
+}
+
+// Classify returns the per-workload class keyed by "namespace/Kind/name",
+// for callers that only need the verdict.
+func Classify(pods []k8s.PodDetails, pdbs []k8s.PDBDetails) map[string]Class {
+	groups := map[string][]k8s.PodDetails{}
+	for _, pod := range pods {
+		if scored(pod) {
+			groups[workloadKey(pod)] = append(groups[workloadKey(pod)], pod)
+		}
+	}
+	classes := make(map[string]Class, len(groups))
+	for key, group := range groups {
+		classes[key] = scoreWorkload(group, pdbs).Class
+	}
+	return classes
+}
+
+// scored reports whether the pod counts towards its workload's score.
+// DaemonSet pods run wherever their nodes do, and finished pods no longer
+// run at all.
+func scored(pod k8s.PodDetails) bool {
+	return pod.OwnerKind != "DaemonSet" && pod.Status != "Succeeded" && pod.Status != "Failed"
+}
+
+func scoreWorkload(pods []k8s.PodDetails, pdbs []k8s.PDBDetails) WorkloadScore {
+	first := pods[0]
+	w := WorkloadScore{
+		Namespace: first.Namespace,
+		Kind:      first.OwnerKind,
+		Name:      first.OwnerName,
+		Replicas:  len(pods),
+		Reasons:   []Reason{},
+	}
+	if w.Kind == "" {
+		w.Kind, w.Name = "Pod", first.Name
+	}
+
+	score := 100
+	add := func(factor string, impact int, detail string) {
+		score += impact
+		w.Reasons = append(w.Reasons, Reason{Factor: factor, Impact: impact, Detail: detail})
+	}
+
+	batch := w.Kind == "Job" || w.Kind == "CronJob"
+	switch {
+	case w.Kind == "Pod":
+		add("controller", -50, "bare pod: nothing recreates it after an interruption")
+	case w.Kind == "StatefulSet":
+		add("controller", -40, "StatefulSet: replicas have stable identity and usually persistent storage")
+	case batch:
+		add("controller", 0, "batch workload: interrupted pods are retried")
+	}
+
+	if !batch {
+		switch {
+		case w.Replicas == 1:
+			add("replicas", -30, "single replica: an interruption is an outage")
+		case w.Replicas == 2:
+			add("replicas", -10, "two replicas: one interruption halves capacity")
+		default:
+			add("replicas", 0, fmt.Sprintf("%d replicas", w.Replicas))
+		}
+
+		if pdb, ok := coveringPDB(first, pdbs); !ok {
+			if w.Replicas > 1 {
+				add("pdb", -10, "no PodDisruptionBudget: simultaneous interruptions are not limited")
+			}
+		} else if pdb.DisruptionsAllowed == 0 {
+			add("pdb", -20, fmt.Sprintf("PodDisruptionBudget %s allows no disruptions", pdb.Name))
+		} else {
+			add("pdb", 0, fmt.Sprintf("PodDisruptionBudget %s limits concurrent evictions", pdb.Name))
+		}
+	}
+
+	if volumes := localVolumes(pods); len(volumes) > 0 {
+		add("local-storage", -20, fmt.Sprintf("node-local volumes %s are lost on interruption", strings.Join(volumes, ", ")))
+	}
+
+	if grace := maxGracePeriod(pods); grace > interruptionNotice {
+		add("termination-grace", -20, fmt.Sprintf("terminationGracePeriodSeconds %d exceeds the two-minute spot notice", grace))
+	}
+
+	switch {
+	case strings.HasPrefix(first.PriorityClassName, "system-"):
+		add("priority", -40, fmt.Sprintf("priority class %s is reserved for critical components", first.PriorityClassName))
+	case first.Priority >= highPriority:
+		add("priority", -15, fmt.Sprintf("priority class %s (%d) marks it business-critical", first.PriorityClassName, first.Priority))
+	}
+
+	if first.DoNotDisrupt {
+		add("do-not-disrupt", -20, "annotated "+k8s.DoNotDisruptAnnotation)
+	}
+
+	switch capacityTypeAffinity(first) {
+	case "on-demand":
+		add("capacity-type", -30, "pinned to on-demand nodes; its scheduling constraints must change first")
+	case "spot":
+		add("capacity-type", 0, "already targets spot nodes")
+	}
+
+	w.Score = max(0, min(100, score))
+	switch {
+	case w.Score >= eligibleScore:
+		w.Class = ClassEligible
+	case w.Score >= cautionScore:
+		w.Class = ClassCaution
+	default:
+		w.Class = ClassIneligible
+	}
+	return w
+}
+
+// capacityTypeAffinity returns "spot" or "on-demand" when the pod selects a
+// capacity type through its node selector or required node affinity.
+func capacityTypeAffinity(pod k8s.PodDetails) string {
+	for _, label := range capacityTypeLabels {
+		switch pod.NodeSelector[label.key] {
+		case label.spot:
+			return "spot"
+		case label.onDemand:
+			return "on-demand"
+		}
+	}
+	if pod.Affinity == nil || pod.Affinity.NodeAffinity == nil || pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	for _, term := range pod.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			for _, label := range capacityTypeLabels {
+				if expr.Key != label.key || (expr.Operator != "In" && expr.Operator != "NotIn") {
+					continue
+				}
+				if slices.Contains(expr.Values, label.spot) == (expr.Operator == "In") {
+					return "spot"
+				}
+				return "on-demand"
+			}
+		}
+	}
+	return ""
+}
+
+func coveringPDB(pod k8s.PodDetails, pdbs []k8s.PDBDetails) (k8s.PDBDetails, bool) {
+	for _, pdb := range pdbs {
+		if pdb.Matches(pod) {
+			return pdb, true
+		}
+	}
+	return k8s.PDBDetails{}, false
+}
+
+func localVolumes(pods []k8s.PodDetails) []string {
+	var volumes []string
+	for _, pod := range pods {
+		for _, volume := range pod.LocalVolumes {
+			if !slices.Contains(volumes, volume) {
+				volumes = append(volumes, volume)
+			}
+		}
+	}
+	sort.Strings(volumes)
+	return volumes
+}
+
+func maxGracePeriod(pods []k8s.PodDetails) int64 {
+	var grace int64
+	for _, pod := range pods {
+		grace = max(grace, pod.TerminationGracePeriodSeconds)
+	}
+	return grace
+}
+
+func workloadKey(pod k8s.PodDetails) string {
+	if pod.OwnerKind == "" {
+		return pod.Namespace + "/Pod/" + pod.Name
+	}
+	return pod.Namespace + "/" + pod.OwnerKind + "/" + pod.OwnerName
+}
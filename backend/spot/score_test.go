@@ -0,0 +1,60 @@
+package spot
+
+import (
+	"testing"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClassifyMatchesScore(t *testing.T) {
+	catalog, err := pricing.Load("../pricing/testdata/catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pod := func(name, kind, owner, status string) k8s.PodDetails {
+		return k8s.PodDetails{
+			Name:      name,
+			Namespace: "shop",
+			NodeName:  "a",
+			Status:    status,
+			OwnerKind: kind,
+			OwnerName: owner,
+			Labels:    map[string]string{"app": owner},
+		}
+	}
+	input := Input{
+		Nodes: []k8s.NodeDetails{{Name: "a", InstanceType: "m5.xlarge", Region: "us-east-1"}},
+		Pods: []k8s.PodDetails{
+			pod("web-a", "Deployment", "web", "Running"),
+			pod("web-b", "Deployment", "web", "Running"),
+			pod("web-c", "Deployment", "web", "Running"),
+			// A failed replica left behind by an eviction: counting it would
+			// make web look like a four-replica Deployment.
+			pod("web-d", "Deployment", "web", "Failed"),
+			// Only failed pods are left of the Job.
+			pod("migrate-a", "Job", "migrate", "Failed"),
+			pod("exporter-a", "DaemonSet", "exporter", "Running"),
+		},
+		PDBs: []k8s.PDBDetails{{
+			Name:               "web",
+			Namespace:          "shop",
+			Selector:           &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			DisruptionsAllowed: 1,
+		}},
+	}
+
+	report := Score(input, catalog)
+	classes := Classify(input.Pods, input.PDBs)
+	if len(report.Workloads) != 1 || len(classes) != 1 {
+		t.Fatalf("Score has %d workloads, Classify %d, want only shop/Deployment/web in both", len(report.Workloads), len(classes))
+	}
+	w := report.Workloads[0]
+	if w.Replicas != 3 {
+		t.Errorf("replicas = %d, want 3", w.Replicas)
+	}
+	if got := classes["shop/Deployment/web"]; got != w.Class {
+		t.Errorf("Classify = %q, Score = %q", got, w.Class)
+	}
+}
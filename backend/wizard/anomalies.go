@@ -0,0 +1,92 @@
+package wizard
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/anomaly"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/authz"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetAnomalies returns the anomalies of a cluster (?cluster=, or *
+// for the fleet) in the last ?window= of recorded history, scored against
+// the ?lookback= before it. ?minScore= raises or lowers the bar.
+func (s *Service) HandleGetAnomalies(c *gin.Context) {
+	if s.anomalies == nil {
+		apierror.Abort(c, apierror.NotEnabled("cost history is not enabled"))
+		return
+	}
+
+	opts := anomaly.Options{Cluster: c.Query("cluster")}
+	if opts.Cluster == "" {
+		opts.Cluster = s.clusters.DefaultName()
+	}
+	var err error
+	if v := c.Query("window"); v != "" {
+		if opts.Window, err = history.ParseDuration(v); err != nil || opts.Window < time.Hour {
+			apierror.Abort(c, apierror.Invalidf("invalid window %q: use at least 1h", v))
+			return
+		}
+	}
+	if v := c.Query("lookback"); v != "" {
+		if opts.Lookback, err = history.ParseDuration(v); err != nil || opts.Lookback < history.Day {
+			apierror.Abort(c, apierror.Invalidf("invalid lookback %q: use at least 1d", v))
+			return
+		}
+	}
+	if v := c.Query("minScore"); v != "" {
+		if opts.MinScore, err = strconv.ParseFloat(v, 64); err != nil || opts.MinScore <= 0 {
+			apierror.Abort(c, apierror.Invalidf("invalid minScore %q: must be positive", v))
+			return
+		}
+	}
+
+	anomalies, err := s.anomalies.Detect(c.Request.Context(), opts)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, visibleAnomalies(c, anomalies))
+}
+
+// visibleAnomalies drops the cost spikes of namespaces the caller may not
+// see, and the workloads of those namespaces from the contributors of the
+// rest.
+func visibleAnomalies(c *gin.Context, anomalies []anomaly.Anomaly) []anomaly.Anomaly {
+	ctx := c.Request.Context()
+	scope := authz.ScopeFromContext(ctx)
+	if scope.All() {
+		return anomalies
+	}
+	visible := []anomaly.Anomaly{}
+	for _, a := range anomalies {
+		if a.Kind == anomaly.KindCostSpike && !scope.Allows(ctx, a.Subject) {
+			continue
+		}
+		contributors := []anomaly.Contributor{}
+		for _, contributor := range a.Contributors {
+			// Nodes have no namespace and belong to the whole cluster.
+			if contributor.Namespace == "" || scope.Allows(ctx, contributor.Namespace) {
+				contributors = append(contributors, contributor)
+			}
+		}
+		a.Contributors = contributors
+		visible = append(visible, a)
+	}
+	return visible
+}
+
+// DetectAnomalies is the anomaly alerts' view of the fleet: the anomalies
+// of every recorded cluster over the default window.
+func (s *Service) DetectAnomalies(ctx context.Context, minScore float64) ([]anomaly.Anomaly, error) {
+	if s.anomalies == nil {
+		return nil, fmt.Errorf("cost history is not enabled")
+	}
+	return s.anomalies.Detect(ctx, anomaly.Options{Cluster: history.AllClusters, MinScore: minScore})
+}
@@ -0,0 +1,45 @@
+package wizard
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/budget"
+	"github.com/gin-gonic/gin"
+)
+
+// SetBudgets serves the status and alerts of evaluator.
+func (s *Service) SetBudgets(evaluator *budget.Evaluator) {
+	s.budgets = evaluator
+}
+
+// HandleListBudgets returns how every budget stands in its current period.
+func (s *Service) HandleListBudgets(c *gin.Context) {
+	if s.budgets == nil {
+		apierror.Abort(c, apierror.NotEnabled("budgets are not configured"))
+		return
+	}
+	c.JSON(http.StatusOK, s.budgets.Statuses())
+}
+
+// HandleListAlerts returns the firing budget and savings alerts.
+func (s *Service) HandleListAlerts(c *gin.Context) {
+	if s.budgets == nil {
+		apierror.Abort(c, apierror.NotEnabled("budgets are not configured"))
+		return
+	}
+	c.JSON(http.StatusOK, s.budgets.Alerts())
+}
+
+// MonthlySavings is the savings target's view of the fleet: what the
+// recommendations would save per reachable cluster.
+func (s *Service) MonthlySavings(ctx context.Context) (map[string]float64, error) {
+	savings := map[string]float64{}
+	for _, cluster := range s.FleetCost(ctx).Clusters {
+		if cluster.Error == "" {
+			savings[cluster.Cluster] = cluster.PotentialSavings
+		}
+	}
+	return savings, nil
+}
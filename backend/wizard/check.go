@@ -0,0 +1,140 @@
+package wizard
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/audit"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/check"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/gin-gonic/gin"
+)
+
+// maxManifestSize caps the manifests of one check request.
+const maxManifestSize = 8 << 20
+
+// CheckRequest is the JSON body of POST /api/v1/check. Multipart requests
+// send the manifests as "manifests" file fields and the rest as form
+// fields of the same names.
+type CheckRequest struct {
+	Manifests []check.File `json:"manifests" binding:"required"`
+	Region    string       `json:"region"`
+	check.Thresholds
+}
+
+// HandleCheck checks proposed NodePool and EC2NodeClass manifests against
+// the live cluster or an uploaded snapshot (?snapshot=<id>). The result is
+// JSON unless ?format= asks for junit, sarif or markdown. A failed check is
+// still a 200; callers gate on "passed".
+func (s *Service) HandleCheck(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxManifestSize)
+
+	var req CheckRequest
+	var err error
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		req, err = checkRequestFromForm(c)
+	} else {
+		err = c.ShouldBindJSON(&req)
+	}
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+	for _, file := range req.Manifests {
+		audit.Attach(c, file.Name, []byte(file.Content))
+	}
+
+	source, err := s.source(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	result, err := s.Check(c.Request.Context(), source, check.Parse(req.Manifests), req.Region, req.Thresholds)
+	if err != nil {
+		apierror.Abort(c, apierror.From(err, http.StatusBadRequest))
+		return
+	}
+
+	switch c.Query("format") {
+	case check.FormatJUnit:
+		c.Header("Content-Type", "application/xml")
+		err = check.WriteJUnit(c.Writer, result)
+	case check.FormatSARIF:
+		c.Header("Content-Type", "application/sarif+json")
+		err = check.WriteSARIF(c.Writer, result)
+	case check.FormatMarkdown:
+		c.Header("Content-Type", "text/markdown; charset=utf-8")
+		err = check.WriteMarkdown(c.Writer, result)
+	case "", "json":
+		c.JSON(http.StatusOK, result)
+	default:
+		apierror.Abort(c, apierror.Invalidf("unsupported format %q", c.Query("format")))
+	}
+	if err != nil {
+		c.Error(err)
+	}
+}
+
+// Check evaluates the manifests against the cluster behind source.
+func (s *Service) Check(ctx context.Context, source k8s.ClusterSource, manifests *check.Manifests, region string, thresholds check.Thresholds) (*check.Result, error) {
+	state, err := s.ClusterState(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+
+	nodeClasses, err := source.GetNodeClasses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return check.Run(check.Input{
+		Manifests:   manifests,
+		Nodes:       state.Nodes.Nodes,
+		Pods:        state.Pods.Pods,
+		PDBs:        state.PDBs,
+		NodePools:   state.NodePools,
+		NodeClasses: nodeClasses,
+		Region:      region,
+		Thresholds:  thresholds,
+	}, s.catalog)
+}
+
+func checkRequestFromForm(c *gin.Context) (CheckRequest, error) {
+	req := CheckRequest{Region: c.PostForm("region")}
+	req.FailOn = check.Severity(c.PostForm("failOn"))
+
+	var err error
+	if v := c.PostForm("maxMonthlyIncrease"); v != "" {
+		if req.MaxMonthlyIncrease, err = strconv.ParseFloat(v, 64); err != nil {
+			return req, err
+		}
+	}
+	if v := c.PostForm("maxIncreasePercent"); v != "" {
+		if req.MaxIncreasePercent, err = strconv.ParseFloat(v, 64); err != nil {
+			return req, err
+		}
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		return req, err
+	}
+	for _, header := range form.File["manifests"] {
+		f, err := header.Open()
+		if err != nil {
+			return req, err
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return req, err
+		}
+		req.Manifests = append(req.Manifests, check.File{Name: header.Filename, Content: string(data)})
+	}
+	return req, nil
+}
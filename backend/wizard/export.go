@@ -0,0 +1,103 @@
+package wizard
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/export"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleExportFocus serves the recorded cost allocation of a cluster
+// (?cluster=, or * for every recorded cluster) between ?from= and ?to=,
+// the last day by default, per ?step= (1h) and ?aggregate= (namespace or
+// label:<key>). ?format= is csv or parquet in the FOCUS columns, or json
+// as OpenCost's allocation API answers.
+func (s *Service) HandleExportFocus(c *gin.Context) {
+	if s.history == nil {
+		apierror.Abort(c, apierror.NotEnabled("cost history is not enabled"))
+		return
+	}
+
+	now := time.Now().UTC()
+	q := export.Query{
+		From:      now.Truncate(time.Hour).Add(-24 * time.Hour),
+		To:        now.Truncate(time.Hour),
+		Step:      time.Hour,
+		Aggregate: c.DefaultQuery("aggregate", history.DimensionNamespace),
+	}
+	format := c.DefaultQuery("format", export.FormatCSV)
+	if _, ok := export.ContentTypes[format]; !ok {
+		apierror.Abort(c, apierror.Invalidf("unknown format %q: use %s", format, strings.Join(export.Formats, ", ")))
+		return
+	}
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		if q.From, err = parseHistoryTime(v, now); err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if q.To, err = parseHistoryTime(v, now); err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+	}
+	if v := c.Query("step"); v != "" {
+		if q.Step, err = history.ParseDuration(v); err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+	}
+
+	switch cluster := c.Query("cluster"); cluster {
+	case history.AllClusters:
+		if q.Clusters, err = s.history.Clusters(); err != nil {
+			apierror.Respond(c, err)
+			return
+		}
+	case "":
+		q.Clusters = []string{s.clusters.DefaultName()}
+	default:
+		q.Clusters = []string{cluster}
+	}
+
+	visible, err := visibleGroups(c, q.Aggregate)
+	if err != nil {
+		apierror.Abort(c, apierror.Forbidden(err))
+		return
+	}
+
+	allocations, err := export.Allocations(s.history, q)
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+	if visible != nil {
+		filtered := allocations[:0]
+		for _, a := range allocations {
+			if visible(a.Name) {
+				filtered = append(filtered, a)
+			}
+		}
+		allocations = filtered
+	}
+
+	var buf bytes.Buffer
+	if err := export.Write(&buf, format, allocations); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	if format != export.FormatJSON {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", path.Base(export.FileName("", format, q.From))))
+	}
+	c.Data(http.StatusOK, export.ContentTypes[format], buf.Bytes())
+}
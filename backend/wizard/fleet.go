@@ -0,0 +1,122 @@
+package wizard
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/gin-gonic/gin"
+)
+
+// fleetConcurrency bounds how many clusters are queried at once.
+const fleetConcurrency = 4
+
+// ClusterCostSummary is one cluster's line in the fleet view. A cluster
+// that couldn't be read keeps its row, with the error, so one unreachable
+// cluster doesn't hide the rest.
+type ClusterCostSummary struct {
+	Cluster          string  `json:"cluster"`
+	Nodes            int     `json:"nodes"`
+	SpotNodes        int     `json:"spotNodes"`
+	UnpricedNodes    int     `json:"unpricedNodes"`
+	MonthlyCost      float64 `json:"monthlyCost"`
+	PotentialSavings float64 `json:"potentialSavings"`
+	Error            string  `json:"error,omitempty"`
+}
+
+type FleetCost struct {
+	Clusters []ClusterCostSummary `json:"clusters"`
+	Total    FleetTotal           `json:"total"`
+}
+
+type FleetTotal struct {
+	Clusters         int     `json:"clusters"`
+	Unreachable      int     `json:"unreachable"`
+	Nodes            int     `json:"nodes"`
+	SpotNodes        int     `json:"spotNodes"`
+	MonthlyCost      float64 `json:"monthlyCost"`
+	PotentialSavings float64 `json:"potentialSavings"`
+	Percentage       float64 `json:"percentage"`
+}
+
+func (s *Service) HandleListClusters(c *gin.Context) {
+	c.JSON(http.StatusOK, s.clusters.List())
+}
+
+// HandleGetFleetCost prices every registered cluster and adds them up.
+func (s *Service) HandleGetFleetCost(c *gin.Context) {
+	c.JSON(http.StatusOK, s.FleetCost(c.Request.Context()))
+}
+
+// FleetCost prices every registered cluster the way the recommender does,
+// with its non-overlapping savings as the potential.
+func (s *Service) FleetCost(ctx context.Context) *FleetCost {
+	registered := s.clusters.List()
+	fleet := &FleetCost{Clusters: make([]ClusterCostSummary, len(registered))}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, fleetConcurrency)
+	for i, cluster := range registered {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			fleet.Clusters[i] = s.clusterCostSummary(ctx, name)
+		}(i, cluster.Name)
+	}
+	wg.Wait()
+
+	for _, summary := range fleet.Clusters {
+		fleet.Total.Clusters++
+		if summary.Error != "" {
+			fleet.Total.Unreachable++
+			continue
+		}
+		fleet.Total.Nodes += summary.Nodes
+		fleet.Total.SpotNodes += summary.SpotNodes
+		fleet.Total.MonthlyCost += summary.MonthlyCost
+		fleet.Total.PotentialSavings += summary.PotentialSavings
+	}
+	if fleet.Total.MonthlyCost > 0 {
+		fleet.Total.Percentage = fleet.Total.PotentialSavings / fleet.Total.MonthlyCost * 100
+	}
+	return fleet
+}
+
+func (s *Service) clusterCostSummary(ctx context.Context, name string) ClusterCostSummary {
+	summary := ClusterCostSummary{Cluster: name}
+
+	source, err := s.clusters.Get(name)
+	if err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+	state, err := s.ClusterState(ctx, source)
+	if err != nil {
+		summary.Error = err.Error()
+		return summary
+	}
+
+	for _, node := range state.Nodes.Nodes {
+		summary.Nodes++
+		if node.IsSpot {
+			summary.SpotNodes++
+		}
+		if _, ok := s.catalog.Lookup(s.nodeRegion(node), node.InstanceType); !ok {
+			summary.UnpricedNodes++
+		}
+	}
+	report := s.Recommend(state, "")
+	summary.MonthlyCost = report.Summary.CurrentMonthlyCost
+	summary.PotentialSavings = report.Summary.MonthlySavings
+	return summary
+}
+
+func (s *Service) nodeRegion(node k8s.NodeDetails) string {
+	if node.Region == "" || node.Region == "unknown" {
+		return s.catalog.DefaultRegion
+	}
+	return node.Region
+}
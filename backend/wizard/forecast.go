@@ -0,0 +1,247 @@
+package wizard
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/forecast"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultForecastLookback   = 90 * history.Day
+	defaultForecastConfidence = 0.95
+
+	// weeklySeason is the cycle of daily cost: weekday and weekend load
+	// differ in most clusters.
+	weeklySeason = 7
+)
+
+var defaultForecastHorizons = []int{30, 90}
+
+// CostForecast projects the spend of a cluster, or of the fleet, from its
+// recorded daily cost.
+type CostForecast struct {
+	Cluster    string          `json:"cluster"`
+	GroupBy    string          `json:"groupBy,omitempty"`
+	Confidence float64         `json:"confidence"`
+	History    ForecastHistory `json:"history"`
+	Model      *forecast.Model `json:"model"`
+	Total      SpendForecast   `json:"total"`
+	Groups     []SpendForecast `json:"groups,omitempty"`
+	// Daily is the projected cost of each day up to the longest horizon.
+	Daily    []DailyForecast `json:"daily"`
+	Warnings []string        `json:"warnings,omitempty"`
+}
+
+// ForecastHistory is the recorded range the forecast is fitted to.
+type ForecastHistory struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	Days int       `json:"days"`
+}
+
+type SpendForecast struct {
+	Name string `json:"name,omitempty"`
+	// DailyCost is the cost of the last recorded day.
+	DailyCost float64           `json:"dailyCost"`
+	Horizons  []HorizonForecast `json:"horizons"`
+}
+
+// HorizonForecast is the spend over the next Days days, with its
+// prediction interval.
+type HorizonForecast struct {
+	Days   int         `json:"days"`
+	Cost   float64     `json:"cost"`
+	Lower  float64     `json:"lower"`
+	Upper  float64     `json:"upper"`
+	Growth *CostGrowth `json:"growth,omitempty"`
+}
+
+// CostGrowth splits how much the daily cost changes by the end of a horizon
+// into workload growth, more or less vCPU capacity at today's cost per vCPU,
+// and price change, the cost per vCPU moving with prices, spot share and
+// instance mix. Capacity and cost per vCPU are projected separately, so
+// Change can differ slightly from the cost forecast; Workload and Price
+// always add up to it.
+type CostGrowth struct {
+	Change   float64 `json:"change"`
+	Workload float64 `json:"workload"`
+	Price    float64 `json:"price"`
+}
+
+type DailyForecast struct {
+	Date time.Time `json:"date"`
+	forecast.Estimate
+}
+
+// HandleGetCostForecast projects the spend of a cluster (?cluster=, or *
+// for the fleet) over the next 30 and 90 days, or the ?horizon= list, from
+// up to ?lookback= of recorded history. ?groupBy= adds a forecast per
+// cluster, namespace, nodepool or other history dimension.
+func (s *Service) HandleGetCostForecast(c *gin.Context) {
+	if s.history == nil {
+		apierror.Abort(c, apierror.NotEnabled("cost history is not enabled"))
+		return
+	}
+
+	cluster := c.Query("cluster")
+	if cluster == "" {
+		cluster = s.clusters.DefaultName()
+	}
+	groupBy := c.Query("groupBy")
+	visible, err := visibleGroups(c, groupBy)
+	if err != nil {
+		apierror.Abort(c, apierror.Forbidden(err))
+		return
+	}
+
+	lookback := defaultForecastLookback
+	if v := c.Query("lookback"); v != "" {
+		d, err := history.ParseDuration(v)
+		if err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+		lookback = d
+	}
+
+	confidence := defaultForecastConfidence
+	if v := c.Query("confidence"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f >= 1 {
+			apierror.Abort(c, apierror.Invalidf("invalid confidence %q: must be between 0 and 1", v))
+			return
+		}
+		confidence = f
+	}
+
+	horizons := defaultForecastHorizons
+	if v := c.Query("horizon"); v != "" {
+		horizons = nil
+		for _, part := range strings.Split(v, ",") {
+			d, err := history.ParseDuration(strings.TrimSpace(part))
+			if err != nil || d < history.Day {
+				apierror.Abort(c, apierror.Invalidf("invalid horizon %q: use whole days such as 30d", part))
+				return
+			}
+			horizons = append(horizons, int(d/history.Day))
+		}
+		sort.Ints(horizons)
+	}
+
+	result, err := s.CostForecast(cluster, groupBy, lookback, horizons, confidence)
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+	if result == nil {
+		apierror.Abort(c, apierror.NotFoundf("no cost history recorded for cluster %s", cluster))
+		return
+	}
+	if visible != nil {
+		groups := result.Groups[:0]
+		for _, group := range result.Groups {
+			if visible(group.Name) {
+				groups = append(groups, group)
+			}
+		}
+		result.Groups = groups
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// CostForecast fits the recorded daily cost of cluster and projects it over
+// each horizon, in days. It returns nil when nothing has been recorded.
+func (s *Service) CostForecast(cluster, groupBy string, lookback time.Duration, horizons []int, confidence float64) (*CostForecast, error) {
+	now := time.Now().UTC()
+	series, err := s.history.Daily(cluster, groupBy, now.Add(-lookback), now)
+	if err != nil || series == nil {
+		return nil, err
+	}
+
+	longest := horizons[len(horizons)-1]
+	model := forecast.FitSeasonal(series.Cost, weeklySeason)
+	result := &CostForecast{
+		Cluster:    cluster,
+		GroupBy:    groupBy,
+		Confidence: confidence,
+		History: ForecastHistory{
+			From: series.From,
+			To:   now,
+			Days: len(series.Cost),
+		},
+		Model: model,
+		Total: spendForecast("", series.Cost, model, horizons, confidence),
+		Daily: make([]DailyForecast, 0, longest),
+	}
+
+	tomorrow := series.From.Add(time.Duration(len(series.Cost)) * history.Day)
+	for i, estimate := range model.Forecast(longest, confidence) {
+		result.Daily = append(result.Daily, DailyForecast{Date: tomorrow.Add(time.Duration(i) * history.Day), Estimate: nonNegative(estimate)})
+	}
+
+	capacity := forecast.FitSeasonal(series.VCPUs, weeklySeason)
+	unitCost := make([]float64, len(series.Cost))
+	for i := range unitCost {
+		if series.VCPUs[i] <= 0 {
+			unitCost = nil
+			break
+		}
+		unitCost[i] = series.Cost[i] / series.VCPUs[i]
+	}
+	if unitCost != nil {
+		price := forecast.FitSeasonal(unitCost, weeklySeason)
+		capacityAhead := capacity.Forecast(longest, confidence)
+		priceAhead := price.Forecast(longest, confidence)
+		v0, p0 := series.VCPUs[len(series.VCPUs)-1], unitCost[len(unitCost)-1]
+		for i, horizon := range horizons {
+			v1, p1 := max(capacityAhead[horizon-1].Value, 0), max(priceAhead[horizon-1].Value, 0)
+			growth := &CostGrowth{
+				Workload: (v1 - v0) * (p0 + p1) / 2,
+				Price:    (p1 - p0) * (v0 + v1) / 2,
+			}
+			growth.Change = growth.Workload + growth.Price
+			result.Total.Horizons[i].Growth = growth
+		}
+	}
+
+	for name, costs := range series.Groups {
+		groupModel := forecast.FitSeasonal(costs, weeklySeason)
+		result.Groups = append(result.Groups, spendForecast(name, costs, groupModel, horizons, confidence))
+	}
+	sort.Slice(result.Groups, func(i, j int) bool {
+		a, b := result.Groups[i].Horizons[0].Cost, result.Groups[j].Horizons[0].Cost
+		if a != b {
+			return a > b
+		}
+		return result.Groups[i].Name < result.Groups[j].Name
+	})
+
+	switch model.Method {
+	case forecast.MethodMean:
+		result.Warnings = append(result.Warnings, fmt.Sprintf("only %d day(s) of history: the forecast is the average daily cost, without trend", len(series.Cost)))
+	case forecast.MethodHolt:
+		result.Warnings = append(result.Warnings, "less than two weeks of history: weekly seasonality is not modelled")
+	}
+	return result, nil
+}
+
+func spendForecast(name string, costs []float64, model *forecast.Model, horizons []int, confidence float64) SpendForecast {
+	spend := SpendForecast{Name: name, DailyCost: costs[len(costs)-1]}
+	for _, horizon := range horizons {
+		total := nonNegative(model.Total(horizon, confidence))
+		spend.Horizons = append(spend.Horizons, HorizonForecast{Days: horizon, Cost: total.Value, Lower: total.Lower, Upper: total.Upper})
+	}
+	return spend
+}
+
+func nonNegative(e forecast.Estimate) forecast.Estimate {
+	return forecast.Estimate{Value: max(e.Value, 0), Lower: max(e.Lower, 0), Upper: max(e.Upper, 0)}
+}
@@ -0,0 +1,39 @@
+package wizard
+
+import (
+	"net/http"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/graviton"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetGravitonReadiness reports which workloads can move to arm64 and
+// what the move would save.
+func (s *Service) HandleGetGravitonReadiness(c *gin.Context) {
+	ctx := c.Request.Context()
+	source, err := s.source(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	nodeInfo, err := source.GetNodes(ctx)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	podInfo, err := source.GetPods(ctx)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	report := graviton.Analyze(ctx, graviton.Input{
+		Nodes:  nodeInfo.Nodes,
+		Pods:   podInfo.Pods,
+		Region: c.Query("region"),
+	}, s.registry, s.catalog)
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,110 @@
+package wizard
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/gin-gonic/gin"
+)
+
+const defaultHistoryRange = 24 * time.Hour
+
+// historySteps are the step sizes picked when the request names none: the
+// smallest that keeps the series under about 200 points.
+var historySteps = []time.Duration{
+	5 * time.Minute, 15 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour,
+}
+
+// HandleGetCostHistory serves the recorded cost and utilization of a
+// cluster (?cluster=, or * for the whole fleet) between ?from= and ?to=,
+// averaged per ?step= and optionally broken down by ?groupBy=.
+func (s *Service) HandleGetCostHistory(c *gin.Context) {
+	if s.history == nil {
+		apierror.Abort(c, apierror.NotEnabled("cost history is not enabled"))
+		return
+	}
+
+	now := time.Now().UTC()
+	q := history.Query{
+		Cluster: c.Query("cluster"),
+		From:    now.Add(-defaultHistoryRange),
+		To:      now,
+		GroupBy: c.Query("groupBy"),
+	}
+	if q.Cluster == "" {
+		q.Cluster = s.clusters.DefaultName()
+	}
+
+	var err error
+	if v := c.Query("from"); v != "" {
+		if q.From, err = parseHistoryTime(v, now); err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+	}
+	if v := c.Query("to"); v != "" {
+		if q.To, err = parseHistoryTime(v, now); err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+	}
+	if v := c.Query("step"); v != "" {
+		if q.Step, err = history.ParseDuration(v); err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+	} else {
+		q.Step = defaultHistoryStep(q.To.Sub(q.From))
+	}
+
+	visible, err := visibleGroups(c, q.GroupBy)
+	if err != nil {
+		apierror.Abort(c, apierror.Forbidden(err))
+		return
+	}
+
+	series, err := s.history.Query(q)
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+	if visible != nil {
+		for _, point := range series.Points {
+			for name := range point.Groups {
+				if !visible(name) {
+					delete(point.Groups, name)
+				}
+			}
+		}
+	}
+	c.JSON(http.StatusOK, series)
+}
+
+// parseHistoryTime accepts RFC 3339, Unix seconds, or a duration before
+// now such as "7d" or "-12h".
+func parseHistoryTime(v string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	if d, err := history.ParseDuration(strings.TrimPrefix(v, "-")); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q: use RFC 3339, Unix seconds or a duration such as 7d", v)
+}
+
+func defaultHistoryStep(span time.Duration) time.Duration {
+	for _, step := range historySteps {
+		if span/step <= 200 {
+			return step
+		}
+	}
+	return historySteps[len(historySteps)-1]
+}
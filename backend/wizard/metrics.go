@@ -0,0 +1,137 @@
+package wizard
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/metrics"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/recommender"
+)
+
+// clusterMetrics is what the cost model says about one cluster.
+type clusterMetrics struct {
+	name       string
+	up         bool
+	nodes      []metrics.Sample
+	namespaces map[string]float64
+	idle       float64
+	savings    map[recommender.Kind]float64
+}
+
+// CollectMetrics prices every registered cluster include accepts, node by
+// node and namespace by namespace, the way the cost history does, along
+// with what the recommendations would save.
+func (s *Service) CollectMetrics(ctx context.Context, include func(cluster string) bool) []metrics.Family {
+	var names []string
+	for _, cluster := range s.clusters.List() {
+		if include(cluster.Name) {
+			names = append(names, cluster.Name)
+		}
+	}
+
+	results := make([]clusterMetrics, len(names))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, fleetConcurrency)
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = s.clusterMetrics(ctx, name)
+		}(i, name)
+	}
+	wg.Wait()
+
+	up := metrics.Gauge("karpops_cluster_up", "Whether the cluster could be read for the cost metrics.")
+	nodeCost := metrics.Gauge("karpops_node_hourly_cost", "Hourly price of the node.")
+	namespaceCost := metrics.Gauge("karpops_namespace_allocated_hourly_cost",
+		"Hourly cost of the nodes allocated to the namespace's pods by their requests.")
+	idleCost := metrics.Gauge("karpops_idle_hourly_cost", "Hourly cost of the node capacity no pod requests.")
+	savings := metrics.Gauge("karpops_savings_opportunity_monthly",
+		"Monthly savings of the recommendations of a kind. Recommendations of different kinds may be alternatives for the same nodes.")
+	for _, result := range results {
+		cluster := map[string]string{"cluster": result.name}
+		if !result.up {
+			up.Samples = append(up.Samples, metrics.Sample{Labels: cluster})
+			continue
+		}
+		up.Samples = append(up.Samples, metrics.Sample{Labels: cluster, Value: 1})
+		nodeCost.Samples = append(nodeCost.Samples, result.nodes...)
+		for _, namespace := range slices.Sorted(maps.Keys(result.namespaces)) {
+			namespaceCost.Samples = append(namespaceCost.Samples, metrics.Sample{
+				Labels: map[string]string{"cluster": result.name, "namespace": namespace},
+				Value:  result.namespaces[namespace],
+			})
+		}
+		idleCost.Samples = append(idleCost.Samples, metrics.Sample{Labels: cluster, Value: result.idle})
+		for _, kind := range []recommender.Kind{recommender.KindFamilyMigration, recommender.KindGraviton, recommender.KindSpot, recommender.KindConsolidation} {
+			savings.Samples = append(savings.Samples, metrics.Sample{
+				Labels: map[string]string{"cluster": result.name, "kind": string(kind)},
+				Value:  result.savings[kind],
+			})
+		}
+	}
+	return []metrics.Family{up, nodeCost, namespaceCost, idleCost, savings}
+}
+
+func (s *Service) clusterMetrics(ctx context.Context, name string) clusterMetrics {
+	result := clusterMetrics{name: name, namespaces: map[string]float64{}, savings: map[recommender.Kind]float64{}}
+	source, err := s.clusters.Get(name)
+	if err != nil {
+		return result
+	}
+	state, err := s.ClusterState(ctx, source)
+	if err != nil {
+		return result
+	}
+	result.up = true
+
+	podsByNode := map[string][]k8s.PodDetails{}
+	for _, pod := range state.Pods.Pods {
+		if pod.NodeName == "" || pod.Status == "Succeeded" || pod.Status == "Failed" {
+			continue
+		}
+		podsByNode[pod.NodeName] = append(podsByNode[pod.NodeName], pod)
+	}
+	for _, node := range state.Nodes.Nodes {
+		price := history.NodePrice(&node, s.catalog)
+		capacityType := "on-demand"
+		if node.IsSpot {
+			capacityType = "spot"
+		}
+		nodePool := node.NodePool
+		if nodePool == "" {
+			nodePool = history.Unmanaged
+		}
+		result.nodes = append(result.nodes, metrics.Sample{
+			Labels: map[string]string{
+				"cluster":       name,
+				"node":          node.Name,
+				"instance_type": node.InstanceType,
+				"capacity_type": capacityType,
+				"zone":          node.Zone,
+				"nodepool":      nodePool,
+			},
+			Value: price,
+		})
+
+		pods := podsByNode[node.Name]
+		shares, idle := history.PodCosts(node, pods, price)
+		result.idle += idle
+		for i, pod := range pods {
+			if shares[i] > 0 {
+				result.namespaces[pod.Namespace] += shares[i]
+			}
+		}
+	}
+
+	for _, recommendation := range s.Recommend(state, "").Recommendations {
+		result.savings[recommendation.Kind] += recommendation.MonthlySavings
+	}
+	return result
+}
@@ -0,0 +1,80 @@
+package wizard
+
+import (
+	"net/http"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/gin-gonic/gin"
+)
+
+// Pricing is the body of GET /api/v1/pricing/:region/:instance-type.
+type Pricing struct {
+	Region       string         `json:"region"`
+	InstanceType string         `json:"instanceType"`
+	VCPU         int64          `json:"vcpu"`
+	MemoryGiB    int64          `json:"memoryGib"`
+	Architecture string         `json:"architecture"`
+	OnDemand     Price          `json:"onDemand"`
+	Spot         SpotPrice      `json:"spot"`
+	Monthly      MonthlyPricing `json:"monthly"`
+}
+
+type Price struct {
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+	Unit     string  `json:"unit"`
+}
+
+type SpotPrice struct {
+	Price
+	// Discount is the percentage off the on-demand price.
+	Discount float64 `json:"discount"`
+}
+
+// MonthlyPricing is the cost of running the instance type for 30 days.
+type MonthlyPricing struct {
+	OnDemand float64 `json:"onDemand"`
+	Spot     float64 `json:"spot"`
+	Savings  float64 `json:"savings"`
+}
+
+// HandleGetPricing returns the catalog prices of one instance type.
+func (s *Service) HandleGetPricing(c *gin.Context) {
+	prices, err := s.Pricing(c.Param("region"), c.Param("instance-type"))
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, prices)
+}
+
+// Pricing looks the instance type up in the catalog. An empty region uses
+// the catalog's default.
+func (s *Service) Pricing(region, instanceType string) (*Pricing, error) {
+	if region == "" {
+		region = s.catalog.DefaultRegion
+	}
+	t, ok := s.catalog.Lookup(region, instanceType)
+	if !ok {
+		return nil, apierror.NotFoundf("no price for %s in %s", instanceType, region)
+	}
+
+	prices := &Pricing{
+		Region:       region,
+		InstanceType: t.Name,
+		VCPU:         t.VCPU,
+		MemoryGiB:    t.MemoryGiB,
+		Architecture: t.Architecture,
+		OnDemand:     Price{Price: t.OnDemand, Currency: "USD", Unit: "per hour"},
+		Spot:         SpotPrice{Price: Price{Price: t.Spot, Currency: "USD", Unit: "per hour"}},
+		Monthly:      MonthlyPricing{OnDemand: t.OnDemand * pricing.HoursPerMonth},
+	}
+	// Types the catalog has no spot price for show no spot savings.
+	if t.Spot > 0 && t.OnDemand > 0 {
+		prices.Spot.Discount = (1 - t.Spot/t.OnDemand) * 100
+		prices.Monthly.Spot = t.Spot * pricing.HoursPerMonth
+		prices.Monthly.Savings = (t.OnDemand - t.Spot) * pricing.HoursPerMonth
+	}
+	return prices, nil
+}
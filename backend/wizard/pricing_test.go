@@ -0,0 +1,48 @@
+package wizard
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/gin-gonic/gin"
+)
+
+func TestPricing(t *testing.T) {
+	s, _ := newTestService(t)
+	router := gin.New()
+	router.GET("/pricing/:region/:instance-type", s.HandleGetPricing)
+
+	// The route answers what the CLI looks up.
+	want, err := s.Pricing("", "m5.xlarge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/pricing/us-east-1/m5.xlarge", nil))
+	var got Pricing
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &got) != nil {
+		t.Fatalf("GET = %d %s", w.Code, w.Body)
+	}
+	if got != *want {
+		t.Errorf("route = %+v, Pricing = %+v", got, *want)
+	}
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if got.Region != "us-east-1" || got.OnDemand.Price != 0.192 || got.Spot.Price.Price != 0.0576 || !near(got.Spot.Discount, 70) {
+		t.Errorf("prices = %+v, want 0.192 on demand and 0.0576 spot, 70%% off", got)
+	}
+	if !near(got.Monthly.OnDemand, 138.24) || !near(got.Monthly.Spot, 41.472) || !near(got.Monthly.Savings, 96.768) {
+		t.Errorf("monthly = %+v", got.Monthly)
+	}
+
+	_, err = s.Pricing("us-east-1", "m5.nope")
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("unknown type: %v, want a 404", err)
+	}
+}
@@ -0,0 +1,239 @@
+package wizard
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/audit"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	cpuHeadroom    = 0.15
+	memoryHeadroom = 0.20
+	minCPUMilli    = 10
+	minMemoryBytes = 32 * 1024 * 1024
+	mebibyte       = 1024 * 1024
+
+	// metrics-server only reports the usage of its last scrape, which can
+	// miss both the peaks and the troughs of a workload, so the bounds are
+	// kept well clear of it: minAllowed at half the usage seen and
+	// maxAllowed at four times the target.
+	lowerBoundFactor = 0.5
+	upperBoundFactor = 4
+
+	// BasisPointInTime is the basis of recommendations derived from the
+	// usage of every pod at one instant.
+	BasisPointInTime = "point-in-time"
+)
+
+// rightsizableKinds are the workload kinds we can render VPAs and patches for.
+var rightsizableKinds = map[string]string{
+	"deployment":  "Deployment",
+	"statefulset": "StatefulSet",
+	"daemonset":   "DaemonSet",
+}
+
+type WorkloadRef struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+}
+
+type ResourceValues struct {
+	CPU    int64 `json:"cpu"`    // millicores
+	Memory int64 `json:"memory"` // bytes
+}
+
+type ContainerRecommendation struct {
+	Name    string         `json:"name"`
+	Current ResourceValues `json:"current"`
+	Limits  ResourceValues `json:"limits"`
+	// Observed is the highest usage any of the pods reported.
+	Observed   ResourceValues `json:"observed"`
+	Target     ResourceValues `json:"target"`
+	LowerBound ResourceValues `json:"lowerBound"`
+	UpperBound ResourceValues `json:"upperBound"`
+	// Pods is how many pods reported usage, one reading each.
+	Pods int `json:"pods"`
+}
+
+type RightsizingRecommendation struct {
+	Workload   WorkloadRef               `json:"workload"`
+	Replicas   int                       `json:"replicas"`
+	Containers []ContainerRecommendation `json:"containers"`
+	// Basis is BasisPointInTime, and Warning says what that means for the
+	// values.
+	Basis   string `json:"basis"`
+	Warning string `json:"warning"`
+}
+
+func (s *Service) HandleGetRightsizing(c *gin.Context) {
+	recommendation, err := s.rightsizingFor(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, recommendation)
+}
+
+// HandleExportRightsizing renders the recommendation either as a
+// VerticalPodAutoscaler (format=vpa) or as a strategic-merge patch for the
+// owning workload (format=patch), ready to drop into a GitOps repo.
+func (s *Service) HandleExportRightsizing(c *gin.Context) {
+	format := c.DefaultQuery("format", "vpa")
+	mode := c.DefaultQuery("mode", "Off")
+	if mode != "Off" && mode != "Initial" {
+		apierror.Abort(c, apierror.Invalidf("mode must be Off or Initial"))
+		return
+	}
+
+	recommendation, err := s.rightsizingFor(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	var manifest []byte
+	var suffix string
+	switch format {
+	case "vpa":
+		manifest, err = renderVPA(recommendation, mode)
+		suffix = "vpa"
+	case "patch":
+		manifest, err = renderResourcesPatch(recommendation)
+		suffix = "resources-patch"
+	default:
+		apierror.Abort(c, apierror.Invalidf("format must be vpa or patch"))
+		return
+	}
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s.yaml", recommendation.Workload.Name, suffix)
+	audit.Attach(c, filename, manifest)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/yaml", manifest)
+}
+
+func (s *Service) rightsizingFor(c *gin.Context) (*RightsizingRecommendation, error) {
+	kind, ok := rightsizableKinds[strings.ToLower(c.Param("kind"))]
+	if !ok {
+		return nil, apierror.Invalidf("unsupported workload kind %q", c.Param("kind"))
+	}
+	ref := WorkloadRef{
+		Namespace: c.Param("namespace"),
+		Kind:      kind,
+		Name:      c.Param("name"),
+	}
+
+	ctx := c.Request.Context()
+	source, err := s.source(c)
+	if err != nil {
+		return nil, err
+	}
+
+	podInfo, err := source.GetPods(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := source.GetPodMetrics(ctx, ref.Namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	recommendation, err := computeRightsizing(ref, podInfo.Pods, usage)
+	if err != nil {
+		return nil, apierror.NotFound(err)
+	}
+	return recommendation, nil
+}
+
+// computeRightsizing derives per-container targets from the peak usage
+// across the workload's pods, plus headroom. The usage is one reading per
+// pod, so the bounds are widened around it. The upper bound never drops
+// below the current requests, so a VPA's maxAllowed can't cap a workload
+// below what it asks for today.
+func computeRightsizing(ref WorkloadRef, pods []k8s.PodDetails, usage map[string]k8s.PodUsage) (*RightsizingRecommendation, error) {
+	recommendation := &RightsizingRecommendation{
+		Workload: ref,
+		Basis:    BasisPointInTime,
+	}
+	byName := map[string]*ContainerRecommendation{}
+	var order []string
+
+	for _, pod := range pods {
+		if pod.Namespace != ref.Namespace || pod.OwnerKind != ref.Kind || pod.OwnerName != ref.Name {
+			continue
+		}
+		recommendation.Replicas++
+
+		podUsage, hasUsage := usage[pod.Namespace+"/"+pod.Name]
+		for _, container := range pod.Containers {
+			rec, ok := byName[container.Name]
+			if !ok {
+				rec = &ContainerRecommendation{
+					Name:    container.Name,
+					Current: ResourceValues{CPU: container.CPURequest, Memory: container.MemoryRequest},
+					Limits:  ResourceValues{CPU: container.CPULimit, Memory: container.MemoryLimit},
+				}
+				byName[container.Name] = rec
+				order = append(order, container.Name)
+			}
+			if !hasUsage {
+				continue
+			}
+			if used, ok := podUsage.Containers[container.Name]; ok {
+				rec.Pods++
+				rec.Observed.CPU = max(rec.Observed.CPU, used.CPU)
+				rec.Observed.Memory = max(rec.Observed.Memory, used.Memory)
+			}
+		}
+	}
+
+	if recommendation.Replicas == 0 {
+		return nil, fmt.Errorf("no pods found for %s %s/%s", ref.Kind, ref.Namespace, ref.Name)
+	}
+
+	sort.Strings(order)
+	for _, name := range order {
+		rec := byName[name]
+		if rec.Pods == 0 {
+			return nil, fmt.Errorf("no usage metrics for container %q of %s %s/%s", name, ref.Kind, ref.Namespace, ref.Name)
+		}
+
+		observedCPU := max(rec.Observed.CPU, minCPUMilli)
+		observedMemory := max(rec.Observed.Memory, minMemoryBytes)
+		rec.Target.CPU = int64(float64(observedCPU) * (1 + cpuHeadroom))
+		rec.Target.Memory = roundUpToMebibyte(int64(float64(observedMemory) * (1 + memoryHeadroom)))
+		rec.LowerBound.CPU = max(int64(float64(observedCPU)*lowerBoundFactor), minCPUMilli)
+		rec.LowerBound.Memory = roundUpToMebibyte(max(int64(float64(observedMemory)*lowerBoundFactor), minMemoryBytes))
+		rec.UpperBound.CPU = max(rec.Target.CPU*upperBoundFactor, rec.Current.CPU)
+		rec.UpperBound.Memory = roundUpToMebibyte(max(rec.Target.Memory*upperBoundFactor, rec.Current.Memory))
+
+		recommendation.Containers = append(recommendation.Containers, *rec)
+	}
+
+	recommendation.Warning = fmt.Sprintf("based on one reading of the usage of %d of %d pods; a spike or quiet period at that instant skews the target, so check it against the workload's usage over time before applying it", maxPods(recommendation.Containers), recommendation.Replicas)
+	return recommendation, nil
+}
+
+// maxPods is the number of pods any container reported usage for.
+func maxPods(containers []ContainerRecommendation) int {
+	pods := 0
+	for _, container := range containers {
+		pods = max(pods, container.Pods)
+	}
+	return pods
+}
+
+func roundUpToMebibyte(bytes int64) int64 {
+	return (bytes + mebibyte - 1) / mebibyte * mebibyte
+}
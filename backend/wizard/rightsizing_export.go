@@ -0,0 +1,165 @@
+package wizard
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+type VPAManifest struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Metadata   Metadata `yaml:"metadata"`
+	Spec       VPASpec  `yaml:"spec"`
+}
+
+type VPASpec struct {
+	TargetRef      VPATargetRef      `yaml:"targetRef"`
+	UpdatePolicy   VPAUpdatePolicy   `yaml:"updatePolicy"`
+	ResourcePolicy VPAResourcePolicy `yaml:"resourcePolicy"`
+}
+
+type VPATargetRef struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Name       string `yaml:"name"`
+}
+
+type VPAUpdatePolicy struct {
+	UpdateMode string `yaml:"updateMode"`
+}
+
+type VPAResourcePolicy struct {
+	ContainerPolicies []VPAContainerPolicy `yaml:"containerPolicies"`
+}
+
+type VPAContainerPolicy struct {
+	ContainerName       string            `yaml:"containerName"`
+	MinAllowed          map[string]string `yaml:"minAllowed"`
+	MaxAllowed          map[string]string `yaml:"maxAllowed"`
+	ControlledResources []string          `yaml:"controlledResources"`
+}
+
+type WorkloadPatch struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   Metadata          `yaml:"metadata"`
+	Spec       WorkloadPatchSpec `yaml:"spec"`
+}
+
+type WorkloadPatchSpec struct {
+	Template struct {
+		Spec struct {
+			Containers []ContainerPatch `yaml:"containers"`
+		} `yaml:"spec"`
+	} `yaml:"template"`
+}
+
+type ContainerPatch struct {
+	Name      string                       `yaml:"name"`
+	Resources map[string]map[string]string `yaml:"resources"`
+}
+
+func renderVPA(rec *RightsizingRecommendation, mode string) ([]byte, error) {
+	vpa := VPAManifest{
+		APIVersion: "autoscaling.k8s.io/v1",
+		Kind:       "VerticalPodAutoscaler",
+		Metadata: Metadata{
+			Name:      rec.Workload.Name,
+			Namespace: rec.Workload.Namespace,
+			Labels: map[string]string{
+				"app.kubernetes.io/managed-by": "karpops-wiz",
+			},
+		},
+		Spec: VPASpec{
+			TargetRef: VPATargetRef{
+				APIVersion: "apps/v1",
+				Kind:       rec.Workload.Kind,
+				Name:       rec.Workload.Name,
+			},
+			UpdatePolicy: VPAUpdatePolicy{UpdateMode: mode},
+		},
+	}
+
+	for _, container := range rec.Containers {
+		vpa.Spec.ResourcePolicy.ContainerPolicies = append(vpa.Spec.ResourcePolicy.ContainerPolicies, VPAContainerPolicy{
+			ContainerName:       container.Name,
+			MinAllowed:          quantities(container.LowerBound),
+			MaxAllowed:          quantities(container.UpperBound),
+			ControlledResources: []string{"cpu", "memory"},
+		})
+	}
+
+	return withBasis(rec, vpa)
+}
+
+// renderResourcesPatch produces a strategic-merge patch that only touches the
+// container resources, so it can be listed under `patches:` in a
+// kustomization without clobbering the rest of the workload spec.
+func renderResourcesPatch(rec *RightsizingRecommendation) ([]byte, error) {
+	patch := WorkloadPatch{
+		APIVersion: "apps/v1",
+		Kind:       rec.Workload.Kind,
+		Metadata: Metadata{
+			Name:      rec.Workload.Name,
+			Namespace: rec.Workload.Namespace,
+		},
+	}
+
+	for _, container := range rec.Containers {
+		resources := map[string]map[string]string{
+			"requests": quantities(container.Target),
+		}
+		// A CPU or memory limit below the new request would be rejected by
+		// the API server, so raise it alongside the request.
+		limits := map[string]string{}
+		if container.Limits.CPU > 0 && container.Limits.CPU < container.Target.CPU {
+			limits["cpu"] = resource.NewMilliQuantity(container.Target.CPU, resource.DecimalSI).String()
+		}
+		if container.Limits.Memory > 0 && container.Limits.Memory < container.Target.Memory {
+			limits["memory"] = resource.NewQuantity(container.Target.Memory, resource.BinarySI).String()
+		}
+		if len(limits) > 0 {
+			resources["limits"] = limits
+		}
+		patch.Spec.Template.Spec.Containers = append(patch.Spec.Template.Spec.Containers, ContainerPatch{
+			Name:      container.Name,
+			Resources: resources,
+		})
+	}
+
+	return withBasis(rec, patch)
+}
+
+// withBasis renders v with a header that says what the values come from,
+// so it travels with the manifest into the repository.
+func withBasis(rec *RightsizingRecommendation, v interface{}) ([]byte, error) {
+	body, err := marshalYAML(v)
+	if err != nil {
+		return nil, err
+	}
+	header := fmt.Sprintf("# Rightsized by karpops-wiz from a %s sample: %s.\n", rec.Basis, rec.Warning)
+	return append([]byte(header), body...), nil
+}
+
+func quantities(values ResourceValues) map[string]string {
+	return map[string]string{
+		"cpu":    resource.NewMilliQuantity(values.CPU, resource.DecimalSI).String(),
+		"memory": resource.NewQuantity(values.Memory, resource.BinarySI).String(),
+	}
+}
+
+func marshalYAML(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
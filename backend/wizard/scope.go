@@ -0,0 +1,28 @@
+package wizard
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/authz"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/history"
+	"github.com/gin-gonic/gin"
+)
+
+// visibleGroups returns which groups of a cost breakdown by groupBy the
+// caller may see, or nil when they may see all of them. Namespace groups
+// follow the caller's scope; label values cut across namespaces, so a
+// breakdown by label needs every namespace.
+func visibleGroups(c *gin.Context, groupBy string) (func(name string) bool, error) {
+	scope := authz.ScopeFromContext(c.Request.Context())
+	if scope.All() {
+		return nil, nil
+	}
+	switch {
+	case groupBy == history.DimensionNamespace:
+		return func(name string) bool { return scope.Allows(c.Request.Context(), name) }, nil
+	case strings.HasPrefix(groupBy, history.DimensionLabelPrefix):
+		return nil, fmt.Errorf("grouping by label needs access to every namespace")
+	}
+	return nil, nil
+}
@@ -1,27 +1,59 @@
 package wizard
 
 import (
+    "context"
+    "errors"
     "fmt"
+    "io"
     "net/http"
+    "slices"
 
     "github.com/gin-gonic/gin"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/anomaly"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/audit"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/budget"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/clusters"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/graviton"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/history"
     "github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/recommender"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/simulator"
+    "github.com/edsf-foundation/karp-ops-wiz/backend/snapshot"
 )
 
 type Service struct {
-	k8sClient *k8s.K8sClient
-}
-
-func NewService(k8sClient *k8s.K8sClient) *Service {
-	return &Service{
-		k8sClient: k8sClient,
+	clusters  *clusters.Registry
+	catalog   *pricing.Catalog
+	snapshots *snapshot.Store
+	registry  graviton.PlatformResolver
+	history   *history.Store
+	budgets   *budget.Evaluator
+	anomalies *anomaly.Detector
+}
+
+// NewService wires the API to its clusters. The image registry resolver
+// and the history store are optional.
+func NewService(clusterRegistry *clusters.Registry, catalog *pricing.Catalog, registry graviton.PlatformResolver, historyStore *history.Store) *Service {
+	s := &Service{
+		clusters:  clusterRegistry,
+		catalog:   catalog,
+		snapshots: snapshot.NewStore(snapshot.DefaultStoreSize),
+		registry:  registry,
+		history:   historyStore,
 	}
+	if historyStore != nil {
+		s.anomalies = anomaly.NewDetector(historyStore, clusterRegistry, catalog)
+	}
+	return s
 }
 
 type ConfigRequest struct {
 	Preset      string            `json:"preset" binding:"required"`
 	Region      string            `json:"region" binding:"required"`
 	Zone        string            `json:"zone"`
+	Zones       []string          `json:"zones"`
 	Features    map[string]bool   `json:"features"`
 	Customizations map[string]interface{} `json:"customizations"`
 }
@@ -113,43 +145,85 @@ type MetadataOptions struct {
 	HTTPPutResponseHopLimit int    `yaml:"httpPutResponseHopLimit,omitempty"`
 }
 
+// GeneratedConfig is a NodePool and EC2NodeClass pair with instructions for
+// applying them.
+type GeneratedConfig struct {
+	Provisioner  *ProvisionerConfig  `json:"provisioner"`
+	NodeTemplate *NodeTemplateConfig `json:"nodeTemplate"`
+	Summary      ConfigSummary       `json:"summary"`
+}
+
+type ConfigSummary struct {
+	Preset       string          `json:"preset"`
+	Region       string          `json:"region"`
+	Features     map[string]bool `json:"features"`
+	Instructions []string        `json:"instructions"`
+}
+
 func (s *Service) HandleGenerateConfig(c *gin.Context) {
 	var req ConfigRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+
+	config, err := s.GenerateConfig(req)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	if err := attachGeneratedConfig(c, config); err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 
+	c.JSON(http.StatusOK, config)
+}
+
+// attachGeneratedConfig adds the generated manifests to the audit record,
+// under the file names of the instructions.
+func attachGeneratedConfig(c *gin.Context, config *GeneratedConfig) error {
+	provisioner, err := marshalYAML(config.Provisioner)
+	if err != nil {
+		return err
+	}
+	nodeTemplate, err := marshalYAML(config.NodeTemplate)
+	if err != nil {
+		return err
+	}
+	audit.Attach(c, "provisioner.yaml", provisioner)
+	audit.Attach(c, "node-template.yaml", nodeTemplate)
+	return nil
+}
+
+// GenerateConfig builds the Karpenter configuration for the request.
+func (s *Service) GenerateConfig(req ConfigRequest) (*GeneratedConfig, error) {
 	// Generate provisioner config
 	provisioner, err := s.generateProvisioner(req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 
 	// Generate node template config
 	nodeTemplate, err := s.generateNodeTemplate(req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		return nil, err
 	}
 
-	response := gin.H{
-		"provisioner":  provisioner,
-		"nodeTemplate": nodeTemplate,
-		"summary": gin.H{
-			"preset":       req.Preset,
-			"region":       req.Region,
-			"features":     req.Features,
-			"instructions": []string{
+	return &GeneratedConfig{
+		Provisioner:  provisioner,
+		NodeTemplate: nodeTemplate,
+		Summary: ConfigSummary{
+			Preset:   req.Preset,
+			Region:   req.Region,
+			Features: req.Features,
+			Instructions: []string{
 				"1. Apply the provisioner configuration: kubectl apply -f provisioner.yaml",
 				"2. Apply the node template: kubectl apply -f node-template.yaml",
 				"3. Monitor node provisioning: kubectl get nodes -w",
 			},
 		},
-	}
-
-	c.JSON(http.StatusOK, response)
+	}, nil
 }
 
 func (s *Service) generateProvisioner(req ConfigRequest) (*ProvisionerConfig, error) {
@@ -221,11 +295,6 @@ func (s *Service) getRequirements(req ConfigRequest) []Requirement {
 			Operator: "In",
 			Values:   []string{"amd64", "arm64"},
 		},
-		{
-			Key:      "topology.kubernetes.io/zone",
-			Operator: "In",
-			Values:   []string{req.Zone},
-		},
 		{
 			Key:      "topology.kubernetes.io/region",
 			Operator: "In",
@@ -233,6 +302,18 @@ func (s *Service) getRequirements(req ConfigRequest) []Requirement {
 		},
 	}
 
+	zones := req.Zones
+	if req.Zone != "" && !slices.Contains(zones, req.Zone) {
+		zones = append([]string{req.Zone}, zones...)
+	}
+	if len(zones) > 0 {
+		requirements = append(requirements, Requirement{
+			Key:      "topology.kubernetes.io/zone",
+			Operator: "In",
+			Values:   zones,
+		})
+	}
+
 	// Add capacity type based on preset
 	switch req.Preset {
 	case "cost-optimized":
@@ -337,34 +418,86 @@ func (s *Service) getLaunchTemplateName(req ConfigRequest) string {
 
 func (s *Service) HandleGetClusterCost(c *gin.Context) {
 	ctx := c.Request.Context()
+	source, err := s.source(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
 	
-	nodeInfo, err := s.k8sClient.GetNodes(ctx)
+	costs, err := s.ClusterCost(ctx, source)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
-    // Fetch pods for potential future use (not used in current cost calc)
-    _, _ = s.k8sClient.GetPods(ctx)
-
-	// Calculate current and potential costs
-	costs := s.calculateCosts(nodeInfo)
+	c.JSON(http.StatusOK, costs)
+}
 
-	response := gin.H{
-		"current": costs.current,
-		"potential": costs.potential,
-		"savings": costs.savings,
-		"recommendations": costs.recommendations,
+// ClusterCost prices the cluster's nodes from the catalog, with the
+// recommender's non-overlapping savings as the potential.
+func (s *Service) ClusterCost(ctx context.Context, source k8s.ClusterSource) (*CostAnalysis, error) {
+	state, err := s.ClusterState(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	report := s.Recommend(state, "")
+
+	costs := &CostAnalysis{Recommendations: []string{}, Warnings: report.Warnings}
+	nodes := map[string]CostBreakdown{}
+	for _, node := range state.Nodes.Nodes {
+		t, ok := s.catalog.Lookup(s.nodeRegion(node), node.InstanceType)
+		if !ok {
+			continue
+		}
+		var cost CostBreakdown
+		if node.IsSpot {
+			cost.Spot = t.HourlyPrice(true) * pricing.HoursPerMonth
+		} else {
+			cost.OnDemand = t.HourlyPrice(false) * pricing.HoursPerMonth
+		}
+		nodes[node.Name] = cost
+		costs.Current.OnDemand += cost.OnDemand
+		costs.Current.Spot += cost.Spot
+	}
+	costs.Current.Total = costs.Current.OnDemand + costs.Current.Spot
+
+	// A spot recommendation moves its nodes' on-demand cost to spot, less
+	// the savings. Any other takes its savings from each capacity type in
+	// proportion to what its nodes cost now.
+	costs.Potential = costs.Current
+	for _, rec := range report.Independent() {
+		var affected CostBreakdown
+		for _, name := range rec.Nodes {
+			affected.OnDemand += nodes[name].OnDemand
+			affected.Spot += nodes[name].Spot
+		}
+		affected.Total = affected.OnDemand + affected.Spot
+		switch {
+		case rec.Kind == recommender.KindSpot:
+			costs.Potential.OnDemand -= affected.OnDemand
+			costs.Potential.Spot += affected.OnDemand - rec.MonthlySavings
+		case affected.Total > 0:
+			costs.Potential.OnDemand -= rec.MonthlySavings * affected.OnDemand / affected.Total
+			costs.Potential.Spot -= rec.MonthlySavings * affected.Spot / affected.Total
+		}
+		costs.Recommendations = append(costs.Recommendations, fmt.Sprintf("%s: $%.2f/month", rec.Title, rec.MonthlySavings))
 	}
+	costs.Potential.Total = costs.Potential.OnDemand + costs.Potential.Spot
 
-	c.JSON(http.StatusOK, response)
+	costs.Savings = CostSavings{Amount: report.Summary.MonthlySavings, Percentage: report.Summary.Percentage}
+	return costs, nil
 }
 
 func (s *Service) HandleGetNodes(c *gin.Context) {
 	ctx := c.Request.Context()
-	nodeInfo, err := s.k8sClient.GetNodes(ctx)
+	source, err := s.source(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
+		return
+	}
+	nodeInfo, err := source.GetNodes(ctx)
+	if err != nil {
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, nodeInfo)
@@ -372,105 +505,197 @@ func (s *Service) HandleGetNodes(c *gin.Context) {
 
 func (s *Service) HandleGetPods(c *gin.Context) {
 	ctx := c.Request.Context()
-	podInfo, err := s.k8sClient.GetPods(ctx)
+	source, err := s.source(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	podInfo, err := source.GetPods(ctx)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, podInfo)
 }
 
+// CostAnalysis is the cluster's monthly cost at catalog prices, and what
+// it would be with the recommender's recommendations applied. Nodes
+// without a price are left out, with a warning.
 type CostAnalysis struct {
-	current        map[string]interface{}
-	potential      map[string]interface{}
-	savings        map[string]interface{}
-	recommendations []string
+	Current         CostBreakdown `json:"current"`
+	Potential       CostBreakdown `json:"potential"`
+	Savings         CostSavings   `json:"savings"`
+	Recommendations []string      `json:"recommendations"`
+	Warnings        []string      `json:"warnings"`
 }
 
-func (s *Service) calculateCosts(nodeInfo *k8s.NodeInfo) CostAnalysis {
-	currentCost := float64(nodeInfo.OnDemandNodes) * 100.0 // Simplified pricing
-	
-	spotSavings := float64(nodeInfo.SpotNodes) * 70.0 // ~70% savings on spot
-	potentialCost := currentCost - spotSavings
-	
-	savings := currentCost - potentialCost
-	
-	return CostAnalysis{
-		current: map[string]interface{}{
-			"total": currentCost,
-			"ondemand": currentCost,
-			"spot": 0.0,
-		},
-		potential: map[string]interface{}{
-			"total": potentialCost,
-			"ondemand": currentCost * 0.3,
-			"spot": currentCost * 0.7,
-		},
-		savings: map[string]interface{}{
-			"amount": savings,
-			"percentage": (savings / currentCost) * 100,
-		},
-		recommendations: []string{
-			fmt.Sprintf("Consider migrating %d workloads to Spot instances", nodeInfo.OnDemandNodes),
-			"Enable Karpenter consolidation for better resource utilization",
-			"Review instance sizing based on actual resource requirements",
-		},
+// CostBreakdown is a monthly cost in dollars by capacity type.
+type CostBreakdown struct {
+	Total    float64 `json:"total"`
+	OnDemand float64 `json:"ondemand"`
+	Spot     float64 `json:"spot"`
+}
+
+type CostSavings struct {
+	Amount     float64 `json:"amount"`
+	Percentage float64 `json:"percentage"`
+}
+
+// ClusterState is what the simulators and the recommender read from a
+// cluster.
+type ClusterState struct {
+	Nodes     *k8s.NodeInfo
+	Pods      *k8s.PodInfo
+	PDBs      []k8s.PDBDetails
+	NodePools []k8s.NodePoolDetails
+}
+
+func (s *Service) ClusterState(ctx context.Context, source k8s.ClusterSource) (*ClusterState, error) {
+	nodeInfo, err := source.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	podInfo, err := source.GetPods(ctx)
+	if err != nil {
+		return nil, err
 	}
+
+	pdbs, err := source.GetPDBs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodePools, err := source.GetNodePools(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClusterState{
+		Nodes:     nodeInfo,
+		Pods:      podInfo,
+		PDBs:      pdbs,
+		NodePools: nodePools,
+	}, nil
 }
 
 func (s *Service) HandleGetRebalancingRecommendations(c *gin.Context) {
 	ctx := c.Request.Context()
-	nodeInfo, err := s.k8sClient.GetNodes(ctx)
+	source, err := s.source(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
 
-    podInfo, err := s.k8sClient.GetPods(ctx)
-    if err != nil {
-        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-        return
-    }
+	state, err := s.ClusterState(ctx, source)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, s.Recommend(state, c.Query("region")))
+}
 
-	recommendations := s.generateRebalancingRecommendations(nodeInfo, podInfo)
-	c.JSON(http.StatusOK, recommendations)
+// Recommend runs the recommender against the cluster. An empty region uses
+// the catalog's default.
+func (s *Service) Recommend(state *ClusterState, region string) *recommender.Report {
+	return recommender.Recommend(recommender.Input{
+		Nodes:     state.Nodes.Nodes,
+		Pods:      state.Pods.Pods,
+		PDBs:      state.PDBs,
+		NodePools: state.NodePools,
+		Region:    region,
+	}, s.catalog)
+}
+
+// SimulationRequest is the optional POST body of the rebalancing simulation.
+// When no instance types or families are given, the preset's instance types
+// are used (balanced by default).
+type SimulationRequest struct {
+	Preset string `json:"preset"`
+	simulator.CandidateConfig
 }
 
 func (s *Service) HandleSimulateRebalancing(c *gin.Context) {
-	// Simulate rebalancing without actually moving pods
-	response := gin.H{
-		"savings": gin.H{
-			"amount": "$245.60",
-			"percentage": 23.4,
-		},
-		"actions": []string{
-			"Migrate 8 pods from t3.large to c6g.large instances",
-			"Consolidate 3 underutilized m5.xlarge nodes",
-			"Enable Spot mixing for non-critical workloads",
-		},
-		"estimatedTime": "2-3 hours",
+	var req SimulationRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
 	}
-	
-	c.JSON(http.StatusOK, response)
+
+	ctx := c.Request.Context()
+	source, err := s.source(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	state, err := s.ClusterState(ctx, source)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	result, err := s.SimulateRebalancing(state, req)
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
 }
 
-func (s *Service) generateRebalancingRecommendations(nodeInfo *k8s.NodeInfo, podInfo *k8s.PodInfo) interface{} {
-	return gin.H{
-		"instanceTypeOptimization": []string{
-			"Consider migrating from m5.large to c6g.large for better price/performance",
-			"Switch compute-intensive workloads to Graviton instances",
-		},
-		"spotInstanceStrategy": []string{
-			"Move batch jobs and non-critical services to Spot instances",
-			"Implement Spot instance diversification across multiple families",
-		},
-		"consolidation": []string{
-			fmt.Sprintf("Consider consolidating %d pods across fewer nodes", podInfo.TotalPods),
-			"Enable Karpenter consolidation to automatically resize nodes",
-		},
-		"estimatedSavings": gin.H{
-			"monthly": "$892.30",
-			"percentage": 34.2,
-		},
+// SimulateRebalancing repacks the cluster onto the requested candidate
+// instance types.
+func (s *Service) SimulateRebalancing(state *ClusterState, req SimulationRequest) (*simulator.Result, error) {
+	if len(req.InstanceTypes) == 0 && len(req.InstanceFamilies) == 0 {
+		req.InstanceTypes = s.getInstanceTypes(req.Preset)
+	}
+	return simulator.Simulate(simulator.Input{
+		Nodes:     state.Nodes.Nodes,
+		Pods:      state.Pods.Pods,
+		PDBs:      state.PDBs,
+		Candidate: req.CandidateConfig,
+	}, s.catalog)
+}
+
+// HandleSimulateConsolidation replays Karpenter's consolidation logic
+// against the live cluster. The optional POST body tunes the emulation.
+func (s *Service) HandleSimulateConsolidation(c *gin.Context) {
+	var opts simulator.ConsolidationOptions
+	if err := c.ShouldBindJSON(&opts); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
 	}
+
+	ctx := c.Request.Context()
+	source, err := s.source(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	state, err := s.ClusterState(ctx, source)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	result, err := s.SimulateConsolidation(state, opts)
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (s *Service) SimulateConsolidation(state *ClusterState, opts simulator.ConsolidationOptions) (*simulator.ConsolidationResult, error) {
+	return simulator.Consolidate(simulator.ConsolidationInput{
+		Nodes:     state.Nodes.Nodes,
+		Pods:      state.Pods.Pods,
+		PDBs:      state.PDBs,
+		NodePools: state.NodePools,
+		Options:   opts,
+	}, s.catalog)
 }
@@ -0,0 +1,61 @@
+package wizard
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/clusters"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/pricing"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/snapshot"
+)
+
+func newTestService(t *testing.T) (*Service, *snapshot.Snapshot) {
+	t.Helper()
+	catalog, err := pricing.Load("../pricing/testdata/catalog.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	snap, err := snapshot.Load("../snapshot/testdata/cluster.json.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := clusters.New(clusters.Cluster{Name: "prod", Origin: clusters.OriginSnapshot}, snap)
+	return NewService(registry, catalog, nil, nil), snap
+}
+
+// The cost command and the fleet view price the same cluster the same way.
+func TestClusterCostMatchesFleet(t *testing.T) {
+	s, snap := newTestService(t)
+	ctx := context.Background()
+
+	costs, err := s.ClusterCost(ctx, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fleet := s.FleetCost(ctx)
+	if len(fleet.Clusters) != 1 || fleet.Clusters[0].Error != "" {
+		t.Fatalf("fleet = %+v", fleet.Clusters)
+	}
+	summary := fleet.Clusters[0]
+
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-6 }
+	if costs.Current.Total <= 0 || !near(costs.Current.Total, summary.MonthlyCost) {
+		t.Errorf("current = %.2f, fleet = %.2f", costs.Current.Total, summary.MonthlyCost)
+	}
+	if costs.Savings.Amount <= 0 || !near(costs.Savings.Amount, summary.PotentialSavings) {
+		t.Errorf("savings = %.2f, fleet = %.2f", costs.Savings.Amount, summary.PotentialSavings)
+	}
+	if !near(costs.Current.OnDemand+costs.Current.Spot, costs.Current.Total) {
+		t.Errorf("current %+v doesn't add up", costs.Current)
+	}
+	if !near(costs.Potential.Total, costs.Current.Total-costs.Savings.Amount) || !near(costs.Potential.OnDemand+costs.Potential.Spot, costs.Potential.Total) {
+		t.Errorf("potential %+v, want %.2f less savings of %.2f", costs.Potential, costs.Current.Total, costs.Savings.Amount)
+	}
+	if costs.Potential.OnDemand < -1e-6 || costs.Potential.Spot < -1e-6 {
+		t.Errorf("potential %+v has a negative part", costs.Potential)
+	}
+	if len(costs.Recommendations) == 0 {
+		t.Error("no recommendations")
+	}
+}
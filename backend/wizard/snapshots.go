@@ -0,0 +1,106 @@
+package wizard
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/authz"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/k8s"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/snapshot"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSnapshotSize caps uploads; compressed snapshots of clusters with tens
+// of thousands of pods stay well below it.
+const maxSnapshotSize = 128 << 20
+
+// source returns the cluster the request should be analyzed against: the
+// uploaded snapshot named by ?snapshot=<id>, or the live cluster named by
+// ?cluster=<name>, the default one when omitted. Callers limited to some
+// namespaces only see the pods of those.
+func (s *Service) source(c *gin.Context) (k8s.ClusterSource, error) {
+	scope := authz.ScopeFromContext(c.Request.Context())
+	id := c.Query("snapshot")
+	if id == "" {
+		source, err := s.clusters.Get(c.Query("cluster"))
+		if err != nil {
+			return nil, apierror.NotFound(err)
+		}
+		return scope.Source(source), nil
+	}
+	snap, ok := s.snapshots.Get(id)
+	if !ok {
+		return nil, apierror.NotFoundf("snapshot %q not found", id)
+	}
+	return scope.Source(snap), nil
+}
+
+// HandleCaptureSnapshot exports a live cluster (?cluster=<name>) as a
+// downloadable snapshot.
+func (s *Service) HandleCaptureSnapshot(c *gin.Context) {
+	source, err := s.clusters.Get(c.Query("cluster"))
+	if err != nil {
+		apierror.Abort(c, apierror.NotFound(err))
+		return
+	}
+
+	snap, err := snapshot.Capture(c.Request.Context(), source)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := snap.Write(&buf); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	filename := fmt.Sprintf("karpops-snapshot-%s.json.gz", snap.CapturedAt.Format("20060102-150405"))
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/gzip", buf.Bytes())
+}
+
+// HandleUploadSnapshot accepts a snapshot either as a multipart "file" field
+// or as the raw request body, and returns the ID to pass as ?snapshot=.
+func (s *Service) HandleUploadSnapshot(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSnapshotSize)
+
+	var body io.Reader = c.Request.Body
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		file, err := c.FormFile("file")
+		if err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+		f, err := file.Open()
+		if err != nil {
+			apierror.Abort(c, apierror.Invalid(err))
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+
+	snap, err := snapshot.Read(body)
+	if err != nil {
+		apierror.Abort(c, apierror.Invalid(err))
+		return
+	}
+
+	id, err := s.snapshots.Put(snap)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, snapshot.Summarize(id, snap))
+}
+
+func (s *Service) HandleListSnapshots(c *gin.Context) {
+	c.JSON(http.StatusOK, s.snapshots.List())
+}
@@ -0,0 +1,46 @@
+package wizard
+
+import (
+	"net/http"
+
+	"github.com/edsf-foundation/karp-ops-wiz/backend/apierror"
+	"github.com/edsf-foundation/karp-ops-wiz/backend/spot"
+	"github.com/gin-gonic/gin"
+)
+
+// HandleGetSpotEligibility scores every workload's tolerance for spot
+// interruptions, with the reasons behind each score.
+func (s *Service) HandleGetSpotEligibility(c *gin.Context) {
+	ctx := c.Request.Context()
+	source, err := s.source(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	nodeInfo, err := source.GetNodes(ctx)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	podInfo, err := source.GetPods(ctx)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	pdbs, err := source.GetPDBs(ctx)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	report := spot.Score(spot.Input{
+		Nodes:  nodeInfo.Nodes,
+		Pods:   podInfo.Pods,
+		PDBs:   pdbs,
+		Region: c.Query("region"),
+	}, s.catalog)
+	c.JSON(http.StatusOK, report)
+}
@@ -60,3 +60,18 @@ Create the name of the service account to use
 {{- default "default" .Values.serviceAccount.name }}
 {{- end }}
 {{- end }}
+
+{{/*
+How often the server reads the pricing catalog again. The deprecated
+cache.pricingData still wins when it differs from the default.
+*/}}
+{{- define "karpops-wiz.pricingRefreshInterval" -}}
+{{- $legacy := .Values.cache.pricingData | default dict }}
+{{- if and (hasKey $legacy "enabled") (not $legacy.enabled) }}
+{{- "0s" }}
+{{- else if and $legacy.ttl (ne $legacy.ttl "24h") }}
+{{- $legacy.ttl }}
+{{- else }}
+{{- .Values.config.pricingRefreshInterval }}
+{{- end }}
+{{- end }}
@@ -1,27 +1,72 @@
 import { useState, useEffect } from 'react'
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from '@/components/ui/card'
 import { Button } from '@/components/ui/button'
-// Removed unused Badge import
+import { Badge } from '@/components/ui/badge'
 import { Alert, AlertDescription } from '@/components/ui/alert'
 import { RefreshCw, ArrowRight, AlertTriangle, CheckCircle } from 'lucide-react'
 
+type RecommendationKind = 'family-migration' | 'graviton' | 'spot' | 'consolidation'
+
+interface Recommendation {
+  id: string
+  kind: RecommendationKind
+  title: string
+  action: string
+  nodes: string[]
+  workloads: string[]
+  monthlySavings: number
+  risk: 'low' | 'medium' | 'high'
+  confidence: number
+  notes?: string[]
+}
+
 interface RebalancingRecommendations {
-  instanceTypeOptimization: string[]
-  spotInstanceStrategy: string[]
-  consolidation: string[]
-  estimatedSavings: {
-    monthly: string
+  recommendations: Recommendation[]
+  summary: {
+    currentMonthlyCost: number
+    monthlySavings: number
     percentage: number
   }
+  warnings: string[] | null
 }
 
+const kindTitles: Record<RecommendationKind, string> = {
+  'family-migration': 'Instance Type Optimization',
+  graviton: 'Graviton Migration',
+  spot: 'Spot Instance Strategy',
+  consolidation: 'Consolidation',
+}
+
+const riskVariants = {
+  low: 'secondary',
+  medium: 'outline',
+  high: 'destructive',
+} as const
+
 interface SimulationResult {
+  strategy: string
+  current: CostSummary
+  projected: CostSummary
   savings: {
-    amount: string
+    monthly: number
     percentage: number
   }
-  actions: string[]
-  estimatedTime: string
+  moves: PodMove[] | null
+  unschedulable: { namespace: string; name: string; reason?: string }[] | null
+  warnings: string[] | null
+}
+
+interface CostSummary {
+  nodes: number
+  hourlyCost: number
+  monthlyCost: number
+}
+
+interface PodMove {
+  namespace: string
+  name: string
+  from: string
+  to: string
 }
 
 export function RebalancerView() {
@@ -51,7 +96,9 @@ export function RebalancerView() {
     setSimulating(true)
     try {
       const response = await fetch('/api/v1/simulate/rebalancing', {
-        method: 'POST'
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ preset: 'cost-optimized', capacityTypes: ['spot', 'on-demand'] })
       })
       const data = await response.json()
       setSimulationResult(data)
@@ -91,11 +138,11 @@ export function RebalancerView() {
         <CardContent>
           <div className="grid gap-4 md:grid-cols-2">
             <div className="text-center p-4 border rounded-lg">
-              <div className="text-2xl font-bold text-green-600">{recommendations.estimatedSavings.monthly}</div>
+              <div className="text-2xl font-bold text-green-600">${recommendations.summary.monthlySavings.toFixed(2)}</div>
               <div className="text-sm text-muted-foreground">Estimated Monthly Savings</div>
             </div>
             <div className="text-center p-4 border rounded-lg">
-              <div className="text-2xl font-bold">{recommendations.estimatedSavings.percentage}%</div>
+              <div className="text-2xl font-bold">{recommendations.summary.percentage.toFixed(1)}%</div>
               <div className="text-sm text-muted-foreground">Cost Reduction</div>
             </div>
           </div>
@@ -103,55 +150,62 @@ export function RebalancerView() {
       </Card>
 
       {/* Recommendations */}
-      <div className="grid gap-6 md:grid-cols-3">
-        <Card>
-          <CardHeader>
-            <CardTitle className="text-lg">Instance Type Optimization</CardTitle>
-          </CardHeader>
-          <CardContent className="space-y-3">
-            {recommendations.instanceTypeOptimization.map((recommendation, idx) => (
-              <Alert key={idx}>
-                <ArrowRight className="w-4 h-4" />
-                <AlertDescription className="text-sm">
-                  {recommendation}
-                </AlertDescription>
-              </Alert>
-            ))}
-          </CardContent>
-        </Card>
-
-        <Card>
-          <CardHeader>
-            <CardTitle className="text-lg">Spot Instance Strategy</CardTitle>
-          </CardHeader>
-          <CardContent className="space-y-3">
-            {recommendations.spotInstanceStrategy.map((recommendation, idx) => (
-              <Alert key={idx}>
-                <CheckCircle className="w-4 h-4" />
-                <AlertDescription className="text-sm">
-                  {recommendation}
-                </AlertDescription>
-              </Alert>
-            ))}
-          </CardContent>
-        </Card>
-
-        <Card>
-          <CardHeader>
-            <CardTitle className="text-lg">Consolidation</CardTitle>
-          </CardHeader>
-          <CardContent className="space-y-3">
-            {recommendations.consolidation.map((recommendation, idx) => (
-              <Alert key={idx}>
-                <AlertTriangle className="w-4 h-4" />
-                <AlertDescription className="text-sm">
-                  {recommendation}
-                </AlertDescription>
-              </Alert>
+      {recommendations.recommendations.length === 0 ? (
+        <Alert>
+          <CheckCircle className="w-4 h-4" />
+          <AlertDescription className="text-sm">
+            No rebalancing opportunities found for the current cluster.
+          </AlertDescription>
+        </Alert>
+      ) : (
+        <div className="grid gap-6 md:grid-cols-2">
+          {(Object.keys(kindTitles) as RecommendationKind[])
+            .filter((kind) => recommendations.recommendations.some((r) => r.kind === kind))
+            .map((kind) => (
+              <Card key={kind}>
+                <CardHeader>
+                  <CardTitle className="text-lg">{kindTitles[kind]}</CardTitle>
+                </CardHeader>
+                <CardContent className="space-y-3">
+                  {recommendations.recommendations
+                    .filter((r) => r.kind === kind)
+                    .map((recommendation) => (
+                      <Alert key={recommendation.id}>
+                        <ArrowRight className="w-4 h-4" />
+                        <AlertDescription className="text-sm space-y-1">
+                          <div className="flex items-center justify-between">
+                            <span className="font-medium">{recommendation.title}</span>
+                            <span className="text-green-600 font-semibold">
+                              ${recommendation.monthlySavings.toFixed(2)}/mo
+                            </span>
+                          </div>
+                          <div>{recommendation.action}</div>
+                          <div className="flex items-center space-x-2">
+                            <Badge variant={riskVariants[recommendation.risk]}>{recommendation.risk} risk</Badge>
+                            <span className="text-muted-foreground">
+                              {Math.round(recommendation.confidence * 100)}% confidence · {recommendation.nodes.length} nodes · {recommendation.workloads.length} workloads
+                            </span>
+                          </div>
+                          {(recommendation.notes ?? []).map((note, idx) => (
+                            <div key={idx} className="text-muted-foreground">{note}</div>
+                          ))}
+                        </AlertDescription>
+                      </Alert>
+                    ))}
+                </CardContent>
+              </Card>
             ))}
-          </CardContent>
-        </Card>
-      </div>
+        </div>
+      )}
+
+      {(recommendations.warnings ?? []).length > 0 && (
+        <Alert>
+          <AlertTriangle className="w-4 h-4" />
+          <AlertDescription className="text-sm">
+            {recommendations.warnings!.join('; ')}
+          </AlertDescription>
+        </Alert>
+      )}
 
       {/* Simulation */}
       <Card>
@@ -176,31 +230,44 @@ export function RebalancerView() {
 
               <div className="grid gap-4 md:grid-cols-3">
                 <div className="text-center p-4 border rounded-lg">
-                  <div className="text-xl font-bold text-green-600">{simulationResult.savings.amount}</div>
-                  <div className="text-sm text-muted-foreground">Estimated Savings</div>
+                  <div className="text-xl font-bold text-green-600">${simulationResult.savings.monthly.toFixed(2)}</div>
+                  <div className="text-sm text-muted-foreground">Estimated Monthly Savings</div>
                 </div>
                 <div className="text-center p-4 border rounded-lg">
-                  <div className="text-xl font-bold">{simulationResult.savings.percentage}%</div>
+                  <div className="text-xl font-bold">{simulationResult.savings.percentage.toFixed(1)}%</div>
                   <div className="text-sm text-muted-foreground">Cost Reduction</div>
                 </div>
                 <div className="text-center p-4 border rounded-lg">
-                  <div className="text-xl font-bold">{simulationResult.estimatedTime}</div>
-                  <div className="text-sm text-muted-foreground">Estimated Time</div>
+                  <div className="text-xl font-bold">
+                    {simulationResult.current.nodes} → {simulationResult.projected.nodes}
+                  </div>
+                  <div className="text-sm text-muted-foreground">Nodes</div>
                 </div>
               </div>
 
               <div>
-                <h4 className="font-medium mb-2">Recommended Actions:</h4>
+                <h4 className="font-medium mb-2">Pods to Move ({simulationResult.moves?.length ?? 0}):</h4>
                 <ul className="space-y-2">
-                  {simulationResult.actions.map((action, idx) => (
+                  {(simulationResult.moves ?? []).map((move, idx) => (
                     <li key={idx} className="flex items-start space-x-2">
                       <CheckCircle className="w-4 h-4 text-green-600 mt-0.5 flex-shrink-0" />
-                      <span className="text-sm">{action}</span>
+                      <span className="text-sm">
+                        {move.namespace}/{move.name}: {move.from || 'pending'} → {move.to}
+                      </span>
                     </li>
                   ))}
                 </ul>
               </div>
 
+              {(simulationResult.unschedulable ?? []).length > 0 && (
+                <Alert>
+                  <AlertTriangle className="w-4 h-4" />
+                  <AlertDescription className="text-sm">
+                    {simulationResult.unschedulable!.length} pods could not be placed on the candidate instance types.
+                  </AlertDescription>
+                </Alert>
+              )}
+
               <div className="bg-yellow-50 border border-yellow-200 rounded-lg p-4">
                 <div className="flex items-start space-x-2">
                   <AlertTriangle className="w-5 h-5 text-yellow-600 mt-0.5" />
@@ -1,4 +1,4 @@
-import React from 'react'
+import { useState, useEffect } from 'react'
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from '@/components/ui/card'
 import { Button } from '@/components/ui/button'
 import { Switch } from '@/components/ui/switch'
@@ -6,7 +6,42 @@ import { Label } from '@/components/ui/label'
 import { Separator } from '@/components/ui/separator'
 import { Settings as SettingsIcon, Database, Shield } from 'lucide-react'
 
+interface Feature {
+  name: string
+  enabled: boolean
+  description: string
+  disabledClusters?: string[]
+}
+
+const featureLabels: Record<string, string> = {
+  costDashboard: 'Cost Dashboard',
+  rebalancing: 'Rebalancing Recommendations',
+  pricingSimulation: 'Pricing Simulation',
+  karpenterIntegration: 'Karpenter Config Wizard',
+  apply: 'Apply to Clusters',
+}
+
 export function Settings() {
+  const [features, setFeatures] = useState<Feature[]>([])
+  const [error, setError] = useState<string | null>(null)
+
+  useEffect(() => {
+    fetchFeatures()
+  }, [])
+
+  const fetchFeatures = async () => {
+    try {
+      const response = await fetch('/api/v1/features')
+      if (!response.ok) {
+        throw new Error(`HTTP ${response.status}`)
+      }
+      setFeatures(await response.json())
+    } catch (err) {
+      console.error('Failed to fetch features:', err)
+      setError('Could not load the features of the server')
+    }
+  }
+
   return (
     <div className="space-y-6">
       <Card>
@@ -16,69 +51,40 @@ export function Settings() {
             Application Settings
           </CardTitle>
           <CardDescription>
-            Configure KarpOps-Wiz behavior and features
+            Features this KarpOps-Wiz server serves
           </CardDescription>
         </CardHeader>
         <CardContent className="space-y-6">
           <div className="space-y-4">
-            <div className="flex items-center justify-between">
-              <div className="space-y-1">
-                <Label htmlFor="cost-dashboard" className="text-sm font-medium">
-                  Cost Dashboard
-                </Label>
-                <p className="text-sm text-muted-foreground">
-                  Enable real-time cost monitoring and analysis
-                </p>
-              </div>
-              <Switch id="cost-dashboard" defaultChecked />
-            </div>
-
-            <Separator />
-
-            <div className="flex items-center justify-between">
-              <div className="space-y-1">
-                <Label htmlFor="rebalancing" className="text-sm font-medium">
-                  Rebalancing Recommendations
-                </Label>
-                <p className="text-sm text-muted-foreground">
-                  Show automated rebalancing suggestions
-                </p>
-              </div>
-              <Switch id="rebalancing" defaultChecked />
-            </div>
-
-            <Separator />
-
-            <div className="flex items-center justify-between">
-              <div className="space-y-1">
-                <Label htmlFor="pricing-simulation" className="text-sm font-medium">
-                  Pricing Simulation
-                </Label>
-                <p className="text-sm text-muted-foreground">
-                  Enable cost estimation and simulation features
-                </p>
-              </div>
-              <Switch id="pricing-simulation" defaultChecked />
-            </div>
-
-            <Separator />
-
-            <div className="flex items-center justify-between">
-              <div className="space-y-1">
-                <Label htmlFor="notifications" className="text-sm font-medium">
-                  Cost Alerts
-                </Label>
-                <p className="text-sm text-muted-foreground">
-                  Receive notifications for significant cost changes
-                </p>
+            {features.map((feature, index) => (
+              <div key={feature.name} className="space-y-4">
+                {index > 0 && <Separator />}
+                <div className="flex items-center justify-between">
+                  <div className="space-y-1">
+                    <Label htmlFor={feature.name} className="text-sm font-medium">
+                      {featureLabels[feature.name] ?? feature.name}
+                    </Label>
+                    <p className="text-sm text-muted-foreground">
+                      {feature.description}
+                    </p>
+                    {feature.enabled && feature.disabledClusters && feature.disabledClusters.length > 0 && (
+                      <p className="text-sm text-muted-foreground">
+                        Off for {feature.disabledClusters.join(', ')}
+                      </p>
+                    )}
+                  </div>
+                  <Switch id={feature.name} checked={feature.enabled} disabled />
+                </div>
               </div>
-              <Switch id="notifications" />
-            </div>
+            ))}
+            {error && (
+              <p className="text-sm text-destructive">{error}</p>
+            )}
           </div>
 
-          <Button className="w-full">
-            Save Settings
-          </Button>
+          <p className="text-sm text-muted-foreground">
+            Features are set by the server config, the <code>features</code> block of the Helm values, and take effect when it is reloaded.
+          </p>
         </CardContent>
       </Card>
 